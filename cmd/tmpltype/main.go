@@ -1,100 +1,308 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/bellwood4486/tmpltype/internal/config"
+	"github.com/bellwood4486/tmpltype/internal/funcmap"
 	"github.com/bellwood4486/tmpltype/internal/gen"
+	"github.com/bellwood4486/tmpltype/internal/typing"
+	"github.com/bellwood4486/tmpltype/internal/util"
 )
 
+const usage = `usage: tmpltype -dir <directory> -pkg <name> -out <file> [-mode text|html] [-funcs <FuncMap>] [-tags <keys>] [-tagcase snake|camel] [-required] [-jsonschema <directory>] [-ts <file>] [-check]
+       tmpltype [-config <file>] [-check]
+       tmpltype schema [-dir <directory> [-pkg <name>] [-out <file>] [-mode text|html] [-funcs <FuncMap>] [-tags <keys>] [-tagcase snake|camel] [-required] | -config <file>]
+       tmpltype render [-dir <directory> [-pkg <name>] [-out <file>] [-mode text|html] [-funcs <FuncMap>] [-tags <keys>] [-tagcase snake|camel] [-required] | -config <file> [-target <name>]] [-data <file>] <template>`
+
 func main() {
-	dir := flag.String("dir", "", "template directory (required)")
-	pkg := flag.String("pkg", "", "output package name (required)")
-	out := flag.String("out", "", "output .go file path (required)")
-	flag.Parse()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "schema":
+			os.Exit(runSchema(os.Args[2:]))
+		case "render":
+			os.Exit(runRender(os.Args[2:]))
+		}
+	}
 
-	if *dir == "" || *pkg == "" || *out == "" {
-		fmt.Fprintln(os.Stderr, "usage: tmpltype -dir <directory> -pkg <name> -out <file>")
+	fs := flag.NewFlagSet("tmpltype", flag.ExitOnError)
+	targetFlags := defineTargetFlags(fs, true)
+	jsonSchema := fs.String("jsonschema", "", "directory to write a JSON Schema of each template's parameters to (optional)")
+	tsFile := fs.String("ts", "", "TypeScript declaration file (.d.ts) to write the template parameter types to (optional)")
+	check := fs.Bool("check", false, "do not write -out; exit with status 1 and print a diff if it is not up to date")
+	_ = fs.Parse(os.Args[1:])
+
+	targets, err := targetFlags.load()
+	if err == nil && (*jsonSchema != "" || *tsFile != "") {
+		if *targetFlags.dir == "" {
+			err = fmt.Errorf("-jsonschema and -ts cannot be used with a config file; set jsonschema and ts in the target instead\n%s", usage)
+		} else {
+			targets[0].JSONSchema = *jsonSchema
+			targets[0].TS = *tsFile
+		}
+		if err == nil && *tsFile != "" && !strings.HasSuffix(*tsFile, ".d.ts") {
+			err = fmt.Errorf("-ts %q must be a .d.ts file", *tsFile)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(2)
 	}
 
+	// 失敗したターゲットがあっても残りのターゲットは処理する
+	status := 0
+	for _, t := range targets {
+		files, err := generate(t)
+		if err != nil {
+			// 位置付きのエラーは1行ずつ "file:line:col: msg" の形式で出力される
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		for _, f := range files {
+			if *check {
+				status = max(status, checkUpToDate(f.path, f.content))
+				continue
+			}
+			if err := writeFile(f.path, f.content); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 1
+			}
+		}
+	}
+	os.Exit(status)
+}
+
+// outputFile は生成するファイルのパスと内容
+type outputFile struct {
+	path    string
+	content []byte
+}
+
+// targetFlags は生成対象を指定するフラグ（生成と schema サブコマンドで共通）
+type targetFlags struct {
+	dir, pkg, out, mode, funcs, config *string
+	tags, tagCase                      *string
+	required                           *bool
+	needOut                            bool // -pkg と -out が必須
+}
+
+// defineTargetFlags は fs に生成対象を指定するフラグを定義する
+// needOut が false なら -dir だけで指定でき、-pkg と -out は省略できる
+func defineTargetFlags(fs *flag.FlagSet, needOut bool) *targetFlags {
+	outUsage := "required without -config"
+	if !needOut {
+		outUsage = "optional; used to resolve -funcs and @type"
+	}
+	return &targetFlags{
+		dir:     fs.String("dir", "", "template directory (required without -config)"),
+		pkg:     fs.String("pkg", "", "output package name ("+outUsage+")"),
+		out:     fs.String("out", "", "output .go file path ("+outUsage+")"),
+		mode:    fs.String("mode", "", "template package: text or html (default: html if all templates are *.html.tmpl, otherwise text)"),
+		funcs:   fs.String("funcs", "", "template.FuncMap variable installed into the templates: Name (output package) or import/path.Name"),
+		tags:    fs.String("tags", "", "comma-separated struct tag keys generated from the field names, e.g. json,yaml"),
+		tagCase: fs.String("tagcase", "", "field name format of the -tags values: snake or camel (default: snake)"),
+		required: fs.Bool("required", false, "make every field except pointers, bools and nested structs required "+
+			"(@optional opts out); generates Validate methods that Render calls before executing"),
+		config:  fs.String("config", "", "config file declaring the targets (default: tmpltype.yaml, tmpltype.yml or tmpltype.json if present)"),
+		needOut: needOut,
+	}
+}
+
+// load は生成対象を決める
+// -dir/-pkg/-out が指定されていればフラグの1ターゲット、なければ設定ファイルのターゲット
+func (f *targetFlags) load() ([]config.Target, error) {
+	flags := config.Target{Dir: *f.dir, Pkg: *f.pkg, Out: *f.out, Mode: *f.mode, Funcs: *f.funcs, TagCase: *f.tagCase, Required: *f.required}
+	if *f.tags != "" {
+		flags.Tags = strings.Split(*f.tags, ",")
+	}
+	useFlags := flags.Dir != "" || flags.Pkg != "" || flags.Out != ""
+	if useFlags && *f.config != "" {
+		return nil, fmt.Errorf("-config cannot be used together with -dir, -pkg and -out\n%s", usage)
+	}
+	if !useFlags && (*f.tags != "" || *f.tagCase != "" || *f.required) {
+		return nil, fmt.Errorf("-tags, -tagcase and -required cannot be used with a config file; set tags, tagcase and required in the target instead\n%s", usage)
+	}
+
+	if useFlags {
+		if flags.Dir == "" || (f.needOut && (flags.Pkg == "" || flags.Out == "")) {
+			return nil, errors.New(usage)
+		}
+		switch flags.Mode {
+		case "", "text", "html":
+		default:
+			return nil, fmt.Errorf("invalid -mode %q (want text or html)", flags.Mode)
+		}
+		if err := config.ValidTagKeys(flags.Tags); err != nil {
+			return nil, fmt.Errorf("invalid -tags: %v", err)
+		}
+		switch flags.TagCase {
+		case "", "snake", "camel":
+		default:
+			return nil, fmt.Errorf("invalid -tagcase %q (want snake or camel)", flags.TagCase)
+		}
+		return []config.Target{flags}, nil
+	}
+
+	configFile := *f.config
+	if configFile == "" {
+		for _, name := range config.DefaultFiles {
+			if _, err := os.Stat(name); err == nil {
+				configFile = name
+				break
+			}
+		}
+		if configFile == "" {
+			return nil, errors.New(usage)
+		}
+	}
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Targets, nil
+}
+
+// generate はターゲットのテンプレートをスキャンし、生成するファイルを返す
+// 先頭は -out のコードで、指定されていれば TypeScript の型定義、テンプレートごとの JSON Schema が続く
+func generate(t config.Target) ([]outputFile, error) {
+	units, opts, err := loadUnits(t)
+	if err != nil {
+		return nil, err
+	}
+
+	// コード生成（basedirを渡す）
+	code, err := gen.EmitWithOptions(units, t.Dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	files := []outputFile{{path: t.Out, content: []byte(code)}}
+	opts.Warn = nil // 警告は Go コードの生成で出力済み
+
+	if t.TS != "" {
+		ts, err := gen.EmitTypeScript(units, t.Dir, opts)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, outputFile{path: t.TS, content: []byte(ts)})
+	}
+	if t.JSONSchema == "" {
+		return files, nil
+	}
+
+	// テンプレート名 "mail/invite" は <jsonschema>/mail/invite.schema.json に出力する
+	schemas, err := gen.EmitJSONSchemas(units, t.Dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range slices.Sorted(maps.Keys(schemas)) {
+		path := filepath.Join(t.JSONSchema, filepath.FromSlash(name)+".schema.json")
+		files = append(files, outputFile{path: path, content: schemas[name]})
+	}
+	return files, nil
+}
+
+// writeFile は必要ならディレクトリを作ってファイルを書き込む
+func writeFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+// loadUnits はターゲットのテンプレートファイルを読み込み、生成の設定とともに返す
+// -out を省略した場合、出力パッケージはカレントディレクトリとみなす
+func loadUnits(t config.Target) ([]gen.Unit, gen.Options, error) {
+	outDir := filepath.Dir(t.Out)
+	opts := gen.Options{Dir: outDir}
+	switch t.Mode {
+	case "":
+		opts.Mode = gen.ModeAuto
+	case "text":
+		opts.Mode = gen.ModeText
+	case "html":
+		opts.Mode = gen.ModeHTML
+	}
+	opts.Tags.Keys = t.Tags
+	if t.TagCase == "camel" {
+		opts.Tags.Case = gen.TagCaseCamel
+	}
+	opts.RequireAll = t.Required
+	opts.Warn = func(w typing.Warning) {
+		fmt.Fprintln(os.Stderr, w)
+	}
+
+	if t.Funcs != "" {
+		fm, err := funcmap.Load(t.Funcs, outDir)
+		if err != nil {
+			return nil, opts, fmt.Errorf("%s: failed to load FuncMap: %w", t.Label(), err)
+		}
+		opts.FuncMap = fm
+	}
+
 	// ディレクトリの存在確認
-	if _, err := os.Stat(*dir); os.IsNotExist(err) {
-		fmt.Fprintf(os.Stderr, "Error: directory not found: %s\n", *dir)
-		os.Exit(1)
+	if _, err := os.Stat(t.Dir); os.IsNotExist(err) {
+		return nil, opts, fmt.Errorf("%s: directory not found: %s", t.Label(), t.Dir)
 	}
 
 	// テンプレートファイルをスキャン
-	files, err := scanTemplateFiles(*dir)
+	files, err := t.Files()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to scan directory: %w", err))
-		os.Exit(1)
+		return nil, opts, fmt.Errorf("%s: failed to scan directory: %w", t.Label(), err)
 	}
-
 	if len(files) == 0 {
-		fmt.Fprintf(os.Stderr, "Error: no .tmpl files found in %s/\n", *dir)
-		os.Exit(1)
+		return nil, opts, fmt.Errorf("%s: no template files found in %s/", t.Label(), t.Dir)
 	}
 
 	// 複数のテンプレートを処理
 	units := make([]gen.Unit, 0, len(files))
-	outDir := filepath.Dir(*out)
-
 	for _, file := range files {
 		src, err := os.ReadFile(file)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to read %s: %w", file, err))
-			os.Exit(1)
+			return nil, opts, fmt.Errorf("failed to read %s: %w", file, err)
 		}
 
 		relPath, err := filepath.Rel(outDir, file)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to get relative path for %s: %w", file, err))
-			os.Exit(1)
+			return nil, opts, fmt.Errorf("failed to get relative path for %s: %w", file, err)
 		}
 
 		units = append(units, gen.Unit{
-			Pkg:           *pkg,
-			SourcePath:    relPath,
+			Pkg:           t.Pkg,
+			SourcePath:    filepath.ToSlash(relPath),
 			SourceLiteral: string(src),
+			File:          file,
 		})
 	}
+	return units, opts, nil
+}
 
-	// コード生成（basedirを渡す）
-	code, err := gen.Emit(units, *dir)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to emit: %w", err))
-		os.Exit(1)
-	}
-
-	if err := os.WriteFile(*out, []byte(code), 0644); err != nil {
+// checkUpToDate は既存の出力ファイルが生成結果 code と一致するか確認し、終了コードを返す
+// 一致しなければ unified diff を標準出力に出力する。ファイルは書き換えない
+func checkUpToDate(out string, code []byte) int {
+	current, err := os.ReadFile(out)
+	if err != nil && !os.IsNotExist(err) {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		return 1
 	}
-}
-
-// scanTemplateFiles はディレクトリから.tmplファイルをスキャンする
-// dir/*.tmpl (フラット) と dir/*/*.tmpl (グループ) のみを対象とする
-func scanTemplateFiles(dir string) ([]string, error) {
-	var files []string
-
-	// フラットなテンプレート: dir/*.tmpl
-	flatPattern := filepath.Join(dir, "*.tmpl")
-	flatFiles, err := filepath.Glob(flatPattern)
-	if err != nil {
-		return nil, fmt.Errorf("failed to scan flat templates: %w", err)
+	if bytes.Equal(current, code) {
+		return 0
 	}
-	files = append(files, flatFiles...)
 
-	// グループ化されたテンプレート: dir/*/*.tmpl (1階層のみ)
-	groupPattern := filepath.Join(dir, "*", "*.tmpl")
-	groupFiles, err := filepath.Glob(groupPattern)
-	if err != nil {
-		return nil, fmt.Errorf("failed to scan grouped templates: %w", err)
+	fmt.Print(util.UnifiedDiff(out, out+" (generated)", current, code))
+	if os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "%s does not exist; run go generate\n", out)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s is out of date; run go generate\n", out)
 	}
-	files = append(files, groupFiles...)
-
-	return files, nil
+	return 1
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/bellwood4486/tmpltype/internal/config"
+	"github.com/bellwood4486/tmpltype/internal/gen"
+	"github.com/bellwood4486/tmpltype/internal/preview"
+)
+
+// runRender は render サブコマンドを実行し、終了コードを返す
+// テンプレートを JSON または YAML のデータで描画して標準出力に出力する
+func runRender(args []string) int {
+	fs := flag.NewFlagSet("tmpltype render", flag.ExitOnError)
+	targetFlags := defineTargetFlags(fs, false)
+	dataFile := fs.String("data", "", "JSON or YAML file with the template data (default: zero values)")
+	targetName := fs.String("target", "", "target in the config file that contains the template (default: the only target that has it)")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	name := fs.Arg(0)
+
+	targets, err := targetFlags.load()
+	if err == nil && *targetName != "" {
+		targets, err = selectTarget(targets, *targetName)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	var data []byte
+	if *dataFile != "" {
+		if data, err = os.ReadFile(*dataFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	out, err := render(targets, name, *dataFile, data)
+	if err != nil {
+		// データの不一致は1行ずつ "file:line:col: path: msg" の形式で出力される
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Stdout.Write(out)
+	return 0
+}
+
+// selectTarget は名前（省略時は out）が name のターゲットだけを返す
+func selectTarget(targets []config.Target, name string) ([]config.Target, error) {
+	var labels []string
+	for _, t := range targets {
+		if t.Label() == name {
+			return []config.Target{t}, nil
+		}
+		labels = append(labels, t.Label())
+	}
+	return nil, fmt.Errorf("target %q not found (available: %s)", name, strings.Join(labels, ", "))
+}
+
+// render はテンプレート name を持つターゲットを探し、data で描画した結果を返す
+// 描画が途中で失敗した場合に出力が混ざらないよう、結果はまとめて返す
+func render(targets []config.Target, name, dataFile string, data []byte) ([]byte, error) {
+	type found struct {
+		target    config.Target
+		mode      gen.Mode
+		opts      gen.Options
+		templates []gen.TemplateSchema
+	}
+	var matches []found
+	var available []string
+	var errs []error
+	for _, t := range targets {
+		units, opts, err := loadUnits(t)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		templates, err := gen.Describe(units, t.Dir, opts)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		mode, err := gen.ResolveMode(units, opts.Mode)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, ts := range templates {
+			available = append(available, ts.Name)
+			if ts.Name == name {
+				matches = append(matches, found{target: t, mode: mode, opts: opts, templates: templates})
+			}
+		}
+	}
+
+	switch {
+	case len(matches) == 0 && len(errs) > 0:
+		return nil, errors.Join(errs...)
+	case len(matches) == 0:
+		return nil, fmt.Errorf("template %q not found (available: %s)", name, strings.Join(available, ", "))
+	case len(matches) > 1:
+		var labels []string
+		for _, m := range matches {
+			labels = append(labels, m.target.Label())
+		}
+		return nil, fmt.Errorf("template %q exists in several targets (%s); specify one with -target", name, strings.Join(labels, ", "))
+	}
+
+	m := matches[0]
+	var funcs []string
+	if m.opts.FuncMap != nil {
+		funcs = append(slices.Sorted(maps.Keys(m.opts.FuncMap.Funcs)), gen.ContextFunc) // 生成コードが加える関数
+	}
+	r, err := preview.New(m.templates, preview.Options{HTML: m.mode == gen.ModeHTML, Funcs: funcs})
+	if err != nil {
+		return nil, err
+	}
+	value, err := r.Decode(name, dataFile, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if err := r.Render(&b, name, value); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
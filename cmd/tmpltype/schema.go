@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bellwood4486/tmpltype/internal/gen"
+)
+
+// schemaTarget は schema サブコマンドが出力するターゲット1つ分の型情報
+type schemaTarget struct {
+	Target    string               `json:"target,omitempty"` // 設定ファイルのターゲット名（省略時は out）
+	Dir       string               `json:"dir"`              // テンプレートディレクトリ
+	Templates []gen.TemplateSchema `json:"templates"`        // テンプレートごとの型情報
+}
+
+// runSchema は schema サブコマンドを実行し、終了コードを返す
+// テンプレートのスキャン結果と型解決結果をターゲットごとに JSON で標準出力に出力する
+func runSchema(args []string) int {
+	fs := flag.NewFlagSet("tmpltype schema", flag.ExitOnError)
+	targetFlags := defineTargetFlags(fs, false)
+	_ = fs.Parse(args)
+
+	targets, err := targetFlags.load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	status := 0
+	result := make([]schemaTarget, 0, len(targets))
+	for _, t := range targets {
+		units, opts, err := loadUnits(t)
+		if err == nil {
+			var templates []gen.TemplateSchema
+			templates, err = gen.Describe(units, t.Dir, opts)
+			if err == nil {
+				result = append(result, schemaTarget{Target: t.Label(), Dir: t.Dir, Templates: templates})
+				continue
+			}
+		}
+		fmt.Fprintln(os.Stderr, err)
+		status = 1
+	}
+	if status != 0 {
+		return status
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
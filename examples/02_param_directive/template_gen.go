@@ -2,10 +2,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"text/template"
+	"time"
 )
 
 // TemplateName is a type-safe template name
@@ -21,28 +30,200 @@ var Template = struct {
 //go:embed templates/user.tmpl
 var userTplSource string
 
-func newTemplate(name TemplateName, source string) *template.Template {
-	return template.Must(template.New(string(name)).Option("missingkey=error").Parse(source))
+// templateFiles lists the templates in parse order, with their paths relative to the package directory
+var templateFiles = []struct {
+	name   TemplateName
+	path   string
+	source string
+}{
+	{Template.User, "templates/user.tmpl", userTplSource},
+}
+
+// parseTemplates parses the templates in deps, in parse order, into a new set and returns the one named name
+func parseTemplates(name TemplateName, deps []TemplateName) (*template.Template, error) {
+	set := template.New("").Option("missingkey=error")
+	for _, f := range templateFiles {
+		if !slices.Contains(deps, f.name) {
+			continue
+		}
+		if _, err := set.New(string(f.name)).Parse(f.source); err != nil {
+			return nil, err
+		}
+	}
+	tmpl := set.Lookup(string(name))
+	if tmpl == nil {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	return tmpl, nil
+}
+
+// lazyTemplate parses a template on first use
+type lazyTemplate struct {
+	name TemplateName
+	deps []TemplateName // the template and the ones defining the templates it calls
+	once sync.Once
+	tmpl *template.Template
+	err  error
 }
 
-var templates = map[TemplateName]*template.Template{
-	Template.User: newTemplate(Template.User, userTplSource),
+func (l *lazyTemplate) load() (*template.Template, error) {
+	l.once.Do(func() {
+		l.tmpl, l.err = parseTemplates(l.name, l.deps)
+	})
+	return l.tmpl, l.err
 }
 
-// Templates returns a map of all templates
+var templates = map[TemplateName]*lazyTemplate{
+	Template.User: {name: Template.User, deps: []TemplateName{Template.User}},
+}
+
+// Templates returns a map of all templates, parsing the ones not used yet
+// Templates that fail to parse are left out; ParseAll reports their errors
 func Templates() map[TemplateName]*template.Template {
-	return templates
+	m := make(map[TemplateName]*template.Template, len(templates))
+	for name, l := range templates {
+		if tmpl, err := l.load(); err == nil {
+			m[name] = tmpl
+		}
+	}
+	return m
+}
+
+// ParseAll parses all templates and returns their parse errors joined
+// Templates are otherwise parsed on first use; call it at startup to fail early
+func ParseAll() error {
+	var errs []error
+	seen := make(map[string]bool) // the templates calling a broken template repeat its error
+	for _, f := range templateFiles {
+		if _, err := templates[f.name].load(); err != nil && !seen[err.Error()] {
+			seen[err.Error()] = true
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// hotReloader reads the templates from disk and reparses them when a file changes
+type hotReloader struct {
+	dir      string
+	mu       sync.Mutex
+	modTimes []time.Time
+	set      *template.Template // never executed, so that it can be cloned
+}
+
+// hotReload is set by EnableHotReload
+var hotReload atomic.Pointer[hotReloader]
+
+// EnableHotReload makes Render and the RenderXxx functions read the templates from the files
+// under dir, the directory of this package, and reparse them whenever a file's modification time changes
+// It is meant for local development; without it, the embedded templates are parsed once, on first use
+// Templates still returns the embedded templates
+func EnableHotReload(dir string) error {
+	r := &hotReloader{dir: dir}
+	if _, err := r.templateSet(); err != nil {
+		return err
+	}
+	hotReload.Store(r)
+	return nil
+}
+
+// templateSet returns the template set, reparsing it if a template file has changed since the last call
+func (r *hotReloader) templateSet() (*template.Template, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	modTimes := make([]time.Time, len(templateFiles))
+	for i, f := range templateFiles {
+		info, err := os.Stat(filepath.Join(r.dir, f.path))
+		if err != nil {
+			return nil, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	if r.set != nil && slices.EqualFunc(modTimes, r.modTimes, time.Time.Equal) {
+		return r.set, nil
+	}
+	set := template.New("").Option("missingkey=error")
+	for _, f := range templateFiles {
+		src, err := os.ReadFile(filepath.Join(r.dir, f.path))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := set.New(string(f.name)).Parse(string(src)); err != nil {
+			return nil, err
+		}
+	}
+	r.set, r.modTimes = set, modTimes
+	return set, nil
+}
+
+// lookup returns the named template from a copy of the current template set
+func (r *hotReloader) lookup(name TemplateName) (*template.Template, error) {
+	set, err := r.templateSet()
+	if err != nil {
+		return nil, err
+	}
+	if set, err = set.Clone(); err != nil {
+		return nil, err
+	}
+	tmpl := set.Lookup(string(name))
+	if tmpl == nil {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	return tmpl, nil
+}
+
+// lookupTemplate returns the named template, reloaded from disk if hot reload is enabled
+func lookupTemplate(name TemplateName) (*template.Template, error) {
+	if r := hotReload.Load(); r != nil {
+		return r.lookup(name)
+	}
+	l, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	return l.load()
 }
 
 // Render renders a template by name with the given data
 func Render(w io.Writer, name TemplateName, data any) error {
-	tmpl, ok := templates[name]
-	if !ok {
-		return fmt.Errorf("template %q not found", name)
+	tmpl, err := lookupTemplate(name)
+	if err != nil {
+		return err
 	}
 	return tmpl.Execute(w, data)
 }
 
+// bufferPool holds the buffers used by the RenderXxxString and RenderXxxBytes functions
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > 65536 { // do not keep large buffers alive
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// contextWriter stops writing once ctx is done
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw *contextWriter) Write(b []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(b)
+}
+
 // ============================================================
 // user template
 // ============================================================
@@ -67,9 +248,50 @@ type User struct {
 
 // RenderUser renders the user template
 func RenderUser(w io.Writer, p User) error {
-	tmpl, ok := templates[Template.User]
-	if !ok {
-		return fmt.Errorf("template %q not found", Template.User)
+	tmpl, err := lookupTemplate(Template.User)
+	if err != nil {
+		return err
 	}
 	return tmpl.Execute(w, p)
 }
+
+// RenderUserContext renders the user template, stopping once ctx is done
+// If ctx is done before or during rendering, it returns ctx.Err()
+func RenderUserContext(ctx context.Context, w io.Writer, p User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmpl, err := lookupTemplate(Template.User)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(&contextWriter{ctx: ctx, w: w}, p); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// RenderUserString renders the user template and returns the output as a string
+// On error, it returns an empty string and discards any partial output
+func RenderUserString(p User) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderUser(buf, p); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderUserBytes renders the user template and returns the output as a byte slice
+// On error, it returns nil and discards any partial output
+func RenderUserBytes(p User) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderUser(buf, p); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil // the buffer is reused
+}
@@ -8,6 +8,12 @@ import (
 func main() {
 	fmt.Println("=== Example: Multi-template support ===")
 
+	// Templates are parsed on first use; ParseAll checks them all up front
+	if err := ParseAll(); err != nil {
+		fmt.Println("parse error:", err)
+		return
+	}
+
 	// Use Templates() map function
 	templates := Templates()
 	fmt.Printf("Available templates: %d\n", len(templates))
@@ -39,6 +45,32 @@ func main() {
 	})
 	fmt.Println("Nav output:")
 	fmt.Println(navBuf.String())
+
+	// Use a page that invokes the other templates via {{ template }}
+	var pageBuf bytes.Buffer
+	err := RenderPage(&pageBuf, Page{
+		Header: Header{Title: "Composed Page"},
+		Nav: Nav{
+			CurrentUser: NavCurrentUser{Name: "Guest"},
+			Items: []NavItemsItem{
+				{Name: "Home", Link: "/", Active: true},
+			},
+		},
+		Sections: []PageSectionsItem{
+			{Heading: "About", Body: "Partials share one template set."},
+		},
+		Footer: Footer{
+			Year:        2025,
+			CompanyName: "Example Inc.",
+			Links:       []FooterLinksItem{{Text: "Privacy", URL: "/privacy"}},
+		},
+	})
+	if err != nil {
+		fmt.Println("render error:", err)
+		return
+	}
+	fmt.Println("Page output:")
+	fmt.Println(pageBuf.String())
 }
 
 func strPtr(s string) *string {
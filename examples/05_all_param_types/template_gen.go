@@ -2,10 +2,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"text/template"
+	"time"
 )
 
 // TemplateName is a type-safe template name
@@ -46,33 +55,210 @@ var slice_typesTplSource string
 //go:embed templates/struct_types.tmpl
 var struct_typesTplSource string
 
-func newTemplate(name TemplateName, source string) *template.Template {
-	return template.Must(template.New(string(name)).Option("missingkey=error").Parse(source))
+// templateFiles lists the templates in parse order, with their paths relative to the package directory
+var templateFiles = []struct {
+	name   TemplateName
+	path   string
+	source string
+}{
+	{Template.BasicTypes, "templates/basic_types.tmpl", basic_typesTplSource},
+	{Template.ComplexTypes, "templates/complex_types.tmpl", complex_typesTplSource},
+	{Template.MapTypes, "templates/map_types.tmpl", map_typesTplSource},
+	{Template.PointerTypes, "templates/pointer_types.tmpl", pointer_typesTplSource},
+	{Template.SliceTypes, "templates/slice_types.tmpl", slice_typesTplSource},
+	{Template.StructTypes, "templates/struct_types.tmpl", struct_typesTplSource},
+}
+
+// parseTemplates parses the templates in deps, in parse order, into a new set and returns the one named name
+func parseTemplates(name TemplateName, deps []TemplateName) (*template.Template, error) {
+	set := template.New("").Option("missingkey=error")
+	for _, f := range templateFiles {
+		if !slices.Contains(deps, f.name) {
+			continue
+		}
+		if _, err := set.New(string(f.name)).Parse(f.source); err != nil {
+			return nil, err
+		}
+	}
+	tmpl := set.Lookup(string(name))
+	if tmpl == nil {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	return tmpl, nil
+}
+
+// lazyTemplate parses a template on first use
+type lazyTemplate struct {
+	name TemplateName
+	deps []TemplateName // the template and the ones defining the templates it calls
+	once sync.Once
+	tmpl *template.Template
+	err  error
 }
 
-var templates = map[TemplateName]*template.Template{
-	Template.BasicTypes:   newTemplate(Template.BasicTypes, basic_typesTplSource),
-	Template.ComplexTypes: newTemplate(Template.ComplexTypes, complex_typesTplSource),
-	Template.MapTypes:     newTemplate(Template.MapTypes, map_typesTplSource),
-	Template.PointerTypes: newTemplate(Template.PointerTypes, pointer_typesTplSource),
-	Template.SliceTypes:   newTemplate(Template.SliceTypes, slice_typesTplSource),
-	Template.StructTypes:  newTemplate(Template.StructTypes, struct_typesTplSource),
+func (l *lazyTemplate) load() (*template.Template, error) {
+	l.once.Do(func() {
+		l.tmpl, l.err = parseTemplates(l.name, l.deps)
+	})
+	return l.tmpl, l.err
 }
 
-// Templates returns a map of all templates
+var templates = map[TemplateName]*lazyTemplate{
+	Template.BasicTypes:   {name: Template.BasicTypes, deps: []TemplateName{Template.BasicTypes}},
+	Template.ComplexTypes: {name: Template.ComplexTypes, deps: []TemplateName{Template.ComplexTypes}},
+	Template.MapTypes:     {name: Template.MapTypes, deps: []TemplateName{Template.MapTypes}},
+	Template.PointerTypes: {name: Template.PointerTypes, deps: []TemplateName{Template.PointerTypes}},
+	Template.SliceTypes:   {name: Template.SliceTypes, deps: []TemplateName{Template.SliceTypes}},
+	Template.StructTypes:  {name: Template.StructTypes, deps: []TemplateName{Template.StructTypes}},
+}
+
+// Templates returns a map of all templates, parsing the ones not used yet
+// Templates that fail to parse are left out; ParseAll reports their errors
 func Templates() map[TemplateName]*template.Template {
-	return templates
+	m := make(map[TemplateName]*template.Template, len(templates))
+	for name, l := range templates {
+		if tmpl, err := l.load(); err == nil {
+			m[name] = tmpl
+		}
+	}
+	return m
+}
+
+// ParseAll parses all templates and returns their parse errors joined
+// Templates are otherwise parsed on first use; call it at startup to fail early
+func ParseAll() error {
+	var errs []error
+	seen := make(map[string]bool) // the templates calling a broken template repeat its error
+	for _, f := range templateFiles {
+		if _, err := templates[f.name].load(); err != nil && !seen[err.Error()] {
+			seen[err.Error()] = true
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// hotReloader reads the templates from disk and reparses them when a file changes
+type hotReloader struct {
+	dir      string
+	mu       sync.Mutex
+	modTimes []time.Time
+	set      *template.Template // never executed, so that it can be cloned
+}
+
+// hotReload is set by EnableHotReload
+var hotReload atomic.Pointer[hotReloader]
+
+// EnableHotReload makes Render and the RenderXxx functions read the templates from the files
+// under dir, the directory of this package, and reparse them whenever a file's modification time changes
+// It is meant for local development; without it, the embedded templates are parsed once, on first use
+// Templates still returns the embedded templates
+func EnableHotReload(dir string) error {
+	r := &hotReloader{dir: dir}
+	if _, err := r.templateSet(); err != nil {
+		return err
+	}
+	hotReload.Store(r)
+	return nil
+}
+
+// templateSet returns the template set, reparsing it if a template file has changed since the last call
+func (r *hotReloader) templateSet() (*template.Template, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	modTimes := make([]time.Time, len(templateFiles))
+	for i, f := range templateFiles {
+		info, err := os.Stat(filepath.Join(r.dir, f.path))
+		if err != nil {
+			return nil, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	if r.set != nil && slices.EqualFunc(modTimes, r.modTimes, time.Time.Equal) {
+		return r.set, nil
+	}
+	set := template.New("").Option("missingkey=error")
+	for _, f := range templateFiles {
+		src, err := os.ReadFile(filepath.Join(r.dir, f.path))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := set.New(string(f.name)).Parse(string(src)); err != nil {
+			return nil, err
+		}
+	}
+	r.set, r.modTimes = set, modTimes
+	return set, nil
+}
+
+// lookup returns the named template from a copy of the current template set
+func (r *hotReloader) lookup(name TemplateName) (*template.Template, error) {
+	set, err := r.templateSet()
+	if err != nil {
+		return nil, err
+	}
+	if set, err = set.Clone(); err != nil {
+		return nil, err
+	}
+	tmpl := set.Lookup(string(name))
+	if tmpl == nil {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	return tmpl, nil
+}
+
+// lookupTemplate returns the named template, reloaded from disk if hot reload is enabled
+func lookupTemplate(name TemplateName) (*template.Template, error) {
+	if r := hotReload.Load(); r != nil {
+		return r.lookup(name)
+	}
+	l, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	return l.load()
 }
 
 // Render renders a template by name with the given data
 func Render(w io.Writer, name TemplateName, data any) error {
-	tmpl, ok := templates[name]
-	if !ok {
-		return fmt.Errorf("template %q not found", name)
+	tmpl, err := lookupTemplate(name)
+	if err != nil {
+		return err
 	}
 	return tmpl.Execute(w, data)
 }
 
+// bufferPool holds the buffers used by the RenderXxxString and RenderXxxBytes functions
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > 65536 { // do not keep large buffers alive
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// contextWriter stops writing once ctx is done
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw *contextWriter) Write(b []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(b)
+}
+
 // ============================================================
 // basic_types template
 // ============================================================
@@ -88,13 +274,54 @@ type BasicTypes struct {
 
 // RenderBasicTypes renders the basic_types template
 func RenderBasicTypes(w io.Writer, p BasicTypes) error {
-	tmpl, ok := templates[Template.BasicTypes]
-	if !ok {
-		return fmt.Errorf("template %q not found", Template.BasicTypes)
+	tmpl, err := lookupTemplate(Template.BasicTypes)
+	if err != nil {
+		return err
 	}
 	return tmpl.Execute(w, p)
 }
 
+// RenderBasicTypesContext renders the basic_types template, stopping once ctx is done
+// If ctx is done before or during rendering, it returns ctx.Err()
+func RenderBasicTypesContext(ctx context.Context, w io.Writer, p BasicTypes) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmpl, err := lookupTemplate(Template.BasicTypes)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(&contextWriter{ctx: ctx, w: w}, p); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// RenderBasicTypesString renders the basic_types template and returns the output as a string
+// On error, it returns an empty string and discards any partial output
+func RenderBasicTypesString(p BasicTypes) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderBasicTypes(buf, p); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderBasicTypesBytes renders the basic_types template and returns the output as a byte slice
+// On error, it returns nil and discards any partial output
+func RenderBasicTypesBytes(p BasicTypes) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderBasicTypes(buf, p); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil // the buffer is reused
+}
+
 // ============================================================
 // complex_types template
 // ============================================================
@@ -121,13 +348,54 @@ type ComplexTypes struct {
 
 // RenderComplexTypes renders the complex_types template
 func RenderComplexTypes(w io.Writer, p ComplexTypes) error {
-	tmpl, ok := templates[Template.ComplexTypes]
-	if !ok {
-		return fmt.Errorf("template %q not found", Template.ComplexTypes)
+	tmpl, err := lookupTemplate(Template.ComplexTypes)
+	if err != nil {
+		return err
 	}
 	return tmpl.Execute(w, p)
 }
 
+// RenderComplexTypesContext renders the complex_types template, stopping once ctx is done
+// If ctx is done before or during rendering, it returns ctx.Err()
+func RenderComplexTypesContext(ctx context.Context, w io.Writer, p ComplexTypes) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmpl, err := lookupTemplate(Template.ComplexTypes)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(&contextWriter{ctx: ctx, w: w}, p); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// RenderComplexTypesString renders the complex_types template and returns the output as a string
+// On error, it returns an empty string and discards any partial output
+func RenderComplexTypesString(p ComplexTypes) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderComplexTypes(buf, p); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderComplexTypesBytes renders the complex_types template and returns the output as a byte slice
+// On error, it returns nil and discards any partial output
+func RenderComplexTypesBytes(p ComplexTypes) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderComplexTypes(buf, p); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil // the buffer is reused
+}
+
 // ============================================================
 // map_types template
 // ============================================================
@@ -142,13 +410,54 @@ type MapTypes struct {
 
 // RenderMapTypes renders the map_types template
 func RenderMapTypes(w io.Writer, p MapTypes) error {
-	tmpl, ok := templates[Template.MapTypes]
-	if !ok {
-		return fmt.Errorf("template %q not found", Template.MapTypes)
+	tmpl, err := lookupTemplate(Template.MapTypes)
+	if err != nil {
+		return err
 	}
 	return tmpl.Execute(w, p)
 }
 
+// RenderMapTypesContext renders the map_types template, stopping once ctx is done
+// If ctx is done before or during rendering, it returns ctx.Err()
+func RenderMapTypesContext(ctx context.Context, w io.Writer, p MapTypes) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmpl, err := lookupTemplate(Template.MapTypes)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(&contextWriter{ctx: ctx, w: w}, p); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// RenderMapTypesString renders the map_types template and returns the output as a string
+// On error, it returns an empty string and discards any partial output
+func RenderMapTypesString(p MapTypes) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderMapTypes(buf, p); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderMapTypesBytes renders the map_types template and returns the output as a byte slice
+// On error, it returns nil and discards any partial output
+func RenderMapTypesBytes(p MapTypes) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderMapTypes(buf, p); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil // the buffer is reused
+}
+
 // ============================================================
 // pointer_types template
 // ============================================================
@@ -163,13 +472,54 @@ type PointerTypes struct {
 
 // RenderPointerTypes renders the pointer_types template
 func RenderPointerTypes(w io.Writer, p PointerTypes) error {
-	tmpl, ok := templates[Template.PointerTypes]
-	if !ok {
-		return fmt.Errorf("template %q not found", Template.PointerTypes)
+	tmpl, err := lookupTemplate(Template.PointerTypes)
+	if err != nil {
+		return err
 	}
 	return tmpl.Execute(w, p)
 }
 
+// RenderPointerTypesContext renders the pointer_types template, stopping once ctx is done
+// If ctx is done before or during rendering, it returns ctx.Err()
+func RenderPointerTypesContext(ctx context.Context, w io.Writer, p PointerTypes) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmpl, err := lookupTemplate(Template.PointerTypes)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(&contextWriter{ctx: ctx, w: w}, p); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// RenderPointerTypesString renders the pointer_types template and returns the output as a string
+// On error, it returns an empty string and discards any partial output
+func RenderPointerTypesString(p PointerTypes) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderPointerTypes(buf, p); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderPointerTypesBytes renders the pointer_types template and returns the output as a byte slice
+// On error, it returns nil and discards any partial output
+func RenderPointerTypesBytes(p PointerTypes) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderPointerTypes(buf, p); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil // the buffer is reused
+}
+
 // ============================================================
 // slice_types template
 // ============================================================
@@ -184,13 +534,54 @@ type SliceTypes struct {
 
 // RenderSliceTypes renders the slice_types template
 func RenderSliceTypes(w io.Writer, p SliceTypes) error {
-	tmpl, ok := templates[Template.SliceTypes]
-	if !ok {
-		return fmt.Errorf("template %q not found", Template.SliceTypes)
+	tmpl, err := lookupTemplate(Template.SliceTypes)
+	if err != nil {
+		return err
 	}
 	return tmpl.Execute(w, p)
 }
 
+// RenderSliceTypesContext renders the slice_types template, stopping once ctx is done
+// If ctx is done before or during rendering, it returns ctx.Err()
+func RenderSliceTypesContext(ctx context.Context, w io.Writer, p SliceTypes) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmpl, err := lookupTemplate(Template.SliceTypes)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(&contextWriter{ctx: ctx, w: w}, p); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// RenderSliceTypesString renders the slice_types template and returns the output as a string
+// On error, it returns an empty string and discards any partial output
+func RenderSliceTypesString(p SliceTypes) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderSliceTypes(buf, p); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderSliceTypesBytes renders the slice_types template and returns the output as a byte slice
+// On error, it returns nil and discards any partial output
+func RenderSliceTypesBytes(p SliceTypes) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderSliceTypes(buf, p); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil // the buffer is reused
+}
+
 // ============================================================
 // struct_types template
 // ============================================================
@@ -215,9 +606,50 @@ type StructTypes struct {
 
 // RenderStructTypes renders the struct_types template
 func RenderStructTypes(w io.Writer, p StructTypes) error {
-	tmpl, ok := templates[Template.StructTypes]
-	if !ok {
-		return fmt.Errorf("template %q not found", Template.StructTypes)
+	tmpl, err := lookupTemplate(Template.StructTypes)
+	if err != nil {
+		return err
 	}
 	return tmpl.Execute(w, p)
 }
+
+// RenderStructTypesContext renders the struct_types template, stopping once ctx is done
+// If ctx is done before or during rendering, it returns ctx.Err()
+func RenderStructTypesContext(ctx context.Context, w io.Writer, p StructTypes) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmpl, err := lookupTemplate(Template.StructTypes)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(&contextWriter{ctx: ctx, w: w}, p); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// RenderStructTypesString renders the struct_types template and returns the output as a string
+// On error, it returns an empty string and discards any partial output
+func RenderStructTypesString(p StructTypes) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderStructTypes(buf, p); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderStructTypesBytes renders the struct_types template and returns the output as a byte slice
+// On error, it returns nil and discards any partial output
+func RenderStructTypesBytes(p StructTypes) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderStructTypes(buf, p); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil // the buffer is reused
+}
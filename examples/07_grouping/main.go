@@ -11,40 +11,36 @@ func main() {
 
 	// Use grouped templates - MailInvite
 	fmt.Println("--- Mail Invite ---")
-	var inviteTitleBuf, inviteContentBuf bytes.Buffer
-
-	_ = RenderMailInviteTitle(&inviteTitleBuf, MailInviteTitle{
+	inviteTitle, _ := RenderMailInviteTitleString(MailInviteTitle{
 		SiteName:    "MyApp",
 		InviterName: "Alice",
 	})
-	fmt.Println("Title:", inviteTitleBuf.String())
+	fmt.Println("Title:", inviteTitle)
 
-	_ = RenderMailInviteContent(&inviteContentBuf, MailInviteContent{
+	inviteContent, _ := RenderMailInviteContentString(MailInviteContent{
 		RecipientName: "Bob",
 		InviterName:   "Alice",
 		SiteName:      "MyApp",
 		InviteURL:     "https://myapp.com/invite/abc123",
 	})
 	fmt.Println("Content:")
-	fmt.Println(inviteContentBuf.String())
+	fmt.Println(inviteContent)
 	fmt.Println()
 
 	// Use grouped templates - MailAccountCreated
 	fmt.Println("--- Mail Account Created ---")
-	var accountTitleBuf, accountContentBuf bytes.Buffer
-
-	_ = RenderMailAccountCreatedTitle(&accountTitleBuf, MailAccountCreatedTitle{
+	accountTitle, _ := RenderMailAccountCreatedTitleString(MailAccountCreatedTitle{
 		SiteName: "MyApp",
 	})
-	fmt.Println("Title:", accountTitleBuf.String())
+	fmt.Println("Title:", accountTitle)
 
-	_ = RenderMailAccountCreatedContent(&accountContentBuf, MailAccountCreatedContent{
+	accountContent, _ := RenderMailAccountCreatedContentString(MailAccountCreatedContent{
 		Username: "bob123",
 		Email:    "bob@example.com",
 		SiteName: "MyApp",
 	})
 	fmt.Println("Content:")
-	fmt.Println(accountContentBuf.String())
+	fmt.Println(accountContent)
 	fmt.Println()
 
 	// Use generic Render with grouped template
@@ -58,13 +54,12 @@ func main() {
 
 	// Use flat template
 	fmt.Println("--- Footer (Flat Template) ---")
-	var footerBuf bytes.Buffer
-	_ = RenderFooter(&footerBuf, Footer{
+	footer, _ := RenderFooterBytes(Footer{
 		SiteName: "MyApp",
 		Year:     "2025",
 		Email:    "support@myapp.com",
 	})
-	fmt.Println(footerBuf.String())
+	fmt.Println(string(footer))
 	fmt.Println()
 
 	// Show all available templates
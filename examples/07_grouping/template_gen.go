@@ -2,10 +2,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"text/template"
+	"time"
 )
 
 // TemplateName is a type-safe template name
@@ -72,34 +81,212 @@ var mail_invite_contentTplSource string
 //go:embed templates/01_mail_invite/title.tmpl
 var mail_invite_titleTplSource string
 
-func newTemplate(name TemplateName, source string) *template.Template {
-	return template.Must(template.New(string(name)).Option("missingkey=error").Parse(source))
+// templateFiles lists the templates in parse order, with their paths relative to the package directory
+var templateFiles = []struct {
+	name   TemplateName
+	path   string
+	source string
+}{
+	{Template.Footer, "templates/footer.tmpl", footerTplSource},
+	{Template.MailAccountCreated.Content, "templates/02_mail_account_created/content.tmpl", mail_account_created_contentTplSource},
+	{Template.MailAccountCreated.Title, "templates/02_mail_account_created/title.tmpl", mail_account_created_titleTplSource},
+	{Template.MailArticleCreated.Content, "templates/03_mail_article_created/content.tmpl", mail_article_created_contentTplSource},
+	{Template.MailArticleCreated.Title, "templates/03_mail_article_created/title.tmpl", mail_article_created_titleTplSource},
+	{Template.MailInvite.Content, "templates/01_mail_invite/content.tmpl", mail_invite_contentTplSource},
+	{Template.MailInvite.Title, "templates/01_mail_invite/title.tmpl", mail_invite_titleTplSource},
+}
+
+// parseTemplates parses the templates in deps, in parse order, into a new set and returns the one named name
+func parseTemplates(name TemplateName, deps []TemplateName) (*template.Template, error) {
+	set := template.New("").Option("missingkey=error")
+	for _, f := range templateFiles {
+		if !slices.Contains(deps, f.name) {
+			continue
+		}
+		if _, err := set.New(string(f.name)).Parse(f.source); err != nil {
+			return nil, err
+		}
+	}
+	tmpl := set.Lookup(string(name))
+	if tmpl == nil {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	return tmpl, nil
+}
+
+// lazyTemplate parses a template on first use
+type lazyTemplate struct {
+	name TemplateName
+	deps []TemplateName // the template and the ones defining the templates it calls
+	once sync.Once
+	tmpl *template.Template
+	err  error
+}
+
+func (l *lazyTemplate) load() (*template.Template, error) {
+	l.once.Do(func() {
+		l.tmpl, l.err = parseTemplates(l.name, l.deps)
+	})
+	return l.tmpl, l.err
 }
 
-var templates = map[TemplateName]*template.Template{
-	Template.Footer:                     newTemplate(Template.Footer, footerTplSource),
-	Template.MailAccountCreated.Content: newTemplate(Template.MailAccountCreated.Content, mail_account_created_contentTplSource),
-	Template.MailAccountCreated.Title:   newTemplate(Template.MailAccountCreated.Title, mail_account_created_titleTplSource),
-	Template.MailArticleCreated.Content: newTemplate(Template.MailArticleCreated.Content, mail_article_created_contentTplSource),
-	Template.MailArticleCreated.Title:   newTemplate(Template.MailArticleCreated.Title, mail_article_created_titleTplSource),
-	Template.MailInvite.Content:         newTemplate(Template.MailInvite.Content, mail_invite_contentTplSource),
-	Template.MailInvite.Title:           newTemplate(Template.MailInvite.Title, mail_invite_titleTplSource),
+var templates = map[TemplateName]*lazyTemplate{
+	Template.Footer:                     {name: Template.Footer, deps: []TemplateName{Template.Footer}},
+	Template.MailAccountCreated.Content: {name: Template.MailAccountCreated.Content, deps: []TemplateName{Template.MailAccountCreated.Content}},
+	Template.MailAccountCreated.Title:   {name: Template.MailAccountCreated.Title, deps: []TemplateName{Template.MailAccountCreated.Title}},
+	Template.MailArticleCreated.Content: {name: Template.MailArticleCreated.Content, deps: []TemplateName{Template.MailArticleCreated.Content}},
+	Template.MailArticleCreated.Title:   {name: Template.MailArticleCreated.Title, deps: []TemplateName{Template.MailArticleCreated.Title}},
+	Template.MailInvite.Content:         {name: Template.MailInvite.Content, deps: []TemplateName{Template.MailInvite.Content}},
+	Template.MailInvite.Title:           {name: Template.MailInvite.Title, deps: []TemplateName{Template.MailInvite.Title}},
 }
 
-// Templates returns a map of all templates
+// Templates returns a map of all templates, parsing the ones not used yet
+// Templates that fail to parse are left out; ParseAll reports their errors
 func Templates() map[TemplateName]*template.Template {
-	return templates
+	m := make(map[TemplateName]*template.Template, len(templates))
+	for name, l := range templates {
+		if tmpl, err := l.load(); err == nil {
+			m[name] = tmpl
+		}
+	}
+	return m
+}
+
+// ParseAll parses all templates and returns their parse errors joined
+// Templates are otherwise parsed on first use; call it at startup to fail early
+func ParseAll() error {
+	var errs []error
+	seen := make(map[string]bool) // the templates calling a broken template repeat its error
+	for _, f := range templateFiles {
+		if _, err := templates[f.name].load(); err != nil && !seen[err.Error()] {
+			seen[err.Error()] = true
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// hotReloader reads the templates from disk and reparses them when a file changes
+type hotReloader struct {
+	dir      string
+	mu       sync.Mutex
+	modTimes []time.Time
+	set      *template.Template // never executed, so that it can be cloned
+}
+
+// hotReload is set by EnableHotReload
+var hotReload atomic.Pointer[hotReloader]
+
+// EnableHotReload makes Render and the RenderXxx functions read the templates from the files
+// under dir, the directory of this package, and reparse them whenever a file's modification time changes
+// It is meant for local development; without it, the embedded templates are parsed once, on first use
+// Templates still returns the embedded templates
+func EnableHotReload(dir string) error {
+	r := &hotReloader{dir: dir}
+	if _, err := r.templateSet(); err != nil {
+		return err
+	}
+	hotReload.Store(r)
+	return nil
+}
+
+// templateSet returns the template set, reparsing it if a template file has changed since the last call
+func (r *hotReloader) templateSet() (*template.Template, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	modTimes := make([]time.Time, len(templateFiles))
+	for i, f := range templateFiles {
+		info, err := os.Stat(filepath.Join(r.dir, f.path))
+		if err != nil {
+			return nil, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	if r.set != nil && slices.EqualFunc(modTimes, r.modTimes, time.Time.Equal) {
+		return r.set, nil
+	}
+	set := template.New("").Option("missingkey=error")
+	for _, f := range templateFiles {
+		src, err := os.ReadFile(filepath.Join(r.dir, f.path))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := set.New(string(f.name)).Parse(string(src)); err != nil {
+			return nil, err
+		}
+	}
+	r.set, r.modTimes = set, modTimes
+	return set, nil
+}
+
+// lookup returns the named template from a copy of the current template set
+func (r *hotReloader) lookup(name TemplateName) (*template.Template, error) {
+	set, err := r.templateSet()
+	if err != nil {
+		return nil, err
+	}
+	if set, err = set.Clone(); err != nil {
+		return nil, err
+	}
+	tmpl := set.Lookup(string(name))
+	if tmpl == nil {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	return tmpl, nil
+}
+
+// lookupTemplate returns the named template, reloaded from disk if hot reload is enabled
+func lookupTemplate(name TemplateName) (*template.Template, error) {
+	if r := hotReload.Load(); r != nil {
+		return r.lookup(name)
+	}
+	l, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	return l.load()
 }
 
 // Render renders a template by name with the given data
 func Render(w io.Writer, name TemplateName, data any) error {
-	tmpl, ok := templates[name]
-	if !ok {
-		return fmt.Errorf("template %q not found", name)
+	tmpl, err := lookupTemplate(name)
+	if err != nil {
+		return err
 	}
 	return tmpl.Execute(w, data)
 }
 
+// bufferPool holds the buffers used by the RenderXxxString and RenderXxxBytes functions
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > 65536 { // do not keep large buffers alive
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// contextWriter stops writing once ctx is done
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw *contextWriter) Write(b []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(b)
+}
+
 // ============================================================
 // footer template
 // ============================================================
@@ -113,13 +300,54 @@ type Footer struct {
 
 // RenderFooter renders the footer template
 func RenderFooter(w io.Writer, p Footer) error {
-	tmpl, ok := templates[Template.Footer]
-	if !ok {
-		return fmt.Errorf("template %q not found", Template.Footer)
+	tmpl, err := lookupTemplate(Template.Footer)
+	if err != nil {
+		return err
 	}
 	return tmpl.Execute(w, p)
 }
 
+// RenderFooterContext renders the footer template, stopping once ctx is done
+// If ctx is done before or during rendering, it returns ctx.Err()
+func RenderFooterContext(ctx context.Context, w io.Writer, p Footer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmpl, err := lookupTemplate(Template.Footer)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(&contextWriter{ctx: ctx, w: w}, p); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// RenderFooterString renders the footer template and returns the output as a string
+// On error, it returns an empty string and discards any partial output
+func RenderFooterString(p Footer) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderFooter(buf, p); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderFooterBytes renders the footer template and returns the output as a byte slice
+// On error, it returns nil and discards any partial output
+func RenderFooterBytes(p Footer) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderFooter(buf, p); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil // the buffer is reused
+}
+
 // ============================================================
 // mail_account_created/content template
 // ============================================================
@@ -133,13 +361,54 @@ type MailAccountCreatedContent struct {
 
 // RenderMailAccountCreatedContent renders the mail_account_created/content template
 func RenderMailAccountCreatedContent(w io.Writer, p MailAccountCreatedContent) error {
-	tmpl, ok := templates[Template.MailAccountCreated.Content]
-	if !ok {
-		return fmt.Errorf("template %q not found", Template.MailAccountCreated.Content)
+	tmpl, err := lookupTemplate(Template.MailAccountCreated.Content)
+	if err != nil {
+		return err
 	}
 	return tmpl.Execute(w, p)
 }
 
+// RenderMailAccountCreatedContentContext renders the mail_account_created/content template, stopping once ctx is done
+// If ctx is done before or during rendering, it returns ctx.Err()
+func RenderMailAccountCreatedContentContext(ctx context.Context, w io.Writer, p MailAccountCreatedContent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmpl, err := lookupTemplate(Template.MailAccountCreated.Content)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(&contextWriter{ctx: ctx, w: w}, p); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// RenderMailAccountCreatedContentString renders the mail_account_created/content template and returns the output as a string
+// On error, it returns an empty string and discards any partial output
+func RenderMailAccountCreatedContentString(p MailAccountCreatedContent) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderMailAccountCreatedContent(buf, p); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderMailAccountCreatedContentBytes renders the mail_account_created/content template and returns the output as a byte slice
+// On error, it returns nil and discards any partial output
+func RenderMailAccountCreatedContentBytes(p MailAccountCreatedContent) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderMailAccountCreatedContent(buf, p); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil // the buffer is reused
+}
+
 // ============================================================
 // mail_account_created/title template
 // ============================================================
@@ -151,13 +420,54 @@ type MailAccountCreatedTitle struct {
 
 // RenderMailAccountCreatedTitle renders the mail_account_created/title template
 func RenderMailAccountCreatedTitle(w io.Writer, p MailAccountCreatedTitle) error {
-	tmpl, ok := templates[Template.MailAccountCreated.Title]
-	if !ok {
-		return fmt.Errorf("template %q not found", Template.MailAccountCreated.Title)
+	tmpl, err := lookupTemplate(Template.MailAccountCreated.Title)
+	if err != nil {
+		return err
 	}
 	return tmpl.Execute(w, p)
 }
 
+// RenderMailAccountCreatedTitleContext renders the mail_account_created/title template, stopping once ctx is done
+// If ctx is done before or during rendering, it returns ctx.Err()
+func RenderMailAccountCreatedTitleContext(ctx context.Context, w io.Writer, p MailAccountCreatedTitle) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmpl, err := lookupTemplate(Template.MailAccountCreated.Title)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(&contextWriter{ctx: ctx, w: w}, p); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// RenderMailAccountCreatedTitleString renders the mail_account_created/title template and returns the output as a string
+// On error, it returns an empty string and discards any partial output
+func RenderMailAccountCreatedTitleString(p MailAccountCreatedTitle) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderMailAccountCreatedTitle(buf, p); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderMailAccountCreatedTitleBytes renders the mail_account_created/title template and returns the output as a byte slice
+// On error, it returns nil and discards any partial output
+func RenderMailAccountCreatedTitleBytes(p MailAccountCreatedTitle) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderMailAccountCreatedTitle(buf, p); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil // the buffer is reused
+}
+
 // ============================================================
 // mail_article_created/content template
 // ============================================================
@@ -173,13 +483,54 @@ type MailArticleCreatedContent struct {
 
 // RenderMailArticleCreatedContent renders the mail_article_created/content template
 func RenderMailArticleCreatedContent(w io.Writer, p MailArticleCreatedContent) error {
-	tmpl, ok := templates[Template.MailArticleCreated.Content]
-	if !ok {
-		return fmt.Errorf("template %q not found", Template.MailArticleCreated.Content)
+	tmpl, err := lookupTemplate(Template.MailArticleCreated.Content)
+	if err != nil {
+		return err
 	}
 	return tmpl.Execute(w, p)
 }
 
+// RenderMailArticleCreatedContentContext renders the mail_article_created/content template, stopping once ctx is done
+// If ctx is done before or during rendering, it returns ctx.Err()
+func RenderMailArticleCreatedContentContext(ctx context.Context, w io.Writer, p MailArticleCreatedContent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmpl, err := lookupTemplate(Template.MailArticleCreated.Content)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(&contextWriter{ctx: ctx, w: w}, p); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// RenderMailArticleCreatedContentString renders the mail_article_created/content template and returns the output as a string
+// On error, it returns an empty string and discards any partial output
+func RenderMailArticleCreatedContentString(p MailArticleCreatedContent) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderMailArticleCreatedContent(buf, p); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderMailArticleCreatedContentBytes renders the mail_article_created/content template and returns the output as a byte slice
+// On error, it returns nil and discards any partial output
+func RenderMailArticleCreatedContentBytes(p MailArticleCreatedContent) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderMailArticleCreatedContent(buf, p); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil // the buffer is reused
+}
+
 // ============================================================
 // mail_article_created/title template
 // ============================================================
@@ -191,13 +542,54 @@ type MailArticleCreatedTitle struct {
 
 // RenderMailArticleCreatedTitle renders the mail_article_created/title template
 func RenderMailArticleCreatedTitle(w io.Writer, p MailArticleCreatedTitle) error {
-	tmpl, ok := templates[Template.MailArticleCreated.Title]
-	if !ok {
-		return fmt.Errorf("template %q not found", Template.MailArticleCreated.Title)
+	tmpl, err := lookupTemplate(Template.MailArticleCreated.Title)
+	if err != nil {
+		return err
 	}
 	return tmpl.Execute(w, p)
 }
 
+// RenderMailArticleCreatedTitleContext renders the mail_article_created/title template, stopping once ctx is done
+// If ctx is done before or during rendering, it returns ctx.Err()
+func RenderMailArticleCreatedTitleContext(ctx context.Context, w io.Writer, p MailArticleCreatedTitle) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmpl, err := lookupTemplate(Template.MailArticleCreated.Title)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(&contextWriter{ctx: ctx, w: w}, p); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// RenderMailArticleCreatedTitleString renders the mail_article_created/title template and returns the output as a string
+// On error, it returns an empty string and discards any partial output
+func RenderMailArticleCreatedTitleString(p MailArticleCreatedTitle) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderMailArticleCreatedTitle(buf, p); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderMailArticleCreatedTitleBytes renders the mail_article_created/title template and returns the output as a byte slice
+// On error, it returns nil and discards any partial output
+func RenderMailArticleCreatedTitleBytes(p MailArticleCreatedTitle) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderMailArticleCreatedTitle(buf, p); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil // the buffer is reused
+}
+
 // ============================================================
 // mail_invite/content template
 // ============================================================
@@ -212,13 +604,54 @@ type MailInviteContent struct {
 
 // RenderMailInviteContent renders the mail_invite/content template
 func RenderMailInviteContent(w io.Writer, p MailInviteContent) error {
-	tmpl, ok := templates[Template.MailInvite.Content]
-	if !ok {
-		return fmt.Errorf("template %q not found", Template.MailInvite.Content)
+	tmpl, err := lookupTemplate(Template.MailInvite.Content)
+	if err != nil {
+		return err
 	}
 	return tmpl.Execute(w, p)
 }
 
+// RenderMailInviteContentContext renders the mail_invite/content template, stopping once ctx is done
+// If ctx is done before or during rendering, it returns ctx.Err()
+func RenderMailInviteContentContext(ctx context.Context, w io.Writer, p MailInviteContent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmpl, err := lookupTemplate(Template.MailInvite.Content)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(&contextWriter{ctx: ctx, w: w}, p); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// RenderMailInviteContentString renders the mail_invite/content template and returns the output as a string
+// On error, it returns an empty string and discards any partial output
+func RenderMailInviteContentString(p MailInviteContent) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderMailInviteContent(buf, p); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderMailInviteContentBytes renders the mail_invite/content template and returns the output as a byte slice
+// On error, it returns nil and discards any partial output
+func RenderMailInviteContentBytes(p MailInviteContent) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderMailInviteContent(buf, p); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil // the buffer is reused
+}
+
 // ============================================================
 // mail_invite/title template
 // ============================================================
@@ -231,9 +664,50 @@ type MailInviteTitle struct {
 
 // RenderMailInviteTitle renders the mail_invite/title template
 func RenderMailInviteTitle(w io.Writer, p MailInviteTitle) error {
-	tmpl, ok := templates[Template.MailInvite.Title]
-	if !ok {
-		return fmt.Errorf("template %q not found", Template.MailInvite.Title)
+	tmpl, err := lookupTemplate(Template.MailInvite.Title)
+	if err != nil {
+		return err
 	}
 	return tmpl.Execute(w, p)
 }
+
+// RenderMailInviteTitleContext renders the mail_invite/title template, stopping once ctx is done
+// If ctx is done before or during rendering, it returns ctx.Err()
+func RenderMailInviteTitleContext(ctx context.Context, w io.Writer, p MailInviteTitle) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmpl, err := lookupTemplate(Template.MailInvite.Title)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(&contextWriter{ctx: ctx, w: w}, p); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// RenderMailInviteTitleString renders the mail_invite/title template and returns the output as a string
+// On error, it returns an empty string and discards any partial output
+func RenderMailInviteTitleString(p MailInviteTitle) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderMailInviteTitle(buf, p); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderMailInviteTitleBytes renders the mail_invite/title template and returns the output as a byte slice
+// On error, it returns nil and discards any partial output
+func RenderMailInviteTitleBytes(p MailInviteTitle) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderMailInviteTitle(buf, p); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil // the buffer is reused
+}
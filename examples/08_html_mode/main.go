@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+)
+
+func main() {
+	fmt.Println("=== Example: html/template mode (*.html.tmpl) ===")
+
+	var buf bytes.Buffer
+	err := RenderProfile(&buf, Profile{
+		Name: "Alice",
+		// Untrusted strings are escaped by html/template
+		Tagline: `<script>alert("xss")</script>`,
+		// Trusted fragments are explicitly typed and rendered as-is
+		Bio:      template.HTML("<strong>Gopher</strong> since 2012"),
+		Homepage: template.URL("https://example.com/alice"),
+	})
+	if err != nil {
+		fmt.Println("render error:", err)
+		return
+	}
+	fmt.Println(buf.String())
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFuncs is installed into the generated templates via -funcs
+var templateFuncs = template.FuncMap{
+	"formatDate":  formatDate,
+	"formatPrice": formatPrice,
+	"repeat":      func(s string, n int) string { return strings.Repeat(s, n) },
+	"upper":       strings.ToUpper,
+}
+
+func formatPrice(amount float64, currency string) string {
+	return fmt.Sprintf("%.2f %s", amount, currency)
+}
+
+func formatDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
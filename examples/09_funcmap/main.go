@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+func main() {
+	fmt.Println("=== Example: Custom FuncMap ===")
+
+	var buf bytes.Buffer
+	err := RenderReceipt(&buf, Receipt{
+		Customer: "Alice",
+		IssuedAt: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC),
+		Width:    20,
+		Currency: "USD",
+		Lines: []ReceiptLinesItem{
+			{Name: "Coffee", Amount: 3.5},
+			{Name: "Bagel", Amount: 2.25},
+		},
+		Total: 5.75,
+	})
+	if err != nil {
+		fmt.Println("render error:", err)
+		return
+	}
+	fmt.Println(buf.String())
+}
@@ -0,0 +1,30 @@
+package domain
+
+import "fmt"
+
+// Order is an existing domain type rendered by the order template
+type Order struct {
+	ID       int64
+	Customer Customer
+	Lines    []Line
+}
+
+// Total returns the sum of all line amounts
+func (o Order) Total() string {
+	var sum float64
+	for _, l := range o.Lines {
+		sum += l.Price * float64(l.Quantity)
+	}
+	return fmt.Sprintf("%.2f", sum)
+}
+
+type Customer struct {
+	Name  string
+	Email string
+}
+
+type Line struct {
+	Title    string
+	Quantity int
+	Price    float64
+}
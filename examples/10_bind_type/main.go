@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/bellwood4486/tmpltype/examples/10_bind_type/domain"
+)
+
+func main() {
+	fmt.Println("=== Example: Binding Existing Types ===")
+
+	order := domain.Order{
+		ID:       1001,
+		Customer: domain.Customer{Name: "Alice", Email: "alice@example.com"},
+		Lines: []domain.Line{
+			{Title: "Coffee", Quantity: 2, Price: 3.5},
+			{Title: "Bagel", Quantity: 1, Price: 2.25},
+		},
+	}
+
+	// The order template takes domain.Order directly
+	var buf bytes.Buffer
+	if err := RenderOrder(&buf, order); err != nil {
+		fmt.Println("render error:", err)
+		return
+	}
+	fmt.Println(buf.String())
+
+	// A template that passes .Order to the bound template gets a domain.Order field
+	buf.Reset()
+	if err := RenderNotification(&buf, Notification{Subject: "Your order", Order: order}); err != nil {
+		fmt.Println("render error:", err)
+		return
+	}
+	fmt.Println(buf.String())
+}
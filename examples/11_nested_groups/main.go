@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+)
+
+func main() {
+	fmt.Println("=== Example: Nested Template Groups ===")
+	fmt.Println()
+
+	// Templates in mail/account/created/ become MailAccountCreated*
+	fmt.Println("--- Mail Account Created ---")
+	var title, content bytes.Buffer
+	_ = RenderMailAccountCreatedTitle(&title, MailAccountCreatedTitle{
+		SiteName: "MyApp",
+		Username: "bob123",
+	})
+	fmt.Println("Title:", title.String())
+
+	_ = RenderMailAccountCreatedContent(&content, MailAccountCreatedContent{
+		Username:  "bob123",
+		Email:     "bob@example.com",
+		Signature: MailSignature{Team: "MyApp"},
+	})
+	fmt.Println("Content:")
+	fmt.Println(content.String())
+
+	// The Template namespace mirrors the directory hierarchy
+	fmt.Println("--- Mail Account Deleted (via generic Render) ---")
+	var deleted bytes.Buffer
+	_ = Render(&deleted, Template.Mail.Account.Deleted.Title, MailAccountDeletedTitle{
+		SiteName: "MyApp",
+	})
+	fmt.Println("Title:", deleted.String())
+
+	// Show all available templates
+	fmt.Println("--- Available Templates ---")
+	var names []string
+	for name := range Templates() {
+		names = append(names, string(name))
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Printf("  - %s\n", name)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/bellwood4486/tmpltype/examples/12_config/mail"
+	"github.com/bellwood4486/tmpltype/examples/12_config/web"
+)
+
+func main() {
+	fmt.Println("=== Example: Config File with Multiple Targets ===")
+	fmt.Println()
+
+	// Target "mail": text/template, mail/templates/drafts/ is excluded
+	fmt.Println("--- mail (text/template) ---")
+	var mailBuf bytes.Buffer
+	_ = mail.RenderWelcome(&mailBuf, mail.Welcome{
+		SiteName: "MyApp",
+		User:     mail.WelcomeUser{Name: "<Alice>"},
+	})
+	fmt.Print(mailBuf.String())
+	fmt.Println()
+
+	// Target "web": html/template, selected automatically from *.html.tmpl
+	fmt.Println("--- web (html/template) ---")
+	var webBuf bytes.Buffer
+	_ = web.RenderProfile(&webBuf, web.Profile{
+		Name: "<Alice>",
+		Bio:  "Gopher since 2012",
+	})
+	fmt.Print(webBuf.String())
+}
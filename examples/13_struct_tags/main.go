@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// payload は他のサービスからキュー経由で届く snake_case の JSON
+const payload = `{
+  "customer": {"full_name": "Alice", "email": "alice@example.com"},
+  "order": {"order_id": "A-1001", "total": 42.5},
+  "items": [
+    {"sku": "BOOK-1", "quantity": 2},
+    {"sku": "PEN-9", "quantity": 1}
+  ]
+}`
+
+func main() {
+	fmt.Println("=== Example: Struct Tags ===")
+
+	// json タグ付きの生成型にそのまま Unmarshal できる
+	var p OrderShipped
+	if err := json.Unmarshal([]byte(payload), &p); err != nil {
+		fmt.Println("unmarshal error:", err)
+		return
+	}
+	if err := RenderOrderShipped(os.Stdout, p); err != nil {
+		fmt.Println("render error:", err)
+	}
+}
@@ -0,0 +1,3 @@
+package main
+
+//go:generate go run ../../cmd/tmpltype -dir templates -pkg main -out template_gen.go -required
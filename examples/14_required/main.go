@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+func main() {
+	fmt.Println("=== Example: Required Fields ===")
+
+	// すべての必須フィールドがあれば通常どおり描画する
+	err := RenderPasswordReset(os.Stdout, PasswordReset{
+		User:      PasswordResetUser{Name: "Alice"},
+		ExpiresIn: 30,
+		ResetURL:  "https://example.com/reset?token=abc",
+		Devices:   []PasswordResetDevicesItem{{Name: "Firefox on Linux"}},
+	})
+	if err != nil {
+		fmt.Println("render error:", err)
+	}
+
+	// 未設定の必須フィールドは描画の前にまとめて報告する
+	err = RenderPasswordReset(os.Stdout, PasswordReset{
+		User:    PasswordResetUser{Nickname: "ali"},
+		Devices: []PasswordResetDevicesItem{{Name: "Safari on iOS"}, {}},
+	})
+	var missing *MissingFieldsError
+	if errors.As(err, &missing) {
+		fmt.Println("render error:", err)
+		fmt.Println("missing fields:", missing.Fields)
+	}
+}
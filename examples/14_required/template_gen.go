@@ -0,0 +1,362 @@
+// Code generated by tmpltype; DO NOT EDIT.
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// TemplateName is a type-safe template name
+type TemplateName string
+
+// Template provides type-safe access to template names
+var Template = struct {
+	PasswordReset TemplateName
+}{
+	PasswordReset: "password_reset",
+}
+
+//go:embed templates/password_reset.tmpl
+var password_resetTplSource string
+
+// templateFiles lists the templates in parse order, with their paths relative to the package directory
+var templateFiles = []struct {
+	name   TemplateName
+	path   string
+	source string
+}{
+	{Template.PasswordReset, "templates/password_reset.tmpl", password_resetTplSource},
+}
+
+// parseTemplates parses the templates in deps, in parse order, into a new set and returns the one named name
+func parseTemplates(name TemplateName, deps []TemplateName) (*template.Template, error) {
+	set := template.New("").Option("missingkey=error")
+	for _, f := range templateFiles {
+		if !slices.Contains(deps, f.name) {
+			continue
+		}
+		if _, err := set.New(string(f.name)).Parse(f.source); err != nil {
+			return nil, err
+		}
+	}
+	tmpl := set.Lookup(string(name))
+	if tmpl == nil {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	return tmpl, nil
+}
+
+// lazyTemplate parses a template on first use
+type lazyTemplate struct {
+	name TemplateName
+	deps []TemplateName // the template and the ones defining the templates it calls
+	once sync.Once
+	tmpl *template.Template
+	err  error
+}
+
+func (l *lazyTemplate) load() (*template.Template, error) {
+	l.once.Do(func() {
+		l.tmpl, l.err = parseTemplates(l.name, l.deps)
+	})
+	return l.tmpl, l.err
+}
+
+var templates = map[TemplateName]*lazyTemplate{
+	Template.PasswordReset: {name: Template.PasswordReset, deps: []TemplateName{Template.PasswordReset}},
+}
+
+// Templates returns a map of all templates, parsing the ones not used yet
+// Templates that fail to parse are left out; ParseAll reports their errors
+func Templates() map[TemplateName]*template.Template {
+	m := make(map[TemplateName]*template.Template, len(templates))
+	for name, l := range templates {
+		if tmpl, err := l.load(); err == nil {
+			m[name] = tmpl
+		}
+	}
+	return m
+}
+
+// ParseAll parses all templates and returns their parse errors joined
+// Templates are otherwise parsed on first use; call it at startup to fail early
+func ParseAll() error {
+	var errs []error
+	seen := make(map[string]bool) // the templates calling a broken template repeat its error
+	for _, f := range templateFiles {
+		if _, err := templates[f.name].load(); err != nil && !seen[err.Error()] {
+			seen[err.Error()] = true
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// hotReloader reads the templates from disk and reparses them when a file changes
+type hotReloader struct {
+	dir      string
+	mu       sync.Mutex
+	modTimes []time.Time
+	set      *template.Template // never executed, so that it can be cloned
+}
+
+// hotReload is set by EnableHotReload
+var hotReload atomic.Pointer[hotReloader]
+
+// EnableHotReload makes Render and the RenderXxx functions read the templates from the files
+// under dir, the directory of this package, and reparse them whenever a file's modification time changes
+// It is meant for local development; without it, the embedded templates are parsed once, on first use
+// Templates still returns the embedded templates
+func EnableHotReload(dir string) error {
+	r := &hotReloader{dir: dir}
+	if _, err := r.templateSet(); err != nil {
+		return err
+	}
+	hotReload.Store(r)
+	return nil
+}
+
+// templateSet returns the template set, reparsing it if a template file has changed since the last call
+func (r *hotReloader) templateSet() (*template.Template, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	modTimes := make([]time.Time, len(templateFiles))
+	for i, f := range templateFiles {
+		info, err := os.Stat(filepath.Join(r.dir, f.path))
+		if err != nil {
+			return nil, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	if r.set != nil && slices.EqualFunc(modTimes, r.modTimes, time.Time.Equal) {
+		return r.set, nil
+	}
+	set := template.New("").Option("missingkey=error")
+	for _, f := range templateFiles {
+		src, err := os.ReadFile(filepath.Join(r.dir, f.path))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := set.New(string(f.name)).Parse(string(src)); err != nil {
+			return nil, err
+		}
+	}
+	r.set, r.modTimes = set, modTimes
+	return set, nil
+}
+
+// lookup returns the named template from a copy of the current template set
+func (r *hotReloader) lookup(name TemplateName) (*template.Template, error) {
+	set, err := r.templateSet()
+	if err != nil {
+		return nil, err
+	}
+	if set, err = set.Clone(); err != nil {
+		return nil, err
+	}
+	tmpl := set.Lookup(string(name))
+	if tmpl == nil {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	return tmpl, nil
+}
+
+// lookupTemplate returns the named template, reloaded from disk if hot reload is enabled
+func lookupTemplate(name TemplateName) (*template.Template, error) {
+	if r := hotReload.Load(); r != nil {
+		return r.lookup(name)
+	}
+	l, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	return l.load()
+}
+
+// Render renders a template by name with the given data
+func Render(w io.Writer, name TemplateName, data any) error {
+	tmpl, err := lookupTemplate(name)
+	if err != nil {
+		return err
+	}
+	switch d := data.(type) {
+	case PasswordReset:
+		err = d.Validate()
+	case *PasswordReset:
+		if d != nil {
+			err = d.Validate()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, data)
+}
+
+// bufferPool holds the buffers used by the RenderXxxString and RenderXxxBytes functions
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > 65536 { // do not keep large buffers alive
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// contextWriter stops writing once ctx is done
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw *contextWriter) Write(b []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(b)
+}
+
+// MissingFieldsError is returned by Validate when required fields are not set
+type MissingFieldsError struct {
+	Template TemplateName
+	Fields   []string // paths of the missing fields (e.g. "User.Email", "Items[0].ID")
+}
+
+func (e *MissingFieldsError) Error() string {
+	return fmt.Sprintf("template %q: missing required fields: %s", e.Template, strings.Join(e.Fields, ", "))
+}
+
+// ============================================================
+// password_reset template
+// ============================================================
+
+type PasswordResetDevicesItem struct {
+	Name string
+}
+
+func (p PasswordResetDevicesItem) validate(path string, missing *[]string) {
+	if p.Name == "" {
+		*missing = append(*missing, path+"Name")
+	}
+}
+
+type PasswordResetUser struct {
+	Name     string
+	Nickname string
+}
+
+func (p PasswordResetUser) validate(path string, missing *[]string) {
+	if p.Name == "" {
+		*missing = append(*missing, path+"Name")
+	}
+}
+
+// PasswordReset represents parameters for password_reset template
+type PasswordReset struct {
+	Devices   []PasswordResetDevicesItem
+	ExpiresIn int
+	ResetURL  string
+	User      PasswordResetUser
+}
+
+// Validate reports the required fields of PasswordReset that are not set
+func (p PasswordReset) Validate() error {
+	var missing []string
+	p.validate("", &missing)
+	if len(missing) > 0 {
+		return &MissingFieldsError{Template: Template.PasswordReset, Fields: missing}
+	}
+	return nil
+}
+
+func (p PasswordReset) validate(path string, missing *[]string) {
+	if len(p.Devices) == 0 {
+		*missing = append(*missing, path+"Devices")
+	}
+	for i, v := range p.Devices {
+		v.validate(fmt.Sprintf("%sDevices[%d].", path, i), missing)
+	}
+	if p.ExpiresIn == 0 {
+		*missing = append(*missing, path+"ExpiresIn")
+	}
+	if p.ResetURL == "" {
+		*missing = append(*missing, path+"ResetURL")
+	}
+	p.User.validate(path+"User.", missing)
+}
+
+// RenderPasswordReset renders the password_reset template
+func RenderPasswordReset(w io.Writer, p PasswordReset) error {
+	tmpl, err := lookupTemplate(Template.PasswordReset)
+	if err != nil {
+		return err
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	return tmpl.Execute(w, p)
+}
+
+// RenderPasswordResetContext renders the password_reset template, stopping once ctx is done
+// If ctx is done before or during rendering, it returns ctx.Err()
+func RenderPasswordResetContext(ctx context.Context, w io.Writer, p PasswordReset) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmpl, err := lookupTemplate(Template.PasswordReset)
+	if err != nil {
+		return err
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if err := tmpl.Execute(&contextWriter{ctx: ctx, w: w}, p); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// RenderPasswordResetString renders the password_reset template and returns the output as a string
+// On error, it returns an empty string and discards any partial output
+func RenderPasswordResetString(p PasswordReset) (string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderPasswordReset(buf, p); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderPasswordResetBytes renders the password_reset template and returns the output as a byte slice
+// On error, it returns nil and discards any partial output
+func RenderPasswordResetBytes(p PasswordReset) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := RenderPasswordReset(buf, p); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil // the buffer is reused
+}
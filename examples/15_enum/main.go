@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	fmt.Println("=== Example: Enum Directive ===")
+
+	// @enum のフィールドは生成された定数で設定する
+	err := RenderOrderStatus(os.Stdout, OrderStatus{
+		ID:     1042,
+		Status: OrderStatusStatusShipped,
+		Items: []OrderStatusItemsItem{
+			{Name: "Go in Practice", Kind: OrderStatusItemsKindEBook},
+			{Name: "Coffee Shop", Kind: OrderStatusItemsKindGiftCard},
+		},
+	})
+	if err != nil {
+		fmt.Println("render error:", err)
+	}
+
+	// 外部から受け取った値は IsValid で確かめられる
+	for _, s := range []string{"cancelled", "lost"} {
+		fmt.Printf("%q is a valid status: %v\n", s, OrderStatusStatus(s).IsValid())
+	}
+}
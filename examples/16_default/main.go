@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	fmt.Println("=== Example: Default Values ===")
+
+	// 未設定のフィールドには @default の値が使われる
+	err := RenderNewsletter(os.Stdout, Newsletter{
+		Articles: []NewsletterArticlesItem{
+			{Title: "Release notes"},
+			{Title: "Deep dive into templates", Minutes: 12},
+		},
+	})
+	if err != nil {
+		fmt.Println("render error:", err)
+	}
+
+	// 設定したフィールドはそのまま使われる
+	err = RenderNewsletter(os.Stdout, Newsletter{
+		SiteName:    "Example",
+		MaxArticles: 10,
+		Format:      NewsletterFormatHtml,
+	})
+	if err != nil {
+		fmt.Println("render error:", err)
+	}
+
+	// WithDefaults で既定値を設定した値を取り出せる
+	p := Newsletter{}.WithDefaults()
+	fmt.Printf("defaults: SiteName=%q MaxArticles=%d Format=%q\n", p.SiteName, p.MaxArticles, p.Format)
+}
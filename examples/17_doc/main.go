@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	fmt.Println("=== Example: Documentation ===")
+
+	// フィールドにカーソルを合わせると @doc の説明が表示される
+	err := RenderInvoice(os.Stdout, Invoice{
+		Customer: InvoiceCustomer{Name: "Alice"},
+		Items: []InvoiceItemsItem{
+			{Description: "Pro plan", Amount: 1200},
+			{Description: "Extra seats", Amount: 800},
+		},
+		DueDays: 14,
+	})
+	if err != nil {
+		fmt.Println("render error:", err)
+	}
+}
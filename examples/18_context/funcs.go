@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"text/template"
+)
+
+// localeKey is the context key of the recipient's locale
+type localeKey struct{}
+
+// templateFuncs is installed into the generated templates via -funcs
+var templateFuncs = template.FuncMap{
+	"greeting": greeting,
+}
+
+// greeting reads the locale from the render context (passed by the context template func)
+func greeting(ctx context.Context) string {
+	if locale, _ := ctx.Value(localeKey{}).(string); locale == "ja" {
+		return "こんにちは"
+	}
+	return "Hello"
+}
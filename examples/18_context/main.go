@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+func main() {
+	fmt.Println("=== Example: Context-Aware Rendering ===")
+	fmt.Println()
+
+	p := Notification{Name: "Alice", Events: []string{"Build passed", "Deploy started"}}
+
+	// The context template func returns the ctx passed to RenderXxxContext
+	fmt.Println("--- Locale from the context ---")
+	ctx := context.WithValue(context.Background(), localeKey{}, "ja")
+	if err := RenderNotificationContext(ctx, os.Stdout, p); err != nil {
+		fmt.Println("render error:", err)
+	}
+	fmt.Println()
+
+	// A cancelled or expired context stops rendering and returns ctx.Err()
+	fmt.Println("--- Expired deadline ---")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	err := RenderNotificationContext(ctx, os.Stdout, p)
+	fmt.Println("deadline exceeded:", errors.Is(err, context.DeadlineExceeded))
+}
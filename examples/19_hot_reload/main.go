@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+func main() {
+	dev := flag.Bool("dev", false, "reload templates/*.tmpl from disk when they change")
+	flag.Parse()
+
+	fmt.Println("=== Example: Hot Reload ===")
+	fmt.Println()
+
+	p := Status{Service: "api", State: "healthy"}
+
+	if !*dev {
+		// Production: the embedded templates, parsed once
+		s, err := RenderStatusString(p)
+		if err != nil {
+			fmt.Println("render error:", err)
+			return
+		}
+		fmt.Print(s)
+		return
+	}
+
+	// Development: read the templates from this directory and reparse them on change
+	if err := EnableHotReload("."); err != nil {
+		fmt.Println("hot reload:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Edit templates/status.tmpl; press Ctrl+C to stop")
+	var last string
+	for {
+		s, err := RenderStatusString(p)
+		if err != nil {
+			s = "render error: " + err.Error() + "\n"
+		}
+		if s != last {
+			fmt.Print(s)
+			last = s
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/bellwood4486/tmpltype
 
 go 1.25.1
+
+require gopkg.in/yaml.v3 v3.0.1
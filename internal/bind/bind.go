@@ -0,0 +1,178 @@
+package bind
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+)
+
+// Type はテンプレートに結び付ける既存の Go 型を表す
+type Type struct {
+	ImportPath string     // 型を定義するパッケージ（空なら出力パッケージ）
+	PkgName    string     // 型を定義するパッケージの名前
+	Name       string     // 型名（例: "OrderView"）
+	Type       types.Type // 読み込んだ型
+}
+
+// Expr は生成コードから型を参照する式を返す（例: "OrderView", "domain.OrderView"）
+func (t *Type) Expr() string {
+	if t.ImportPath == "" {
+		return t.Name
+	}
+	return t.PkgName + "." + t.Name
+}
+
+// Loader は型を読み込む。読み込んだパッケージはキャッシュされる
+type Loader struct {
+	dir  string // 出力パッケージのディレクトリ（import パス解決の基点）
+	fset *token.FileSet
+	imp  types.ImporterFrom
+	pkgs map[string]*types.Package // import パス（出力パッケージは ""）-> パッケージ
+}
+
+// NewLoader は dir を出力パッケージのディレクトリとする Loader を返す
+func NewLoader(dir string) *Loader {
+	if dir == "" {
+		dir = "."
+	}
+	fset := token.NewFileSet()
+	return &Loader{
+		dir:  dir,
+		fset: fset,
+		imp:  importer.ForCompiler(fset, "source", nil).(types.ImporterFrom),
+		pkgs: make(map[string]*types.Package),
+	}
+}
+
+// ParseRef は "Name" または "import/path.Name" 形式の参照を import パスと型名に分ける
+func ParseRef(ref string) (importPath, name string, err error) {
+	name = ref
+	if i := strings.LastIndex(ref, "."); i >= 0 {
+		importPath, name = ref[:i], ref[i+1:]
+	}
+	if !token.IsIdentifier(name) || (importPath == "" && strings.Contains(ref, ".")) {
+		return "", "", fmt.Errorf("invalid type reference %q (want Name or import/path.Name)", ref)
+	}
+	return importPath, name, nil
+}
+
+// Load は参照が指す型を読み込む
+func (l *Loader) Load(ref string) (*Type, error) {
+	importPath, name, err := ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg, err := l.loadPackage(importPath)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := pkg.Scope().Lookup(name)
+	if obj == nil {
+		return nil, fmt.Errorf("type %s not found in package %s", name, pkgLabel(pkg, importPath))
+	}
+	tn, ok := obj.(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("%s in package %s is not a type", name, pkgLabel(pkg, importPath))
+	}
+	if importPath != "" && !tn.Exported() {
+		return nil, fmt.Errorf("type %s in package %s is not exported", name, importPath)
+	}
+	if named, ok := tn.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+		return nil, fmt.Errorf("generic type %s is not supported", ref)
+	}
+
+	return &Type{
+		ImportPath: importPath,
+		PkgName:    pkg.Name(),
+		Name:       name,
+		Type:       tn.Type(),
+	}, nil
+}
+
+// loadPackage はパッケージを型検査して返す
+func (l *Loader) loadPackage(importPath string) (*types.Package, error) {
+	if pkg, ok := l.pkgs[importPath]; ok {
+		return pkg, nil
+	}
+
+	var pkg *types.Package
+	var err error
+	if importPath == "" {
+		pkg, err = l.loadLocalPackage()
+	} else {
+		pkg, err = l.imp.ImportFrom(importPath, l.absDir(), 0)
+		if err != nil {
+			err = fmt.Errorf("failed to load package %s: %w", importPath, err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l.pkgs[importPath] = pkg
+	return pkg, nil
+}
+
+// loadLocalPackage は出力パッケージを型検査する
+// 出力パッケージは生成コードを参照していることが多いので、tmpltype の生成ファイルを除外し、
+// 型エラーは無視する（宣言された型は型エラーがあっても得られる）
+func (l *Loader) loadLocalPackage() (*types.Package, error) {
+	bp, err := build.ImportDir(l.dir, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load package in %s: %w", l.dir, err)
+	}
+
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(l.fset, filepath.Join(l.dir, name), nil, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		if isGeneratedByTmpltype(f) {
+			continue
+		}
+		files = append(files, f)
+	}
+
+	conf := types.Config{
+		Importer: l.imp,
+		Error:    func(error) {},
+	}
+	pkg, _ := conf.Check(bp.Name, l.fset, files, nil)
+	return pkg, nil
+}
+
+func (l *Loader) absDir() string {
+	if abs, err := filepath.Abs(l.dir); err == nil {
+		return abs
+	}
+	return l.dir
+}
+
+// isGeneratedByTmpltype は tmpltype が生成したファイルかどうかを返す
+func isGeneratedByTmpltype(f *ast.File) bool {
+	if !ast.IsGenerated(f) {
+		return false
+	}
+	for _, cg := range f.Comments {
+		if strings.Contains(cg.Text(), "Code generated by tmpltype") {
+			return true
+		}
+	}
+	return false
+}
+
+func pkgLabel(pkg *types.Package, importPath string) string {
+	if importPath == "" {
+		return pkg.Name()
+	}
+	return importPath
+}
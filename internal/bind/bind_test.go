@@ -0,0 +1,142 @@
+package bind
+
+import (
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+)
+
+const domainPath = "github.com/bellwood4486/tmpltype/internal/bind/testdata/domain"
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		ref      string
+		wantPath string
+		wantName string
+	}{
+		{"OrderView", "", "OrderView"},
+		{"github.com/acme/app/domain.OrderView", "github.com/acme/app/domain", "OrderView"},
+	}
+
+	for _, tt := range tests {
+		path, name, err := ParseRef(tt.ref)
+		if err != nil {
+			t.Fatalf("ParseRef(%q): unexpected error: %v", tt.ref, err)
+		}
+		if path != tt.wantPath || name != tt.wantName {
+			t.Errorf("ParseRef(%q) = (%q, %q), want (%q, %q)", tt.ref, path, name, tt.wantPath, tt.wantName)
+		}
+	}
+
+	for _, ref := range []string{"", ".OrderView", "domain.", "domain.1abc"} {
+		if _, _, err := ParseRef(ref); err == nil {
+			t.Errorf("ParseRef(%q): expected error, got nil", ref)
+		}
+	}
+}
+
+func TestLoader_Load(t *testing.T) {
+	l := NewLoader(".")
+
+	typ, err := l.Load(domainPath + ".OrderView")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ.Expr() != "domain.OrderView" || typ.ImportPath != domainPath {
+		t.Errorf("unexpected type: %+v", typ)
+	}
+
+	for _, ref := range []string{
+		domainPath + ".Missing",   // 存在しない
+		domainPath + ".Shipped",   // 型ではない
+		domainPath + ".Page",      // ジェネリック型
+		"example.invalid/nopkg.X", // パッケージが見つからない
+	} {
+		if _, err := l.Load(ref); err == nil {
+			t.Errorf("Load(%q): expected error, got nil", ref)
+		}
+	}
+}
+
+func TestLoader_LoadLocal_SkipsGeneratedFile(t *testing.T) {
+	typ, err := NewLoader("testdata/local").Load("PageView")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ.Expr() != "PageView" {
+		t.Errorf("Expr() = %q", typ.Expr())
+	}
+	st, ok := typ.Type.Underlying().(*types.Struct)
+	if !ok || st.NumFields() != 1 || st.Field(0).Name() != "Title" {
+		t.Errorf("PageView was loaded from the generated file: %v", typ.Type)
+	}
+}
+
+func scanSet(t *testing.T, sources ...scan.Source) map[string]scan.Schema {
+	t.Helper()
+	schemas, err := scan.ScanTemplateSet(sources, scan.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schemas
+}
+
+func TestCheck_Compatible(t *testing.T) {
+	typ, err := NewLoader(".").Load(domainPath + ".OrderView")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schemas := scanSet(t,
+		scan.Source{Name: "order", Src: `
+{{ .ID }} {{ .CreatedAt }} {{ .Total }} {{ .Note }}
+{{ with .Customer }}{{ .Name }} {{ .Display }}{{ end }}
+{{ range .Items }}{{ .Title }} {{ .Price }}{{ end }}
+{{ index .Meta "k" }} {{ .Meta.foo }}
+{{ .Extra.Anything.Goes }}
+{{ template "customer" .Customer }}
+`},
+		scan.Source{Name: "customer", Src: `{{ .Email }}`},
+	)
+
+	if errs := Check(schemas, "order", typ.Type); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestCheck_Mismatches(t *testing.T) {
+	typ, err := NewLoader(".").Load(domainPath + ".OrderView")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schemas := scanSet(t,
+		scan.Source{Name: "order", File: "templates/order.tmpl", Src: `{{ .Missing }}
+{{ .secret }}
+{{ range .Note }}{{ end }}
+{{ range .Items }}{{ .SKU }}{{ end }}
+{{ .Refresh }} {{ .Pair }}
+{{ template "customer" .Customer }}`},
+		scan.Source{Name: "customer", File: "templates/customer.tmpl", Src: `{{ .Phone }}`},
+	)
+
+	errs := Check(schemas, "order", typ.Type)
+	want := []string{
+		"templates/customer.tmpl:1:4: domain.Customer has no field or method Phone",
+		"templates/order.tmpl:1:4: domain.OrderView has no field or method Missing",
+		"templates/order.tmpl:2:4: secret is not an exported field or method of domain.OrderView",
+		"templates/order.tmpl:3:10: cannot range over Note (type string)",
+		"templates/order.tmpl:4:22: domain.OrderItem has no field or method SKU",
+		"templates/order.tmpl:5:4: method Refresh of domain.OrderView has a pointer receiver and cannot be called on a value",
+		"templates/order.tmpl:5:19: method Pair of domain.OrderView must return one value, or a value and an error",
+	}
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Error())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,216 @@
+package bind
+
+import (
+	"cmp"
+	"fmt"
+	"go/token"
+	"go/types"
+	"maps"
+	"slices"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+)
+
+// Error はテンプレートのフィールド参照と Go の型の不一致を表す
+// 位置は最初に参照された位置（不明ならゼロ値）
+type Error = scan.Error
+
+// Check はテンプレート name のスキーマを型 t に対して検証し、不一致をすべて返す
+// {{ template "x" .Foo }} や {{ template "x" . }} で渡される先のテンプレートも、渡される値の型で検証する
+// 返すエラーは位置順に並ぶ
+func Check(schemas map[string]scan.Schema, name string, t types.Type) []*Error {
+	c := &checker{
+		schemas: schemas,
+		seen:    make(map[string]bool),
+	}
+	c.checkTemplate(name, t)
+
+	slices.SortStableFunc(c.errs, func(a, b *Error) int {
+		return cmp.Or(
+			cmp.Compare(a.Pos.File, b.Pos.File),
+			cmp.Compare(a.Pos.Line, b.Pos.Line),
+			cmp.Compare(a.Pos.Col, b.Pos.Col),
+		)
+	})
+	return c.errs
+}
+
+type checker struct {
+	schemas map[string]scan.Schema
+	seen    map[string]bool // 検証済みの テンプレート名+型（再帰対策）
+	errs    []*Error
+}
+
+// checkTemplate はテンプレートのトップレベルのフィールドと埋め込みを型 t に対して検証する
+func (c *checker) checkTemplate(name string, t types.Type) {
+	key := name + "\x00" + t.String()
+	if c.seen[key] {
+		return
+	}
+	c.seen[key] = true
+
+	schema, ok := c.schemas[name]
+	if !ok {
+		return
+	}
+	c.checkFields(schema.Fields, t, false)
+	for _, e := range schema.Embeds {
+		c.checkTemplate(e, t)
+	}
+}
+
+// checkFields は構造体の子フィールドを型 t に対して検証する
+// addressable はテンプレート実行時に値がアドレス可能か（ポインタレシーバのメソッドを呼べるか）を表す
+func (c *checker) checkFields(fields map[string]*scan.Field, t types.Type, addressable bool) {
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		f := fields[name]
+		ft, addr, ok := c.lookup(f, name, t, addressable)
+		if !ok {
+			continue
+		}
+		c.checkField(f, ft, addr)
+	}
+}
+
+// checkField はフィールドの推論された種別を型 t に対して検証する
+// t が nil（interface など実行時まで分からない）の場合は検証しない
+func (c *checker) checkField(f *scan.Field, t types.Type, addressable bool) {
+	if t == nil {
+		return
+	}
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		t, addressable = p.Elem(), true
+	}
+	if isInterface(t) {
+		return
+	}
+
+	switch f.Kind {
+	case scan.KindStruct:
+		c.checkFields(f.Children, t, addressable)
+		for _, e := range f.Embeds {
+			c.checkTemplate(e, t)
+		}
+	case scan.KindSlice:
+		elem, addr, ok := rangeElem(t, addressable)
+		if !ok {
+			c.errorf(f, "cannot range over %s (type %s)", f.Name, typeString(t))
+			return
+		}
+		if f.Elem != nil {
+			c.checkField(f.Elem, elem, addr)
+		}
+	case scan.KindMap:
+		elem, ok := indexElem(t)
+		if !ok {
+			c.errorf(f, "cannot index %s (type %s)", f.Name, typeString(t))
+			return
+		}
+		if f.Elem != nil {
+			c.checkField(f.Elem, elem, false)
+		}
+	case scan.KindTemplate:
+		c.checkTemplate(f.Template, t)
+	}
+}
+
+// lookup は型 t の name（.Name で参照されるフィールド、メソッド、またはマップのキー）の型を返す
+func (c *checker) lookup(f *scan.Field, name string, t types.Type, addressable bool) (types.Type, bool, bool) {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		t, addressable = p.Elem(), true
+	}
+	if isInterface(t) {
+		return nil, false, true
+	}
+	// text/template と同様、マップのキーとして扱う
+	if m, ok := t.Underlying().(*types.Map); ok {
+		if b, ok := m.Key().Underlying().(*types.Basic); ok && b.Info()&types.IsString != 0 {
+			return m.Elem(), false, true
+		}
+		c.errorf(f, "cannot access %s: map key type of %s is not string", name, typeString(t))
+		return nil, false, false
+	}
+
+	if !token.IsExported(name) {
+		c.errorf(f, "%s is not an exported field or method of %s", name, typeString(t))
+		return nil, false, false
+	}
+
+	obj, _, _ := types.LookupFieldOrMethod(t, addressable, nil, name)
+	switch obj := obj.(type) {
+	case *types.Var:
+		return obj.Type(), addressable, true
+	case *types.Func:
+		sig := obj.Type().(*types.Signature)
+		res := sig.Results()
+		if res.Len() == 1 || (res.Len() == 2 && isError(res.At(1).Type())) {
+			return res.At(0).Type(), false, true
+		}
+		c.errorf(f, "method %s of %s must return one value, or a value and an error", name, typeString(t))
+		return nil, false, false
+	}
+
+	if obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name); obj != nil && !addressable {
+		c.errorf(f, "method %s of %s has a pointer receiver and cannot be called on a value", name, typeString(t))
+		return nil, false, false
+	}
+	c.errorf(f, "%s has no field or method %s", typeString(t), name)
+	return nil, false, false
+}
+
+func (c *checker) errorf(f *scan.Field, format string, args ...any) {
+	e := &Error{Msg: fmt.Sprintf(format, args...)}
+	if len(f.Refs) > 0 {
+		e.Pos = f.Refs[0]
+	}
+	c.errs = append(c.errs, e)
+}
+
+// rangeElem は {{ range }} で得られる要素の型を返す
+func rangeElem(t types.Type, addressable bool) (types.Type, bool, bool) {
+	switch u := t.Underlying().(type) {
+	case *types.Slice:
+		return u.Elem(), true, true
+	case *types.Array:
+		return u.Elem(), addressable, true
+	case *types.Map:
+		return u.Elem(), false, true
+	case *types.Chan:
+		return u.Elem(), false, true
+	case *types.Basic:
+		if u.Info()&types.IsInteger != 0 {
+			return u, false, true
+		}
+	case *types.Signature:
+		// range-over-func（iter.Seq など）は要素の型を検証しない
+		return nil, false, true
+	}
+	return nil, false, false
+}
+
+// indexElem は {{ index .X "key" }} で得られる要素の型を返す
+func indexElem(t types.Type) (types.Type, bool) {
+	switch u := t.Underlying().(type) {
+	case *types.Map:
+		return u.Elem(), true
+	case *types.Slice:
+		return u.Elem(), true
+	case *types.Array:
+		return u.Elem(), true
+	}
+	return nil, false
+}
+
+func isInterface(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Interface)
+	return ok
+}
+
+func isError(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
+
+// typeString はパッケージ名で修飾した型の文字列を返す
+func typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string { return p.Name() })
+}
@@ -0,0 +1,7 @@
+// Package bind はテンプレートを既存の Go 型に結び付けます。
+//
+// @type ディレクティブで指定された型を go/types で読み込み、
+// scan が収集したフィールドパス（with/range/index を含む）がその型に
+// 実際に存在し、テンプレートからアクセスできるかを検証します。
+// 型は "Name"（出力パッケージ内）または "import/path.Name"（別パッケージ）で指定します。
+package bind
@@ -0,0 +1,44 @@
+package domain
+
+import "time"
+
+type Base struct {
+	CreatedAt time.Time
+}
+
+type OrderView struct {
+	Base
+	ID       int64
+	Customer Customer
+	Items    []OrderItem
+	Meta     map[string]string
+	Note     *string
+	Extra    any
+	secret   string
+}
+
+func (o OrderView) Total() float64 { return 0 }
+
+func (o *OrderView) Refresh() string { return o.secret }
+
+func (o OrderView) Pair() (int, int) { return 0, 0 }
+
+type Customer struct {
+	Name  string
+	Email string
+}
+
+func (c Customer) Display() (string, error) { return c.Name, nil }
+
+type OrderItem struct {
+	Title string
+	Price float64
+}
+
+type Status string
+
+const Shipped Status = "shipped"
+
+type Page[T any] struct {
+	Items []T
+}
@@ -0,0 +1,8 @@
+package local
+
+type PageView struct {
+	Title string
+}
+
+// 生成コードの関数を参照していても型は読み込める
+var _ = RenderPage
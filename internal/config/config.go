@@ -0,0 +1,301 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFiles は -config を省略したときにカレントディレクトリで探す設定ファイル名（優先順）
+var DefaultFiles = []string{"tmpltype.yaml", "tmpltype.yml", "tmpltype.json"}
+
+// DefaultInclude は include を省略したときに対象とするテンプレートファイルのパターン
+var DefaultInclude = []string{"**/*.tmpl"}
+
+// Config は tmpltype.yaml / tmpltype.json の内容
+type Config struct {
+	Targets []Target `json:"targets" yaml:"targets"`
+}
+
+// Target は1つの生成対象（1つの出力ファイル）の設定
+// パスは設定ファイルのディレクトリからの相対パス（Load で解決済み）
+type Target struct {
+	Name    string   `json:"name" yaml:"name"`       // エラー表示などに使う名前（省略時は out）
+	Dir     string   `json:"dir" yaml:"dir"`         // テンプレートディレクトリ（必須）
+	Pkg     string   `json:"pkg" yaml:"pkg"`         // 出力パッケージ名（必須）
+	Out     string   `json:"out" yaml:"out"`         // 出力 .go ファイルパス（必須）
+	Include []string `json:"include" yaml:"include"` // 対象とするファイルの glob（dir からの相対パス、省略時は DefaultInclude）
+	Exclude []string `json:"exclude" yaml:"exclude"` // 除外するファイルの glob（dir からの相対パス）
+	Mode    string   `json:"mode" yaml:"mode"`       // text または html（省略時は拡張子から自動判定）
+	Funcs   string   `json:"funcs" yaml:"funcs"`     // テンプレートに組み込む FuncMap 変数（Name または import/path.Name）
+	Tags    []string `json:"tags" yaml:"tags"`       // フィールド名から作る構造体タグのキー（例: json, yaml）
+	TagCase string   `json:"tagcase" yaml:"tagcase"` // タグの値にするフィールド名の形式: snake（既定）または camel
+	// Required はポインタ、bool、入れ子の構造体以外のフィールドをすべて必須にする（@optional で除外できる）
+	Required bool `json:"required" yaml:"required"`
+
+	JSONSchema string `json:"jsonschema" yaml:"jsonschema"` // テンプレートごとの JSON Schema を出力するディレクトリ（省略時は出力しない）
+	TS         string `json:"ts" yaml:"ts"`                 // TypeScript の型定義を出力する .d.ts ファイルパス（省略時は出力しない）
+}
+
+// Label はエラー表示に使うターゲットの名前を返す（name、out、dir の順）
+func (t Target) Label() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	if t.Out != "" {
+		return t.Out
+	}
+	return t.Dir
+}
+
+// Load は設定ファイルを読み込んで検証し、パスを設定ファイルのディレクトリ基準で解決する
+// 拡張子が .json なら JSON、それ以外は YAML として読む。未知のキーはエラー
+func Load(file string) (*Config, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&cfg)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&cfg)
+		if errors.Is(err, io.EOF) {
+			err = nil // 空のファイルは targets なしとして検証でエラーにする
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, prefixErrors(file, err)
+	}
+
+	base := filepath.Dir(file)
+	for i := range cfg.Targets {
+		t := &cfg.Targets[i]
+		t.Dir = resolve(base, t.Dir)
+		t.Out = resolve(base, t.Out)
+		t.JSONSchema = resolve(base, t.JSONSchema)
+		t.TS = resolve(base, t.TS)
+	}
+	return &cfg, nil
+}
+
+// Validate は設定を検証し、問題をすべて返す
+func (c *Config) Validate() error {
+	if len(c.Targets) == 0 {
+		return errors.New("no targets defined")
+	}
+
+	var errs []error
+	outs := make(map[string]int) // 出力パス -> 最初に使ったターゲットの添字
+	names := make(map[string]int)
+	for i, t := range c.Targets {
+		errorf := func(format string, args ...any) {
+			errs = append(errs, fmt.Errorf("targets[%d]: %s", i, fmt.Sprintf(format, args...)))
+		}
+
+		for _, f := range []struct{ key, value string }{{"dir", t.Dir}, {"pkg", t.Pkg}, {"out", t.Out}} {
+			if f.value == "" {
+				errorf("%s is required", f.key)
+			}
+		}
+		if t.Pkg != "" && (!token.IsIdentifier(t.Pkg) || t.Pkg == "_") {
+			errorf("pkg %q is not a valid package name", t.Pkg)
+		}
+		if t.Out != "" {
+			if filepath.Ext(t.Out) != ".go" {
+				errorf("out %q must be a .go file", t.Out)
+			}
+			key := filepath.Clean(t.Out)
+			if j, ok := outs[key]; ok {
+				errorf("out %q is already used by targets[%d]", t.Out, j)
+			} else {
+				outs[key] = i
+			}
+			// 生成コードは //go:embed でテンプレートを埋め込むため、親ディレクトリは参照できない
+			if t.Dir != "" && !within(filepath.Dir(t.Out), t.Dir) {
+				errorf("dir %q must be inside the directory of out %q (go:embed cannot reference parent directories)", t.Dir, t.Out)
+			}
+		}
+		if t.TS != "" && !strings.HasSuffix(t.TS, ".d.ts") {
+			errorf("ts %q must be a .d.ts file", t.TS)
+		}
+		if t.Name != "" {
+			if j, ok := names[t.Name]; ok {
+				errorf("name %q is already used by targets[%d]", t.Name, j)
+			} else {
+				names[t.Name] = i
+			}
+		}
+		switch t.Mode {
+		case "", "text", "html":
+		default:
+			errorf("invalid mode %q (want text or html)", t.Mode)
+		}
+		if err := ValidTagKeys(t.Tags); err != nil {
+			errorf("tags: %v", err)
+		}
+		switch t.TagCase {
+		case "", "snake", "camel":
+		default:
+			errorf("invalid tagcase %q (want snake or camel)", t.TagCase)
+		}
+		for _, p := range t.Include {
+			if err := validGlob(p); err != nil {
+				errorf("include %q: %v", p, err)
+			}
+		}
+		for _, p := range t.Exclude {
+			if err := validGlob(p); err != nil {
+				errorf("exclude %q: %v", p, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Files は dir 以下の include に一致し exclude に一致しないファイルをパス順に返す
+// "." で始まるディレクトリは対象外
+func (t Target) Files() ([]string, error) {
+	include := t.Include
+	if len(include) == 0 {
+		include = DefaultInclude
+	}
+
+	var files []string
+	err := filepath.WalkDir(t.Dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != t.Dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(t.Dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if matchAny(include, rel) && !matchAny(t.Exclude, rel) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// MatchGlob は "/" 区切りのパス name がパターンに一致するか返す
+// パターンの各要素は path.Match の構文で、"**" は0個以上のディレクトリに一致する
+// 例: "**/*.tmpl" は "a.tmpl" と "mail/invite/title.tmpl" の両方に一致する
+func MatchGlob(pattern, name string) bool {
+	return matchParts(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchParts(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchParts(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if MatchGlob(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func validGlob(pattern string) error {
+	if pattern == "" {
+		return errors.New("empty pattern")
+	}
+	if strings.HasPrefix(pattern, "/") {
+		return errors.New("pattern must be relative to dir")
+	}
+	for _, part := range strings.Split(pattern, "/") {
+		if _, err := path.Match(part, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidTagKeys は構造体タグのキーとして使えるか検証する（空白、引用符、コロンを含まない）
+func ValidTagKeys(keys []string) error {
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if key == "" || strings.ContainsAny(key, " \t\":`") {
+			return fmt.Errorf("invalid key %q", key)
+		}
+		if seen[key] {
+			return fmt.Errorf("duplicate key %q", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// within は dir が base と同じか base 以下のディレクトリかを返す
+func within(base, dir string) bool {
+	rel, err := filepath.Rel(base, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolve は設定ファイルのディレクトリ base を基準にパス p を解決する
+func resolve(base, p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(base, p)
+}
+
+// prefixErrors は errors.Join でまとめたエラーの各行に設定ファイル名を付ける
+func prefixErrors(file string, err error) error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	var errs []error
+	for _, e := range joined.Unwrap() {
+		errs = append(errs, fmt.Errorf("%s: %w", file, e))
+	}
+	return errors.Join(errs...)
+}
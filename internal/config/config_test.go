@@ -0,0 +1,268 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "tmpltype.yaml",
+			content: `targets:
+  - name: mail
+    dir: mail/templates
+    pkg: mail
+    out: mail/templates_gen.go
+    include: ["**/*.tmpl"]
+    exclude: ["drafts/**"]
+    mode: text
+    funcs: Funcs
+    tags: [json, yaml]
+    tagcase: camel
+    required: true
+    jsonschema: schemas/mail
+    ts: web/src/mail.d.ts
+  - dir: web/templates
+    pkg: web
+    out: web/templates_gen.go
+`,
+		},
+		{
+			name: "json",
+			file: "tmpltype.json",
+			content: `{"targets": [
+  {"name": "mail", "dir": "mail/templates", "pkg": "mail", "out": "mail/templates_gen.go",
+   "include": ["**/*.tmpl"], "exclude": ["drafts/**"], "mode": "text", "funcs": "Funcs",
+   "tags": ["json", "yaml"], "tagcase": "camel", "required": true,
+   "jsonschema": "schemas/mail", "ts": "web/src/mail.d.ts"},
+  {"dir": "web/templates", "pkg": "web", "out": "web/templates_gen.go"}
+]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, tt.file)
+			writeFile(t, file, tt.content)
+
+			cfg, err := Load(file)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			want := []Target{
+				{
+					Name:     "mail",
+					Dir:      filepath.Join(dir, "mail/templates"),
+					Pkg:      "mail",
+					Out:      filepath.Join(dir, "mail/templates_gen.go"),
+					Include:  []string{"**/*.tmpl"},
+					Exclude:  []string{"drafts/**"},
+					Mode:     "text",
+					Funcs:    "Funcs",
+					Tags:     []string{"json", "yaml"},
+					TagCase:  "camel",
+					Required: true,
+
+					JSONSchema: filepath.Join(dir, "schemas/mail"),
+					TS:         filepath.Join(dir, "web/src/mail.d.ts"),
+				},
+				{
+					Dir: filepath.Join(dir, "web/templates"),
+					Pkg: "web",
+					Out: filepath.Join(dir, "web/templates_gen.go"),
+				},
+			}
+			if len(cfg.Targets) != len(want) {
+				t.Fatalf("got %d targets, want %d", len(cfg.Targets), len(want))
+			}
+			for i := range want {
+				got, w := cfg.Targets[i], want[i]
+				if got.Name != w.Name || got.Dir != w.Dir || got.Pkg != w.Pkg || got.Out != w.Out ||
+					got.Mode != w.Mode || got.Funcs != w.Funcs || got.Required != w.Required ||
+					!slices.Equal(got.Include, w.Include) || !slices.Equal(got.Exclude, w.Exclude) {
+					t.Errorf("targets[%d] = %+v, want %+v", i, got, w)
+				}
+			}
+			if got := cfg.Targets[1].Label(); got != filepath.Join(dir, "web/templates_gen.go") {
+				t.Errorf("Label() = %q, want out path", got)
+			}
+		})
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []string // エラーメッセージに含まれるべき文字列
+	}{
+		{
+			name:    "empty file",
+			file:    "tmpltype.yaml",
+			content: "",
+			want:    []string{"tmpltype.yaml: no targets defined"},
+		},
+		{
+			name:    "unknown yaml key",
+			file:    "tmpltype.yaml",
+			content: "targets:\n  - dir: t\n    pkg: p\n    out: o.go\n    output: x.go\n",
+			want:    []string{"tmpltype.yaml:", "field output not found"},
+		},
+		{
+			name:    "unknown json key",
+			file:    "tmpltype.json",
+			content: `{"targets": [{"dir": "t", "pkg": "p", "out": "o.go", "package": "p"}]}`,
+			want:    []string{"tmpltype.json:", `unknown field "package"`},
+		},
+		{
+			name: "validation errors are all reported",
+			file: "tmpltype.yaml",
+			content: `targets:
+  - dir: a
+    pkg: my-pkg
+    out: a.txt
+    mode: xml
+    ts: a.ts
+    tags: [json, json]
+    tagcase: kebab
+  - name: b
+    pkg: b
+    out: b.go
+    include: ["[a"]
+  - name: b
+    dir: c
+    pkg: c
+    out: b.go
+    exclude: ["/abs/*.tmpl"]
+`,
+			want: []string{
+				`tmpltype.yaml: targets[0]: pkg "my-pkg" is not a valid package name`,
+				`tmpltype.yaml: targets[0]: out "a.txt" must be a .go file`,
+				`tmpltype.yaml: targets[0]: invalid mode "xml" (want text or html)`,
+				`tmpltype.yaml: targets[0]: ts "a.ts" must be a .d.ts file`,
+				`tmpltype.yaml: targets[0]: tags: duplicate key "json"`,
+				`tmpltype.yaml: targets[0]: invalid tagcase "kebab" (want snake or camel)`,
+				`tmpltype.yaml: targets[1]: dir is required`,
+				`tmpltype.yaml: targets[1]: include "[a": syntax error in pattern`,
+				`tmpltype.yaml: targets[2]: out "b.go" is already used by targets[1]`,
+				`tmpltype.yaml: targets[2]: name "b" is already used by targets[1]`,
+				`tmpltype.yaml: targets[2]: exclude "/abs/*.tmpl": pattern must be relative to dir`,
+			},
+		},
+		{
+			name:    "dir outside of the out directory",
+			file:    "tmpltype.yaml",
+			content: "targets:\n  - dir: templates\n    pkg: p\n    out: p/gen.go\n",
+			want:    []string{`targets[0]: dir "templates" must be inside the directory of out "p/gen.go"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), tt.file)
+			writeFile(t, file, tt.content)
+
+			_, err := Load(file)
+			if err == nil {
+				t.Fatal("Load() error = nil, want error")
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("error does not contain %q:\n%v", w, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"**/*.tmpl", "a.tmpl", true},
+		{"**/*.tmpl", "mail/invite/title.tmpl", true},
+		{"**/*.tmpl", "a.html", false},
+		{"*.tmpl", "mail/a.tmpl", false},
+		{"mail/*.tmpl", "mail/a.tmpl", true},
+		{"mail/**", "mail/account/created.tmpl", true},
+		{"mail/**/created.tmpl", "mail/created.tmpl", true},
+		{"mail/**/created.tmpl", "web/created.tmpl", false},
+		{"**/_*", "mail/_partial.tmpl", true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTarget_Files(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{
+		"a.tmpl",
+		"b.html.tmpl",
+		"notes.txt",
+		"mail/invite.tmpl",
+		"mail/drafts/old.tmpl",
+		".cache/c.tmpl",
+	} {
+		writeFile(t, filepath.Join(dir, f), "")
+	}
+
+	tests := []struct {
+		name             string
+		include, exclude []string
+		want             []string
+	}{
+		{
+			name: "default include",
+			want: []string{"a.tmpl", "b.html.tmpl", "mail/drafts/old.tmpl", "mail/invite.tmpl"},
+		},
+		{
+			name:    "include and exclude",
+			include: []string{"**/*.tmpl", "*.txt"},
+			exclude: []string{"**/drafts/**", "*.html.tmpl"},
+			want:    []string{"a.tmpl", "mail/invite.tmpl", "notes.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := Target{Dir: dir, Include: tt.include, Exclude: tt.exclude}.Files()
+			if err != nil {
+				t.Fatalf("Files() error = %v", err)
+			}
+			var got []string
+			for _, f := range files {
+				rel, _ := filepath.Rel(dir, f)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Files() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
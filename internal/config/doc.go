@@ -0,0 +1,17 @@
+// Package config は複数の生成対象をまとめて記述する設定ファイル（tmpltype.yaml / tmpltype.json）を扱います。
+//
+// 設定ファイルの形式:
+//
+//	targets:
+//	  - name: mail
+//	    dir: internal/mail/templates
+//	    pkg: mail
+//	    out: internal/mail/template_gen.go
+//	    include: ["**/*.tmpl"]
+//	    exclude: ["drafts/**"]
+//	    mode: text
+//	    funcs: templateFuncs
+//
+// パスは設定ファイルのディレクトリからの相対パスです。include と exclude は dir からの相対パスに対する glob で、
+// "**" は0個以上のディレクトリに一致します。
+package config
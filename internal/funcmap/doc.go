@@ -0,0 +1,7 @@
+// Package funcmap は生成コードに組み込む template.FuncMap 変数を読み込みます。
+//
+// FuncMap 変数は "Name"（出力パッケージ内）または "import/path.Name"（別パッケージ）で指定します。
+// 変数がコンポジットリテラルで定義されていれば、キーから関数名を、
+// 値が同じパッケージの関数宣言や関数リテラルであればそのシグネチャを取得します。
+// 取得した関数はテンプレートのパースと引数位置による型推論に使われます。
+package funcmap
@@ -0,0 +1,225 @@
+package funcmap
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+	"github.com/bellwood4486/tmpltype/internal/typing/magic"
+)
+
+// FuncMap は生成コードに組み込む template.FuncMap 変数を表す
+type FuncMap struct {
+	ImportPath string               // 変数を定義するパッケージ（空なら出力パッケージ）
+	PkgName    string               // 変数を定義するパッケージの名前（空なら import パスの最後の要素）
+	Name       string               // 変数名（例: "FuncMap"）
+	Funcs      map[string]scan.Func // 関数名 -> シグネチャ
+}
+
+// Expr は生成コードから FuncMap 変数を参照する式を返す（例: "templateFuncs", "tmplfuncs.FuncMap"）
+func (m *FuncMap) Expr() string {
+	if m.ImportPath == "" {
+		return m.Name
+	}
+	return m.Qualifier() + "." + m.Name
+}
+
+// Qualifier は生成コードで FuncMap のパッケージを参照する名前を返す（出力パッケージ内なら空）
+func (m *FuncMap) Qualifier() string {
+	if m.ImportPath == "" {
+		return ""
+	}
+	if m.PkgName != "" {
+		return m.PkgName
+	}
+	return path.Base(m.ImportPath)
+}
+
+// ParseRef は "Name" または "import/path.Name" 形式の参照をパースする
+func ParseRef(ref string) (*FuncMap, error) {
+	importPath, name := "", ref
+	if i := strings.LastIndex(ref, "."); i >= 0 {
+		importPath, name = ref[:i], ref[i+1:]
+	}
+	if !token.IsIdentifier(name) || (importPath == "" && strings.Contains(ref, ".")) {
+		return nil, fmt.Errorf("invalid FuncMap reference %q (want Name or import/path.Name)", ref)
+	}
+	return &FuncMap{
+		ImportPath: importPath,
+		Name:       name,
+		Funcs:      make(map[string]scan.Func),
+	}, nil
+}
+
+// Load は FuncMap 変数の定義を読み込み、関数名とシグネチャを取得する
+// dir は出力パッケージのディレクトリで、別パッケージの import パス解決の基点にもなる
+func Load(ref string, dir string) (*FuncMap, error) {
+	m, err := ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgDir := dir
+	if m.ImportPath != "" {
+		bp, err := build.Default.Import(m.ImportPath, dir, build.FindOnly)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find package %s: %w", m.ImportPath, err)
+		}
+		pkgDir = bp.Dir
+	}
+
+	fset := token.NewFileSet()
+	files, err := parsePackage(fset, pkgDir)
+	if err != nil {
+		return nil, err
+	}
+
+	lit, pkgName := findFuncMapLiteral(files, m.Name)
+	if lit == nil {
+		return nil, fmt.Errorf("FuncMap variable %s not found in %s", m.Name, pkgDir)
+	}
+	if m.ImportPath != "" {
+		m.PkgName = pkgName
+	}
+
+	decls := funcDecls(files)
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := kv.Key.(*ast.BasicLit)
+		if !ok || key.Kind != token.STRING {
+			continue
+		}
+		name, err := strconv.Unquote(key.Value)
+		if err != nil {
+			continue
+		}
+
+		var fn scan.Func
+		if ft := funcTypeOf(kv.Value, decls); ft != nil {
+			if m.ImportPath != "" {
+				qualifyLocalTypes(ft, pkgName)
+			}
+			fn.Params, fn.Variadic = magic.FuncParams(ft)
+		}
+		m.Funcs[name] = fn
+	}
+
+	return m, nil
+}
+
+// parsePackage はディレクトリ内の Go ファイル（テストを除く）をパースする
+func parsePackage(fset *token.FileSet, dir string) ([]*ast.File, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load package in %s: %w", dir, err)
+	}
+
+	files := make([]*ast.File, 0, len(bp.GoFiles))
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(fset, dir+"/"+name, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// findFuncMapLiteral はトップレベルの var name = X{...} のコンポジットリテラルを探す
+func findFuncMapLiteral(files []*ast.File, name string) (*ast.CompositeLit, string) {
+	for _, f := range files {
+		for _, d := range f.Decls {
+			gd, ok := d.(*ast.GenDecl)
+			if !ok || gd.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, n := range vs.Names {
+					if n.Name != name || i >= len(vs.Values) {
+						continue
+					}
+					if lit, ok := vs.Values[i].(*ast.CompositeLit); ok {
+						return lit, f.Name.Name
+					}
+				}
+			}
+		}
+	}
+	return nil, ""
+}
+
+// funcDecls はトップレベルの関数宣言（メソッドを除く）を名前で引けるようにする
+func funcDecls(files []*ast.File) map[string]*ast.FuncDecl {
+	decls := make(map[string]*ast.FuncDecl)
+	for _, f := range files {
+		for _, d := range f.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv == nil {
+				decls[fd.Name.Name] = fd
+			}
+		}
+	}
+	return decls
+}
+
+// funcTypeOf は FuncMap の値から関数型を求める（同じパッケージの関数宣言と関数リテラルのみ）
+func funcTypeOf(v ast.Expr, decls map[string]*ast.FuncDecl) *ast.FuncType {
+	switch x := v.(type) {
+	case *ast.FuncLit:
+		return x.Type
+	case *ast.Ident:
+		if fd, ok := decls[x.Name]; ok {
+			return fd.Type
+		}
+	}
+	return nil
+}
+
+// qualifyLocalTypes は別パッケージの関数シグネチャに現れるパッケージ内の型を pkg.Type に書き換える
+func qualifyLocalTypes(ft *ast.FuncType, pkgName string) {
+	if ft.Params == nil {
+		return
+	}
+	for _, field := range ft.Params.List {
+		field.Type = qualify(field.Type, pkgName)
+	}
+}
+
+func qualify(e ast.Expr, pkgName string) ast.Expr {
+	switch x := e.(type) {
+	case *ast.Ident:
+		if isPredeclared(x.Name) {
+			return x
+		}
+		return &ast.SelectorExpr{X: ast.NewIdent(pkgName), Sel: x}
+	case *ast.StarExpr:
+		x.X = qualify(x.X, pkgName)
+	case *ast.ArrayType:
+		x.Elt = qualify(x.Elt, pkgName)
+	case *ast.MapType:
+		x.Key = qualify(x.Key, pkgName)
+		x.Value = qualify(x.Value, pkgName)
+	case *ast.Ellipsis:
+		x.Elt = qualify(x.Elt, pkgName)
+	}
+	return e
+}
+
+func isPredeclared(name string) bool {
+	switch name {
+	case "any", "bool", "byte", "comparable", "complex64", "complex128", "error",
+		"float32", "float64", "int", "int8", "int16", "int32", "int64", "rune",
+		"string", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr":
+		return true
+	}
+	return false
+}
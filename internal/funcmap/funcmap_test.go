@@ -0,0 +1,85 @@
+package funcmap
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		ref      string
+		wantPath string
+		wantName string
+		wantExpr string
+	}{
+		{"templateFuncs", "", "templateFuncs", "templateFuncs"},
+		{"github.com/acme/app/tmplfuncs.FuncMap", "github.com/acme/app/tmplfuncs", "FuncMap", "tmplfuncs.FuncMap"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			m, err := ParseRef(tt.ref)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if m.ImportPath != tt.wantPath || m.Name != tt.wantName {
+				t.Errorf("ParseRef(%q) = (%q, %q), want (%q, %q)", tt.ref, m.ImportPath, m.Name, tt.wantPath, tt.wantName)
+			}
+			if m.Expr() != tt.wantExpr {
+				t.Errorf("Expr() = %q, want %q", m.Expr(), tt.wantExpr)
+			}
+		})
+	}
+}
+
+func TestParseRef_Invalid(t *testing.T) {
+	for _, ref := range []string{"", ".FuncMap", "pkg.", "pkg.1abc"} {
+		if _, err := ParseRef(ref); err == nil {
+			t.Errorf("ParseRef(%q): expected error, got nil", ref)
+		}
+	}
+}
+
+func TestLoad_LocalPackage(t *testing.T) {
+	m, err := Load("templateFuncs", "testdata/local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.Funcs) != 4 {
+		t.Fatalf("expected 4 funcs, got %d", len(m.Funcs))
+	}
+	if got := m.Funcs["formatDate"].Params; !slices.Equal(got, []string{"time.Time"}) {
+		t.Errorf("formatDate params = %v", got)
+	}
+	if got := m.Funcs["price"].Params; !slices.Equal(got, []string{"float64", "string"}) {
+		t.Errorf("price params = %v", got)
+	}
+	// 別パッケージの関数はシグネチャ不明
+	if got := m.Funcs["upper"].Params; got != nil {
+		t.Errorf("upper params = %v, want nil", got)
+	}
+	if fn := m.Funcs["join"]; !slices.Equal(fn.Params, []string{"string", "string"}) || !fn.Variadic {
+		t.Errorf("join = %+v", fn)
+	}
+}
+
+func TestLoad_QualifiesTypesOfOtherPackage(t *testing.T) {
+	m, err := Load("github.com/bellwood4486/tmpltype/internal/funcmap/testdata/ext.FuncMap", ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := m.Funcs["money"].Params; !slices.Equal(got, []string{"ext.Money", "[]ext.Symbol"}) {
+		t.Errorf("money params = %v", got)
+	}
+	if m.Expr() != "ext.FuncMap" {
+		t.Errorf("Expr() = %q", m.Expr())
+	}
+}
+
+func TestLoad_VariableNotFound(t *testing.T) {
+	if _, err := Load("missing", "testdata/local"); err == nil {
+		t.Error("expected error for missing variable, got nil")
+	}
+}
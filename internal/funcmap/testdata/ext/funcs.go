@@ -0,0 +1,11 @@
+package ext
+
+import "text/template"
+
+type Money int64
+
+var FuncMap = template.FuncMap{
+	"money": func(m Money, symbols []Symbol) string { return "" },
+}
+
+type Symbol string
@@ -0,0 +1,18 @@
+package local
+
+import (
+	"strings"
+	"text/template"
+	"time"
+)
+
+var templateFuncs = template.FuncMap{
+	"formatDate": formatDate,
+	"price":      func(v float64, currency string) string { return "" },
+	"upper":      strings.ToUpper,
+	"join":       join,
+}
+
+func formatDate(t time.Time) string { return t.Format(time.DateOnly) }
+
+func join(sep string, parts ...string) string { return strings.Join(parts, sep) }
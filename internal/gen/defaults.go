@@ -0,0 +1,165 @@
+package gen
+
+import (
+	"errors"
+	"go/ast"
+	"go/constant"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+	"github.com/bellwood4486/tmpltype/internal/typing"
+)
+
+// defaults は @default の既定値を設定するメソッドの生成内容
+// 既定値のあるフィールドを（入れ子の型や埋め込みも含めて）持つ型だけに生成する
+type defaults struct {
+	methods map[string]string // 型名 -> 生成する WithDefaults メソッド
+}
+
+// needed は型 typeName に WithDefaults メソッドを生成するか返す
+func (d *defaults) needed(typeName string) bool {
+	_, ok := d.methods[typeName]
+	return ok
+}
+
+// planDefaults は既定値のあるフィールドを持つ型を求め、WithDefaults メソッドを生成して必要な import を追加する
+// @type で既存の型に結び付けたテンプレートは対象外
+func planDefaults(p *emitPrepared) error {
+	dg := &defaultsGen{structGraph: newStructGraph(p)}
+	dg.mark(func(t tmpl, f *typing.TypedField) bool {
+		return f.Default != nil
+	})
+
+	p.defaults = &defaults{methods: make(map[string]string)}
+	if len(dg.needs) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(dg.needs)) {
+		def := dg.structs[name]
+		if f := fieldByName(def.fields, "WithDefaults"); f != nil {
+			errs = append(errs, scan.Errorf(f.Pos, "field WithDefaults conflicts with the generated method %s.WithDefaults", name))
+			continue
+		}
+		p.defaults.methods[name] = dg.method(name, def)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	return dg.addImports()
+}
+
+// defaultsGen は WithDefaults メソッドを生成する
+type defaultsGen struct {
+	*structGraph
+}
+
+// method は型 name の WithDefaults メソッドを生成する
+// 値のコピーに既定値を設定して返す（スライス、マップ、ポインタの先は複製するので、呼び出し側の値は変わらない）
+func (dg *defaultsGen) method(name string, def structDef) string {
+	var b strings.Builder
+	write(&b, "// WithDefaults returns a copy of p with the @default values set on the fields that are not set\n")
+	write(&b, "func (p %s) WithDefaults() %s {\n", name, name)
+	for _, e := range def.embeds {
+		if typeName := dg.p.typeNames[e]; dg.needs[typeName] {
+			write(&b, "\tp.%s = p.%s.WithDefaults()\n", typeName, typeName)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(def.fields)) {
+		f := def.fields[key]
+		expr := dg.fieldExpr(def.t, f)
+		value := "p." + f.Name
+		if f.Default != nil && expr != nil {
+			write(&b, "\tif %s {\n", dg.zero(value, expr))
+			write(&b, "\t\t%s = %s\n", value, dg.defaultExpr(def.t, f, expr))
+			write(&b, "\t}\n")
+		}
+		if expr != nil {
+			dg.nested(&b, value, expr, 1)
+		}
+	}
+	write(&b, "\treturn p\n")
+	write(&b, "}\n\n")
+	return b.String()
+}
+
+// defaultExpr はフィールドに代入する既定値の式を返す（@enum の型なら定数名）
+func (dg *defaultsGen) defaultExpr(t tmpl, f *typing.TypedField, expr ast.Expr) string {
+	if id, ok := expr.(*ast.Ident); ok {
+		if e := enumOf(t, id.Name); e != nil {
+			for _, c := range enumConsts(id.Name, e) {
+				if c.value == constant.StringVal(f.Default.Value) {
+					return c.name
+				}
+			}
+		}
+	}
+	return f.Default.Expr
+}
+
+// nested は値 value（型 expr）の中にある WithDefaults を持つ型に既定値を設定するコードを生成する
+// depth は入れ子のブロックの深さ（変数名と字下げに使う）
+func (dg *defaultsGen) nested(b *strings.Builder, value string, expr ast.Expr, depth int) {
+	if !dg.nests(expr) {
+		return
+	}
+	indent := strings.Repeat("\t", depth)
+	suffix := ""
+	if depth > 1 {
+		suffix = strconv.Itoa(depth - 1)
+	}
+
+	switch e := expr.(type) {
+	case *ast.Ident:
+		write(b, "%s%s = %s.WithDefaults()\n", indent, value, value)
+	case *ast.StarExpr:
+		x := "x" + suffix
+		write(b, "%sif %s != nil {\n", indent, value)
+		write(b, "%s\t%s := *%s\n", indent, x, value)
+		dg.nested(b, x, e.X, depth+1)
+		write(b, "%s\t%s = &%s\n", indent, value, x)
+		write(b, "%s}\n", indent)
+	case *ast.ArrayType:
+		i := "i" + suffix
+		if e.Len == nil {
+			dg.imports["slices"] = true
+			write(b, "%s%s = slices.Clone(%s)\n", indent, value, value)
+		}
+		write(b, "%sfor %s := range %s {\n", indent, i, value)
+		dg.nested(b, value+"["+i+"]", e.Elt, depth+1)
+		write(b, "%s}\n", indent)
+	case *ast.MapType:
+		k, v := "k"+suffix, "v"+suffix
+		dg.imports["maps"] = true
+		write(b, "%s%s = maps.Clone(%s)\n", indent, value, value)
+		write(b, "%sfor %s, %s := range %s {\n", indent, k, v, value)
+		dg.nested(b, v, e.Value, depth+1)
+		write(b, "%s\t%s[%s] = %s\n", indent, value, k, v)
+		write(b, "%s}\n", indent)
+	}
+}
+
+// defaultJSON は既定値を JSON の値（string、bool、int64、uint64、float64）に変換する
+// JSON Schema の default と schema サブコマンドで使う
+func defaultJSON(d *typing.Default) any {
+	v := d.Value
+	switch v.Kind() {
+	case constant.String:
+		return constant.StringVal(v)
+	case constant.Bool:
+		return constant.BoolVal(v)
+	case constant.Int:
+		if i, ok := constant.Int64Val(v); ok {
+			return i
+		}
+		if u, ok := constant.Uint64Val(v); ok {
+			return u
+		}
+	}
+	f, _ := constant.Float64Val(v)
+	return f
+}
@@ -1,16 +1,21 @@
 package gen
 
 import (
+	"errors"
 	"fmt"
 	"go/format"
 	"maps"
+	"path"
 	"path/filepath"
 	"regexp"
 	"slices"
 	"strings"
 
+	"github.com/bellwood4486/tmpltype/internal/bind"
+	"github.com/bellwood4486/tmpltype/internal/funcmap"
 	"github.com/bellwood4486/tmpltype/internal/scan"
 	"github.com/bellwood4486/tmpltype/internal/typing"
+	"github.com/bellwood4486/tmpltype/internal/typing/magic"
 	"github.com/bellwood4486/tmpltype/internal/util"
 )
 
@@ -19,163 +24,668 @@ type Unit struct {
 	Pkg           string // 出力パッケージ名
 	SourcePath    string // 埋め込むテンプレファイルのパス（go:embedディレクティブで使用）
 	SourceLiteral string // テンプレ本文
+	File          string // エラー報告に使うテンプレファイルのパス（未指定なら SourcePath）
+}
+
+// file はエラー報告に使うテンプレファイルのパスを返す
+func (u Unit) file() string {
+	if u.File != "" {
+		return u.File
+	}
+	return u.SourcePath
+}
+
+// Mode は生成コードが使うテンプレートパッケージを表す
+type Mode int
+
+const (
+	ModeAuto Mode = iota // 拡張子から判定（すべて *.html.tmpl なら html、それ以外は text）
+	ModeText             // text/template
+	ModeHTML             // html/template（コンテキストに応じたエスケープ）
+)
+
+// htmlTemplateExt は html モードを自動選択するテンプレートの拡張子
+const htmlTemplateExt = ".html.tmpl"
+
+// ContextFunc は FuncMap を組み込むときに追加するテンプレート関数の名前
+// RenderXxxContext では描画に渡した context.Context を、それ以外では context.Background() を返す
+const ContextFunc = "context"
+
+// Options はコード生成の設定
+type Options struct {
+	Mode    Mode             // テンプレートパッケージ（既定は拡張子から自動判定）
+	FuncMap *funcmap.FuncMap // テンプレートに組み込む FuncMap 変数（nil なら組み込まない）
+	Dir     string           // 出力パッケージのディレクトリ（@type の型の読み込みに使う。空ならカレントディレクトリ）
+	Tags    TagOptions       // フィールドに付ける構造体タグの方針
+	// RequireAll はポインタ、bool、入れ子の構造体以外のフィールドをすべて必須にする（@optional で除外できる）
+	// 必須のフィールドを持つパラメータ型には Validate メソッドを生成し、Render 関数が描画の前に呼ぶ
+	RequireAll bool
+	// Warn は生成を止めない問題（@enum にない値との比較など）を受け取る（nil なら捨てる）
+	Warn func(w typing.Warning)
 }
 
 // tmpl は単一テンプレートのコード生成に必要な情報
 type tmpl struct {
 	name       string              // テンプレート名
-	groupName  string              // グループ名（空ならフラット）
+	groupName  string              // グループのパス（例: "mail/account"、空ならフラット）
 	typeName   string              // 生成する型名
+	localName  string              // Template 名前空間でのフィールド名（グループ名を除く）
 	sourcePath string              // テンプレートファイルパス
+	file       string              // エラー報告に使うテンプレートファイルパス
+	namePos    scan.Pos            // 名前の由来（@name があればその位置、なければファイル）
 	varName    string              // embed変数名
+	source     string              // テンプレ本文
 	typed      *typing.TypedSchema // 型情報
+	bound      *bind.Type          // @type で結び付けた既存の型（nil ならパラメータ型を生成する）
 }
 
 // tmplGroup はテンプレートグループのコード生成に必要な情報
+// サブディレクトリはサブグループとして入れ子になる
 type tmplGroup struct {
-	name      string // グループ名
-	typeName  string // 生成する型名
-	templates []tmpl // グループ内のテンプレート
+	name      string      // グループのパス（例: "mail/account"）
+	fieldName string      // Template 名前空間でのフィールド名（例: "Account"）
+	templates []tmpl      // グループ直下のテンプレート
+	groups    []tmplGroup // サブグループ
 }
 
 // emitPrepared は解析・準備が完了したコード生成のための情報
 type emitPrepared struct {
 	pkg           string
-	imports       map[string]struct{}
-	groups        []tmplGroup // グループ
-	flatTemplates []tmpl      // フラットなテンプレート
+	mode          Mode                   // ModeText または ModeHTML（解決済み）
+	funcMapExpr   string                 // FuncMap 変数を参照する式（空なら組み込まない）
+	imports       map[string]string      // import パス -> パッケージ名（"_" はブランク import）
+	groups        []tmplGroup            // グループ
+	flatTemplates []tmpl                 // フラットなテンプレート
+	typeNames     map[string]string      // テンプレート名 -> 生成する型名
+	schemas       map[string]scan.Schema // テンプレート名 -> スキャン結果
+	tags          TagOptions             // フィールドに付ける構造体タグの方針
+	requireAll    bool                   // 指定のないフィールドを必須にする
+	validation    *validation            // 必須のフィールドを検証するメソッド
+	defaults      *defaults              // 既定値を設定するメソッド
 }
 
 // allTemplates はフラットとグループ内の全テンプレートを返す
+// 順序はフラットなテンプレート、各グループ（直下のテンプレート、サブグループの順）
 func (p *emitPrepared) allTemplates() []tmpl {
-	all := make([]tmpl, 0, len(p.flatTemplates)+len(p.groups)*2)
-	all = append(all, p.flatTemplates...)
-	for _, g := range p.groups {
-		all = append(all, g.templates...)
-	}
+	var all []tmpl
+	_ = p.eachTemplate(func(t *tmpl) error {
+		all = append(all, *t)
+		return nil
+	})
 	return all
 }
 
+// eachTemplate はフラットとグループ内の全テンプレートを allTemplates と同じ順序で更新する
+func (p *emitPrepared) eachTemplate(fn func(t *tmpl) error) error {
+	for i := range p.flatTemplates {
+		if err := fn(&p.flatTemplates[i]); err != nil {
+			return err
+		}
+	}
+	return eachGroupTemplate(p.groups, fn)
+}
+
+// eachGroupTemplate はグループ内の全テンプレートをサブグループまで再帰的に更新する
+func eachGroupTemplate(groups []tmplGroup, fn func(t *tmpl) error) error {
+	for i := range groups {
+		for j := range groups[i].templates {
+			if err := fn(&groups[i].templates[j]); err != nil {
+				return err
+			}
+		}
+		if err := eachGroupTemplate(groups[i].groups, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // prepare はテンプレートをスキャンし、型を解決して、コード生成に必要なデータを準備する
-func prepare(units []Unit, basedir string) (*emitPrepared, error) {
+func prepare(units []Unit, basedir string, opts Options) (*emitPrepared, error) {
 	if len(units) == 0 {
 		return nil, fmt.Errorf("no units provided")
 	}
 
+	mode, err := ResolveMode(units, opts.Mode)
+	if err != nil {
+		return nil, err
+	}
+
 	templates := make([]tmpl, 0, len(units))
-	allImports := make(map[string]struct{})
+	allImports := make(map[string]string)
 
 	// デフォルトのimport
-	allImports["io"] = struct{}{}
-	allImports["text/template"] = struct{}{}
-	allImports["embed"] = struct{}{}
-	allImports["fmt"] = struct{}{}
+	allImports["io"] = "io"
+	allImports["embed"] = "_"
+	allImports["fmt"] = "fmt"
+	allImports["bytes"] = "bytes" // RenderXxxString / RenderXxxBytes
+	allImports["sync"] = "sync"
+	allImports["context"] = "context" // RenderXxxContext
+	allImports["errors"] = "errors"   // ParseAll
+	allImports["os"] = "os"           // EnableHotReload
+	allImports["path/filepath"] = "filepath"
+	allImports["slices"] = "slices"
+	allImports["sync/atomic"] = "atomic"
+	allImports["time"] = "time"
+	if mode == ModeHTML {
+		allImports["html/template"] = "template"
+	} else {
+		allImports["text/template"] = "template"
+	}
+
+	// テンプレート関数（FuncMap と @func ディレクティブ）
+	funcs, err := collectFuncs(units, opts.FuncMap)
+	if err != nil {
+		return nil, err
+	}
+	var funcMapExpr string
+	knownImports := make(map[string]string) // 型の解決に使えるパッケージ修飾子 -> import パス
+	if opts.FuncMap != nil {
+		funcMapExpr = opts.FuncMap.Expr()
+		if opts.FuncMap.ImportPath != "" {
+			if err := addImport(allImports, opts.FuncMap.ImportPath, opts.FuncMap.Qualifier()); err != nil {
+				return nil, err
+			}
+			knownImports[opts.FuncMap.Qualifier()] = opts.FuncMap.ImportPath
+		}
+	}
 
 	// 各テンプレートを処理
+	var errs []error
 	for _, unit := range units {
-		// テンプレート名を抽出 (例: "mail_invite/title" または "footer")
-		templateName, err := extractTemplateName(unit.SourcePath, basedir)
+		// テンプレート名を抽出 (例: "mail/account/title" または "footer")
+		templateName, err := extractTemplateName(unit.file(), basedir)
 		if err != nil {
-			return nil, fmt.Errorf("failed to extract template name from %s: %w", unit.SourcePath, err)
+			return nil, scan.InFile(err, unit.file())
 		}
 
-		// グループ名を抽出 (スラッシュが含まれていればグループ)
-		var groupName string
-		var localName string
-		if strings.Contains(templateName, "/") {
-			parts := strings.Split(templateName, "/")
-			groupName = parts[0]
-			localName = parts[1]
-		} else {
-			localName = templateName
+		// @name でテンプレート名（グループ内ならグループを除いた部分）を上書き
+		namePos := scan.Pos{File: unit.file()}
+		d, err := magic.ParseNameDirective(unit.SourceLiteral)
+		if err != nil {
+			errs = append(errs, scan.InFile(err, unit.file()))
+			continue
 		}
-
-		// 型名を生成 (例: "MailInviteTitle" または "Footer")
-		var typeName string
-		if groupName != "" {
-			typeName = util.Export(groupName) + util.Export(localName)
-		} else {
-			typeName = util.Export(localName)
+		if d != nil {
+			dir, _ := path.Split(templateName)
+			templateName = dir + d.Name
+			namePos = scan.Pos{File: unit.file(), Line: d.Line, Col: d.Col}
 		}
 
-		// embed変数名を生成 (スラッシュをアンダースコアに変換)
-		varName := strings.ReplaceAll(templateName, "/", "_") + "TplSource"
+		// グループのパスを抽出 (スラッシュが含まれていればグループ)
+		groupName, localName := path.Split(templateName)
+		groupName = strings.TrimSuffix(groupName, "/")
 
-		// テンプレートをスキャン
-		sch, err := scan.ScanTemplate(unit.SourceLiteral)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan template %s: %w", unit.SourcePath, err)
-		}
+		// 型名を生成 (例: "MailAccountTitle" または "Footer")
+		typeName := exportPath(templateName)
 
-		// 型解決
-		typed, err := typing.Resolve(sch, unit.SourceLiteral)
-		if err != nil {
-			return nil, fmt.Errorf("failed to resolve types for %s: %w", unit.SourcePath, err)
-		}
+		// embed変数名を生成 (スラッシュをアンダースコアに変換)
+		varName := strings.ReplaceAll(templateName, "/", "_") + "TplSource"
 
 		// テンプレートデータを追加
 		templates = append(templates, tmpl{
 			name:       templateName,
 			groupName:  groupName,
 			typeName:   typeName,
+			localName:  util.Export(localName),
 			sourcePath: unit.SourcePath,
+			file:       unit.file(),
+			namePos:    namePos,
 			varName:    varName,
-			typed:      typed,
+			source:     unit.SourceLiteral,
 		})
 	}
 
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
 	// テンプレート名でソート（出力を安定させるため）
-	slices.SortFunc(templates, func(a, b tmpl) int {
+	slices.SortStableFunc(templates, func(a, b tmpl) int {
 		return strings.Compare(a.name, b.name)
 	})
 
+	// 同じテンプレート名は同じテンプレートセットの中で上書きし合うのでエラー
+	if err := checkTemplateNames(templates); err != nil {
+		return nil, err
+	}
+
 	// グループ情報を整理
 	groups, flatTemplates := organizeGroups(templates)
 
-	return &emitPrepared{
+	p := &emitPrepared{
 		pkg:           units[0].Pkg, // すべて同じパッケージ名のはず
+		mode:          mode,
+		funcMapExpr:   funcMapExpr,
 		imports:       allImports,
 		groups:        groups,
 		flatTemplates: flatTemplates,
-	}, nil
+		typeNames:     make(map[string]string, len(templates)),
+		tags:          opts.Tags,
+		requireAll:    opts.RequireAll,
+	}
+
+	// 全テンプレートを1つのテンプレートセットとしてスキャン（生成コードと同じパース順）
+	all := p.allTemplates()
+	sources := make([]scan.Source, 0, len(all))
+	for _, t := range all {
+		sources = append(sources, scan.Source{Name: t.name, Src: t.source, File: t.file})
+		p.typeNames[t.name] = t.typeName
+	}
+	schemas, err := scan.ScanTemplateSet(sources, scan.Config{
+		HTML:  mode == ModeHTML,
+		Funcs: funcs,
+	})
+	if err != nil {
+		// 構文エラーで推論できなくても、各テンプレートのディレクティブのエラーは一緒に報告する
+		errs = append(errs, err)
+		for _, t := range all {
+			if err := typing.CheckDirectives(t.source, t.file, knownImports); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return nil, errors.Join(errs...)
+	}
+	p.schemas = schemas
+
+	// @type で既存の型に結び付けたテンプレートを検証
+	// 型解決のエラーと合わせて、テンプレートをまたいでまとめて報告する
+	if err := bindTypes(p, schemas, opts.Dir); err != nil {
+		errs = append(errs, err)
+	}
+
+	// 型解決
+	_ = p.eachTemplate(func(t *tmpl) error {
+		typed, err := typing.ResolveWithImports(schemas[t.name], t.source, knownImports)
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		t.typed = typed
+		if t.bound != nil {
+			return nil // パラメータ型を生成しないので import は不要
+		}
+
+		// 型で使われるパッケージを import に追加
+		for _, q := range slices.Sorted(maps.Keys(typed.Imports)) {
+			if err := addImport(p.imports, typed.Imports[q], q); err != nil {
+				errs = append(errs, scan.Errorf(typed.ImportPos[q], "%v", err))
+			}
+		}
+		return nil
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	// テンプレート間の参照（{{ template }}）を検証・整理
+	if err := checkTemplateRefCycles(p); err != nil {
+		return nil, err
+	}
+	removePromotedFields(p)
+
+	// @enum の定数名を検証
+	if err := checkEnums(p); err != nil {
+		return nil, err
+	}
+
+	// 既定値を設定するメソッド（import の追加を含む）
+	if err := planDefaults(p); err != nil {
+		return nil, err
+	}
+
+	// 必須のフィールドを検証するメソッド（import の追加を含む）
+	if err := planValidation(p); err != nil {
+		return nil, err
+	}
+
+	// 生成する識別子の衝突を検出
+	if err := checkIdentifiers(p); err != nil {
+		return nil, err
+	}
+
+	if opts.Warn != nil {
+		for _, t := range p.allTemplates() {
+			for _, w := range t.typed.Warnings {
+				opts.Warn(w)
+			}
+		}
+	}
+
+	return p, nil
 }
 
-// organizeGroups はテンプレートをグループとフラットに分類する
-func organizeGroups(templates []tmpl) ([]tmplGroup, []tmpl) {
-	groupMap := make(map[string][]tmpl)
-	var flatTemplates []tmpl
+// bindTypes は @type ディレクティブを持つテンプレートを既存の型に結び付け、
+// スキャンしたフィールドパスがその型に存在するか検証する
+// 不一致はすべてのテンプレートについてまとめて報告する
+func bindTypes(p *emitPrepared, schemas map[string]scan.Schema, dir string) error {
+	var loader *bind.Loader // 型の読み込みは重いので @type があるときだけ作る
+	var errs []error
 
-	for _, t := range templates {
-		if t.groupName != "" {
-			groupMap[t.groupName] = append(groupMap[t.groupName], t)
+	_ = p.eachTemplate(func(t *tmpl) error {
+		d, err := magic.ParseTypeDirective(t.source)
+		if err != nil {
+			errs = append(errs, scan.InFile(err, t.file))
+			return nil
+		}
+		if d == nil {
+			return nil
+		}
+		pos := scan.Pos{File: t.file, Line: d.Line, Col: d.Col}
+		if err := checkTypeExclusive(t, pos); err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+
+		if loader == nil {
+			loader = bind.NewLoader(dir)
+		}
+		typ, err := loader.Load(d.Ref)
+		if err != nil {
+			errs = append(errs, scan.Errorf(pos, "%v", err))
+			return nil
+		}
+		if typ.ImportPath != "" {
+			if err := addImport(p.imports, typ.ImportPath, typ.PkgName); err != nil {
+				errs = append(errs, scan.Errorf(pos, "%v", err))
+				return nil
+			}
+		}
+		t.bound = typ
+		p.typeNames[t.name] = typ.Expr()
+
+		for _, e := range bind.Check(schemas, t.name, typ.Type) {
+			errs = append(errs, e)
+		}
+		return nil
+	})
+
+	return errors.Join(errs...)
+}
+
+// typeExclusiveDirectives は @type と一緒に使えないディレクティブ
+// それぞれテンプレートで最初に現れたものの名前と位置を返す（なければ名前は空）
+var typeExclusiveDirectives = []func(src string) (name string, line, col int, err error){
+	firstDirective(magic.ParseParams, func(d magic.ParamDirective) (string, int, int) { return "@param", d.Line, d.Col }),
+	firstDirective(magic.ParseTagDirectives, func(d magic.TagDirective) (string, int, int) { return "@tag", d.Line, d.Col }),
+	firstDirective(magic.ParseEnumDirectives, func(d magic.EnumDirective) (string, int, int) { return "@enum", d.Line, d.Col }),
+	firstDirective(magic.ParseDocDirectives, func(d magic.DocDirective) (string, int, int) { return "@doc", d.Line, d.Col }),
+	firstDirective(magic.ParseDefaultDirectives, func(d magic.DefaultDirective) (string, int, int) { return "@default", d.Line, d.Col }),
+	firstDirective(magic.ParseRequirementDirectives, func(d magic.RequirementDirective) (string, int, int) {
+		if d.Required {
+			return "@required", d.Line, d.Col
+		}
+		return "@optional", d.Line, d.Col
+	}),
+}
+
+// firstDirective は parse で得た最初のディレクティブの名前と位置を返す関数を作る
+func firstDirective[D any](parse func(string) ([]D, error), at func(D) (string, int, int)) func(string) (string, int, int, error) {
+	return func(src string) (string, int, int, error) {
+		ds, err := parse(src)
+		if err != nil || len(ds) == 0 {
+			return "", 0, 0, err
+		}
+		name, line, col := at(ds[0])
+		return name, line, col, nil
+	}
+}
+
+// checkTypeExclusive は @type（typePos で宣言）と一緒に使えないディレクティブがあれば、最初のものをエラーにする
+func checkTypeExclusive(t *tmpl, typePos scan.Pos) error {
+	for _, find := range typeExclusiveDirectives {
+		name, line, col, err := find(t.source)
+		if err != nil {
+			return scan.InFile(err, t.file)
+		}
+		if name != "" {
+			return scan.Errorf(scan.Pos{File: t.file, Line: line, Col: col},
+				"%s cannot be used together with @type (declared at %s)", name, typePos)
+		}
+	}
+	return nil
+}
+
+// addImport は import を追加する
+// 同じパッケージを別の名前で、または同じ名前で別のパッケージを import しようとした場合はエラーにする
+func addImport(imports map[string]string, importPath, name string) error {
+	if prev, ok := imports[importPath]; ok {
+		if prev != name {
+			return fmt.Errorf("package %q is referenced as both %s and %s", importPath, prev, name)
+		}
+		return nil
+	}
+	for p, n := range imports {
+		if n == name {
+			return fmt.Errorf("package name %s refers to both %q and %q; use @import with a different alias", name, p, importPath)
+		}
+	}
+	imports[importPath] = name
+	return nil
+}
+
+// collectFuncs は FuncMap 変数と各テンプレートの @func ディレクティブからテンプレート関数を集める
+// テンプレートは1つのセットにまとめられるので、@func はどのテンプレートで宣言しても全体に効く
+func collectFuncs(units []Unit, fm *funcmap.FuncMap) (map[string]scan.Func, error) {
+	funcs := make(map[string]scan.Func)
+	if fm != nil {
+		maps.Copy(funcs, fm.Funcs)
+	}
+
+	declared := make(map[string]scan.Pos) // 関数名 -> 宣言した位置
+	var errs []error
+	for _, unit := range units {
+		directives, err := magic.ParseFuncs(unit.SourceLiteral)
+		if err != nil {
+			errs = append(errs, scan.InFile(err, unit.file()))
+			continue
+		}
+		for _, d := range directives {
+			fn := scan.Func{Params: d.Params, Variadic: d.Variadic}
+			pos := scan.Pos{File: unit.file(), Line: d.Line, Col: d.Col}
+			if prev, ok := declared[d.Name]; ok {
+				if !slices.Equal(funcs[d.Name].Params, fn.Params) || funcs[d.Name].Variadic != fn.Variadic {
+					errs = append(errs, scan.Errorf(pos, "@func %s conflicts with the declaration at %s", d.Name, prev))
+				}
+				continue
+			}
+			declared[d.Name] = pos
+			funcs[d.Name] = fn
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if len(declared) > 0 && fm == nil {
+		return nil, fmt.Errorf("templates declare @func but no FuncMap is configured to provide the functions")
+	}
+
+	// FuncMap を組み込むときは描画の context.Context を返す関数を加える
+	if fm != nil {
+		if pos, ok := declared[ContextFunc]; ok {
+			return nil, scan.Errorf(pos, "@func %s conflicts with the generated %s func", ContextFunc, ContextFunc)
+		}
+		if _, ok := fm.Funcs[ContextFunc]; ok {
+			return nil, fmt.Errorf("FuncMap %s: func %q conflicts with the generated %s func", fm.Expr(), ContextFunc, ContextFunc)
+		}
+		funcs[ContextFunc] = scan.Func{}
+	}
+
+	return funcs, nil
+}
+
+// ResolveMode は生成モードを決定する
+// ModeAuto の場合、すべてのテンプレートが *.html.tmpl なら ModeHTML、ひとつもなければ ModeText とする
+func ResolveMode(units []Unit, mode Mode) (Mode, error) {
+	if mode != ModeAuto {
+		return mode, nil
+	}
+
+	var htmlPaths, textPaths []string
+	for _, u := range units {
+		if strings.HasSuffix(u.SourcePath, htmlTemplateExt) {
+			htmlPaths = append(htmlPaths, u.SourcePath)
 		} else {
-			flatTemplates = append(flatTemplates, t)
+			textPaths = append(textPaths, u.SourcePath)
 		}
 	}
 
-	// グループ情報を構築
-	var groups []tmplGroup
-	for groupName, groupTemplates := range groupMap {
-		groups = append(groups, tmplGroup{
-			name:      groupName,
-			typeName:  util.Export(groupName),
-			templates: groupTemplates,
-		})
+	switch {
+	case len(textPaths) == 0:
+		return ModeHTML, nil
+	case len(htmlPaths) == 0:
+		return ModeText, nil
+	default:
+		return ModeAuto, fmt.Errorf("cannot mix %s templates (%s) with other templates (%s) in one package; specify the mode explicitly",
+			htmlTemplateExt, htmlPaths[0], textPaths[0])
+	}
+}
+
+// templateRefs はテンプレートが型として参照・埋め込みしている他テンプレート名を返す
+func templateRefs(typed *typing.TypedSchema) []string {
+	var refs []string
+	refs = append(refs, typed.Embeds...)
+
+	var collect func(fields map[string]*typing.TypedField)
+	collect = func(fields map[string]*typing.TypedField) {
+		for _, f := range fields {
+			if f.Template != "" {
+				refs = append(refs, f.Template)
+			}
+			refs = append(refs, f.Embeds...)
+			collect(f.Children)
+		}
+	}
+	collect(typed.Fields)
+	for _, nt := range typed.NamedTypes {
+		refs = append(refs, nt.Embeds...)
+		collect(nt.Fields)
+	}
+
+	slices.Sort(refs)
+	return slices.Compact(refs)
+}
+
+// checkTemplateRefCycles はテンプレート間の型参照が循環していないか検証する
+// （循環すると再帰的な構造体定義になりコンパイルできない）
+func checkTemplateRefCycles(p *emitPrepared) error {
+	// 既存の型に結び付けたテンプレートは構造体を生成しないので循環の対象外
+	typedByName := make(map[string]*typing.TypedSchema)
+	for _, t := range p.allTemplates() {
+		if t.bound == nil {
+			typedByName[t.name] = t.typed
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	var stack []string
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			i := slices.Index(stack, name)
+			cycle := append(slices.Clone(stack[i:]), name)
+			return fmt.Errorf("template reference cycle: %s", strings.Join(cycle, " -> "))
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		stack = append(stack, name)
+		if typed := typedByName[name]; typed != nil {
+			for _, ref := range templateRefs(typed) {
+				if err := visit(ref); err != nil {
+					return err
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = done
+		return nil
+	}
+
+	for _, t := range p.allTemplates() {
+		if err := visit(t.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removePromotedFields は埋め込んだテンプレートの型から昇格されるフィールドを、
+// 埋め込み先の構造体から取り除く（同じ .Foo を親と部分テンプレートの両方で参照した場合など）
+func removePromotedFields(p *emitPrepared) {
+	fieldsByName := make(map[string]map[string]*typing.TypedField)
+	for _, t := range p.allTemplates() {
+		fieldsByName[t.name] = t.typed.Fields
+	}
+
+	remove := func(fields map[string]*typing.TypedField, embeds []string) {
+		for _, e := range embeds {
+			for name := range fieldsByName[e] {
+				delete(fields, name)
+			}
+		}
+	}
+
+	for _, t := range p.allTemplates() {
+		remove(t.typed.Fields, t.typed.Embeds)
+		for _, nt := range t.typed.NamedTypes {
+			remove(nt.Fields, nt.Embeds)
+		}
+	}
+}
+
+// organizeGroups はテンプレートをグループとフラットに分類する
+func organizeGroups(templates []tmpl) ([]tmplGroup, []tmpl) {
+	root := organizeGroup("", templates)
+	return root.groups, root.templates
+}
+
+// organizeGroup はグループ prefix（空ならルート）に属するテンプレートを、直下のテンプレートとサブグループに分ける
+func organizeGroup(prefix string, templates []tmpl) tmplGroup {
+	g := tmplGroup{name: prefix}
+	if prefix != "" {
+		g.fieldName = util.Export(path.Base(prefix))
+	}
+
+	sub := make(map[string][]tmpl) // サブグループのパス -> 属するテンプレート
+	for _, t := range templates {
+		if t.groupName == prefix {
+			g.templates = append(g.templates, t)
+			continue
+		}
+		// prefix 直下のサブグループ（例: prefix="mail", groupName="mail/account/created" -> "mail/account"）
+		rest := strings.TrimPrefix(t.groupName, prefix)
+		rest = strings.TrimPrefix(rest, "/")
+		first, _, _ := strings.Cut(rest, "/")
+		sub[path.Join(prefix, first)] = append(sub[path.Join(prefix, first)], t)
 	}
 
 	// グループ名でソート
-	slices.SortFunc(groups, func(a, b tmplGroup) int {
-		return strings.Compare(a.name, b.name)
-	})
+	for _, name := range slices.Sorted(maps.Keys(sub)) {
+		g.groups = append(g.groups, organizeGroup(name, sub[name]))
+	}
 
-	return groups, flatTemplates
+	return g
 }
 
 // Emit は複数のテンプレートから1つの統合Goファイルを生成する
 // 単一テンプレートの場合も同じフォーマットで生成される
 func Emit(units []Unit, basedir string) (string, error) {
+	return EmitWithOptions(units, basedir, Options{})
+}
+
+// EmitWithOptions は Emit と同様だが、生成の設定を指定できる
+func EmitWithOptions(units []Unit, basedir string, opts Options) (string, error) {
 	// Phase 1: データ収集と準備
-	prepared, err := prepare(units, basedir)
+	prepared, err := prepare(units, basedir, opts)
 	if err != nil {
 		return "", err
 	}
@@ -188,8 +698,12 @@ func Emit(units []Unit, basedir string) (string, error) {
 	generateEmbedDeclarations(&b, prepared.allTemplates())
 	generateTemplateInitialization(&b, prepared)
 	generateTemplatesFunction(&b)
-	generateGenericRenderFunction(&b)
-	generateTemplateBlocks(&b, prepared.allTemplates())
+	generateHotReload(&b, prepared)
+	generateGenericRenderFunction(&b, prepared)
+	generateBufferPool(&b)
+	generateContextHelpers(&b, prepared)
+	generateMissingFieldsError(&b, prepared)
+	generateTemplateBlocks(&b, prepared)
 
 	// Phase 3: フォーマット
 	return formatCode(b.String())
@@ -202,12 +716,13 @@ func generateHeader(b *strings.Builder, pkg string) {
 }
 
 // generateImports はimportセクションを生成する
-func generateImports(b *strings.Builder, imports map[string]struct{}) {
+// パッケージ名が import パスの最後の要素と異なる場合は別名付きで出力する
+func generateImports(b *strings.Builder, imports map[string]string) {
 	write(b, "import (\n")
 	keys := slices.Sorted(maps.Keys(imports))
 	for _, k := range keys {
-		if k == "embed" {
-			write(b, "\t_ %q\n", k)
+		if name := imports[k]; name != path.Base(k) {
+			write(b, "\t%s %q\n", name, k)
 		} else {
 			write(b, "\t%q\n", k)
 		}
@@ -216,51 +731,44 @@ func generateImports(b *strings.Builder, imports map[string]struct{}) {
 }
 
 // generateTemplateNamespace はTemplateName型と名前空間を生成する
+// グループはサブディレクトリの階層どおりに入れ子の構造体になる
 func generateTemplateNamespace(b *strings.Builder, p *emitPrepared) {
 	write(b, "// TemplateName is a type-safe template name\n")
 	write(b, "type TemplateName string\n\n")
 	write(b, "// Template provides type-safe access to template names\n")
-	write(b, "var Template = struct {\n")
+	write(b, "var Template = ")
+	root := tmplGroup{templates: p.flatTemplates, groups: p.groups}
+	generateGroupValue(b, root)
+	write(b, "\n\n")
+}
 
-	// フラットなテンプレート
-	for _, t := range p.flatTemplates {
-		write(b, "\t%s TemplateName\n", t.typeName)
+// generateGroupType はグループの名前空間の構造体型を生成する（直下のテンプレート、サブグループの順）
+func generateGroupType(b *strings.Builder, g tmplGroup) {
+	write(b, "struct {\n")
+	for _, t := range g.templates {
+		write(b, "%s TemplateName\n", t.localName)
 	}
-
-	// グループ
-	for _, g := range p.groups {
-		write(b, "\t%s struct {\n", g.typeName)
-		for _, t := range g.templates {
-			// ローカル名（グループプレフィックスなし）を取得
-			localName := strings.TrimPrefix(t.typeName, g.typeName)
-			write(b, "\t\t%s TemplateName\n", localName)
-		}
-		write(b, "\t}\n")
+	for _, sg := range g.groups {
+		write(b, "%s ", sg.fieldName)
+		generateGroupType(b, sg)
+		write(b, "\n")
 	}
+	write(b, "}")
+}
 
-	write(b, "}{\n")
-
-	// フラットなテンプレートの初期化
-	for _, t := range p.flatTemplates {
-		write(b, "\t%s: %q,\n", t.typeName, t.name)
+// generateGroupValue はグループの名前空間の構造体リテラルを生成する
+func generateGroupValue(b *strings.Builder, g tmplGroup) {
+	generateGroupType(b, g)
+	write(b, "{\n")
+	for _, t := range g.templates {
+		write(b, "%s: %q,\n", t.localName, t.name)
 	}
-
-	// グループの初期化
-	for _, g := range p.groups {
-		write(b, "\t%s: struct {\n", g.typeName)
-		for _, t := range g.templates {
-			localName := strings.TrimPrefix(t.typeName, g.typeName)
-			write(b, "\t\t%s TemplateName\n", localName)
-		}
-		write(b, "\t}{\n")
-		for _, t := range g.templates {
-			localName := strings.TrimPrefix(t.typeName, g.typeName)
-			write(b, "\t\t%s: %q,\n", localName, t.name)
-		}
-		write(b, "\t},\n")
+	for _, sg := range g.groups {
+		write(b, "%s: ", sg.fieldName)
+		generateGroupValue(b, sg)
+		write(b, ",\n")
 	}
-
-	write(b, "}\n\n")
+	write(b, "}")
 }
 
 // generateEmbedDeclarations は各テンプレートのembed宣言を生成する
@@ -272,73 +780,412 @@ func generateEmbedDeclarations(b *strings.Builder, templates []tmpl) {
 }
 
 // generateTemplateInitialization はテンプレート初期化のためのヘルパー関数とマップを生成する
+// 全テンプレートは1つのテンプレートセットに関連付けられ、{{ template "name" }} で互いに呼び出せる
 func generateTemplateInitialization(b *strings.Builder, p *emitPrepared) {
-	// Helper function for template initialization
-	write(b, "func newTemplate(name TemplateName, source string) *template.Template {\n")
-	write(b, "\treturn template.Must(template.New(string(name)).Option(%q).Parse(source))\n", "missingkey=error")
+	all := p.allTemplates()
+	write(b, "// templateFiles lists the templates in parse order, with their paths relative to the package directory\n")
+	write(b, "var templateFiles = []struct {\n")
+	write(b, "\tname   TemplateName\n")
+	write(b, "\tpath   string\n")
+	write(b, "\tsource string\n")
+	write(b, "}{\n")
+	for _, t := range all {
+		write(b, "\t{%s, %q, %s},\n", templateFieldRef(t), t.sourcePath, t.varName)
+	}
 	write(b, "}\n\n")
 
-	// Templates map - initialized once at package initialization
-	write(b, "var templates = map[TemplateName]*template.Template{\n")
+	// 使うテンプレートだけを最初に使うときにパースする（パースのエラーは panic せずに返す）
+	write(b, "// parseTemplates parses the templates in deps, in parse order, into a new set and returns the one named name\n")
+	write(b, "func parseTemplates(name TemplateName, deps []TemplateName) (*template.Template, error) {\n")
+	generateNewSet(b, p, "\t")
+	write(b, "\tfor _, f := range templateFiles {\n")
+	write(b, "\t\tif !slices.Contains(deps, f.name) {\n")
+	write(b, "\t\t\tcontinue\n")
+	write(b, "\t\t}\n")
+	write(b, "\t\tif _, err := set.New(string(f.name)).Parse(f.source); err != nil {\n")
+	write(b, "\t\t\treturn nil, err\n")
+	write(b, "\t\t}\n")
+	write(b, "\t}\n")
+	write(b, "\ttmpl := set.Lookup(string(name))\n")
+	write(b, "\tif tmpl == nil {\n")
+	write(b, "\t\treturn nil, fmt.Errorf(\"template %%q not found\", name)\n")
+	write(b, "\t}\n")
+	write(b, "\treturn tmpl, nil\n")
+	write(b, "}\n\n")
 
-	// フラットなテンプレート
-	for _, t := range p.flatTemplates {
-		fieldRef := "Template." + t.typeName
-		write(b, "\t%s: newTemplate(%s, %s),\n",
-			fieldRef, fieldRef, t.varName)
+	write(b, "// lazyTemplate parses a template on first use\n")
+	write(b, "type lazyTemplate struct {\n")
+	write(b, "\tname TemplateName\n")
+	write(b, "\tdeps []TemplateName // the template and the ones defining the templates it calls\n")
+	write(b, "\tonce sync.Once\n")
+	if p.funcMapExpr != "" {
+		write(b, "\tbase *template.Template // never executed, so that RenderXxxContext can clone it\n")
+	}
+	write(b, "\ttmpl *template.Template\n")
+	write(b, "\terr  error\n")
+	write(b, "}\n\n")
+	write(b, "func (l *lazyTemplate) load() (*template.Template, error) {\n")
+	write(b, "\tl.once.Do(func() {\n")
+	if p.funcMapExpr != "" {
+		write(b, "\t\tl.base, l.err = parseTemplates(l.name, l.deps)\n")
+		write(b, "\t\tif l.err == nil {\n")
+		write(b, "\t\t\tl.tmpl, l.err = l.base.Clone()\n")
+		write(b, "\t\t}\n")
+	} else {
+		write(b, "\t\tl.tmpl, l.err = parseTemplates(l.name, l.deps)\n")
 	}
+	write(b, "\t})\n")
+	write(b, "\treturn l.tmpl, l.err\n")
+	write(b, "}\n\n")
 
-	// グループ内のテンプレート
-	for _, g := range p.groups {
-		for _, t := range g.templates {
-			localName := strings.TrimPrefix(t.typeName, g.typeName)
-			fieldRef := "Template." + g.typeName + "." + localName
-			write(b, "\t%s: newTemplate(%s, %s),\n",
-				fieldRef, fieldRef, t.varName)
+	deps := templateDeps(all)
+	byName := make(map[string]tmpl, len(all))
+	for _, t := range all {
+		byName[t.name] = t
+	}
+	write(b, "var templates = map[TemplateName]*lazyTemplate{\n")
+	for _, t := range all {
+		fieldRef := templateFieldRef(t)
+		refs := make([]string, 0, len(deps[t.name]))
+		for _, d := range deps[t.name] {
+			refs = append(refs, templateFieldRef(byName[d]))
 		}
+		write(b, "\t%s: {name: %s, deps: []TemplateName{%s}},\n", fieldRef, fieldRef, strings.Join(refs, ", "))
 	}
-
 	write(b, "}\n\n")
 }
 
-// generateTemplatesFunction はTemplates()関数を生成する
+// generateNewSet はテンプレートを加える前の空のセット set を作るコードを生成する
+// 埋め込んだテンプレートと、ホットリロードでディスクから読むテンプレートで同じ設定にする
+func generateNewSet(b *strings.Builder, p *emitPrepared, indent string) {
+	if p.funcMapExpr != "" {
+		write(b, "%sset := template.New(\"\").Option(%q).Funcs(template.FuncMap(%s))\n", indent, "missingkey=error", p.funcMapExpr)
+		write(b, "%sset.Funcs(template.FuncMap{%q: context.Background}) // RenderXxxContext replaces it with the render context\n", indent, ContextFunc)
+	} else {
+		write(b, "%sset := template.New(\"\").Option(%q)\n", indent, "missingkey=error")
+	}
+}
+
+// templateFieldRef はテンプレート名の名前空間フィールドへの参照式を返す (グループ対応)
+// 例: "Template.Footer", "Template.MailInvite.Title"
+func templateFieldRef(t tmpl) string {
+	ref := "Template"
+	if t.groupName != "" {
+		for _, part := range strings.Split(t.groupName, "/") {
+			ref += "." + util.Export(part)
+		}
+	}
+	return ref + "." + t.localName
+}
+
+// generateTemplatesFunction はTemplates()関数と、すべてのテンプレートをパースする ParseAll() を生成する
 func generateTemplatesFunction(b *strings.Builder) {
-	write(b, "// Templates returns a map of all templates\n")
+	write(b, "// Templates returns a map of all templates, parsing the ones not used yet\n")
+	write(b, "// Templates that fail to parse are left out; ParseAll reports their errors\n")
 	write(b, "func Templates() map[TemplateName]*template.Template {\n")
-	write(b, "\treturn templates\n")
+	write(b, "\tm := make(map[TemplateName]*template.Template, len(templates))\n")
+	write(b, "\tfor name, l := range templates {\n")
+	write(b, "\t\tif tmpl, err := l.load(); err == nil {\n")
+	write(b, "\t\t\tm[name] = tmpl\n")
+	write(b, "\t\t}\n")
+	write(b, "\t}\n")
+	write(b, "\treturn m\n")
+	write(b, "}\n\n")
+
+	write(b, "// ParseAll parses all templates and returns their parse errors joined\n")
+	write(b, "// Templates are otherwise parsed on first use; call it at startup to fail early\n")
+	write(b, "func ParseAll() error {\n")
+	write(b, "\tvar errs []error\n")
+	write(b, "\tseen := make(map[string]bool) // the templates calling a broken template repeat its error\n")
+	write(b, "\tfor _, f := range templateFiles {\n")
+	write(b, "\t\tif _, err := templates[f.name].load(); err != nil && !seen[err.Error()] {\n")
+	write(b, "\t\t\tseen[err.Error()] = true\n")
+	write(b, "\t\t\terrs = append(errs, err)\n")
+	write(b, "\t\t}\n")
+	write(b, "\t}\n")
+	write(b, "\treturn errors.Join(errs...)\n")
+	write(b, "}\n\n")
+}
+
+// generateHotReload は開発用のホットリロード（EnableHotReload）と、描画するテンプレートを得る lookupTemplate を生成する
+// ホットリロードを有効にしなければ、埋め込んだテンプレートを最初に使うときに一度だけパースしたものを使う
+func generateHotReload(b *strings.Builder, p *emitPrepared) {
+	write(b, "// hotReloader reads the templates from disk and reparses them when a file changes\n")
+	write(b, "type hotReloader struct {\n")
+	write(b, "\tdir      string\n")
+	write(b, "\tmu       sync.Mutex\n")
+	write(b, "\tmodTimes []time.Time\n")
+	write(b, "\tset      *template.Template // never executed, so that it can be cloned\n")
+	write(b, "}\n\n")
+	write(b, "// hotReload is set by EnableHotReload\n")
+	write(b, "var hotReload atomic.Pointer[hotReloader]\n\n")
+
+	write(b, "// EnableHotReload makes Render and the RenderXxx functions read the templates from the files\n")
+	write(b, "// under dir, the directory of this package, and reparse them whenever a file's modification time changes\n")
+	write(b, "// It is meant for local development; without it, the embedded templates are parsed once, on first use\n")
+	write(b, "// Templates still returns the embedded templates\n")
+	write(b, "func EnableHotReload(dir string) error {\n")
+	write(b, "\tr := &hotReloader{dir: dir}\n")
+	write(b, "\tif _, err := r.templateSet(); err != nil {\n")
+	write(b, "\t\treturn err\n")
+	write(b, "\t}\n")
+	write(b, "\thotReload.Store(r)\n")
+	write(b, "\treturn nil\n")
+	write(b, "}\n\n")
+
+	write(b, "// templateSet returns the template set, reparsing it if a template file has changed since the last call\n")
+	write(b, "func (r *hotReloader) templateSet() (*template.Template, error) {\n")
+	write(b, "\tr.mu.Lock()\n")
+	write(b, "\tdefer r.mu.Unlock()\n")
+	write(b, "\tmodTimes := make([]time.Time, len(templateFiles))\n")
+	write(b, "\tfor i, f := range templateFiles {\n")
+	write(b, "\t\tinfo, err := os.Stat(filepath.Join(r.dir, f.path))\n")
+	write(b, "\t\tif err != nil {\n")
+	write(b, "\t\t\treturn nil, err\n")
+	write(b, "\t\t}\n")
+	write(b, "\t\tmodTimes[i] = info.ModTime()\n")
+	write(b, "\t}\n")
+	write(b, "\tif r.set != nil && slices.EqualFunc(modTimes, r.modTimes, time.Time.Equal) {\n")
+	write(b, "\t\treturn r.set, nil\n")
+	write(b, "\t}\n")
+	generateNewSet(b, p, "\t")
+	write(b, "\tfor _, f := range templateFiles {\n")
+	write(b, "\t\tsrc, err := os.ReadFile(filepath.Join(r.dir, f.path))\n")
+	write(b, "\t\tif err != nil {\n")
+	write(b, "\t\t\treturn nil, err\n")
+	write(b, "\t\t}\n")
+	write(b, "\t\tif _, err := set.New(string(f.name)).Parse(string(src)); err != nil {\n")
+	write(b, "\t\t\treturn nil, err\n")
+	write(b, "\t\t}\n")
+	write(b, "\t}\n")
+	write(b, "\tr.set, r.modTimes = set, modTimes\n")
+	write(b, "\treturn set, nil\n")
+	write(b, "}\n\n")
+
+	write(b, "// lookup returns the named template from a copy of the current template set\n")
+	write(b, "func (r *hotReloader) lookup(name TemplateName) (*template.Template, error) {\n")
+	write(b, "\tset, err := r.templateSet()\n")
+	write(b, "\tif err != nil {\n")
+	write(b, "\t\treturn nil, err\n")
+	write(b, "\t}\n")
+	write(b, "\tif set, err = set.Clone(); err != nil {\n")
+	write(b, "\t\treturn nil, err\n")
+	write(b, "\t}\n")
+	write(b, "\ttmpl := set.Lookup(string(name))\n")
+	write(b, "\tif tmpl == nil {\n")
+	write(b, "\t\treturn nil, fmt.Errorf(\"template %%q not found\", name)\n")
+	write(b, "\t}\n")
+	write(b, "\treturn tmpl, nil\n")
+	write(b, "}\n\n")
+
+	write(b, "// lookupTemplate returns the named template, reloaded from disk if hot reload is enabled\n")
+	write(b, "func lookupTemplate(name TemplateName) (*template.Template, error) {\n")
+	write(b, "\tif r := hotReload.Load(); r != nil {\n")
+	write(b, "\t\treturn r.lookup(name)\n")
+	write(b, "\t}\n")
+	write(b, "\tl, ok := templates[name]\n")
+	write(b, "\tif !ok {\n")
+	write(b, "\t\treturn nil, fmt.Errorf(\"template %%q not found\", name)\n")
+	write(b, "\t}\n")
+	write(b, "\treturn l.load()\n")
 	write(b, "}\n\n")
 }
 
 // generateGenericRenderFunction は汎用Render関数を生成する
-func generateGenericRenderFunction(b *strings.Builder) {
+// 既定値があれば WithDefaults を持つパラメータ型（とそのポインタ）のデータに設定し、
+// 必須のフィールドがあれば、Validate メソッドを持つデータを描画の前に検証する
+func generateGenericRenderFunction(b *strings.Builder, p *emitPrepared) {
 	write(b, "// Render renders a template by name with the given data\n")
 	write(b, "func Render(w io.Writer, name TemplateName, data any) error {\n")
-	write(b, "\ttmpl, ok := templates[name]\n")
-	write(b, "\tif !ok {\n")
-	write(b, "\t\treturn fmt.Errorf(\"template %%q not found\", name)\n")
+	write(b, "\ttmpl, err := lookupTemplate(name)\n")
+	write(b, "\tif err != nil {\n")
+	write(b, "\t\treturn err\n")
 	write(b, "\t}\n")
+	var withDefaults []string
+	for _, t := range p.allTemplates() {
+		if t.bound == nil && p.defaults.needed(t.typeName) {
+			withDefaults = append(withDefaults, t.typeName)
+		}
+	}
+	if len(withDefaults) > 0 {
+		write(b, "\tswitch d := data.(type) {\n")
+		for _, typeName := range withDefaults {
+			write(b, "\tcase %s:\n", typeName)
+			write(b, "\t\tdata = d.WithDefaults()\n")
+			write(b, "\tcase *%s:\n", typeName)
+			write(b, "\t\tif d != nil {\n")
+			write(b, "\t\t\tdata = d.WithDefaults()\n")
+			write(b, "\t\t}\n")
+		}
+		write(b, "\t}\n")
+	}
+	var validated []string
+	for _, t := range p.allTemplates() {
+		if t.bound == nil && p.validation.needed(t.typeName) {
+			validated = append(validated, t.typeName)
+		}
+	}
+	if len(validated) > 0 {
+		write(b, "\tswitch d := data.(type) {\n")
+		for _, typeName := range validated {
+			write(b, "\tcase %s:\n", typeName)
+			write(b, "\t\terr = d.Validate()\n")
+			write(b, "\tcase *%s:\n", typeName)
+			write(b, "\t\tif d != nil {\n")
+			write(b, "\t\t\terr = d.Validate()\n")
+			write(b, "\t\t}\n")
+		}
+		write(b, "\t}\n")
+		write(b, "\tif err != nil {\n")
+		write(b, "\t\treturn err\n")
+		write(b, "\t}\n")
+	}
 	write(b, "\treturn tmpl.Execute(w, data)\n")
 	write(b, "}\n\n")
 }
 
+// maxPooledBufferSize は bufferPool に戻すバッファの容量の上限（大きな出力のバッファを持ち続けない）
+const maxPooledBufferSize = 64 << 10
+
+// generateBufferPool は RenderXxxString / RenderXxxBytes が使うバッファのプールを生成する
+func generateBufferPool(b *strings.Builder) {
+	write(b, "// bufferPool holds the buffers used by the RenderXxxString and RenderXxxBytes functions\n")
+	write(b, "var bufferPool = sync.Pool{\n")
+	write(b, "\tNew: func() any { return new(bytes.Buffer) },\n")
+	write(b, "}\n\n")
+	write(b, "func getBuffer() *bytes.Buffer {\n")
+	write(b, "\tbuf := bufferPool.Get().(*bytes.Buffer)\n")
+	write(b, "\tbuf.Reset()\n")
+	write(b, "\treturn buf\n")
+	write(b, "}\n\n")
+	write(b, "func putBuffer(buf *bytes.Buffer) {\n")
+	write(b, "\tif buf.Cap() > %d { // do not keep large buffers alive\n", maxPooledBufferSize)
+	write(b, "\t\treturn\n")
+	write(b, "\t}\n")
+	write(b, "\tbufferPool.Put(buf)\n")
+	write(b, "}\n\n")
+}
+
+// generateContextHelpers は RenderXxxContext が使う、ctx が終わったら書き込みを止める Writer を生成する
+// FuncMap を組み込むときは、context 関数が ctx を返すテンプレートを得る関数も生成する
+func generateContextHelpers(b *strings.Builder, p *emitPrepared) {
+	write(b, "// contextWriter stops writing once ctx is done\n")
+	write(b, "type contextWriter struct {\n")
+	write(b, "\tctx context.Context\n")
+	write(b, "\tw   io.Writer\n")
+	write(b, "}\n\n")
+	write(b, "func (cw *contextWriter) Write(b []byte) (int, error) {\n")
+	write(b, "\tif err := cw.ctx.Err(); err != nil {\n")
+	write(b, "\t\treturn 0, err\n")
+	write(b, "\t}\n")
+	write(b, "\treturn cw.w.Write(b)\n")
+	write(b, "}\n\n")
+	if p.funcMapExpr == "" {
+		return
+	}
+
+	// 実行済みのテンプレートは（html/template では）Clone できないので、lazyTemplate の実行しない base を複製する
+	// 複製（html/template では再エスケープも）は重いので、テンプレートごとにプールして使い回す。
+	// 複製の context 関数は固定のクロージャで、描画のたびに差し替える ctx を返す
+	write(b, "// contextClone is a copy of a template set whose %s func returns the ctx of the render using it\n", ContextFunc)
+	write(b, "type contextClone struct {\n")
+	write(b, "\ttmpl *template.Template\n")
+	write(b, "\tctx  context.Context // set while a RenderXxxContext call executes tmpl\n")
+	write(b, "}\n\n")
+	write(b, "// contextPool holds the copies of one template made from src\n")
+	write(b, "type contextPool struct {\n")
+	write(b, "\tsrc  *template.Template\n")
+	write(b, "\tpool sync.Pool\n")
+	write(b, "}\n\n")
+	write(b, "// contextPools keeps a contextPool per template, so that RenderXxxContext does not clone the set on every call\n")
+	write(b, "var contextPools sync.Map // TemplateName -> *contextPool\n\n")
+
+	write(b, "// contextSource returns the template the copies for RenderXxxContext are made from\n")
+	write(b, "// With hot reload it is the current template set, so that the copies of an old set are dropped after a reload\n")
+	write(b, "func contextSource(name TemplateName) (*template.Template, error) {\n")
+	write(b, "\tif r := hotReload.Load(); r != nil {\n")
+	write(b, "\t\treturn r.templateSet()\n")
+	write(b, "\t}\n")
+	write(b, "\tl, ok := templates[name]\n")
+	write(b, "\tif !ok {\n")
+	write(b, "\t\treturn nil, fmt.Errorf(\"template %%q not found\", name)\n")
+	write(b, "\t}\n")
+	write(b, "\tif _, err := l.load(); err != nil {\n")
+	write(b, "\t\treturn nil, err\n")
+	write(b, "\t}\n")
+	write(b, "\treturn l.base, nil\n")
+	write(b, "}\n\n")
+
+	write(b, "// contextTemplate returns the named template from a copy of its set whose %s func returns ctx\n", ContextFunc)
+	write(b, "// Call release once the template has been executed, so that later calls can reuse the copy\n")
+	write(b, "func contextTemplate(ctx context.Context, name TemplateName) (tmpl *template.Template, release func(), err error) {\n")
+	write(b, "\tsrc, err := contextSource(name)\n")
+	write(b, "\tif err != nil {\n")
+	write(b, "\t\treturn nil, nil, err\n")
+	write(b, "\t}\n")
+	write(b, "\tv, _ := contextPools.Load(name)\n")
+	write(b, "\tp, _ := v.(*contextPool)\n")
+	write(b, "\tif p == nil || p.src != src {\n")
+	write(b, "\t\tp = &contextPool{src: src}\n")
+	write(b, "\t\tcontextPools.Store(name, p)\n")
+	write(b, "\t}\n")
+	write(b, "\tc, _ := p.pool.Get().(*contextClone)\n")
+	write(b, "\tif c == nil {\n")
+	write(b, "\t\tset, err := src.Clone()\n")
+	write(b, "\t\tif err != nil {\n")
+	write(b, "\t\t\treturn nil, nil, err\n")
+	write(b, "\t\t}\n")
+	write(b, "\t\tclone := &contextClone{tmpl: set.Lookup(string(name))}\n")
+	write(b, "\t\tif clone.tmpl == nil {\n")
+	write(b, "\t\t\treturn nil, nil, fmt.Errorf(\"template %%q not found\", name)\n")
+	write(b, "\t\t}\n")
+	write(b, "\t\tset.Funcs(template.FuncMap{%q: func() context.Context { return clone.ctx }})\n", ContextFunc)
+	write(b, "\t\tc = clone\n")
+	write(b, "\t}\n")
+	write(b, "\tc.ctx = ctx\n")
+	write(b, "\treturn c.tmpl, func() {\n")
+	write(b, "\t\tc.ctx = nil\n")
+	write(b, "\t\tp.pool.Put(c)\n")
+	write(b, "\t}, nil\n")
+	write(b, "}\n\n")
+}
+
+// generateMissingFieldsError は Validate が返すエラー型を生成する（必須のフィールドがあるときのみ）
+func generateMissingFieldsError(b *strings.Builder, p *emitPrepared) {
+	if len(p.validation.methods) == 0 {
+		return
+	}
+	write(b, "// %s is returned by Validate when required fields are not set\n", missingFieldsError)
+	write(b, "type %s struct {\n", missingFieldsError)
+	write(b, "\tTemplate TemplateName\n")
+	write(b, "\tFields   []string // paths of the missing fields (e.g. \"User.Email\", \"Items[0].ID\")\n")
+	write(b, "}\n\n")
+	write(b, "func (e *%s) Error() string {\n", missingFieldsError)
+	write(b, "\treturn fmt.Sprintf(\"template %%q: missing required fields: %%s\", e.Template, strings.Join(e.Fields, \", \"))\n")
+	write(b, "}\n\n")
+}
+
 // generateTemplateBlocks は各テンプレートごとの型定義とRender関数を生成する
-func generateTemplateBlocks(b *strings.Builder, templates []tmpl) {
+func generateTemplateBlocks(b *strings.Builder, p *emitPrepared) {
 	generatedTypes := make(map[string]bool)
 
-	for _, t := range templates {
+	for _, t := range p.allTemplates() {
 		// テンプレートブロックのセパレータ
 		write(b, "// ============================================================\n")
 		write(b, "// %s template\n", t.name)
 		write(b, "// ============================================================\n\n")
 
-		generateNamedTypes(b, t, generatedTypes)
-		generateParamType(b, t)
-		generateRenderFunction(b, t)
+		if t.bound == nil {
+			generateNamedTypes(b, p, t, generatedTypes)
+			generateParamType(b, p, t)
+		}
+		generateRenderFunction(b, p, t)
 	}
 }
 
 // generateNamedTypes は名前付き型を生成する
-func generateNamedTypes(b *strings.Builder, t tmpl, generatedTypes map[string]bool) {
+func generateNamedTypes(b *strings.Builder, p *emitPrepared, t tmpl, generatedTypes map[string]bool) {
+	generateEnums(b, t)
 	for _, namedType := range t.typed.NamedTypes {
 		// 型名の衝突を避けるため、プレフィックスを付ける
 		typeName := t.typeName + namedType.Name
@@ -347,56 +1194,156 @@ func generateNamedTypes(b *strings.Builder, t tmpl, generatedTypes map[string]bo
 		}
 		generatedTypes[typeName] = true
 
+		writeDoc(b, "", namedType.Doc)
 		write(b, "type %s struct {\n", typeName)
-		// フィールドをソートして順序を安定化
-		fieldNames := slices.Sorted(maps.Keys(namedType.Fields))
-		for _, fieldName := range fieldNames {
-			field := namedType.Fields[fieldName]
-			// フィールドの型名も調整が必要な場合がある
-			goType := adjustTypeForTemplate(field.GoType, t.typeName)
-			write(b, "\t%s %s\n", field.Name, goType)
-		}
+		generateStructFields(b, p, t, namedType.Embeds, namedType.Fields)
 		write(b, "}\n\n")
+		write(b, "%s", p.defaults.methods[typeName])
+		write(b, "%s", p.validation.methods[typeName])
 	}
 }
 
 // generateParamType はメインのパラメータ型を生成する
-func generateParamType(b *strings.Builder, t tmpl) {
+func generateParamType(b *strings.Builder, p *emitPrepared, t tmpl) {
 	write(b, "// %s represents parameters for %s template\n", t.typeName, t.name)
-	write(b, "type %s struct {\n", t.typeName)
-	// トップレベルフィールドをソートして順序を安定化
-	topFieldNames := slices.Sorted(maps.Keys(t.typed.Fields))
-	for _, fieldName := range topFieldNames {
-		field := t.typed.Fields[fieldName]
-		// フィールドの型名も調整が必要な場合がある
-		goType := adjustTypeForTemplate(field.GoType, t.typeName)
-		write(b, "\t%s %s\n", field.Name, goType)
+	if t.typed.Doc != "" {
+		write(b, "//\n")
+		writeDoc(b, "", t.typed.Doc)
 	}
+	write(b, "type %s struct {\n", t.typeName)
+	generateStructFields(b, p, t, t.typed.Embeds, t.typed.Fields)
 	write(b, "}\n\n")
+	write(b, "%s", p.defaults.methods[t.typeName])
+	write(b, "%s", p.validation.methods[t.typeName])
+}
+
+// generateStructFields は構造体の埋め込みフィールドと通常フィールドを生成する
+func generateStructFields(b *strings.Builder, p *emitPrepared, t tmpl, embeds []string, fields map[string]*typing.TypedField) {
+	// 埋め込むテンプレートの型を先頭に並べる
+	for _, e := range embeds {
+		write(b, "\t%s\n", p.typeNames[e])
+	}
+	// フィールドをソートして順序を安定化
+	fieldNames := slices.Sorted(maps.Keys(fields))
+	for _, fieldName := range fieldNames {
+		field := fields[fieldName]
+		writeDoc(b, "\t", field.Doc)
+		if tag := p.structTag(field); tag != "" {
+			write(b, "\t%s %s %s\n", field.Name, p.fieldType(field, t), tag)
+		} else {
+			write(b, "\t%s %s\n", field.Name, p.fieldType(field, t))
+		}
+	}
+}
+
+// fieldType はフィールドの生成コード上の型名を返す
+func (p *emitPrepared) fieldType(field *typing.TypedField, t tmpl) string {
+	// 別テンプレートを参照するフィールドはそのテンプレートの型
+	if field.Template != "" {
+		return p.typeNames[field.Template]
+	}
+	// フィールドの型名も調整が必要な場合がある
+	return adjustTypeForTemplate(field.GoType, t.typeName)
+}
+
+// generatePrepareParams は描画の前に既定値を設定してパラメータを検証するコードを生成する
+func generatePrepareParams(b *strings.Builder, p *emitPrepared, t tmpl) {
+	if p.defaults.needed(t.typeName) && t.bound == nil {
+		write(b, "\tp = p.WithDefaults()\n")
+	}
+	if p.validation.needed(t.typeName) && t.bound == nil {
+		write(b, "\tif err := p.Validate(); err != nil {\n")
+		write(b, "\t\treturn err\n")
+		write(b, "\t}\n")
+	}
 }
 
 // generateRenderFunction は型安全なRender関数を生成する
-func generateRenderFunction(b *strings.Builder, t tmpl) {
+func generateRenderFunction(b *strings.Builder, p *emitPrepared, t tmpl) {
 	funcName := "Render" + t.typeName
+	fieldRef := templateFieldRef(t)
 
-	// フィールド参照を構築 (グループ対応)
-	var fieldRef string
-	if t.groupName != "" {
-		groupTypeName := util.Export(t.groupName)
-		localName := strings.TrimPrefix(t.typeName, groupTypeName)
-		fieldRef = "Template." + groupTypeName + "." + localName
+	write(b, "// %s renders the %s template\n", funcName, t.name)
+	if t.typed.Doc != "" {
+		write(b, "//\n")
+		writeDoc(b, "", t.typed.Doc)
+	}
+	write(b, "func %s(w io.Writer, p %s) error {\n", funcName, p.typeNames[t.name])
+	write(b, "\ttmpl, err := lookupTemplate(%s)\n", fieldRef)
+	write(b, "\tif err != nil {\n")
+	write(b, "\t\treturn err\n")
+	write(b, "\t}\n")
+	generatePrepareParams(b, p, t)
+	write(b, "\treturn tmpl.Execute(w, p)\n")
+	write(b, "}\n\n")
+
+	// ctx が終わったら書き込みを止める版
+	write(b, "// %sContext renders the %s template, stopping once ctx is done\n", funcName, t.name)
+	write(b, "// If ctx is done before or during rendering, it returns ctx.Err()\n")
+	if p.funcMapExpr != "" {
+		write(b, "// The %s template func returns ctx\n", ContextFunc)
+	}
+	write(b, "func %sContext(ctx context.Context, w io.Writer, p %s) error {\n", funcName, p.typeNames[t.name])
+	write(b, "\tif err := ctx.Err(); err != nil {\n")
+	write(b, "\t\treturn err\n")
+	write(b, "\t}\n")
+	if p.funcMapExpr != "" {
+		write(b, "\ttmpl, release, err := contextTemplate(ctx, %s)\n", fieldRef)
 	} else {
-		fieldRef = "Template." + t.typeName
+		write(b, "\ttmpl, err := lookupTemplate(%s)\n", fieldRef)
+	}
+	write(b, "\tif err != nil {\n")
+	write(b, "\t\treturn err\n")
+	write(b, "\t}\n")
+	if p.funcMapExpr != "" {
+		write(b, "\tdefer release()\n")
 	}
+	generatePrepareParams(b, p, t)
+	write(b, "\tif err := tmpl.Execute(&contextWriter{ctx: ctx, w: w}, p); err != nil {\n")
+	write(b, "\t\tif ctxErr := ctx.Err(); ctxErr != nil {\n")
+	write(b, "\t\t\treturn ctxErr\n")
+	write(b, "\t\t}\n")
+	write(b, "\t\treturn err\n")
+	write(b, "\t}\n")
+	write(b, "\treturn nil\n")
+	write(b, "}\n\n")
 
-	write(b, "// %s renders the %s template\n", funcName, t.name)
-	write(b, "func %s(w io.Writer, p %s) error {\n", funcName, t.typeName)
-	write(b, "\ttmpl, ok := templates[%s]\n", fieldRef)
-	write(b, "\tif !ok {\n")
-	write(b, "\t\treturn fmt.Errorf(\"template %%q not found\", %s)\n", fieldRef)
+	// 出力を文字列・バイト列で返す版（エラーなら途中までの出力は捨てる）
+	write(b, "// %sString renders the %s template and returns the output as a string\n", funcName, t.name)
+	write(b, "// On error, it returns an empty string and discards any partial output\n")
+	write(b, "func %sString(p %s) (string, error) {\n", funcName, p.typeNames[t.name])
+	write(b, "\tbuf := getBuffer()\n")
+	write(b, "\tdefer putBuffer(buf)\n")
+	write(b, "\tif err := %s(buf, p); err != nil {\n", funcName)
+	write(b, "\t\treturn \"\", err\n")
 	write(b, "\t}\n")
-	write(b, "\treturn tmpl.Execute(w, p)\n")
+	write(b, "\treturn buf.String(), nil\n")
 	write(b, "}\n\n")
+
+	write(b, "// %sBytes renders the %s template and returns the output as a byte slice\n", funcName, t.name)
+	write(b, "// On error, it returns nil and discards any partial output\n")
+	write(b, "func %sBytes(p %s) ([]byte, error) {\n", funcName, p.typeNames[t.name])
+	write(b, "\tbuf := getBuffer()\n")
+	write(b, "\tdefer putBuffer(buf)\n")
+	write(b, "\tif err := %s(buf, p); err != nil {\n", funcName)
+	write(b, "\t\treturn nil, err\n")
+	write(b, "\t}\n")
+	write(b, "\treturn bytes.Clone(buf.Bytes()), nil // the buffer is reused\n")
+	write(b, "}\n\n")
+}
+
+// writeDoc は説明 text を行ごとにドキュメントコメントとして書く（text が空なら何もしない）
+func writeDoc(b *strings.Builder, indent, text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			write(b, "%s//\n", indent)
+		} else {
+			write(b, "%s// %s\n", indent, line)
+		}
+	}
 }
 
 // formatCode はgo/formatでコードをフォーマットする
@@ -412,6 +1359,7 @@ func formatCode(code string) (string, error) {
 // basedir からの相対パスでグループ判定を行う
 // 例: basedir="templates", path="templates/footer.tmpl" -> "footer" (フラット)
 // 例: basedir="templates", path="templates/email/welcome.tmpl" -> "email/welcome" (グループ)
+// 例: basedir="templates", path="templates/mail/account/title.tmpl" -> "mail/account/title" (入れ子のグループ)
 func extractTemplateName(path string, basedir string) (string, error) {
 	// basedir からの相対パスを取得
 	absPath, err := filepath.Abs(path)
@@ -428,8 +1376,9 @@ func extractTemplateName(path string, basedir string) (string, error) {
 		return "", fmt.Errorf("path %s is not under basedir %s", path, basedir)
 	}
 
-	// 拡張子を削除
+	// 拡張子を削除（*.html.tmpl は ".html" も含めて削除）
 	pathWithoutExt := strings.TrimSuffix(relPath, filepath.Ext(relPath))
+	pathWithoutExt = strings.TrimSuffix(pathWithoutExt, ".html")
 
 	// ディレクトリ区切りで分割
 	parts := strings.Split(filepath.ToSlash(pathWithoutExt), "/")
@@ -439,15 +1388,20 @@ func extractTemplateName(path string, basedir string) (string, error) {
 		parts[i] = cleanName(part)
 	}
 
-	// 階層チェック（フラット=1パーツ、グループ=2パーツ、それ以上はエラー）
-	if len(parts) > 2 {
-		return "", fmt.Errorf("template nesting too deep: %s (max 1 level of grouping)", relPath)
-	}
-
 	// パスとして結合
 	return strings.Join(parts, "/"), nil
 }
 
+// exportPath はテンプレート名の各パーツをエクスポートして連結した型名を返す
+// 例: "mail/account/created/title" -> "MailAccountCreatedTitle"
+func exportPath(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "/") {
+		b.WriteString(util.Export(part))
+	}
+	return b.String()
+}
+
 // cleanName は名前から数字プレフィックスを削除し、ハイフンをアンダースコアに変換する
 func cleanName(name string) string {
 	// 数字プレフィックスを削除（例: "01_header" -> "header", "1-mail" -> "mail"）
@@ -1,19 +1,76 @@
 package gen_test
 
 import (
+	"encoding/json"
+	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"go/types"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
 
+	"github.com/bellwood4486/tmpltype/internal/funcmap"
 	"github.com/bellwood4486/tmpltype/internal/gen"
+	"github.com/bellwood4486/tmpltype/internal/scan"
+	"github.com/bellwood4486/tmpltype/internal/typing"
+	"github.com/bellwood4486/tmpltype/internal/util"
 )
 
+// goCache は生成コードを一時モジュールでビルドするテストが共有するビルドキャッシュ
+// テストごとに空のキャッシュから標準ライブラリをビルドし直さないようにする
+var goCache string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "tmpltype-gocache-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	goCache = dir
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// goInTempModule は files（パス -> 内容。go.mod は加える）を一時モジュールに書き、go コマンドを実行して出力を返す
+// 失敗したら生成コード（files["gen.go"]）と一緒に報告する
+func goInTempModule(t *testing.T, files map[string]string, args ...string) string {
+	t.Helper()
+	if runtime.GOOS == "js" || runtime.GOOS == "wasip1" {
+		t.Skip("skip on restricted platforms")
+	}
+
+	dir := t.TempDir()
+	files = maps.Clone(files)
+	files["go.mod"] = "module example.com/tmpmod\n\ngo 1.25\n"
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOCACHE="+goCache)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go %s failed: %v\n%s\n%s", strings.Join(args, " "), err, out, files["gen.go"])
+	}
+	return string(out)
+}
+
 func parseCode(t *testing.T, code string) *ast.File {
 	t.Helper()
 	fset := token.NewFileSet()
@@ -205,7 +262,7 @@ func TestEmit_RangeAndIndex_TypesAndOrder(t *testing.T) {
 		t.Fatalf("EmailItemsItem fields not sorted as expected: got %s, %s", it.Fields.List[0].Names[0].Name, it.Fields.List[1].Names[0].Name)
 	}
 
-	params := findType(f, "Email")  // 新しいフォーマット
+	params := findType(f, "Email") // 新しいフォーマット
 	if params == nil || len(params.Fields.List) != 2 {
 		t.Fatalf("Email unexpected")
 	}
@@ -253,38 +310,25 @@ func TestEmit_Golden_Simple(t *testing.T) {
 }
 
 func TestEmit_CompilesInTempModule(t *testing.T) {
-	if runtime.GOOS == "js" || runtime.GOOS == "wasip1" {
-		t.Skip("skip on restricted platforms")
+	// @param のパッケージ修飾付きの型の import も確かめる
+	units := []gen.Unit{
+		{Pkg: "x", SourcePath: "tpl.tmpl", SourceLiteral: "Hello {{ .Message }}"},
+		{Pkg: "x", SourcePath: "imports.tmpl", SourceLiteral: `
+{{/* @param CreatedAt time.Time */}}
+{{/* @param Links []struct{URL *url.URL; Raw json.RawMessage} */}}
+{{ .CreatedAt }}{{ range .Links }}{{ .URL }}{{ .Raw }}{{ end }}
+`},
 	}
-
-	u := gen.Unit{Pkg: "x", SourcePath: "tpl.tmpl", SourceLiteral: "Hello {{ .Message }}"}
-	code, err := gen.Emit([]gen.Unit{u}, ".")
+	code, err := gen.Emit(units, ".")
 	if err != nil {
 		t.Fatalf("Emit failed: %v", err)
 	}
 
-	dir := t.TempDir()
-	// Create module
-	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/tmpmod\n\ngo 1.25\n"), 0644); err != nil {
-		t.Fatal(err)
-	}
-	// Write the template file for go:embed
-	if err := os.WriteFile(filepath.Join(dir, u.SourcePath), []byte("content"), 0644); err != nil {
-		t.Fatal(err)
-	}
-	// Write generated code
-	if err := os.WriteFile(filepath.Join(dir, "gen.go"), []byte(code), 0644); err != nil {
-		t.Fatal(err)
-	}
-
-	cmd := exec.Command("go", "build", "./...")
-	cmd.Dir = dir
-	// Ensure build cache is writable within sandbox
-	cmd.Env = append(os.Environ(), "GOCACHE="+filepath.Join(dir, ".gocache"))
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		t.Fatalf("go build failed: %v\n%s", err, string(out))
+	files := map[string]string{"gen.go": code}
+	for _, u := range units {
+		files[u.SourcePath] = u.SourceLiteral
 	}
+	goInTempModule(t, files, "build", "./...")
 }
 
 func TestEmit_WithParamOverride_BasicTypes(t *testing.T) {
@@ -393,3 +437,1951 @@ func TestEmit_WithParamOverride_SliceType(t *testing.T) {
 	}
 }
 
+func TestEmit_TemplateSet_PartialReferenceAndEmbed(t *testing.T) {
+	units := []gen.Unit{
+		{Pkg: "x", SourcePath: "header.tmpl", SourceLiteral: "<h1>{{ .Title }}</h1>"},
+		{Pkg: "x", SourcePath: "footer.tmpl", SourceLiteral: "<p>{{ .Copyright }}</p>"},
+		{Pkg: "x", SourcePath: "page.tmpl", SourceLiteral: `{{ template "header" .Header }}{{ .Body }}{{ template "footer" . }}{{ .Copyright }}`},
+	}
+
+	code, err := gen.Emit(units, ".")
+	if err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	// page は呼び出す header と footer と一緒にパースする
+	if !strings.Contains(code, "{name: Template.Page, deps: []TemplateName{Template.Footer, Template.Header, Template.Page}}") {
+		t.Fatalf("header and footer are not parsed with page\n%s", code)
+	}
+
+	f := parseCode(t, code)
+	page := findType(f, "Page")
+	if page == nil {
+		t.Fatal("Page type not found")
+	}
+
+	// 埋め込み Footer + Body + Header（Copyright は Footer から昇格するので持たない）
+	if len(page.Fields.List) != 3 {
+		t.Fatalf("Page fields = %d; want 3\n%s", len(page.Fields.List), code)
+	}
+	embed := page.Fields.List[0]
+	if len(embed.Names) != 0 {
+		t.Fatalf("Page first field should be embedded Footer")
+	}
+	if id, ok := embed.Type.(*ast.Ident); !ok || id.Name != "Footer" {
+		t.Fatalf("Page embedded type not Footer")
+	}
+	header := page.Fields.List[2]
+	if header.Names[0].Name != "Header" {
+		t.Fatalf("Page third field = %s; want Header", header.Names[0].Name)
+	}
+	if id, ok := header.Type.(*ast.Ident); !ok || id.Name != "Header" {
+		t.Fatalf("Page.Header type not Header")
+	}
+}
+
+func TestEmit_TemplateSet_ReferenceCycle(t *testing.T) {
+	units := []gen.Unit{
+		{Pkg: "x", SourcePath: "a.tmpl", SourceLiteral: `{{ template "b" .B }}`},
+		{Pkg: "x", SourcePath: "b.tmpl", SourceLiteral: `{{ template "a" . }}`},
+	}
+
+	_, err := gen.Emit(units, ".")
+	if err == nil {
+		t.Fatal("expected error for template reference cycle, got nil")
+	}
+	if !strings.Contains(err.Error(), "a -> b -> a") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestEmit_TemplateSet_EmbedConflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources map[string]string
+		want    string
+	}{
+		{
+			name: "field promoted from two partials",
+			sources: map[string]string{
+				"header.tmpl": "<h1>{{ .Title }}</h1>",
+				"footer.tmpl": "<p>{{ .Title }}</p>",
+				"page.tmpl":   `{{ template "header" . }}{{ template "footer" . }}`,
+			},
+			want: `footer.tmpl:1:7: field Title is promoted into Page from both {{ template "header" . }} (header.tmpl:1:8) and {{ template "footer" . }}, so it is ambiguous`,
+		},
+		{
+			name: "field promoted through a shared partial",
+			sources: map[string]string{
+				"nav.tmpl":    "{{ .Home }}",
+				"header.tmpl": `{{ template "nav" . }}`,
+				"footer.tmpl": `{{ template "nav" . }}`,
+				"page.tmpl":   `{{ template "header" . }}{{ template "footer" . }}`,
+			},
+			want: `nav.tmpl:1:4: field Home is promoted into Page from both {{ template "header" . }} (nav.tmpl:1:4) and {{ template "footer" . }}, so it is ambiguous`,
+		},
+		{
+			name: "field named like the embedded type",
+			sources: map[string]string{
+				"header.tmpl": "<h1>{{ .Title }}</h1>",
+				"page.tmpl":   `{{ template "header" . }}{{ .Header.Lang }}`,
+			},
+			want: `page.tmpl:1:29: field Header conflicts with the type Header embedded by {{ template "header" . }}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var units []gen.Unit
+			for _, p := range slices.Sorted(maps.Keys(tt.sources)) {
+				units = append(units, gen.Unit{Pkg: "x", SourcePath: p, SourceLiteral: tt.sources[p]})
+			}
+			_, err := gen.Emit(units, ".")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmit_TemplateSet_Embed_CompilesInTempModule(t *testing.T) {
+	// 同じフィールドを使う部分テンプレートは、片方にフィールドを渡せば埋め込みと参照を一緒に使える
+	sources := map[string]string{
+		"header.tmpl": "<h1>{{ .Title }}</h1>",
+		"footer.tmpl": "<p>{{ .Title }}</p>",
+		"page.tmpl":   `{{ template "header" .Top }}{{ template "footer" . }}{{ .Body }}`,
+	}
+	var units []gen.Unit
+	for _, p := range slices.Sorted(maps.Keys(sources)) {
+		units = append(units, gen.Unit{Pkg: "main", SourcePath: p, SourceLiteral: sources[p]})
+	}
+	code, err := gen.Emit(units, ".")
+	if err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	main := `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(RenderPageString(Page{Top: Header{Title: "A"}, Footer: Footer{Title: "B"}, Body: "C"}))
+}
+`
+	files := map[string]string{"gen.go": code, "main.go": main}
+	maps.Copy(files, sources)
+	out := goInTempModule(t, files, "run", ".")
+	if want := "<h1>A</h1><p>B</p>C <nil>\n"; out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
+
+func TestEmit_HTMLMode_AutoByExtension(t *testing.T) {
+	src := `
+{{/* @param Bio template.HTML */}}
+{{/* @param Homepage template.URL */}}
+<a href="{{ .Homepage }}">{{ .Name }}</a>{{ .Bio }}
+`
+	u := gen.Unit{Pkg: "x", SourcePath: "profile.html.tmpl", SourceLiteral: src}
+
+	code, err := gen.Emit([]gen.Unit{u}, ".")
+	if err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	f := parseCode(t, code)
+	if !hasImport(f, "html/template", "") {
+		t.Fatalf("import html/template not found\n%s", code)
+	}
+	if hasImport(f, "text/template", "") {
+		t.Fatalf("text/template must not be imported in html mode\n%s", code)
+	}
+
+	// *.html.tmpl の ".html" はテンプレート名に含めない
+	profile := findType(f, "Profile")
+	if profile == nil {
+		t.Fatalf("Profile type not found\n%s", code)
+	}
+	for _, field := range profile.Fields.List {
+		if field.Names[0].Name != "Bio" {
+			continue
+		}
+		se, ok := field.Type.(*ast.SelectorExpr)
+		if !ok || se.Sel.Name != "HTML" {
+			t.Fatalf("Profile.Bio type not template.HTML")
+		}
+	}
+}
+
+func TestEmit_HTMLMode_Explicit(t *testing.T) {
+	u := gen.Unit{Pkg: "x", SourcePath: "page.tmpl", SourceLiteral: "<p>{{ .Message }}</p>"}
+
+	code, err := gen.EmitWithOptions([]gen.Unit{u}, ".", gen.Options{Mode: gen.ModeHTML})
+	if err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if !hasImport(parseCode(t, code), "html/template", "") {
+		t.Fatalf("import html/template not found\n%s", code)
+	}
+}
+
+func TestEmit_AutoMode_MixedExtensions(t *testing.T) {
+	units := []gen.Unit{
+		{Pkg: "x", SourcePath: "page.html.tmpl", SourceLiteral: "<p>{{ .Message }}</p>"},
+		{Pkg: "x", SourcePath: "subject.tmpl", SourceLiteral: "{{ .Title }}"},
+	}
+
+	if _, err := gen.Emit(units, "."); err == nil {
+		t.Fatal("expected error for mixed .html.tmpl and .tmpl templates, got nil")
+	}
+
+	// 明示的にモードを指定すれば混在していても生成できる
+	if _, err := gen.EmitWithOptions(units, ".", gen.Options{Mode: gen.ModeText}); err != nil {
+		t.Fatalf("Emit with explicit mode failed: %v", err)
+	}
+}
+
+func TestEmit_FuncMap_InstalledAndInfersArgumentTypes(t *testing.T) {
+	src := `
+{{/* @func price func(float64) string */}}
+{{ price .Amount }} {{ shout .Name }}
+`
+	u := gen.Unit{Pkg: "x", SourcePath: "tpl.tmpl", SourceLiteral: src}
+	fm, err := funcmap.ParseRef("templateFuncs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fm.Funcs["shout"] = scan.Func{}
+
+	code, err := gen.EmitWithOptions([]gen.Unit{u}, ".", gen.Options{FuncMap: fm})
+	if err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if !strings.Contains(code, `.Funcs(template.FuncMap(templateFuncs))`) {
+		t.Fatalf("FuncMap not installed\n%s", code)
+	}
+
+	f := parseCode(t, code)
+	params := findType(f, "Tpl")
+	if params == nil || len(params.Fields.List) != 2 {
+		t.Fatalf("Tpl fields unexpected\n%s", code)
+	}
+	if id, ok := params.Fields.List[0].Type.(*ast.Ident); !ok || params.Fields.List[0].Names[0].Name != "Amount" || id.Name != "float64" {
+		t.Fatalf("Tpl.Amount not float64\n%s", code)
+	}
+}
+
+func TestEmit_BuiltinTypeInference_ParamTakesPrecedence(t *testing.T) {
+	src := `
+{{/* @param Total int64 */}}
+{{ if gt .Count 10 }}{{ printf "%.2f" .Total }}{{ end }}
+{{ if and .IsAdmin .IsActive }}admin{{ end }}
+{{ len .Tags }}
+`
+	u := gen.Unit{Pkg: "x", SourcePath: "tpl.tmpl", SourceLiteral: src}
+
+	code, err := gen.Emit([]gen.Unit{u}, ".")
+	if err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	f := parseCode(t, code)
+	params := findType(f, "Tpl")
+	if params == nil {
+		t.Fatalf("Tpl not found\n%s", code)
+	}
+	want := map[string]string{
+		"Count":    "int",
+		"IsActive": "bool",
+		"IsAdmin":  "bool",
+		"Tags":     "[]string",
+		"Total":    "int64", // @param は推論より優先
+	}
+	got := make(map[string]string)
+	for _, field := range params.Fields.List {
+		got[field.Names[0].Name] = types.ExprString(field.Type)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tpl fields = %v; want %v\n%s", got, want, code)
+	}
+}
+
+func TestEmit_Variables_CompilesInTempModule(t *testing.T) {
+	src := `
+{{ range $i, $tag := .Tags }}{{ if $i }}, {{ end }}{{ $tag }}{{ end }}
+{{ range $k, $s := .Sections }}{{ printf "%s" $k }}: {{ $s.Title }}{{ end }}
+{{ $u := .User }}{{ $u.Name }}
+`
+	u := gen.Unit{Pkg: "main", SourcePath: "tpl.tmpl", SourceLiteral: src}
+	code, err := gen.Emit([]gen.Unit{u}, ".")
+	if err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	f := parseCode(t, code)
+	params := findType(f, "Tpl")
+	if params == nil {
+		t.Fatalf("Tpl not found\n%s", code)
+	}
+	got := make(map[string]string)
+	for _, field := range params.Fields.List {
+		got[field.Names[0].Name] = types.ExprString(field.Type)
+	}
+	want := map[string]string{
+		"Sections": "map[string]TplSectionsValue",
+		"Tags":     "[]string",
+		"User":     "TplUser",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tpl fields = %v; want %v\n%s", got, want, code)
+	}
+
+	main := `package main
+
+import (
+	"os"
+)
+
+func main() {
+	err := RenderTpl(os.Stdout, Tpl{
+		Tags:     []string{"a", "b"},
+		Sections: map[string]TplSectionsValue{"intro": {Title: "Hello"}},
+		User:     TplUser{Name: "Alice"},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+`
+	out := goInTempModule(t, map[string]string{u.SourcePath: src, "gen.go": code, "main.go": main}, "run", ".")
+	for _, w := range []string{"a, b", "intro: Hello", "Alice"} {
+		if !strings.Contains(string(out), w) {
+			t.Errorf("output does not contain %q\n%s", w, out)
+		}
+	}
+}
+
+func TestEmit_FuncDirectiveWithoutFuncMap(t *testing.T) {
+	src := `
+{{/* @func price func(float64) string */}}
+{{ price .Amount }}
+`
+	u := gen.Unit{Pkg: "x", SourcePath: "tpl.tmpl", SourceLiteral: src}
+
+	if _, err := gen.Emit([]gen.Unit{u}, "."); err == nil {
+		t.Fatal("expected error for @func without FuncMap, got nil")
+	}
+}
+
+func TestEmit_Imports_WellKnownAndDirective(t *testing.T) {
+	src := `
+{{/* @import decimal "github.com/shopspring/decimal" */}}
+{{/* @param CreatedAt time.Time */}}
+{{/* @param Homepage *url.URL */}}
+{{/* @param Price decimal.Decimal */}}
+{{ .CreatedAt }} {{ .Homepage }} {{ .Price }}
+`
+	u := gen.Unit{Pkg: "x", SourcePath: "tpl.tmpl", SourceLiteral: src}
+
+	code, err := gen.Emit([]gen.Unit{u}, ".")
+	if err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	f := parseCode(t, code)
+	for _, p := range []string{"time", "net/url", "github.com/shopspring/decimal", "text/template"} {
+		if !hasImport(f, p, "") {
+			t.Errorf("import %q not found\n%s", p, code)
+		}
+	}
+}
+
+func TestEmit_Imports_AliasAndConflict(t *testing.T) {
+	// text/template モードで html/template の型を使うと名前が衝突する
+	u := gen.Unit{Pkg: "x", SourcePath: "tpl.tmpl", SourceLiteral: "{{/* @param Body template.HTML */}}{{ .Body }}"}
+	if _, err := gen.Emit([]gen.Unit{u}, "."); err == nil {
+		t.Fatal("expected import conflict error, got nil")
+	}
+
+	// 別名を付ければ共存できる
+	u.SourceLiteral = `{{/* @import htmltemplate "html/template" */}}{{/* @param Body htmltemplate.HTML */}}{{ .Body }}`
+	code, err := gen.Emit([]gen.Unit{u}, ".")
+	if err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	f := parseCode(t, code)
+	if !hasImport(f, "html/template", "htmltemplate") || !hasImport(f, "text/template", "") {
+		t.Fatalf("aliased import not found\n%s", code)
+	}
+}
+
+const bindDomainPath = "github.com/bellwood4486/tmpltype/internal/bind/testdata/domain"
+
+func TestEmit_TypeDirective_BindsExistingType(t *testing.T) {
+	units := []gen.Unit{
+		{Pkg: "x", SourcePath: "order.tmpl", SourceLiteral: `{{/* @type ` + bindDomainPath + `.OrderView */}}
+{{ .ID }} {{ .Customer.Name }}{{ range .Items }}{{ .Title }}{{ end }}`},
+		{Pkg: "x", SourcePath: "mail.tmpl", SourceLiteral: `{{ .Subject }}{{ template "order" .Order }}`},
+	}
+
+	code, err := gen.EmitWithOptions(units, ".", gen.Options{Dir: "."})
+	if err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	f := parseCode(t, code)
+	if !hasImport(f, bindDomainPath, "") {
+		t.Fatalf("import of bound type package not found\n%s", code)
+	}
+	// 結び付けたテンプレートのパラメータ型は生成しない
+	if findType(f, "Order") != nil {
+		t.Fatalf("Order param type should not be generated\n%s", code)
+	}
+	if !strings.Contains(code, "func RenderOrder(w io.Writer, p domain.OrderView) error") {
+		t.Fatalf("RenderOrder does not take domain.OrderView\n%s", code)
+	}
+	// 参照する側のフィールドは既存の型になる
+	mail := findType(f, "Mail")
+	if mail == nil {
+		t.Fatalf("Mail type not found\n%s", code)
+	}
+	for _, fld := range mail.Fields.List {
+		if fld.Names[0].Name != "Order" {
+			continue
+		}
+		sel, ok := fld.Type.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "OrderView" {
+			t.Fatalf("Mail.Order is not domain.OrderView\n%s", code)
+		}
+	}
+}
+
+func TestEmit_TypeDirective_ReportsMismatches(t *testing.T) {
+	units := []gen.Unit{
+		{Pkg: "x", SourcePath: "templates/order.tmpl", SourceLiteral: `{{/* @type ` + bindDomainPath + `.OrderView */}}
+{{ .Number }}
+{{ range .Items }}{{ .SKU }}{{ end }}`},
+	}
+
+	_, err := gen.EmitWithOptions(units, "templates", gen.Options{Dir: "."})
+	if err == nil {
+		t.Fatal("expected error for fields missing in the bound type, got nil")
+	}
+	for _, want := range []string{
+		"templates/order.tmpl:2:4: domain.OrderView has no field or method Number",
+		"templates/order.tmpl:3:22: domain.OrderItem has no field or method SKU",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not contain %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestEmit_TypeDirective_WithParam(t *testing.T) {
+	tests := []struct {
+		directive string
+		want      string
+	}{
+		{`{{/* @param ID int64 */}}`, "order.tmpl:2:1: @param cannot be used together with @type (declared at order.tmpl:1:1)"},
+		{`{{/* @tag ID json:"id" */}}`, "order.tmpl:2:1: @tag cannot be used together with @type"},
+		{`{{/* @required ID */}}`, "order.tmpl:2:1: @required cannot be used together with @type"},
+		{`{{/* @optional ID */}}`, "order.tmpl:2:1: @optional cannot be used together with @type"},
+	}
+	for _, tt := range tests {
+		u := gen.Unit{Pkg: "x", SourcePath: "order.tmpl", SourceLiteral: `{{/* @type ` + bindDomainPath + `.OrderView */}}
+` + tt.directive + `{{ .ID }}`}
+		_, err := gen.EmitWithOptions([]gen.Unit{u}, ".", gen.Options{Dir: "."})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.directive, err, tt.want)
+		}
+	}
+}
+
+func TestEmit_ErrorPositions(t *testing.T) {
+	tests := []struct {
+		name  string
+		units []gen.Unit
+		want  []string
+	}{
+		{
+			name: "parse errors in several templates",
+			units: []gen.Unit{
+				{Pkg: "x", SourcePath: "a.tmpl", SourceLiteral: "Hello\n{{ if .X }}"},
+				{Pkg: "x", SourcePath: "b.tmpl", SourceLiteral: "{{ .Y ) }}"},
+			},
+			want: []string{"a.tmpl:2: ", "b.tmpl:1: "},
+		},
+		{
+			name: "type errors in several templates",
+			units: []gen.Unit{
+				{Pkg: "x", SourcePath: "a.tmpl", SourceLiteral: "{{/* @param Price decimal.Decimal */}}{{ .Price }}"},
+				{Pkg: "x", SourcePath: "b.tmpl", SourceLiteral: "\n  {{/* @param Items struct{X */}}"},
+			},
+			want: []string{
+				`a.tmpl:1:1: unknown package qualifier "decimal"`,
+				`b.tmpl:2:21: invalid type expression`,
+			},
+		},
+		{
+			name: "parse error and directive errors in other templates",
+			units: []gen.Unit{
+				{Pkg: "x", SourcePath: "a.tmpl", SourceLiteral: "{{ .X ) }}"},
+				{Pkg: "x", SourcePath: "b.tmpl", SourceLiteral: "{{/* @param Price decimal.Decimal */}}{{ .Price }}"},
+				{Pkg: "x", SourcePath: "c.tmpl", SourceLiteral: "\n  {{/* @param Items struct{X */}}"},
+			},
+			want: []string{
+				"a.tmpl:1: ",
+				`b.tmpl:1:1: unknown package qualifier "decimal"`,
+				"c.tmpl:2:21: invalid type expression",
+			},
+		},
+		{
+			name: "template called by its name before @name",
+			units: []gen.Unit{
+				{Pkg: "x", SourcePath: "a.tmpl", SourceLiteral: "{{/* @name Alpha */}}{{ .X }}"},
+				{Pkg: "x", SourcePath: "b.tmpl", SourceLiteral: `{{ template "a" . }}`},
+			},
+			want: []string{`b.tmpl:1:13: template "a" is not defined`},
+		},
+		{
+			name: "conflicting @func",
+			units: []gen.Unit{
+				{Pkg: "x", SourcePath: "a.tmpl", SourceLiteral: "{{/* @func f func(int) string */}}"},
+				{Pkg: "x", SourcePath: "b.tmpl", SourceLiteral: "\n{{/* @func f func(string) string */}}"},
+			},
+			want: []string{"b.tmpl:2:1: @func f conflicts with the declaration at a.tmpl:1:1"},
+		},
+		{
+			name: "package imported with different names",
+			units: []gen.Unit{
+				{Pkg: "x", SourcePath: "a.tmpl", SourceLiteral: "{{/* @import d \"example.com/d\" */}}{{/* @param X d.T */}}{{ .X }}"},
+				{Pkg: "x", SourcePath: "b.tmpl", SourceLiteral: "\n{{/* @import e \"example.com/d\" */}}{{/* @param Y e.T */}}{{ .Y }}"},
+			},
+			want: []string{`b.tmpl:2:1: package "example.com/d" is referenced as both d and e`},
+		},
+		{
+			name: "File is used for positions",
+			units: []gen.Unit{
+				{Pkg: "x", SourcePath: "a.tmpl", File: "templates/a.tmpl", SourceLiteral: "{{ .X ) }}"},
+			},
+			want: []string{"templates/a.tmpl:1: "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := gen.Emit(tt.units, ".")
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(tt.want) {
+				t.Fatalf("expected %d errors, got:\n%v", len(tt.want), err)
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(lines[i], want) {
+					t.Errorf("error[%d] = %q; want prefix %q", i, lines[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestEmit_IdentifierCollisions(t *testing.T) {
+	unit := func(path, src string) gen.Unit {
+		return gen.Unit{Pkg: "x", SourcePath: path, SourceLiteral: src}
+	}
+
+	tests := []struct {
+		name  string
+		units []gen.Unit
+		want  []string
+	}{
+		{
+			name: "same template name",
+			units: []gen.Unit{
+				unit("user_list.tmpl", "{{ .A }}"),
+				unit("01_user-list.tmpl", "{{ .B }}"),
+			},
+			want: []string{`01_user-list.tmpl: template name "user_list" conflicts with user_list.tmpl`},
+		},
+		{
+			name: "same type name",
+			units: []gen.Unit{
+				unit("user_list.tmpl", "{{ .A }}"),
+				unit("userList.tmpl", "{{ .B }}"),
+			},
+			want: []string{"user_list.tmpl: type UserList conflicts with type UserList generated for userList.tmpl"},
+		},
+		{
+			name: "group and flat template",
+			units: []gen.Unit{
+				unit("mail/invite.tmpl", "{{ .A }}"),
+				unit("mail_invite.tmpl", "{{ .B }}"),
+			},
+			want: []string{"mail/invite.tmpl: type MailInvite conflicts with type MailInvite generated for mail_invite.tmpl"},
+		},
+		{
+			name: "group and flat namespace field",
+			units: []gen.Unit{
+				unit("mail.tmpl", "{{ .A }}"),
+				unit("mail/invite.tmpl", "{{ .B }}"),
+			},
+			want: []string{"mail/invite.tmpl: field Mail conflicts with field Mail generated for mail.tmpl"},
+		},
+		{
+			name: "template and nested group field",
+			units: []gen.Unit{
+				unit("mail/account.tmpl", "{{ .A }}"),
+				unit("mail/account/title.tmpl", "{{ .B }}"),
+			},
+			want: []string{"mail/account/title.tmpl: field Account conflicts with field Account generated for mail/account.tmpl"},
+		},
+		{
+			name: "named type and another template",
+			units: []gen.Unit{
+				unit("user.tmpl", "{{ range .Items }}{{ .Title }}{{ end }}"),
+				unit("user_items_item.tmpl", "{{ .B }}"),
+			},
+			want: []string{"user_items_item.tmpl: type UserItemsItem conflicts with type UserItemsItem generated for user.tmpl"},
+		},
+		{
+			name: "fixed identifier",
+			units: []gen.Unit{
+				unit("template_name.tmpl", "{{ .A }}"),
+			},
+			want: []string{"template_name.tmpl: type TemplateName conflicts with the generated type TemplateName"},
+		},
+		{
+			name: "reported at @name",
+			units: []gen.Unit{
+				unit("a.tmpl", "\n{{/* @name b */}}{{ .A }}"),
+				unit("b.tmpl", "{{ .B }}"),
+			},
+			want: []string{`a.tmpl:2:1: template name "b" conflicts with b.tmpl`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := gen.Emit(tt.units, ".")
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(tt.want) {
+				t.Fatalf("expected %d errors, got:\n%v", len(tt.want), err)
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(lines[i], want) {
+					t.Errorf("error[%d] = %q; want prefix %q", i, lines[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestEmit_NameDirective(t *testing.T) {
+	units := []gen.Unit{
+		{Pkg: "x", SourcePath: "user_list.tmpl", SourceLiteral: "{{ .A }}"},
+		{Pkg: "x", SourcePath: "userList.tmpl", SourceLiteral: "{{/* @name legacy_user_list */}}{{ .B }}"},
+		{Pkg: "x", SourcePath: "mail/invite.tmpl", SourceLiteral: "{{/* @name invitation */}}{{ .C }}"},
+		{Pkg: "x", SourcePath: "mail_invite.tmpl", SourceLiteral: `{{ template "mail/invitation" .Mail }}`},
+	}
+
+	code, err := gen.Emit(units, ".")
+	if err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	f := parseCode(t, code)
+	for _, name := range []string{"UserList", "LegacyUserList", "MailInvitation", "MailInvite"} {
+		if findType(f, name) == nil {
+			t.Errorf("type %s not found\n%s", name, code)
+		}
+	}
+	for _, name := range []string{"RenderLegacyUserList", "RenderMailInvitation"} {
+		if findFunc(f, name) == nil {
+			t.Errorf("func %s not found", name)
+		}
+	}
+	for _, want := range []string{
+		`LegacyUserList: "legacy_user_list"`,
+		`Invitation: "mail/invitation"`,
+		"Mail MailInvitation",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code does not contain %q\n%s", want, code)
+		}
+	}
+}
+
+func TestEmit_NestedGroups_CompilesInTempModule(t *testing.T) {
+	sources := map[string]string{
+		"footer.tmpl":                       "-- {{ .Site }}",
+		"mail/signature.tmpl":               "{{ .Name }}",
+		"mail/account/created/title.tmpl":   "Welcome {{ .User }}",
+		"mail/account/created/content.tmpl": `{{ .Body }} {{ template "mail/signature" .Sig }}`,
+		"mail/account/deleted/title.tmpl":   "Bye {{ .User }}",
+	}
+	var units []gen.Unit
+	for _, p := range slices.Sorted(maps.Keys(sources)) {
+		units = append(units, gen.Unit{Pkg: "main", SourcePath: p, SourceLiteral: sources[p]})
+	}
+	code, err := gen.Emit(units, ".")
+	if err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	f := parseCode(t, code)
+	for _, name := range []string{"Footer", "MailSignature", "MailAccountCreatedTitle", "MailAccountCreatedContent", "MailAccountDeletedTitle"} {
+		if findType(f, name) == nil {
+			t.Errorf("type %s not found", name)
+		}
+		if findFunc(f, "Render"+name) == nil {
+			t.Errorf("func Render%s not found", name)
+		}
+	}
+
+	main := `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	fmt.Println(Template.Mail.Account.Created.Title, Template.Mail.Account.Deleted.Title, Template.Mail.Signature)
+	err := RenderMailAccountCreatedContent(os.Stdout, MailAccountCreatedContent{
+		Body: "Hello",
+		Sig:  MailSignature{Name: "Team"},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+`
+	files := map[string]string{"gen.go": code, "main.go": main}
+	maps.Copy(files, sources)
+	out := goInTempModule(t, files, "run", ".")
+	for _, w := range []string{"mail/account/created/title mail/account/deleted/title mail/signature", "Hello Team"} {
+		if !strings.Contains(string(out), w) {
+			t.Errorf("output does not contain %q\n%s", w, out)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	units := []gen.Unit{
+		{Pkg: "x", SourcePath: "footer.tmpl", File: "templates/footer.tmpl", SourceLiteral: "{{ .Company }}"},
+		{Pkg: "x", SourcePath: "mail/invite.tmpl", File: "templates/mail/invite.tmpl", SourceLiteral: `{{/* @param User.Age int */}}
+{{/* @param Items []struct{ID int64} */}}
+{{ .User.Name }} {{ .User.Age }}
+{{ range .Items }}{{ .ID }}{{ end }}
+{{ template "footer" .Footer }}`},
+	}
+
+	schemas, err := gen.Describe(units, "templates", gen.Options{})
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+	if len(schemas) != 2 || schemas[0].Name != "footer" || schemas[1].Name != "mail/invite" {
+		t.Fatalf("unexpected templates: %+v", schemas)
+	}
+
+	invite := schemas[1]
+	if invite.File != "templates/mail/invite.tmpl" || invite.Type != "MailInvite" || invite.Bound {
+		t.Errorf("invite = %+v", invite)
+	}
+
+	// フィールドをパスで引けるようにする
+	fields := make(map[string]gen.FieldSchema)
+	var collect func(prefix string, fs []gen.FieldSchema)
+	collect = func(prefix string, fs []gen.FieldSchema) {
+		for _, f := range fs {
+			fields[prefix+f.Name] = f
+			collect(prefix+f.Name+".", f.Fields)
+		}
+	}
+	collect("", invite.Fields)
+
+	tests := []struct {
+		path     string
+		kind     string
+		typ      string
+		source   string
+		template string
+		refs     []string
+	}{
+		{"User", "struct", "MailInviteUser", gen.SourceInferred, "", []string{"templates/mail/invite.tmpl:3:4", "templates/mail/invite.tmpl:3:21"}},
+		{"User.Name", "string", "string", gen.SourceInferred, "", []string{"templates/mail/invite.tmpl:3:4"}},
+		{"User.Age", "string", "int", gen.SourceParam, "", []string{"templates/mail/invite.tmpl:3:21"}},
+		{"Items", "slice", "[]MailInviteItemsItem", gen.SourceParam, "", []string{"templates/mail/invite.tmpl:4:10"}},
+		{"Items.ID", "string", "int64", gen.SourceParam, "", []string{"templates/mail/invite.tmpl:4:22"}},
+		{"Footer", "template", "Footer", gen.SourceInferred, "footer", []string{"templates/mail/invite.tmpl:5:22"}},
+	}
+	for _, tt := range tests {
+		f, ok := fields[tt.path]
+		if !ok {
+			t.Errorf("field %s not found in %+v", tt.path, invite.Fields)
+			continue
+		}
+		if f.Kind != tt.kind || f.Type != tt.typ || f.Source != tt.source || f.Template != tt.template || !slices.Equal(f.Refs, tt.refs) {
+			t.Errorf("field %s = %+v, want kind=%s type=%s source=%s template=%s refs=%v",
+				tt.path, f, tt.kind, tt.typ, tt.source, tt.template, tt.refs)
+		}
+	}
+}
+
+func TestEmitJSONSchemas(t *testing.T) {
+	units := []gen.Unit{
+		{Pkg: "x", SourcePath: "footer.tmpl", SourceLiteral: "{{ .Company }}"},
+		{Pkg: "x", SourcePath: "mail/invite.tmpl", SourceLiteral: `{{/* @param User.Age *int */}}{{/* @param Tags map[string]int8 */}}{{/* @param At *time.Time */}}
+{{ .User.Name }} {{ .User.Age }} {{ .Tags }} {{ .At }}
+{{ range .Items }}{{ .ID }}{{ end }}
+{{ template "footer" .Footer }}`},
+	}
+
+	docs, err := gen.EmitJSONSchemas(units, ".", gen.Options{})
+	if err != nil {
+		t.Fatalf("EmitJSONSchemas failed: %v", err)
+	}
+	if len(docs) != 2 || docs["footer"] == nil || docs["mail/invite"] == nil {
+		t.Fatalf("unexpected documents: %v", slices.Sorted(maps.Keys(docs)))
+	}
+
+	var got any
+	if err := json.Unmarshal(docs["mail/invite"], &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, docs["mail/invite"])
+	}
+	var want any
+	if err := json.Unmarshal([]byte(`{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "MailInvite",
+  "description": "Parameters for the mail/invite template",
+  "type": "object",
+  "properties": {
+    "At": {"type": ["string", "null"], "format": "date-time"},
+    "Footer": {"$ref": "#/$defs/Footer"},
+    "Items": {"type": ["array", "null"], "items": {"$ref": "#/$defs/MailInviteItemsItem"}},
+    "Tags": {"type": ["object", "null"], "additionalProperties": {"type": "integer", "minimum": -128, "maximum": 127}},
+    "User": {"$ref": "#/$defs/MailInviteUser"}
+  },
+  "additionalProperties": false,
+  "$defs": {
+    "Footer": {"type": "object", "properties": {"Company": {"type": "string"}}, "additionalProperties": false},
+    "MailInviteItemsItem": {"type": "object", "properties": {"ID": {"type": "string"}}, "additionalProperties": false},
+    "MailInviteUser": {
+      "type": "object",
+      "properties": {"Age": {"type": ["integer", "null"]}, "Name": {"type": "string"}},
+      "additionalProperties": false
+    }
+  }
+}`), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("schema mismatch:\n%s", docs["mail/invite"])
+	}
+}
+
+func TestEmitJSONSchemas_Embed(t *testing.T) {
+	units := []gen.Unit{
+		{Pkg: "x", SourcePath: "base.tmpl", SourceLiteral: "{{ .Title }}"},
+		{Pkg: "x", SourcePath: "page.tmpl", SourceLiteral: `{{ template "base" . }}{{ .Body }}`},
+	}
+
+	docs, err := gen.EmitJSONSchemas(units, ".", gen.Options{})
+	if err != nil {
+		t.Fatalf("EmitJSONSchemas failed: %v", err)
+	}
+	// 埋め込んだテンプレートのフィールドは同じ階層のプロパティになる
+	var page struct {
+		Properties map[string]any `json:"properties"`
+	}
+	if err := json.Unmarshal(docs["page"], &page); err != nil {
+		t.Fatal(err)
+	}
+	if got := slices.Sorted(maps.Keys(page.Properties)); !slices.Equal(got, []string{"Body", "Title"}) {
+		t.Errorf("page properties = %v, want [Body Title]", got)
+	}
+}
+
+func TestEmitTypeScript(t *testing.T) {
+	units := []gen.Unit{
+		{Pkg: "x", SourcePath: "base.tmpl", SourceLiteral: "{{ .Title }}"},
+		{Pkg: "x", SourcePath: "footer.tmpl", SourceLiteral: "{{ .Company }}"},
+		{Pkg: "x", SourcePath: "mail/invite.tmpl", SourceLiteral: `{{/* @param User.Age *int */}}{{/* @param Tags map[string][]*int8 */}}{{/* @param At *time.Time */}}{{/* @param Any any */}}
+{{ template "base" . }}{{ .User.Name }} {{ .User.Age }} {{ .Tags }} {{ .At }} {{ .Any }}
+{{ range .Items }}{{ .ID }}{{ end }}
+{{ template "footer" .Footer }}`},
+	}
+
+	got, err := gen.EmitTypeScript(units, ".", gen.Options{})
+	if err != nil {
+		t.Fatalf("EmitTypeScript failed: %v", err)
+	}
+	want := `// Code generated by tmpltype; DO NOT EDIT.
+
+/** TemplateName is a template name (mirrors the Template namespace in Go) */
+export type TemplateName =
+  | "base"
+  | "footer"
+  | "mail/invite";
+
+/** Base represents parameters for base template */
+export interface Base {
+  Title: string;
+}
+
+/** Footer represents parameters for footer template */
+export interface Footer {
+  Company: string;
+}
+
+export interface MailInviteItemsItem {
+  ID: string;
+}
+
+export interface MailInviteUser {
+  Age?: number | null;
+  Name: string;
+}
+
+/** MailInvite represents parameters for mail/invite template */
+export interface MailInvite extends Base {
+  Any: unknown;
+  At?: string | null;
+  Footer: Footer;
+  Items: MailInviteItemsItem[] | null;
+  Tags: Record<string, (number | null)[] | null> | null;
+  User: MailInviteUser;
+}
+
+/** TemplateParams maps each template name to its parameter type */
+export interface TemplateParams {
+  "base": Base;
+  "footer": Footer;
+  "mail/invite": MailInvite;
+}
+`
+	if got != want {
+		t.Errorf("EmitTypeScript mismatch:\n%s", util.UnifiedDiff("want", "got", []byte(want), []byte(got)))
+	}
+}
+
+func TestEmit_StructTags(t *testing.T) {
+	units := []gen.Unit{
+		{Pkg: "x", SourcePath: "footer.tmpl", SourceLiteral: "{{ .Company }}"},
+		{Pkg: "x", SourcePath: "mail/invite.tmpl", SourceLiteral: `{{/* @param Items []struct{ID int64} */}}
+{{/* @tag User.Email json:"email,omitempty" validate:"required,email" */}}
+{{/* @tag Items.ID json:"item_id" */}}
+{{/* @tag Secret json:"-" */}}
+{{ .User.Email }} {{ .User.HTMLName }} {{ range .Items }}{{ .ID }}{{ end }} {{ .Secret }}
+{{ template "footer" .Footer }}`},
+	}
+	opts := gen.Options{Tags: gen.TagOptions{Keys: []string{"json", "yaml"}, Case: gen.TagCaseCamel}}
+
+	code, err := gen.EmitWithOptions(units, ".", opts)
+	if err != nil {
+		t.Fatalf("EmitWithOptions failed: %v", err)
+	}
+	f := parseCode(t, code)
+
+	// 型名.フィールド名 -> タグ
+	tags := make(map[string]string)
+	for _, typeName := range []string{"MailInvite", "MailInviteUser", "MailInviteItemsItem", "Footer"} {
+		st := findType(f, typeName)
+		if st == nil {
+			t.Fatalf("type %s not found\n%s", typeName, code)
+		}
+		for _, field := range st.Fields.List {
+			if field.Tag != nil {
+				tags[typeName+"."+field.Names[0].Name] = field.Tag.Value
+			}
+		}
+	}
+	want := map[string]string{
+		"MailInvite.Footer":       "`json:\"footer\" yaml:\"footer\"`",
+		"MailInvite.Items":        "`json:\"items\" yaml:\"items\"`",
+		"MailInvite.Secret":       "`json:\"-\" yaml:\"secret\"`",
+		"MailInvite.User":         "`json:\"user\" yaml:\"user\"`",
+		"MailInviteUser.Email":    "`json:\"email,omitempty\" yaml:\"email\" validate:\"required,email\"`",
+		"MailInviteUser.HTMLName": "`json:\"htmlName\" yaml:\"htmlName\"`",
+		"MailInviteItemsItem.ID":  "`json:\"item_id\" yaml:\"id\"`",
+		"Footer.Company":          "`json:\"company\" yaml:\"company\"`",
+	}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %v\nwant %v", tags, want)
+	}
+
+	// JSON Schema と TypeScript のプロパティ名も json タグに従う
+	docs, err := gen.EmitJSONSchemas(units, ".", opts)
+	if err != nil {
+		t.Fatalf("EmitJSONSchemas failed: %v", err)
+	}
+	var doc struct {
+		Properties map[string]any `json:"properties"`
+	}
+	if err := json.Unmarshal(docs["mail/invite"], &doc); err != nil {
+		t.Fatal(err)
+	}
+	if got := slices.Sorted(maps.Keys(doc.Properties)); !slices.Equal(got, []string{"footer", "items", "user"}) {
+		t.Errorf("JSON Schema properties = %v", got)
+	}
+	ts, err := gen.EmitTypeScript(units, ".", opts)
+	if err != nil {
+		t.Fatalf("EmitTypeScript failed: %v", err)
+	}
+	for _, want := range []string{"  email?: string;\n", "  item_id: number;\n", "  htmlName: string;\n"} {
+		if !strings.Contains(ts, want) {
+			t.Errorf("TypeScript does not contain %q\n%s", want, ts)
+		}
+	}
+	if strings.Contains(ts, "secret") || strings.Contains(ts, "Secret") {
+		t.Errorf("json:\"-\" field should be omitted\n%s", ts)
+	}
+}
+
+func TestEmit_Required_CompilesInTempModule(t *testing.T) {
+	units := []gen.Unit{
+		{Pkg: "main", SourcePath: "base.tmpl", SourceLiteral: `{{/* @required Lang */}}{{ .Lang }}`},
+		{Pkg: "main", SourcePath: "footer.tmpl", SourceLiteral: `{{/* @required Company */}}{{ .Company }}`},
+		{Pkg: "main", SourcePath: "invite.tmpl", SourceLiteral: `{{/* @param Items []struct{ID int64; Note string} */}}
+{{/* @param Owner *string */}}
+{{/* @required User.Email */}}
+{{/* @optional User.Nickname */}}
+{{/* @required Items.ID */}}
+{{/* @required Sections.Title */}}
+{{ template "base" . }}{{ .User.Email }} {{ .User.Nickname }} {{ .User.Age }} {{ .Owner }}
+{{ range .Items }}{{ .ID }}{{ .Note }}{{ end }}
+{{ range $k, $s := .Sections }}{{ printf "%s" $k }}: {{ $s.Title }}{{ end }}
+{{ template "footer" .Footer }}`},
+		{Pkg: "main", SourcePath: "plain.tmpl", SourceLiteral: `{{ .Name }}`},
+	}
+
+	main := `package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+func main() {
+	p := Invite{
+		Items:    []InviteItemsItem{{ID: 1}, {}},
+		Sections: map[string]InviteSectionsValue{"b": {}, "a": {Title: "ok"}, "c": {}},
+	}
+	err := RenderInvite(os.Stdout, p)
+	var missing *MissingFieldsError
+	if !errors.As(err, &missing) {
+		panic(err)
+	}
+	fmt.Println(err)
+	fmt.Println(Render(os.Stdout, Template.Footer, Footer{}))
+	fmt.Println(Render(os.Stdout, Template.Footer, (*Footer)(nil))) // nil のポインタは検証せず、実行時のエラーになる
+	fmt.Println(RenderPlain(os.Stdout, Plain{}))
+}
+`
+	tests := []struct {
+		name string
+		opts gen.Options
+		want []string
+	}{
+		{
+			name: "directives",
+			want: []string{
+				`template "invite": missing required fields: Lang, Footer.Company, Items[1].ID, Sections["b"].Title, Sections["c"].Title, User.Email`,
+				`template "footer": missing required fields: Company`,
+				`template: footer:1:30: executing "footer" at <.Company>: nil pointer evaluating *main.Footer.Company`,
+				"<nil>", // 必須のフィールドがない型は検証しない
+			},
+		},
+		{
+			name: "require all",
+			opts: gen.Options{RequireAll: true},
+			want: []string{
+				`template "invite": missing required fields: Lang, Footer.Company, Items[0].Note, Items[1].ID, Items[1].Note, Sections["b"].Title, Sections["c"].Title, User.Age, User.Email`,
+				`template "footer": missing required fields: Company`,
+				`template: footer:1:30: executing "footer" at <.Company>: nil pointer evaluating *main.Footer.Company`,
+				`template "plain": missing required fields: Name`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := gen.EmitWithOptions(units, ".", tt.opts)
+			if err != nil {
+				t.Fatalf("EmitWithOptions failed: %v", err)
+			}
+
+			files := map[string]string{"gen.go": code, "main.go": main}
+			for _, u := range units {
+				files[u.SourcePath] = u.SourceLiteral
+			}
+			out := goInTempModule(t, files, "run", ".")
+			if got := strings.Split(strings.TrimSpace(out), "\n"); !slices.Equal(got, tt.want) {
+				t.Errorf("output =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestEmit_Required_SchemaAndErrors(t *testing.T) {
+	units := []gen.Unit{
+		{Pkg: "x", SourcePath: "mail.tmpl", SourceLiteral: `{{/* @required User.Email */}}{{/* @optional Title */}}
+{{ .Title }} {{ .Count }} {{ .User.Email }} {{ .User.Name }}`},
+	}
+
+	// JSON Schema の required と schema サブコマンドの required は生成する検証と同じ
+	docs, err := gen.EmitJSONSchemas(units, ".", gen.Options{RequireAll: true})
+	if err != nil {
+		t.Fatalf("EmitJSONSchemas failed: %v", err)
+	}
+	var doc struct {
+		Required []string `json:"required"`
+		Defs     map[string]struct {
+			Required []string `json:"required"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(docs["mail"], &doc); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(doc.Required, []string{"Count"}) || !slices.Equal(doc.Defs["MailUser"].Required, []string{"Email", "Name"}) {
+		t.Errorf("required = %v, $defs.MailUser.required = %v", doc.Required, doc.Defs["MailUser"].Required)
+	}
+	schemas, err := gen.Describe(units, ".", gen.Options{})
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+	for _, f := range schemas[0].Fields {
+		if f.Required {
+			t.Errorf("%s: Required = true, want false", f.Name)
+		}
+		if f.Name == "User" && !f.Fields[0].Required {
+			t.Errorf("User.Email: Required = false, want true")
+		}
+	}
+
+	// 生成する Validate メソッドと衝突するフィールド
+	units[0].SourceLiteral = `{{/* @required Title */}}{{ .Title }} {{ .Validate }}`
+	_, err = gen.Emit(units, ".")
+	if err == nil || !strings.Contains(err.Error(), "mail.tmpl:1:42: field Validate conflicts with the generated method Mail.Validate") {
+		t.Fatalf("error = %v, want Validate conflict", err)
+	}
+
+	// 検証がなければ MissingFieldsError は生成しないので、同名のテンプレートも使える
+	if _, err := gen.Emit([]gen.Unit{{Pkg: "x", SourcePath: "missing_fields_error.tmpl", SourceLiteral: "{{ .A }}"}}, "."); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	_, err = gen.Emit([]gen.Unit{{Pkg: "x", SourcePath: "missing_fields_error.tmpl", SourceLiteral: "{{/* @required A */}}{{ .A }}"}}, ".")
+	if err == nil || !strings.Contains(err.Error(), "type MissingFieldsError conflicts with the generated type MissingFieldsError") {
+		t.Fatalf("error = %v, want MissingFieldsError conflict", err)
+	}
+}
+
+func TestEmit_Enum_CompilesInTempModule(t *testing.T) {
+	u := gen.Unit{Pkg: "main", SourcePath: "order.tmpl", SourceLiteral: `{{/* @enum Status shipped|cancelled|pending */}}
+{{/* @enum Items.Kind */}}
+{{ if eq .Status "shipped" }}shipped{{ else if eq .Status "lost" }}lost{{ end }}
+{{ range .Items }}{{ if eq .Kind "book" }}B{{ else if ne .Kind "e-book" }}?{{ end }}{{ end }}`}
+
+	var warnings []string
+	code, err := gen.EmitWithOptions([]gen.Unit{u}, ".", gen.Options{Warn: func(w typing.Warning) {
+		warnings = append(warnings, w.String())
+	}})
+	if err != nil {
+		t.Fatalf("EmitWithOptions failed: %v", err)
+	}
+	wantWarnings := []string{`order.tmpl:3:59: warning: Status is compared with "lost", which is not a value of @enum Status (shipped|cancelled|pending)`}
+	if !slices.Equal(warnings, wantWarnings) {
+		t.Errorf("warnings = %q, want %q", warnings, wantWarnings)
+	}
+
+	f := parseCode(t, code)
+	for _, name := range []string{"OrderStatus", "OrderItemsKind"} {
+		if findType(f, name) != nil {
+			t.Errorf("%s should be a string type, not a struct", name)
+		}
+	}
+	for _, want := range []string{
+		"type OrderStatus string",
+		`OrderStatusShipped   OrderStatus = "shipped"`,
+		`OrderItemsKindEBook OrderItemsKind = "e-book"`,
+		"Status OrderStatus",
+		"Kind OrderItemsKind",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code does not contain %q\n%s", want, code)
+		}
+	}
+
+	main := `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	err := RenderOrder(os.Stdout, Order{Status: OrderStatusShipped, Items: []OrderItemsItem{{Kind: OrderItemsKindBook}}})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println()
+	fmt.Println(OrderStatusPending.IsValid(), OrderStatus("lost").IsValid(), OrderItemsKindEBook.IsValid())
+}
+`
+	files := map[string]string{
+		u.SourcePath: u.SourceLiteral,
+		"gen.go":     code,
+		"main.go":    main,
+	}
+	out := goInTempModule(t, files, "run", ".")
+	for _, w := range []string{"shipped\nB", "true false true"} {
+		if !strings.Contains(out, w) {
+			t.Errorf("output does not contain %q\n%s", w, out)
+		}
+	}
+}
+
+func TestEmit_Enum_SchemasAndErrors(t *testing.T) {
+	units := []gen.Unit{{Pkg: "x", SourcePath: "order.tmpl", SourceLiteral: `{{/* @enum Status shipped|in-transit */}}{{ .Status }}`}}
+
+	docs, err := gen.EmitJSONSchemas(units, ".", gen.Options{})
+	if err != nil {
+		t.Fatalf("EmitJSONSchemas failed: %v", err)
+	}
+	var doc struct {
+		Properties map[string]struct {
+			Type string   `json:"type"`
+			Enum []string `json:"enum"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(docs["order"], &doc); err != nil {
+		t.Fatal(err)
+	}
+	if st := doc.Properties["Status"]; st.Type != "string" || !slices.Equal(st.Enum, []string{"shipped", "in-transit"}) {
+		t.Errorf("Status schema = %+v", st)
+	}
+	ts, err := gen.EmitTypeScript(units, ".", gen.Options{})
+	if err != nil {
+		t.Fatalf("EmitTypeScript failed: %v", err)
+	}
+	for _, want := range []string{`export type OrderStatus = "shipped" | "in-transit";`, "  Status: OrderStatus;\n"} {
+		if !strings.Contains(ts, want) {
+			t.Errorf("TypeScript does not contain %q\n%s", want, ts)
+		}
+	}
+	schemas, err := gen.Describe(units, ".", gen.Options{})
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+	if f := schemas[0].Fields[0]; f.Type != "OrderStatus" || !slices.Equal(f.Enum, []string{"shipped", "in-transit"}) {
+		t.Errorf("Describe Status = %+v", f)
+	}
+
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"constant collision", `{{/* @enum Status in-transit|in_transit */}}{{ .Status }}`,
+			`order.tmpl:1:1: @enum Status: values "in-transit" and "in_transit" both become the constant OrderStatusInTransit`},
+		{"no identifier", `{{/* @enum Status a|-- */}}{{ .Status }}`,
+			`order.tmpl:1:1: @enum Status: value "--" has no letters or digits to name a constant`},
+		{"with @type", `{{/* @type ` + bindDomainPath + `.OrderView */}}{{/* @enum Status a|b */}}{{ .Status }}`,
+			"@enum cannot be used together with @type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := gen.Unit{Pkg: "x", SourcePath: "order.tmpl", SourceLiteral: tt.src}
+			_, err := gen.EmitWithOptions([]gen.Unit{u}, ".", gen.Options{Dir: "."})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmit_Default_CompilesInTempModule(t *testing.T) {
+	u := gen.Unit{Pkg: "main", SourcePath: "page.tmpl", SourceLiteral: `{{/* @param Items []struct{Name string; Qty int} */}}
+{{/* @param Ratio float32 */}}
+{{/* @enum Theme light|dark */}}
+{{/* @default Theme "dark" */}}
+{{/* @optional Items */}}
+{{/* @optional Sections */}}
+{{/* @default SiteName "Acme" */}}
+{{/* @default Ratio 1.5 */}}
+{{/* @default Items.Qty 1 */}}
+{{/* @default Sections.Title "untitled" */}}
+{{/* @default Footer.Company "Acme Inc." */}}
+{{ .Title }} {{ .SiteName }} {{ .Theme }} {{ .Ratio }}
+{{- range .Items }} {{ .Name }}x{{ .Qty }}{{ end }}
+{{- range $k, $v := .Sections }} {{ printf "%s" $k }}={{ $v.Title }}{{ end }}
+{{ .Footer.Company }}`}
+
+	code, err := gen.EmitWithOptions([]gen.Unit{u}, ".", gen.Options{RequireAll: true})
+	if err != nil {
+		t.Fatalf("EmitWithOptions failed: %v", err)
+	}
+	for _, want := range []string{
+		"func (p Page) WithDefaults() Page {",
+		"func (p PageItemsItem) WithDefaults() PageItemsItem {",
+		"\tp = p.WithDefaults()\n\tif err := p.Validate(); err != nil {",
+		"p.Theme = PageThemeDark",
+		"p.Items = slices.Clone(p.Items)",
+		"p.Sections = maps.Clone(p.Sections)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code does not contain %q\n%s", want, code)
+		}
+	}
+	// 既定値のあるフィールドは -required でも検証しない
+	if strings.Contains(code, `path+"SiteName"`) || !strings.Contains(code, `path+"Title"`) {
+		t.Errorf("only Title should be validated\n%s", code)
+	}
+
+	main := `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	items := []PageItemsItem{{Name: "a"}, {Name: "b", Qty: 3}}
+	sections := map[string]PageSectionsValue{"intro": {}}
+	p := Page{Title: "T", Items: items, Sections: sections}
+	if err := RenderPage(os.Stdout, p); err != nil {
+		panic(err)
+	}
+	fmt.Println()
+	// 呼び出し側の値は変わらない
+	fmt.Println(items[0].Qty, sections["intro"].Title == "", p.SiteName == "")
+
+	if err := Render(os.Stdout, Template.Page, &Page{Title: "P", SiteName: "Example", Theme: PageThemeLight}); err != nil {
+		panic(err)
+	}
+	fmt.Println()
+	fmt.Println(Render(os.Stdout, Template.Page, Page{}))
+}
+`
+	files := map[string]string{
+		u.SourcePath: u.SourceLiteral,
+		"gen.go":     code,
+		"main.go":    main,
+	}
+	out := goInTempModule(t, files, "run", ".")
+	for _, w := range []string{
+		"T Acme dark 1.5 ax1 bx3 intro=untitled\nAcme Inc.\n",
+		"0 true true\n",
+		"P Example light 1.5\nAcme Inc.\n",
+		`template "page": missing required fields: Title`,
+	} {
+		if !strings.Contains(out, w) {
+			t.Errorf("output does not contain %q\n%s", w, out)
+		}
+	}
+}
+
+func TestEmit_Default_SchemasAndErrors(t *testing.T) {
+	units := []gen.Unit{{Pkg: "x", SourcePath: "page.tmpl", SourceLiteral: `{{/* @param Size int */}}{{/* @default Size 20 */}}{{/* @default SiteName "Acme" */}}{{ .Size }}{{ .SiteName }}{{ .Title }}`}}
+
+	docs, err := gen.EmitJSONSchemas(units, ".", gen.Options{RequireAll: true})
+	if err != nil {
+		t.Fatalf("EmitJSONSchemas failed: %v", err)
+	}
+	var doc struct {
+		Properties map[string]struct {
+			Default any `json:"default"`
+		} `json:"properties"`
+		Required []string `json:"required"`
+	}
+	if err := json.Unmarshal(docs["page"], &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Properties["Size"].Default != 20.0 || doc.Properties["SiteName"].Default != "Acme" || doc.Properties["Title"].Default != nil {
+		t.Errorf("defaults = %+v", doc.Properties)
+	}
+	if !slices.Equal(doc.Required, []string{"Title"}) {
+		t.Errorf("required = %v, want [Title]", doc.Required)
+	}
+
+	ts, err := gen.EmitTypeScript(units, ".", gen.Options{})
+	if err != nil {
+		t.Fatalf("EmitTypeScript failed: %v", err)
+	}
+	if want := "  /** @default \"Acme\" */\n  SiteName: string;\n"; !strings.Contains(ts, want) {
+		t.Errorf("TypeScript does not contain %q\n%s", want, ts)
+	}
+
+	schemas, err := gen.Describe(units, ".", gen.Options{})
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+	if f := schemas[0].Fields[0]; f.Name != "SiteName" || f.Default != "Acme" {
+		t.Errorf("Describe SiteName = %+v", f)
+	}
+
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"method conflict", `{{/* @default Title "x" */}}{{ .Title }}{{ .WithDefaults }}`,
+			"field WithDefaults conflicts with the generated method Page.WithDefaults"},
+		{"type check", `{{/* @param Size int */}}{{/* @default Size "big" */}}{{ .Size }}`,
+			`page.tmpl:1:45: @default Size: cannot use "big" (untyped string constant) as int value`},
+		{"with @type", `{{/* @type ` + bindDomainPath + `.OrderView */}}{{/* @default Status "x" */}}{{ .Status }}`,
+			"@default cannot be used together with @type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := gen.Unit{Pkg: "x", SourcePath: "page.tmpl", SourceLiteral: tt.src}
+			_, err := gen.EmitWithOptions([]gen.Unit{u}, ".", gen.Options{Dir: "."})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmit_DocDirective(t *testing.T) {
+	units := []gen.Unit{{Pkg: "x", SourcePath: "order.tmpl", SourceLiteral: `{{/* @param Items []struct{ID int; Name string} */}}
+{{/*
+  Order confirmation mail.
+
+  Sent right after checkout.
+*/}}
+{{/* @doc Items ordered items */}}
+{{/* @doc Footer footer of every mail */}}
+{{/* @doc Footer.Year copyright year */}}
+{{ range .Items }}{{ .ID }} {{ .Name }}{{ end }}{{ .Footer.Year }}`}}
+
+	code, err := gen.Emit(units, ".")
+	if err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	f := parseCode(t, code)
+
+	docs := make(map[string]string) // 宣言または "型.フィールド" -> ドキュメントコメント
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.GenDecl:
+			for _, s := range d.Specs {
+				ts, ok := s.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				docs[ts.Name.Name] = d.Doc.Text()
+				if st, ok := ts.Type.(*ast.StructType); ok {
+					for _, field := range st.Fields.List {
+						for _, n := range field.Names {
+							docs[ts.Name.Name+"."+n.Name] = field.Doc.Text()
+						}
+					}
+				}
+			}
+		case *ast.FuncDecl:
+			docs[d.Name.Name] = d.Doc.Text()
+		}
+	}
+	description := "Order confirmation mail.\n\nSent right after checkout.\n"
+	want := map[string]string{
+		"Order":             "Order represents parameters for order template\n\n" + description,
+		"RenderOrder":       "RenderOrder renders the order template\n\n" + description,
+		"Order.Items":       "ordered items\n",
+		"Order.Footer":      "footer of every mail\n",
+		"OrderItemsItem":    "ordered items\n",
+		"OrderFooter":       "footer of every mail\n",
+		"OrderFooter.Year":  "copyright year\n",
+		"OrderItemsItem.ID": "",
+	}
+	for name, w := range want {
+		if got := docs[name]; got != w {
+			t.Errorf("doc of %s = %q, want %q", name, got, w)
+		}
+	}
+
+	docsJSON, err := gen.EmitJSONSchemas(units, ".", gen.Options{})
+	if err != nil {
+		t.Fatalf("EmitJSONSchemas failed: %v", err)
+	}
+	var doc struct {
+		Description string `json:"description"`
+		Properties  map[string]struct {
+			Description string `json:"description"`
+		} `json:"properties"`
+		Defs map[string]struct {
+			Description string `json:"description"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(docsJSON["order"], &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Description != strings.TrimSuffix(description, "\n") || doc.Properties["Items"].Description != "ordered items" ||
+		doc.Defs["OrderFooter"].Description != "footer of every mail" {
+		t.Errorf("JSON Schema descriptions = %+v", doc)
+	}
+
+	ts, err := gen.EmitTypeScript(units, ".", gen.Options{})
+	if err != nil {
+		t.Fatalf("EmitTypeScript failed: %v", err)
+	}
+	for _, w := range []string{
+		"/**\n * Order represents parameters for order template\n *\n * Order confirmation mail.\n *\n * Sent right after checkout.\n */\nexport interface Order {",
+		"  /** ordered items */\n  Items: OrderItemsItem[] | null;\n",
+		"/** footer of every mail */\nexport interface OrderFooter {",
+	} {
+		if !strings.Contains(ts, w) {
+			t.Errorf("TypeScript does not contain %q\n%s", w, ts)
+		}
+	}
+
+	schemas, err := gen.Describe(units, ".", gen.Options{})
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+	if s := schemas[0]; s.Doc != strings.TrimSuffix(description, "\n") || s.Fields[0].Doc != "footer of every mail" || s.Fields[0].Fields[0].Doc != "copyright year" {
+		t.Errorf("Describe = %+v", s)
+	}
+
+	u := gen.Unit{Pkg: "x", SourcePath: "order.tmpl", SourceLiteral: `{{/* @type ` + bindDomainPath + `.OrderView */}}{{/* @doc Status x */}}{{ .Status }}`}
+	_, err = gen.EmitWithOptions([]gen.Unit{u}, ".", gen.Options{Dir: "."})
+	if err == nil || !strings.Contains(err.Error(), "@doc cannot be used together with @type") {
+		t.Errorf("error = %v, want @doc with @type error", err)
+	}
+}
+
+func TestEmit_StringBytes_CompilesInTempModule(t *testing.T) {
+	// index が範囲外なら、途中まで出力したあとで実行時エラーになる
+	u := gen.Unit{Pkg: "main", SourcePath: "greet.tmpl", SourceLiteral: `{{/* @param Items []string */}}
+{{/* @required Name */}}
+Hello {{ .Name }}{{ if .Items }} {{ index .Items 1 }}{{ end }}`}
+
+	code, err := gen.Emit([]gen.Unit{u}, ".")
+	if err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	for _, want := range []string{
+		"var bufferPool = sync.Pool{",
+		"func RenderGreetString(p Greet) (string, error) {",
+		"func RenderGreetBytes(p Greet) ([]byte, error) {",
+		"return bytes.Clone(buf.Bytes()), nil",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code does not contain %q\n%s", want, code)
+		}
+	}
+
+	main := `package main
+
+import "fmt"
+
+func main() {
+	s, err := RenderGreetString(Greet{Name: "Alice", Items: []string{"a", "b"}})
+	fmt.Printf("%q %v\n", s, err)
+	b, err := RenderGreetBytes(Greet{Name: "Bob"})
+	fmt.Printf("%q %v\n", b, err)
+
+	// エラーなら途中までの出力は返さない
+	s, err = RenderGreetString(Greet{Name: "Carol", Items: []string{"a"}})
+	fmt.Printf("%q %v\n", s, err != nil)
+	b, err = RenderGreetBytes(Greet{Name: "Dave", Items: []string{"a"}})
+	fmt.Printf("%v %v\n", b == nil, err != nil)
+	s, err = RenderGreetString(Greet{})
+	fmt.Printf("%q %v\n", s, err)
+
+	// プールから再利用したバッファに前の出力が残らない
+	s, _ = RenderGreetString(Greet{Name: "Eve"})
+	fmt.Printf("%q\n", s)
+}
+`
+	files := map[string]string{
+		u.SourcePath: u.SourceLiteral,
+		"gen.go":     code,
+		"main.go":    main,
+	}
+	out := goInTempModule(t, files, "run", ".")
+	want := `"\n\nHello Alice b" <nil>
+"\n\nHello Bob" <nil>
+"" true
+true true
+"" template "greet": missing required fields: Name
+"\n\nHello Eve"
+`
+	if out != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+
+	// RenderXxxString と別のテンプレートの Render 関数が衝突する
+	units := []gen.Unit{
+		{Pkg: "main", SourcePath: "greet.tmpl", SourceLiteral: "{{ .Name }}"},
+		{Pkg: "main", SourcePath: "greet_string.tmpl", SourceLiteral: "{{ .Name }}"},
+	}
+	_, err = gen.Emit(units, ".")
+	if err == nil || !strings.Contains(err.Error(), "RenderGreetString") {
+		t.Errorf("error = %v, want RenderGreetString collision", err)
+	}
+}
+
+func TestEmit_Context_CompilesInTempModule(t *testing.T) {
+	// html/template は実行済みのテンプレートを Clone できないので、html モードで確かめる
+	u := gen.Unit{Pkg: "main", SourcePath: "page.html.tmpl", SourceLiteral: `{{/* @func user func(context.Context) string */}}
+{{/* @func halt func() string */}}
+<p>{{ user context }}</p>
+{{- range .Items }}<li>{{ . }}{{ if eq . "halt" }}{{ halt }}{{ end }}</li>{{ end }}`}
+	fm, err := funcmap.ParseRef("templateFuncs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := gen.EmitWithOptions([]gen.Unit{u}, ".", gen.Options{FuncMap: fm})
+	if err != nil {
+		t.Fatalf("EmitWithOptions failed: %v", err)
+	}
+	for _, want := range []string{
+		"func RenderPageContext(ctx context.Context, w io.Writer, p Page) error {",
+		`set.Funcs(template.FuncMap{"context": context.Background})`,
+		"tmpl, release, err := contextTemplate(ctx, Template.Page)",
+		"\tdefer release()\n",
+		"if err := tmpl.Execute(&contextWriter{ctx: ctx, w: w}, p); err != nil {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code does not contain %q\n%s", want, code)
+		}
+	}
+
+	main := `package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type userKey struct{}
+
+var cancel context.CancelFunc
+
+var templateFuncs = map[string]any{
+	"user": func(ctx context.Context) string {
+		if name, ok := ctx.Value(userKey{}).(string); ok {
+			return name
+		}
+		return "anonymous"
+	},
+	"halt": func() string {
+		cancel()
+		return ""
+	},
+}
+
+func main() {
+	ctx := context.WithValue(context.Background(), userKey{}, "alice")
+	p := Page{Items: []string{"a", "b"}}
+
+	// context 関数は描画に渡した ctx を返す（RenderPage では Background）
+	for range 2 {
+		if err := RenderPage(os.Stdout, p); err != nil {
+			panic(err)
+		}
+		fmt.Println()
+		if err := RenderPageContext(ctx, os.Stdout, p); err != nil {
+			panic(err)
+		}
+		fmt.Println()
+	}
+
+	// 描画の前に終わっていれば何も書かない
+	done, stop := context.WithCancel(ctx)
+	stop()
+	var buf bytes.Buffer
+	fmt.Printf("%v %q\n", RenderPageContext(done, &buf, p), buf.String())
+	past, stop := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer stop()
+	fmt.Println(RenderPageContext(past, &buf, p))
+
+	// 描画の途中で終われば、それ以降は書かない
+	var halted context.Context
+	halted, cancel = context.WithCancel(ctx)
+	buf.Reset()
+	err := RenderPageContext(halted, &buf, Page{Items: []string{"a", "halt", "b"}})
+	fmt.Printf("%v %q\n", err, buf.String())
+
+	// 描画を終えた複製は使い回し、描画中の複製は別の描画に渡さない
+	t1, release1, _ := contextTemplate(ctx, Template.Page)
+	t2, release2, _ := contextTemplate(ctx, Template.Page)
+	release1()
+	release2()
+	t3, release3, _ := contextTemplate(ctx, Template.Page)
+	release3()
+	fmt.Println(t1 != t2, t3 == t1 || t3 == t2)
+
+	// 並行した描画は、それぞれに渡した ctx を context 関数で受け取る
+	var wg sync.WaitGroup
+	var mixed atomic.Bool
+	for i := range 8 {
+		wg.Go(func() {
+			name := fmt.Sprint("user", i)
+			ctx := context.WithValue(context.Background(), userKey{}, name)
+			for range 50 {
+				var b bytes.Buffer
+				if err := RenderPageContext(ctx, &b, p); err != nil || !strings.Contains(b.String(), "<p>"+name+"</p>") {
+					mixed.Store(true)
+				}
+			}
+		})
+	}
+	wg.Wait()
+	fmt.Println(mixed.Load())
+
+	// ホットリロードでは読み込み直したテンプレートから複製する
+	if err := EnableHotReload("."); err != nil {
+		panic(err)
+	}
+	src, err := os.ReadFile("page.html.tmpl")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile("page.html.tmpl", append(src, "!"...), 0644); err != nil {
+		panic(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes("page.html.tmpl", later, later); err != nil {
+		panic(err)
+	}
+	buf.Reset()
+	fmt.Println(RenderPageContext(ctx, &buf, p), strings.HasSuffix(buf.String(), "</li>!"))
+}
+`
+	files := map[string]string{
+		u.SourcePath: u.SourceLiteral,
+		"gen.go":     code,
+		"main.go":    main,
+	}
+	out := goInTempModule(t, files, "run", ".")
+	want := `
+
+<p>anonymous</p><li>a</li><li>b</li>
+
+
+<p>alice</p><li>a</li><li>b</li>
+
+
+<p>anonymous</p><li>a</li><li>b</li>
+
+
+<p>alice</p><li>a</li><li>b</li>
+context canceled ""
+context deadline exceeded
+context canceled "\n\n<p>alice</p><li>a</li><li>halt"
+true true
+false
+<nil> true
+`
+	if out != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestEmit_Context_WithoutFuncMapAndConflicts(t *testing.T) {
+	u := gen.Unit{Pkg: "x", SourcePath: "tpl.tmpl", SourceLiteral: "{{ .Name }}"}
+	code, err := gen.Emit([]gen.Unit{u}, ".")
+	if err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	// FuncMap がなければテンプレートを複製しない
+	if !strings.Contains(code, "func RenderTplContext(ctx context.Context, w io.Writer, p Tpl) error {") ||
+		strings.Contains(code, "contextTemplate") {
+		t.Errorf("unexpected RenderTplContext\n%s", code)
+	}
+
+	// context 関数は生成コードが加えるので、FuncMap や @func では使えない
+	fm, err := funcmap.ParseRef("templateFuncs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fm.Funcs["context"] = scan.Func{}
+	_, err = gen.EmitWithOptions([]gen.Unit{u}, ".", gen.Options{FuncMap: fm})
+	if err == nil || !strings.Contains(err.Error(), `func "context" conflicts with the generated context func`) {
+		t.Errorf("error = %v, want FuncMap conflict", err)
+	}
+
+	fm, err = funcmap.ParseRef("templateFuncs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.SourceLiteral = "{{/* @func context func() string */}}\n{{ .Name }}"
+	_, err = gen.EmitWithOptions([]gen.Unit{u}, ".", gen.Options{FuncMap: fm})
+	if err == nil || !strings.Contains(err.Error(), "tpl.tmpl:1:1: @func context conflicts with the generated context func") {
+		t.Errorf("error = %v, want @func conflict", err)
+	}
+}
+
+func TestEmit_HotReload_CompilesInTempModule(t *testing.T) {
+	units := []gen.Unit{
+		{Pkg: "main", SourcePath: "templates/page.html.tmpl", SourceLiteral: `<h1>{{ .Title }}</h1>{{ template "footer" . }}`},
+		{Pkg: "main", SourcePath: "templates/footer.html.tmpl", SourceLiteral: `<p>{{ .Note }}</p>`},
+	}
+	code, err := gen.Emit(units, "templates")
+	if err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	for _, want := range []string{
+		"func EnableHotReload(dir string) error {",
+		`{Template.Page, "templates/page.html.tmpl", `,
+		"\ttmpl, err := lookupTemplate(Template.Page)\n",
+		"\ttmpl, err := lookupTemplate(name)\n",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code does not contain %q\n%s", want, code)
+		}
+	}
+
+	main := `package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+var modTime = time.Now()
+
+// edit は template を書き換え、更新時刻を進める
+func edit(path, src string) {
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		panic(err)
+	}
+	modTime = modTime.Add(time.Second)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		panic(err)
+	}
+}
+
+func show() {
+	s, err := RenderPageString(Page{Title: "T", Footer: Footer{Note: "N"}})
+	fmt.Printf("%q %v\n", s, err)
+}
+
+func main() {
+	// 既定では埋め込んだテンプレートを使う
+	edit("templates/page.html.tmpl", "<h2>{{ .Title }}</h2>{{ template \"footer\" . }}")
+	show()
+
+	fmt.Println(EnableHotReload("missing") != nil)
+	if err := EnableHotReload("."); err != nil {
+		panic(err)
+	}
+	show()
+	edit("templates/footer.html.tmpl", "<small>{{ .Note }}</small>")
+	show()
+	fmt.Println(Render(os.Stdout, Template.Footer, Footer{Note: "F"}))
+	fmt.Println(RenderPageContext(context.Background(), os.Stdout, Page{Title: "C", Footer: Footer{Note: "N"}}))
+
+	// パースできなければエラーを返し、直せば元に戻る
+	edit("templates/footer.html.tmpl", "{{ .Note ")
+	show()
+	edit("templates/footer.html.tmpl", "<i>{{ .Note }}</i>")
+	show()
+}
+`
+	files := map[string]string{
+		"gen.go":  code,
+		"main.go": main,
+	}
+	for _, u := range units {
+		files[u.SourcePath] = u.SourceLiteral
+	}
+	out := goInTempModule(t, files, "run", ".")
+	want := `"<h1>T</h1><p>N</p>" <nil>
+true
+"<h2>T</h2><p>N</p>" <nil>
+"<h2>T</h2><small>N</small>" <nil>
+<small>F</small><nil>
+<h2>C</h2><small>N</small><nil>
+"" template: footer:1: unclosed action
+"<h2>T</h2><i>N</i>" <nil>
+`
+	if out != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestEmit_LazyInit_CompilesInTempModule(t *testing.T) {
+	// layout は {{define}} の title を呼ぶ。title は a の {{block}} を b が上書きするので b が勝つ
+	// broken は FuncMap にない関数を使うので、生成はできても実行時のパースに失敗する
+	units := []gen.Unit{
+		{Pkg: "main", SourcePath: "a.tmpl", SourceLiteral: `{{ block "title" . }}A{{ end }}a`},
+		{Pkg: "main", SourcePath: "b.tmpl", SourceLiteral: `{{ define "title" }}B{{ end }}b`},
+		{Pkg: "main", SourcePath: "broken.tmpl", SourceLiteral: "{{/* @func shout func(string) string */}}{{ shout .Name }}"},
+		{Pkg: "main", SourcePath: "caller.tmpl", SourceLiteral: `{{ template "broken" . }}`},
+		{Pkg: "main", SourcePath: "layout.tmpl", SourceLiteral: `[{{ template "title" }}] {{ .Body }}`},
+	}
+	fm, err := funcmap.ParseRef("templateFuncs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := gen.EmitWithOptions(units, ".", gen.Options{FuncMap: fm})
+	if err != nil {
+		t.Fatalf("EmitWithOptions failed: %v", err)
+	}
+	for _, want := range []string{
+		"Template.A:      {name: Template.A, deps: []TemplateName{Template.A, Template.B}},",
+		"Template.Caller: {name: Template.Caller, deps: []TemplateName{Template.Broken, Template.Caller}},",
+		"Template.Layout: {name: Template.Layout, deps: []TemplateName{Template.A, Template.B, Template.Layout}},",
+		"func ParseAll() error {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated code does not contain %q\n%s", want, code)
+		}
+	}
+	if strings.Contains(code, "template.Must") {
+		t.Errorf("generated code should not panic on parse errors\n%s", code)
+	}
+
+	main := `package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+var templateFuncs = map[string]any{"upper": strings.ToUpper}
+
+func main() {
+	// 使わないテンプレートのエラーで起動は失敗しない
+	if err := RenderLayout(os.Stdout, Layout{Body: "body"}); err != nil {
+		panic(err)
+	}
+	fmt.Println()
+	fmt.Println(RenderCaller(os.Stdout, Caller{Broken: Broken{Name: "x"}}))
+	fmt.Println(len(Templates()))
+	fmt.Println(ParseAll())
+}
+`
+	files := map[string]string{
+		"gen.go":  code,
+		"main.go": main,
+	}
+	for _, u := range units {
+		files[u.SourcePath] = u.SourceLiteral
+	}
+	out := goInTempModule(t, files, "run", ".")
+	want := `[B] body
+template: broken:1: function "shout" not defined
+3
+template: broken:1: function "shout" not defined
+`
+	if out != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+}
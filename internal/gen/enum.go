@@ -0,0 +1,113 @@
+package gen
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+	"github.com/bellwood4486/tmpltype/internal/typing"
+	"github.com/bellwood4486/tmpltype/internal/util"
+)
+
+// enumConst は @enum の値の定数
+type enumConst struct {
+	name  string // 定数名（例: "OrderStatusShipped"）
+	value string
+}
+
+// enumConsts は @enum の型 typeName の定数を値の順に返す
+// 定数名は型名に値をエクスポートした名前を付けたもの（英数字以外は単語の区切り。例: "in-transit" -> InTransit）
+func enumConsts(typeName string, e *typing.Enum) []enumConst {
+	consts := make([]enumConst, 0, len(e.Values))
+	for _, v := range e.Values {
+		word := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+			return '_'
+		}, v)
+		consts = append(consts, enumConst{name: typeName + util.Export(word), value: v})
+	}
+	return consts
+}
+
+// checkEnums は @enum の値から作る定数名が衝突しないか検証する
+// 例: "in-transit" と "in_transit" はどちらも InTransit になる
+func checkEnums(p *emitPrepared) error {
+	var errs []error
+	_ = p.eachTemplate(func(t *tmpl) error {
+		for _, e := range t.typed.Enums {
+			typeName := t.typeName + e.Name
+			seen := make(map[string]string) // 定数名 -> 値
+			for _, c := range enumConsts(typeName, e) {
+				if c.name == typeName {
+					errs = append(errs, scan.Errorf(e.Pos, "@enum %s: value %q has no letters or digits to name a constant", e.Path, c.value))
+					continue
+				}
+				if prev, ok := seen[c.name]; ok {
+					errs = append(errs, scan.Errorf(e.Pos, "@enum %s: values %q and %q both become the constant %s", e.Path, prev, c.value, c.name))
+					continue
+				}
+				seen[c.name] = c.value
+			}
+		}
+		return nil
+	})
+	return errors.Join(errs...)
+}
+
+// isEnumType は型名 name が @enum で生成する型か返す
+func (p *emitPrepared) isEnumType(name string) bool {
+	for _, t := range p.allTemplates() {
+		if enumOf(t, name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// enumOf はテンプレート t の @enum の型のうち、生成コード上の型名が name のものを返す（なければ nil）
+func enumOf(t tmpl, name string) *typing.Enum {
+	if t.typed == nil {
+		return nil
+	}
+	for _, e := range t.typed.Enums {
+		if t.typeName+e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// generateEnums は @enum の型、定数、IsValid メソッドを生成する
+func generateEnums(b *strings.Builder, t tmpl) {
+	for _, e := range t.typed.Enums {
+		typeName := t.typeName + e.Name
+		consts := enumConsts(typeName, e)
+
+		write(b, "// %s is the set of values of %s in the %s template\n", typeName, e.Path, t.name)
+		write(b, "type %s string\n\n", typeName)
+		write(b, "const (\n")
+		for _, c := range consts {
+			write(b, "\t%s %s = %q\n", c.name, typeName, c.value)
+		}
+		write(b, ")\n\n")
+
+		write(b, "// IsValid reports whether s is one of the %s constants\n", typeName)
+		write(b, "func (s %s) IsValid() bool {\n", typeName)
+		write(b, "\tswitch s {\n")
+		write(b, "\tcase ")
+		for i, c := range consts {
+			if i > 0 {
+				write(b, ", ")
+			}
+			write(b, "%s", c.name)
+		}
+		write(b, ":\n")
+		write(b, "\t\treturn true\n")
+		write(b, "\t}\n")
+		write(b, "\treturn false\n")
+		write(b, "}\n\n")
+	}
+}
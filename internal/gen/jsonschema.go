@@ -0,0 +1,288 @@
+package gen
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"maps"
+	"math"
+	"slices"
+
+	"github.com/bellwood4486/tmpltype/internal/typing"
+)
+
+// jsonSchemaDialect は生成する JSON Schema のバージョン
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonSchema は JSON Schema のノード（使うキーワードのみ）
+// encoding/json は map のキーをソートして出力するので、出力は安定する
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Comment              string                 `json:"$comment,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 any                    `json:"type,omitempty"` // string または []string（null を許す場合）
+	Format               string                 `json:"format,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Default              any                    `json:"default,omitempty"`
+	ContentEncoding      string                 `json:"contentEncoding,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties any                    `json:"additionalProperties,omitempty"` // false または *jsonSchema
+	AnyOf                []*jsonSchema          `json:"anyOf,omitempty"`
+	Defs                 map[string]*jsonSchema `json:"$defs,omitempty"`
+}
+
+// EmitJSONSchemas はテンプレートごとのパラメータ型の JSON Schema を生成する
+// 戻り値はテンプレート名 -> JSON Schema（draft 2020-12）のドキュメント
+// プロパティ名は encoding/json のキー（json タグがあればその名前）。必須のフィールドは required に、@default の値は default に含める
+// テンプレート先頭のコメントと @doc の説明は description になる
+// 名前付き型と参照先テンプレートの型は $defs に生成コードと同じ型名で含め、ドキュメントごとに自己完結させる
+// @type で既存の型に結び付けたテンプレートは型の定義が別にあるので対象外
+func EmitJSONSchemas(units []Unit, basedir string, opts Options) (map[string][]byte, error) {
+	p, err := prepare(units, basedir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]tmpl)
+	for _, t := range p.allTemplates() {
+		byName[t.name] = t
+	}
+
+	docs := make(map[string][]byte)
+	for _, t := range p.allTemplates() {
+		if t.bound != nil {
+			continue
+		}
+		b := &jsonSchemaBuilder{p: p, templates: byName, defs: make(map[string]*jsonSchema)}
+		root := b.object(t, t.typed.Fields, t.typed.Embeds)
+		root.Schema = jsonSchemaDialect
+		root.Title = t.typeName
+		root.Description = fmt.Sprintf("Parameters for the %s template", t.name)
+		if t.typed.Doc != "" {
+			root.Description = t.typed.Doc // テンプレート先頭のコメント
+		}
+		if len(b.defs) > 0 {
+			root.Defs = b.defs
+		}
+
+		data, err := json.MarshalIndent(root, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		docs[t.name] = append(data, '\n')
+	}
+	return docs, nil
+}
+
+// jsonSchemaBuilder は1つのドキュメントの $defs を集めながらスキーマを構築する
+type jsonSchemaBuilder struct {
+	p         *emitPrepared
+	templates map[string]tmpl
+	defs      map[string]*jsonSchema
+}
+
+// object は構造体（フィールドと埋め込むテンプレート）のスキーマを返す
+// 埋め込んだテンプレートのフィールドは encoding/json と同じく同じ階層のプロパティになる
+func (b *jsonSchemaBuilder) object(t tmpl, fields map[string]*typing.TypedField, embeds []string) *jsonSchema {
+	s := &jsonSchema{Type: "object", Properties: make(map[string]*jsonSchema)}
+	closed := true // 未知のプロパティを拒否できる（フィールドがすべて分かっている）
+
+	var add func(t tmpl, fields map[string]*typing.TypedField, embeds []string)
+	add = func(t tmpl, fields map[string]*typing.TypedField, embeds []string) {
+		for _, name := range slices.Sorted(maps.Keys(fields)) {
+			f := fields[name]
+			key, _, ok := b.p.jsonKey(f)
+			if !ok {
+				continue // json:"-"
+			}
+			if _, ok := s.Properties[key]; !ok {
+				s.Properties[key] = b.field(t, f)
+				if f.Default != nil {
+					s.Properties[key].Default = defaultJSON(f.Default)
+				}
+				if f.Doc != "" {
+					s.Properties[key].Description = describeWith(f.Doc, s.Properties[key].Description)
+				}
+				if b.p.validation.required(t, f) {
+					s.Required = append(s.Required, key)
+				}
+			}
+		}
+		for _, e := range embeds {
+			et, ok := b.templates[e]
+			if !ok || et.bound != nil {
+				closed = false // 既存の型のフィールドは分からない
+				continue
+			}
+			add(et, et.typed.Fields, et.typed.Embeds)
+		}
+	}
+	add(t, fields, embeds)
+
+	if closed {
+		s.AdditionalProperties = false
+	}
+	slices.Sort(s.Required)
+	return s
+}
+
+// field はフィールドのスキーマを返す
+func (b *jsonSchemaBuilder) field(t tmpl, f *typing.TypedField) *jsonSchema {
+	if f.Template != "" {
+		return b.templateRef(f.Template)
+	}
+	expr, err := parser.ParseExpr(f.GoType)
+	if err != nil {
+		return &jsonSchema{Comment: "Go type " + f.GoType}
+	}
+	return b.typ(t, expr)
+}
+
+// typ は Go の型の式のスキーマを返す
+func (b *jsonSchemaBuilder) typ(t tmpl, expr ast.Expr) *jsonSchema {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if s := basicJSONSchema(e.Name); s != nil {
+			return s
+		}
+		// @enum の型
+		if en := enumOf(t, t.typeName+e.Name); en != nil {
+			return &jsonSchema{Type: "string", Enum: en.Values}
+		}
+		// テンプレートの名前付き型（生成コードではテンプレートの型名が前に付く）
+		for _, nt := range t.typed.NamedTypes {
+			if nt.Name == e.Name {
+				name := t.typeName + nt.Name
+				if _, ok := b.defs[name]; !ok {
+					b.defs[name] = nil // 再帰的な参照に備えて先に登録する
+					b.defs[name] = b.object(t, nt.Fields, nt.Embeds)
+					b.defs[name].Description = nt.Doc
+				}
+				return &jsonSchema{Ref: "#/$defs/" + name}
+			}
+		}
+	case *ast.SelectorExpr:
+		if s := qualifiedJSONSchema(types.ExprString(e)); s != nil {
+			return s
+		}
+	case *ast.StarExpr:
+		return nullable(b.typ(t, e.X))
+	case *ast.ArrayType:
+		// nil のスライスとマップは encoding/json で null になる
+		if id, ok := e.Elt.(*ast.Ident); ok && e.Len == nil && (id.Name == "byte" || id.Name == "uint8") {
+			return nullable(&jsonSchema{Type: "string", ContentEncoding: "base64"}) // encoding/json と同じ
+		}
+		if e.Len == nil {
+			return nullable(&jsonSchema{Type: "array", Items: b.typ(t, e.Elt)})
+		}
+	case *ast.MapType:
+		return nullable(&jsonSchema{Type: "object", AdditionalProperties: b.typ(t, e.Value)})
+	case *ast.InterfaceType:
+		return &jsonSchema{}
+	case *ast.StructType:
+		// @param の struct{...}
+		fields := make(map[string]*typing.TypedField)
+		for _, f := range e.Fields.List {
+			for _, n := range f.Names {
+				fields[n.Name] = &typing.TypedField{Name: n.Name, GoType: types.ExprString(f.Type)}
+			}
+		}
+		return b.object(t, fields, nil)
+	}
+	// JSON での表現が分からない型はどんな値も受け付ける
+	return &jsonSchema{Comment: "Go type " + types.ExprString(expr)}
+}
+
+// templateRef は別テンプレートの型への参照を返し、その型を $defs に加える
+func (b *jsonSchemaBuilder) templateRef(name string) *jsonSchema {
+	rt, ok := b.templates[name]
+	if !ok || rt.bound != nil {
+		return &jsonSchema{Comment: "Go type " + b.p.typeNames[name]}
+	}
+	if _, ok := b.defs[rt.typeName]; !ok {
+		b.defs[rt.typeName] = nil // 再帰的な参照に備えて先に登録する
+		b.defs[rt.typeName] = b.object(rt, rt.typed.Fields, rt.typed.Embeds)
+		b.defs[rt.typeName].Description = rt.typed.Doc
+	}
+	return &jsonSchema{Ref: "#/$defs/" + rt.typeName}
+}
+
+// describeWith は @doc の説明 doc に、型のスキーマにある説明 typeDesc（例: "nanoseconds"）を括弧書きで添える
+func describeWith(doc, typeDesc string) string {
+	if typeDesc == "" {
+		return doc
+	}
+	return doc + " (" + typeDesc + ")"
+}
+
+// nullable は null も受け付けるスキーマを返す（ポインタ、スライス、マップの型）
+func nullable(s *jsonSchema) *jsonSchema {
+	if typ, ok := s.Type.(string); ok {
+		s.Type = []string{typ, "null"}
+		return s
+	}
+	if s.Type == nil && s.Ref == "" {
+		return s // どんな値も受け付ける
+	}
+	return &jsonSchema{AnyOf: []*jsonSchema{s, {Type: "null"}}}
+}
+
+// basicJSONSchema は組み込み型のスキーマを返す（組み込み型でなければ nil）
+func basicJSONSchema(name string) *jsonSchema {
+	integer := func(min, max float64) *jsonSchema {
+		return &jsonSchema{Type: "integer", Minimum: &min, Maximum: &max}
+	}
+	switch name {
+	case "string":
+		return &jsonSchema{Type: "string"}
+	case "bool":
+		return &jsonSchema{Type: "boolean"}
+	case "int", "int64":
+		return &jsonSchema{Type: "integer"}
+	case "int8":
+		return integer(math.MinInt8, math.MaxInt8)
+	case "int16":
+		return integer(math.MinInt16, math.MaxInt16)
+	case "int32", "rune":
+		return integer(math.MinInt32, math.MaxInt32)
+	case "uint", "uint64":
+		min := 0.0
+		return &jsonSchema{Type: "integer", Minimum: &min}
+	case "uint8", "byte":
+		return integer(0, math.MaxUint8)
+	case "uint16":
+		return integer(0, math.MaxUint16)
+	case "uint32":
+		return integer(0, math.MaxUint32)
+	case "float32", "float64":
+		return &jsonSchema{Type: "number"}
+	case "any":
+		return &jsonSchema{}
+	}
+	return nil
+}
+
+// qualifiedJSONSchema はパッケージ修飾付きの型のうち、JSON での表現が決まっている型のスキーマを返す
+func qualifiedJSONSchema(name string) *jsonSchema {
+	switch name {
+	case "time.Time":
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	case "time.Duration":
+		return &jsonSchema{Type: "integer", Description: "nanoseconds"}
+	case "template.HTML", "template.HTMLAttr", "template.CSS", "template.JS", "template.JSStr", "template.URL", "template.Srcset":
+		return &jsonSchema{Type: "string"}
+	case "json.RawMessage":
+		return &jsonSchema{}
+	case "json.Number":
+		return &jsonSchema{Type: "number"}
+	}
+	return nil
+}
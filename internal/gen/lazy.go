@@ -0,0 +1,100 @@
+package gen
+
+import (
+	"slices"
+	"text/template/parse"
+)
+
+// templateDeps はテンプレートごとに、一緒にパースするテンプレートの名前（自身と、呼び出すテンプレートを定義するもの）をパース順に返す
+// 生成コードはテンプレートを最初に使うときに、このテンプレートだけを1つのセットにパースする
+// {{define}} は後勝ちなので、呼び出す名前を定義するテンプレートはすべて含める（全体をパースしたときと同じ定義になる）
+func templateDeps(all []tmpl) map[string][]string {
+	defines := make(map[string][]string) // テンプレート名 -> 定義する名前（自身と {{define}} / {{block}}）
+	calls := make(map[string][]string)   // テンプレート名 -> {{template}} で呼び出す名前
+	for _, t := range all {
+		trees, err := parseTrees(t.name, t.source)
+		if err != nil {
+			// パースできないテンプレートは生成の前にエラーになるが、念のため全体を一緒にパースする
+			deps := make(map[string][]string, len(all))
+			for _, t := range all {
+				deps[t.name] = allNames(all)
+			}
+			return deps
+		}
+		defines[t.name] = append(defines[t.name], t.name)
+		for name, tree := range trees {
+			if name != t.name {
+				defines[t.name] = append(defines[t.name], name)
+			}
+			calls[t.name] = append(calls[t.name], templateCalls(tree.Root)...)
+		}
+	}
+
+	deps := make(map[string][]string, len(all))
+	for _, t := range all {
+		need := map[string]bool{t.name: true}
+		queue := []string{t.name}
+		for len(queue) > 0 {
+			cur := queue[0]
+			queue = queue[1:]
+			for _, called := range calls[cur] {
+				for _, other := range all {
+					if !need[other.name] && slices.Contains(defines[other.name], called) {
+						need[other.name] = true
+						queue = append(queue, other.name)
+					}
+				}
+			}
+		}
+		for _, other := range all {
+			if need[other.name] {
+				deps[t.name] = append(deps[t.name], other.name)
+			}
+		}
+	}
+	return deps
+}
+
+// parseTrees はテンプレートを構文だけパースし、テンプレート自身と {{define}} / {{block}} の木を返す
+// 関数の有無は確かめない（FuncMap の関数は生成コードのパース時に解決される）
+func parseTrees(name, src string) (map[string]*parse.Tree, error) {
+	trees := make(map[string]*parse.Tree)
+	t := parse.New(name)
+	t.Mode = parse.SkipFuncCheck
+	if _, err := t.Parse(src, "", "", trees); err != nil {
+		return nil, err
+	}
+	return trees, nil
+}
+
+// templateCalls は node 以下の {{template}}（{{block}} を含む）で呼び出すテンプレート名を返す
+func templateCalls(node parse.Node) []string {
+	var names []string
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, c := range n.Nodes {
+			names = append(names, templateCalls(c)...)
+		}
+	case *parse.IfNode:
+		names = append(templateCalls(n.List), templateCalls(n.ElseList)...)
+	case *parse.RangeNode:
+		names = append(templateCalls(n.List), templateCalls(n.ElseList)...)
+	case *parse.WithNode:
+		names = append(templateCalls(n.List), templateCalls(n.ElseList)...)
+	case *parse.TemplateNode:
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+// allNames はテンプレート名をパース順に返す
+func allNames(all []tmpl) []string {
+	names := make([]string, 0, len(all))
+	for _, t := range all {
+		names = append(names, t.name)
+	}
+	return names
+}
@@ -0,0 +1,286 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+	"github.com/bellwood4486/tmpltype/internal/typing"
+)
+
+// nameHint はテンプレート名や識別子が衝突したときに示す解決方法
+const nameHint = "rename one of the templates or set {{/* @name ... */}}"
+
+// fixedIdentifiers はテンプレートによらず生成されるパッケージレベルの識別子
+var fixedIdentifiers = []struct {
+	name string
+	kind string
+}{
+	{"TemplateName", "type"},
+	{"Template", "var"},
+	{"templateFiles", "var"},
+	{"parseTemplates", "func"},
+	{"lazyTemplate", "type"},
+	{"templates", "var"},
+	{"Templates", "func"},
+	{"ParseAll", "func"},
+	{"Render", "func"},
+	{"bufferPool", "var"},
+	{"getBuffer", "func"},
+	{"putBuffer", "func"},
+	{"contextWriter", "type"},
+	{"contextClone", "type"},
+	{"contextPool", "type"},
+	{"contextPools", "var"},
+	{"contextSource", "func"},
+	{"contextTemplate", "func"},
+	{"hotReloader", "type"},
+	{"hotReload", "var"},
+	{"EnableHotReload", "func"},
+	{"lookupTemplate", "func"},
+}
+
+// checkTemplateNames は同じテンプレート名になるテンプレートがないか検証する
+// templates はテンプレート名でソート済みであること
+// 例: "user_list.tmpl", "user-list.tmpl", "01_user_list.tmpl" はすべて "user_list" になる
+func checkTemplateNames(templates []tmpl) error {
+	var errs []error
+	for i := 1; i < len(templates); i++ {
+		prev, t := &templates[i-1], &templates[i]
+		if t.name == prev.name {
+			t, prev = reportAt(t, prev)
+			errs = append(errs, scan.Errorf(t.namePos, "template name %q conflicts with %s; %s", t.name, prev.file, nameHint))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reportAt は衝突した2つのテンプレートのうち、エラーを報告する方を先にして返す
+// @name で名前を指定したテンプレートがあればそちらで報告する
+func reportAt(t, prev *tmpl) (*tmpl, *tmpl) {
+	if prev.namePos.Line > 0 && t.namePos.Line == 0 {
+		return prev, t
+	}
+	return t, prev
+}
+
+// identOwner は生成する識別子とその由来
+type identOwner struct {
+	kind string // "type", "func", "var", "const", "field"
+	t    *tmpl  // 由来のテンプレート（nil なら fixedIdentifiers）
+}
+
+// identChecker は生成する識別子の衝突を記録する
+// 同じテンプレートの組の衝突は、最初に見つかった識別子だけを報告する
+type identChecker struct {
+	reported map[[2]*tmpl]bool
+	errs     []error
+}
+
+// identScope は識別子の衝突を検出する名前空間（パッケージや Template 変数の構造体）
+type identScope struct {
+	owners map[string]identOwner
+	c      *identChecker
+}
+
+func (c *identChecker) newScope() *identScope {
+	return &identScope{owners: make(map[string]identOwner), c: c}
+}
+
+// declare は識別子 name を宣言し、別の由来の同名の識別子があればエラーを記録する
+// 同じテンプレートが同じ種類の識別子を重ねて宣言するのは衝突としない（名前付き型の再利用など）
+func (s *identScope) declare(name, kind string, t *tmpl) {
+	prev, ok := s.owners[name]
+	if !ok {
+		s.owners[name] = identOwner{kind: kind, t: t}
+		return
+	}
+	if prev.t == t && prev.kind == kind {
+		return
+	}
+	if prev.t != nil && prev.t != t {
+		if s.c.reported[[2]*tmpl{prev.t, t}] {
+			return
+		}
+		s.c.reported[[2]*tmpl{prev.t, t}] = true
+		s.c.reported[[2]*tmpl{t, prev.t}] = true
+		if at, _ := reportAt(t, prev.t); at != t {
+			t, prev, kind = prev.t, identOwner{kind: kind, t: t}, prev.kind
+		}
+	}
+
+	var desc string
+	switch {
+	case prev.t == nil:
+		desc = fmt.Sprintf("the generated %s %s", prev.kind, name)
+	case prev.t == t:
+		desc = fmt.Sprintf("%s %s generated for the same template", prev.kind, name)
+	default:
+		desc = fmt.Sprintf("%s %s generated for %s", prev.kind, name, prev.t.file)
+	}
+	s.c.errs = append(s.c.errs, scan.Errorf(t.namePos, "%s %s conflicts with %s; %s", kind, name, desc, nameHint))
+}
+
+// checkNamespace は Template 変数の1階層分（テンプレートとサブグループのフィールド）の衝突を検出する
+// 例: "mail/account.tmpl" と "mail/account/title.tmpl" はどちらも Template.Mail.Account を使う
+func (c *identChecker) checkNamespace(templates []tmpl, groups []tmplGroup) {
+	scope := c.newScope()
+	for i := range templates {
+		scope.declare(templates[i].localName, "field", &templates[i])
+	}
+	for i := range groups {
+		g := &groups[i]
+		if t := firstTemplate(g); t != nil {
+			scope.declare(g.fieldName, "field", t)
+		}
+		c.checkNamespace(g.templates, g.groups)
+	}
+}
+
+// firstTemplate はグループ（サブグループを含む）の最初のテンプレートを返す
+func firstTemplate(g *tmplGroup) *tmpl {
+	if len(g.templates) > 0 {
+		return &g.templates[0]
+	}
+	for i := range g.groups {
+		if t := firstTemplate(&g.groups[i]); t != nil {
+			return t
+		}
+	}
+	return nil
+}
+
+// checkIdentifiers は生成する識別子（型、@enum の定数、Render 関数とその String / Bytes / Context 版、embed 変数、Template のフィールド）の衝突を検出する
+// 例: "userList.tmpl" と "user_list.tmpl" はどちらも UserList、グループ mail の invite とフラットな mail_invite はどちらも MailInvite
+func checkIdentifiers(p *emitPrepared) error {
+	c := &identChecker{reported: make(map[[2]*tmpl]bool)}
+	pkg := c.newScope()
+	for _, id := range fixedIdentifiers {
+		pkg.declare(id.name, id.kind, nil)
+	}
+	if len(p.validation.methods) > 0 {
+		pkg.declare(missingFieldsError, "type", nil)
+	}
+
+	_ = p.eachTemplate(func(t *tmpl) error {
+		if t.bound == nil {
+			pkg.declare(t.typeName, "type", t)
+			for _, nt := range t.typed.NamedTypes {
+				pkg.declare(t.typeName+nt.Name, "type", t)
+			}
+			for _, e := range t.typed.Enums {
+				pkg.declare(t.typeName+e.Name, "type", t)
+				for _, c := range enumConsts(t.typeName+e.Name, e) {
+					pkg.declare(c.name, "const", t)
+				}
+			}
+		}
+		pkg.declare("Render"+t.typeName, "func", t)
+		pkg.declare("Render"+t.typeName+"String", "func", t)
+		pkg.declare("Render"+t.typeName+"Bytes", "func", t)
+		pkg.declare("Render"+t.typeName+"Context", "func", t)
+		pkg.declare(t.varName, "var", t)
+		return nil
+	})
+
+	// Template 変数のフィールド（フラットなテンプレートとグループ）
+	c.checkNamespace(p.flatTemplates, p.groups)
+
+	c.errs = append(c.errs, checkEmbeds(p)...)
+	return errors.Join(c.errs...)
+}
+
+// promotedField は埋め込んだテンプレートの型から昇格されるフィールド（埋め込んだ型そのものを含む）
+type promotedField struct {
+	name string
+	pos  scan.Pos // フィールドの位置（埋め込んだ型なら位置なし）
+}
+
+// checkEmbeds は {{ template "name" . }} で埋め込むテンプレートの型とフィールドの衝突を検出する
+//   - 埋め込む型と同じ名前のフィールド（{{ template "header" . }} と {{ .Header.X }}）はコンパイルできない
+//   - 2つ以上の埋め込みから昇格される同じ名前のフィールド（header と footer の両方の {{ .Title }}）は曖昧で、描画に失敗する
+func checkEmbeds(p *emitPrepared) []error {
+	templates := make(map[string]tmpl)
+	for _, t := range p.allTemplates() {
+		templates[t.name] = t
+	}
+
+	var errs []error
+	check := func(typeName string, fields map[string]*typing.TypedField, embeds []string) {
+		from := make(map[string]string)  // 昇格されるフィールド名 -> 埋め込んだテンプレート名
+		pos := make(map[string]scan.Pos) // 昇格されるフィールド名 -> 位置
+		for _, e := range embeds {
+			embedded := embeddedName(p.typeNames[e])
+			for _, key := range slices.Sorted(maps.Keys(fields)) {
+				if f := fields[key]; f.Name == embedded {
+					errs = append(errs, scan.Errorf(f.Pos, "field %s conflicts with the type %s embedded by {{ template %q . }}; pass a field instead of . to the template or rename the field", f.Name, embedded, e))
+				}
+			}
+			for _, pf := range promotedFields(p, templates, e, nil) {
+				prev, ok := from[pf.name]
+				switch {
+				case !ok:
+					from[pf.name], pos[pf.name] = e, pf.pos
+				case prev != e && prev != "":
+					from[pf.name] = "" // 同じフィールドは1度だけ報告する
+					at, other, first, second := pf.pos, pos[pf.name], prev, e
+					if at == (scan.Pos{}) {
+						// 埋め込んだ型そのものには位置がないので、もう一方のフィールドの位置で報告する
+						at, other, first, second = other, at, e, prev
+					}
+					if at == (scan.Pos{}) {
+						continue
+					}
+					firstDesc := fmt.Sprintf("{{ template %q . }}", first)
+					if other != (scan.Pos{}) {
+						firstDesc += fmt.Sprintf(" (%s)", other)
+					}
+					errs = append(errs, scan.Errorf(at, "field %s is promoted into %s from both %s and {{ template %q . }}, so it is ambiguous; pass a field instead of . to one of the templates", pf.name, typeName, firstDesc, second))
+				}
+			}
+		}
+	}
+	for _, t := range p.allTemplates() {
+		if t.bound != nil {
+			continue
+		}
+		check(t.typeName, t.typed.Fields, t.typed.Embeds)
+		for _, nt := range t.typed.NamedTypes {
+			check(t.typeName+nt.Name, nt.Fields, nt.Embeds)
+		}
+	}
+	return errs
+}
+
+// promotedFields はテンプレート name の型を埋め込んだときに昇格されるフィールドを返す
+// 型の分からない @type のテンプレートは対象外
+func promotedFields(p *emitPrepared, templates map[string]tmpl, name string, visiting []string) []promotedField {
+	t, ok := templates[name]
+	if !ok || t.bound != nil || slices.Contains(visiting, name) {
+		return nil
+	}
+	var fields []promotedField
+	own := make(map[string]bool)
+	for _, key := range slices.Sorted(maps.Keys(t.typed.Fields)) {
+		f := t.typed.Fields[key]
+		fields = append(fields, promotedField{name: f.Name, pos: f.Pos})
+		own[f.Name] = true
+	}
+	for _, e := range t.typed.Embeds {
+		fields = append(fields, promotedField{name: embeddedName(p.typeNames[e])})
+		for _, pf := range promotedFields(p, templates, e, append(visiting, name)) {
+			if !own[pf.name] { // 浅いフィールドが優先される
+				fields = append(fields, pf)
+			}
+		}
+	}
+	return fields
+}
+
+// embeddedName は埋め込んだ型のフィールド名を返す（例: "domain.OrderView" -> "OrderView"）
+func embeddedName(typeName string) string {
+	return typeName[strings.LastIndex(typeName, ".")+1:]
+}
@@ -0,0 +1,147 @@
+package gen
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+	"github.com/bellwood4486/tmpltype/internal/typing"
+	"github.com/bellwood4486/tmpltype/internal/typing/magic"
+)
+
+// 型の由来（FieldSchema.Source）
+const (
+	SourceInferred = "inferred" // テンプレートの使われ方から推論
+	SourceParam    = "@param"   // @param ディレクティブで指定
+)
+
+// TemplateSchema はテンプレート1つ分のスキャン結果と型解決結果（tmpltype schema の出力）
+type TemplateSchema struct {
+	Name   string        `json:"name"`             // テンプレート名（例: "mail/invite/title"）
+	File   string        `json:"file"`             // テンプレートファイルのパス
+	Type   string        `json:"type"`             // パラメータの型（@type なら結び付けた既存の型）
+	Bound  bool          `json:"bound,omitempty"`  // @type で既存の型に結び付けた
+	Embeds []string      `json:"embeds,omitempty"` // トップレベルに埋め込むテンプレート名
+	Fields []FieldSchema `json:"fields"`           // フィールド（名前順）
+	Doc    string        `json:"doc,omitempty"`    // テンプレートの説明（テンプレート先頭のコメント）
+	Source string        `json:"-"`                // テンプレ本文
+}
+
+// FieldSchema はフィールド1つ分のスキャン結果と型解決結果
+type FieldSchema struct {
+	Name     string        `json:"name"`               // テンプレートでの名前（例: "user"）
+	GoName   string        `json:"goName"`             // 生成する構造体のフィールド名（例: "User"）
+	Kind     string        `json:"kind,omitempty"`     // スキャンで推論した種別（テンプレートで参照していないフィールドは空）
+	Type     string        `json:"type"`               // 生成する Go の型
+	Tag      string        `json:"tag,omitempty"`      // 生成する構造体タグ（例: `json:"user"`、バッククォートなし）
+	Source   string        `json:"source"`             // 型の由来（SourceInferred または SourceParam）
+	Required bool          `json:"required,omitempty"` // 必須（@required または Options.RequireAll）
+	Enum     []string      `json:"enum,omitempty"`     // @enum の値
+	Default  any           `json:"default,omitempty"`  // @default の値（string、bool、int64、uint64、float64）
+	Doc      string        `json:"doc,omitempty"`      // @doc の説明
+	Template string        `json:"template,omitempty"` // 型を参照するテンプレート名（{{ template "name" .Foo }}）
+	Embeds   []string      `json:"embeds,omitempty"`   // 埋め込むテンプレート名
+	Refs     []string      `json:"refs,omitempty"`     // テンプレート内で参照している位置（"file:line:col"、出現順）
+	Fields   []FieldSchema `json:"fields,omitempty"`   // 構造体（スライス・マップでは要素）のフィールド
+}
+
+// Describe はテンプレートをスキャン・型解決し、テンプレートごとの型情報を返す
+// 引数とエラーは EmitWithOptions と同じ。順序は生成コードと同じ（フラットなテンプレート、グループの順）
+func Describe(units []Unit, basedir string, opts Options) ([]TemplateSchema, error) {
+	p, err := prepare(units, basedir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []TemplateSchema
+	for _, t := range p.allTemplates() {
+		result = append(result, TemplateSchema{
+			Name:   t.name,
+			File:   t.file,
+			Type:   p.typeNames[t.name],
+			Bound:  t.bound != nil,
+			Embeds: t.typed.Embeds,
+			Fields: p.describeFields(t, nil, t.typed.Fields),
+			Doc:    t.typed.Doc,
+			Source: t.source,
+		})
+	}
+	return result, nil
+}
+
+// describeFields は path 以下のフィールドを名前順に変換する
+func (p *emitPrepared) describeFields(t tmpl, path []string, fields map[string]*typing.TypedField) []FieldSchema {
+	result := []FieldSchema{}
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		f := fields[key]
+		fieldPath := append(slices.Clone(path), key)
+
+		fs := FieldSchema{
+			Name:     key,
+			GoName:   f.Name,
+			Type:     p.fieldType(f, t),
+			Source:   SourceInferred,
+			Template: f.Template,
+			Embeds:   f.Embeds,
+			Tag:      magic.FormatStructTag(p.tags.fieldTags(f)),
+			Required: p.validation.required(t, f),
+			Doc:      f.Doc,
+		}
+		if e := enumOf(t, fs.Type); e != nil {
+			fs.Enum = e.Values
+		}
+		if f.Default != nil {
+			fs.Default = defaultJSON(f.Default)
+		}
+		if f.Param {
+			fs.Source = SourceParam
+		}
+		if sf := schemaField(p.schemas[t.name].Fields, fieldPath); sf != nil {
+			fs.Kind = sf.Kind.String()
+			for _, pos := range sf.Refs {
+				fs.Refs = append(fs.Refs, pos.String())
+			}
+		}
+
+		// @param の []struct{...} は子フィールドを名前付き型（例: ItemsItem）に持つ
+		children := f.Children
+		if children == nil {
+			if nt := namedType(t.typed, f.GoType); nt != nil {
+				children = nt.Fields
+			}
+		}
+		if len(children) > 0 {
+			fs.Fields = p.describeFields(t, fieldPath, children)
+		}
+		result = append(result, fs)
+	}
+	return result
+}
+
+// schemaField はスキャン結果からパスのフィールドを探す（スライス・マップは要素の子をたどる）
+func schemaField(fields map[string]*scan.Field, path []string) *scan.Field {
+	var f *scan.Field
+	for _, key := range path {
+		f = fields[key]
+		if f == nil {
+			return nil
+		}
+		fields = f.Children
+		if f.Elem != nil {
+			fields = f.Elem.Children
+		}
+	}
+	return f
+}
+
+// namedType は "[]X" や "map[string]X" の要素型 X の名前付き型を返す
+func namedType(typed *typing.TypedSchema, goType string) *typing.NamedType {
+	name := strings.TrimPrefix(strings.TrimPrefix(goType, "[]"), "map[string]")
+	for _, nt := range typed.NamedTypes {
+		if nt.Name == name {
+			return nt
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package gen
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bellwood4486/tmpltype/internal/typing"
+	"github.com/bellwood4486/tmpltype/internal/typing/magic"
+	"github.com/bellwood4486/tmpltype/internal/util"
+)
+
+// TagCase は構造体タグの値にするフィールド名の形式を表す
+type TagCase int
+
+const (
+	TagCaseSnake TagCase = iota // user_name（既定）
+	TagCaseCamel                // userName
+)
+
+// TagOptions は生成する構造体のフィールドに付ける構造体タグの方針
+// @tag ディレクティブで指定したキーはこの方針より優先される
+type TagOptions struct {
+	Keys []string // フィールド名から値を作るタグのキー（例: json, yaml）。空なら @tag のタグだけ付ける
+	Case TagCase  // 値にするフィールド名の形式
+}
+
+// fieldTags はフィールドに付ける構造体タグを返す
+// 方針のキーを先に並べ、@tag のタグで同じキーを上書きし、残りを記述順に続ける
+func (o TagOptions) fieldTags(f *typing.TypedField) []magic.Tag {
+	tags := make([]magic.Tag, 0, len(o.Keys)+len(f.Tags))
+	for _, key := range o.Keys {
+		tags = append(tags, magic.Tag{Key: key, Value: o.name(f.Name)})
+	}
+	for _, t := range f.Tags {
+		i := slices.IndexFunc(tags, func(x magic.Tag) bool { return x.Key == t.Key })
+		if i >= 0 {
+			tags[i] = t
+		} else {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// name はフィールド名 goName をタグの値の形式にする
+func (o TagOptions) name(goName string) string {
+	if o.Case == TagCaseCamel {
+		return util.CamelCase(goName)
+	}
+	return util.SnakeCase(goName)
+}
+
+// structTag はフィールドの構造体タグを生成コードのリテラル（バッククォート付き）で返す
+// タグがなければ空文字
+func (p *emitPrepared) structTag(f *typing.TypedField) string {
+	tags := p.tags.fieldTags(f)
+	if len(tags) == 0 {
+		return ""
+	}
+	s := magic.FormatStructTag(tags)
+	if strings.Contains(s, "`") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+// jsonKey は encoding/json がフィールドに使うキーを返す
+// json タグで除外される（json:"-"）なら ok が false、omitempty なら omitEmpty が true
+func (p *emitPrepared) jsonKey(f *typing.TypedField) (key string, omitEmpty, ok bool) {
+	key = f.Name
+	for _, t := range p.tags.fieldTags(f) {
+		if t.Key != "json" {
+			continue
+		}
+		name, opts, _ := strings.Cut(t.Value, ",")
+		if name == "-" && opts == "" {
+			return "", false, false
+		}
+		if name != "" {
+			key = name
+		}
+		omitEmpty = slices.Contains(strings.Split(opts, ","), "omitempty")
+	}
+	return key, omitEmpty, true
+}
@@ -0,0 +1,279 @@
+package gen
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/bellwood4486/tmpltype/internal/typing"
+)
+
+// EmitTypeScript はテンプレートのパラメータ型の TypeScript 型定義（.d.ts）を生成する
+// 型名は生成する Go コードと同じで、encoding/json でエンコードしたデータの形を表す（json タグがあればその名前）
+// @type で既存の型に結び付けたテンプレートの型は unknown になる
+func EmitTypeScript(units []Unit, basedir string, opts Options) (string, error) {
+	p, err := prepare(units, basedir, opts)
+	if err != nil {
+		return "", err
+	}
+
+	ts := &tsWriter{p: p, templates: make(map[string]tmpl)}
+	for _, t := range p.allTemplates() {
+		ts.templates[t.name] = t
+	}
+
+	var b strings.Builder
+	write(&b, "// Code generated by tmpltype; DO NOT EDIT.\n\n")
+	generateTSTemplateName(&b, p)
+	generateTSTemplateBlocks(&b, ts)
+	generateTSTemplateParams(&b, p)
+	return b.String(), nil
+}
+
+// tsWriter は Go の型を TypeScript の型に変換する
+type tsWriter struct {
+	p         *emitPrepared
+	templates map[string]tmpl
+}
+
+// generateTSTemplateName は Go の Template 名前空間に対応するテンプレート名の union 型を生成する
+func generateTSTemplateName(b *strings.Builder, p *emitPrepared) {
+	write(b, "/** TemplateName is a template name (mirrors the Template namespace in Go) */\n")
+	write(b, "export type TemplateName =\n")
+	all := p.allTemplates()
+	for i, t := range all {
+		sep := ""
+		if i == len(all)-1 {
+			sep = ";"
+		}
+		write(b, "  | %q%s\n", t.name, sep)
+	}
+	write(b, "\n")
+}
+
+// generateTSTemplateBlocks は各テンプレートのパラメータ型、名前付き型、@enum の型を生成する（Go コードと同じ順序）
+func generateTSTemplateBlocks(b *strings.Builder, ts *tsWriter) {
+	generatedTypes := make(map[string]bool)
+
+	for _, t := range ts.p.allTemplates() {
+		if t.bound != nil {
+			write(b, "/** %s is bound to the Go type %s (@type) */\n", t.typeName, ts.p.typeNames[t.name])
+			write(b, "export type %s = unknown;\n\n", t.typeName)
+			continue
+		}
+
+		for _, e := range t.typed.Enums {
+			values := make([]string, 0, len(e.Values))
+			for _, v := range e.Values {
+				values = append(values, strconv.Quote(v))
+			}
+			write(b, "/** %s is the set of values of %s in the %s template */\n", t.typeName+e.Name, e.Path, t.name)
+			write(b, "export type %s = %s;\n\n", t.typeName+e.Name, strings.Join(values, " | "))
+		}
+
+		for _, namedType := range t.typed.NamedTypes {
+			typeName := t.typeName + namedType.Name
+			if generatedTypes[typeName] {
+				continue // すでに生成済み
+			}
+			generatedTypes[typeName] = true
+			if namedType.Doc != "" {
+				writeJSDoc(b, "", namedType.Doc)
+			}
+			ts.writeInterface(b, t, typeName, namedType.Embeds, namedType.Fields)
+		}
+
+		doc := []string{fmt.Sprintf("%s represents parameters for %s template", t.typeName, t.name)}
+		if t.typed.Doc != "" {
+			doc = append(doc, "", t.typed.Doc)
+		}
+		writeJSDoc(b, "", doc...)
+		ts.writeInterface(b, t, t.typeName, t.typed.Embeds, t.typed.Fields)
+	}
+}
+
+// generateTSTemplateParams はテンプレート名からパラメータ型を引く型を生成する
+func generateTSTemplateParams(b *strings.Builder, p *emitPrepared) {
+	write(b, "/** TemplateParams maps each template name to its parameter type */\n")
+	write(b, "export interface TemplateParams {\n")
+	for _, t := range p.allTemplates() {
+		write(b, "  %q: %s;\n", t.name, t.typeName)
+	}
+	write(b, "}\n")
+}
+
+// writeInterface は構造体を interface として出力する
+// 埋め込んだテンプレートの型は extends で継承する（encoding/json ではフィールドが同じ階層になる）
+func (ts *tsWriter) writeInterface(b *strings.Builder, t tmpl, name string, embeds []string, fields map[string]*typing.TypedField) {
+	var bases []string
+	for _, e := range embeds {
+		if ts.templates[e].bound == nil {
+			bases = append(bases, ts.p.typeNames[e])
+		}
+	}
+	write(b, "export interface %s ", name)
+	if len(bases) > 0 {
+		write(b, "extends %s ", strings.Join(bases, ", "))
+	}
+	write(b, "{\n")
+	for _, fieldName := range slices.Sorted(maps.Keys(fields)) {
+		field := fields[fieldName]
+		key, omitEmpty, ok := ts.p.jsonKey(field)
+		if !ok {
+			continue // json:"-"
+		}
+		typ, optional := ts.fieldType(t, field)
+		var doc []string
+		if field.Doc != "" {
+			doc = append(doc, field.Doc)
+		}
+		if field.Default != nil {
+			if value, err := json.Marshal(defaultJSON(field.Default)); err == nil {
+				doc = append(doc, "@default "+string(value))
+			}
+		}
+		writeJSDoc(b, "  ", doc...)
+		if optional || omitEmpty {
+			write(b, "  %s?: %s;\n", tsPropertyName(key), typ)
+		} else {
+			write(b, "  %s: %s;\n", tsPropertyName(key), typ)
+		}
+	}
+	write(b, "}\n\n")
+}
+
+// writeJSDoc は JSDoc コメントを書く（1行なら /** ... */、複数行ならブロック。lines が空なら何もしない）
+// 各要素に改行が含まれていれば行に分ける
+func writeJSDoc(b *strings.Builder, indent string, lines ...string) {
+	if len(lines) == 0 {
+		return
+	}
+	// 説明に含まれる */ でコメントが終わらないようにする
+	lines = strings.Split(strings.ReplaceAll(strings.Join(lines, "\n"), "*/", "*\\/"), "\n")
+	if len(lines) == 1 {
+		write(b, "%s/** %s */\n", indent, lines[0])
+		return
+	}
+	write(b, "%s/**\n", indent)
+	for _, line := range lines {
+		if line == "" {
+			write(b, "%s *\n", indent)
+		} else {
+			write(b, "%s * %s\n", indent, line)
+		}
+	}
+	write(b, "%s */\n", indent)
+}
+
+// tsPropertyName はプロパティ名を返す（識別子として使えない名前は引用符で囲む）
+func tsPropertyName(name string) string {
+	for i, r := range name {
+		if !(r == '_' || r == '$' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r))) {
+			return strconv.Quote(name)
+		}
+	}
+	if name == "" {
+		return `""`
+	}
+	return name
+}
+
+// fieldType はフィールドの TypeScript の型を返す
+// ポインタのフィールドは省略可能（optional）になる
+func (ts *tsWriter) fieldType(t tmpl, field *typing.TypedField) (string, bool) {
+	if field.Template != "" {
+		if ts.templates[field.Template].bound != nil {
+			return fmt.Sprintf("unknown /* %s */", ts.p.typeNames[field.Template]), false
+		}
+		return ts.p.typeNames[field.Template], false
+	}
+	return ts.exprType(t, field.GoType)
+}
+
+// exprType は Go の型 goType を TypeScript の型に変換する（ポインタなら optional）
+func (ts *tsWriter) exprType(t tmpl, goType string) (string, bool) {
+	expr, err := parser.ParseExpr(goType)
+	if err != nil {
+		return fmt.Sprintf("unknown /* %s */", goType), false
+	}
+	if star, ok := expr.(*ast.StarExpr); ok {
+		return ts.typ(t, star.X) + " | null", true
+	}
+	return ts.typ(t, expr), false
+}
+
+// typ は Go の型の式を TypeScript の型に変換する
+func (ts *tsWriter) typ(t tmpl, expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		switch e.Name {
+		case "string":
+			return "string"
+		case "bool":
+			return "boolean"
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64",
+			"uintptr", "byte", "rune", "float32", "float64":
+			return "number"
+		case "any":
+			return "unknown"
+		}
+		// テンプレートの名前付き型（生成コードではテンプレートの型名が前に付く）
+		for _, nt := range t.typed.NamedTypes {
+			if nt.Name == e.Name {
+				return t.typeName + nt.Name
+			}
+		}
+		if en := enumOf(t, t.typeName+e.Name); en != nil {
+			return t.typeName + en.Name
+		}
+	case *ast.SelectorExpr:
+		switch types.ExprString(e) {
+		case "time.Time", "template.HTML", "template.HTMLAttr", "template.CSS", "template.JS", "template.JSStr", "template.URL", "template.Srcset":
+			return "string"
+		case "time.Duration", "json.Number":
+			return "number" // Duration はナノ秒
+		}
+	case *ast.StarExpr:
+		return ts.typ(t, e.X) + " | null"
+	case *ast.ArrayType:
+		// nil のスライスとマップは encoding/json で null になる（JSON Schema と同じ）
+		if id, ok := e.Elt.(*ast.Ident); ok && e.Len == nil && (id.Name == "byte" || id.Name == "uint8") {
+			return "string | null" // encoding/json は base64 の文字列にする
+		}
+		elem := ts.typ(t, e.Elt)
+		if strings.Contains(elem, "|") {
+			elem = "(" + elem + ")"
+		}
+		if e.Len != nil {
+			return elem + "[]" // 配列は nil にならない
+		}
+		return elem + "[] | null"
+	case *ast.MapType:
+		return "Record<string, " + ts.typ(t, e.Value) + "> | null"
+	case *ast.InterfaceType:
+		return "unknown"
+	case *ast.StructType:
+		// @param の struct{...}
+		var fields []string
+		for _, f := range e.Fields.List {
+			for _, n := range f.Names {
+				typ, optional := ts.exprType(t, types.ExprString(f.Type))
+				if optional {
+					fields = append(fields, fmt.Sprintf("%s?: %s", n.Name, typ))
+				} else {
+					fields = append(fields, fmt.Sprintf("%s: %s", n.Name, typ))
+				}
+			}
+		}
+		return "{ " + strings.Join(fields, "; ") + " }"
+	}
+	// TypeScript での表現が分からない型
+	return fmt.Sprintf("unknown /* %s */", types.ExprString(expr))
+}
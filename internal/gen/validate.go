@@ -0,0 +1,356 @@
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+	"github.com/bellwood4486/tmpltype/internal/typing"
+)
+
+// missingFieldsError は必須のフィールドが未設定のときに Validate が返すエラー型の名前
+const missingFieldsError = "MissingFieldsError"
+
+// structDef は生成する構造体型（テンプレートのパラメータ型または名前付き型）
+type structDef struct {
+	t      tmpl
+	fields map[string]*typing.TypedField
+	embeds []string
+	param  bool // テンプレートのパラメータ型
+}
+
+// validation は必須のフィールドを検証するメソッドの生成内容
+// 必須のフィールドを（入れ子の型や埋め込みも含めて）持つ型だけに生成する
+type validation struct {
+	methods map[string]string // 型名 -> 生成する validate（パラメータ型なら Validate も）メソッド
+	gen     *validationGen
+}
+
+// needed は型 typeName に検証のメソッドを生成するか返す
+func (v *validation) needed(typeName string) bool {
+	_, ok := v.methods[typeName]
+	return ok
+}
+
+// planValidation は必須のフィールドを持つ型を求め、検証のメソッドを生成して必要な import を追加する
+// @type で既存の型に結び付けたテンプレートは対象外
+func planValidation(p *emitPrepared) error {
+	vg := &validationGen{structGraph: newStructGraph(p)}
+	vg.mark(func(t tmpl, f *typing.TypedField) bool {
+		return vg.required(f, vg.fieldExpr(t, f))
+	})
+
+	p.validation = &validation{methods: make(map[string]string), gen: vg}
+	if len(vg.needs) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(vg.needs)) {
+		def := vg.structs[name]
+		if def.param {
+			if f := fieldByName(def.fields, "Validate"); f != nil {
+				errs = append(errs, scan.Errorf(f.Pos, "field Validate conflicts with the generated method %s.Validate", name))
+				continue
+			}
+		}
+		p.validation.methods[name] = vg.methods(name, def)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	vg.imports["strings"] = true // MissingFieldsError.Error
+	return vg.addImports()
+}
+
+// fieldByName は生成コード上の名前が name のフィールドを返す（なければ nil）
+func fieldByName(fields map[string]*typing.TypedField, name string) *typing.TypedField {
+	for _, f := range fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// structGraph は生成する構造体型と、そのうちメソッドを生成する型を表す
+// メソッドは条件を満たすフィールドを直接持つ型と、その型を（入れ子の型や埋め込みで）含む型に生成する
+type structGraph struct {
+	p       *emitPrepared
+	structs map[string]structDef // 型名 -> 定義
+	needs   map[string]bool      // 型名 -> メソッドを生成する
+	imports map[string]bool      // 生成したコードが使うパッケージ
+}
+
+// newStructGraph は生成する構造体型（@type で結び付けたテンプレートを除く）を集める
+func newStructGraph(p *emitPrepared) *structGraph {
+	structs := make(map[string]structDef)
+	for _, t := range p.allTemplates() {
+		if t.bound != nil {
+			continue
+		}
+		structs[t.typeName] = structDef{t: t, fields: t.typed.Fields, embeds: t.typed.Embeds, param: true}
+		for _, nt := range t.typed.NamedTypes {
+			name := t.typeName + nt.Name
+			if _, ok := structs[name]; !ok {
+				structs[name] = structDef{t: t, fields: nt.Fields, embeds: nt.Embeds}
+			}
+		}
+	}
+	return &structGraph{p: p, structs: structs, needs: make(map[string]bool), imports: make(map[string]bool)}
+}
+
+// mark は direct を満たすフィールドを持つ型から、それを含む型へメソッドを生成する型を広げる
+// 再帰的な型に備えて変化がなくなるまで繰り返す
+func (g *structGraph) mark(direct func(t tmpl, f *typing.TypedField) bool) {
+	for name, def := range g.structs {
+		for _, f := range def.fields {
+			if direct(def.t, f) {
+				g.needs[name] = true
+				break
+			}
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for name, def := range g.structs {
+			if g.needs[name] || !g.containsNeeded(def) {
+				continue
+			}
+			g.needs[name] = true
+			changed = true
+		}
+	}
+}
+
+// addImports は生成したコードが使うパッケージを import に追加する
+func (g *structGraph) addImports() error {
+	for _, importPath := range slices.Sorted(maps.Keys(g.imports)) {
+		if err := addImport(g.p.imports, importPath, importPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldExpr はフィールドの生成コード上の型を式として返す（解析できなければ nil）
+func (g *structGraph) fieldExpr(t tmpl, f *typing.TypedField) ast.Expr {
+	expr, err := parser.ParseExpr(g.p.fieldType(f, t))
+	if err != nil {
+		return nil
+	}
+	return expr
+}
+
+// containsNeeded は構造体がメソッドを生成する型をフィールドや埋め込みに持つか返す
+func (g *structGraph) containsNeeded(def structDef) bool {
+	for _, e := range def.embeds {
+		if g.needs[g.p.typeNames[e]] {
+			return true
+		}
+	}
+	for _, f := range def.fields {
+		if expr := g.fieldExpr(def.t, f); expr != nil && g.nests(expr) {
+			return true
+		}
+	}
+	return false
+}
+
+// nests は型 expr の値（ポインタの先、スライス・マップの要素を含む）にメソッドを生成する型があるか返す
+func (g *structGraph) nests(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return g.needs[e.Name]
+	case *ast.StarExpr:
+		return g.nests(e.X)
+	case *ast.ArrayType:
+		return g.nests(e.Elt)
+	case *ast.MapType:
+		return g.nests(e.Value)
+	}
+	return false
+}
+
+// validationGen は検証のメソッドを生成する
+type validationGen struct {
+	*structGraph
+}
+
+// required はフィールドが必須か返す
+// 既定値（@default）があれば未設定にならないので必須ではない
+// 指定がなければ生成オプションに従い、ポインタ、bool、入れ子の構造体やテンプレートの型（中身を検証する）以外を必須にする
+func (vg *validationGen) required(f *typing.TypedField, expr ast.Expr) bool {
+	if f.Default != nil {
+		return false
+	}
+	switch f.Required {
+	case typing.RequirementRequired:
+		return true
+	case typing.RequirementOptional:
+		return false
+	}
+	if !vg.p.requireAll || expr == nil || f.Template != "" {
+		return false
+	}
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return false
+	case *ast.Ident:
+		if _, ok := vg.structs[e.Name]; ok || e.Name == "bool" {
+			return false
+		}
+	}
+	return true
+}
+
+// validationPath は検証で報告するフィールドのパス（fmt の書式と引数）
+// 例: 書式 "%sItems[%d]"、引数 path, i
+type validationPath struct {
+	format string
+	args   []string
+}
+
+// expr はパスに suffix を付けた文字列を作る式を返す
+func (vp validationPath) expr(suffix string) string {
+	if len(vp.args) == 1 {
+		return "path+" + strconv.Quote(strings.TrimPrefix(vp.format, "%s")+suffix)
+	}
+	return fmt.Sprintf("fmt.Sprintf(%q, %s)", vp.format+suffix, strings.Join(vp.args, ", "))
+}
+
+// with は書式と引数を追加したパスを返す
+func (vp validationPath) with(format, arg string) validationPath {
+	return validationPath{format: vp.format + format, args: append(slices.Clone(vp.args), arg)}
+}
+
+// methods は型 name の検証のメソッドを生成する
+// パスはテンプレートでの名前で報告する（例: "User.Email", "Items[0].ID"）
+func (vg *validationGen) methods(name string, def structDef) string {
+	var b strings.Builder
+	if def.param {
+		write(&b, "// Validate reports the required fields of %s that are not set\n", name)
+		write(&b, "func (p %s) Validate() error {\n", name)
+		write(&b, "\tvar missing []string\n")
+		write(&b, "\tp.validate(\"\", &missing)\n")
+		write(&b, "\tif len(missing) > 0 {\n")
+		write(&b, "\t\treturn &%s{Template: %s, Fields: missing}\n", missingFieldsError, templateFieldRef(def.t))
+		write(&b, "\t}\n")
+		write(&b, "\treturn nil\n")
+		write(&b, "}\n\n")
+	}
+
+	write(&b, "func (p %s) validate(path string, missing *[]string) {\n", name)
+	for _, e := range def.embeds {
+		if typeName := vg.p.typeNames[e]; vg.needs[typeName] {
+			write(&b, "\tp.%s.validate(path, missing)\n", typeName)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(def.fields)) {
+		f := def.fields[key]
+		expr := vg.fieldExpr(def.t, f)
+		value := "p." + f.Name
+		path := validationPath{format: "%s" + key, args: []string{"path"}}
+		if vg.required(f, expr) {
+			write(&b, "\tif %s {\n", vg.zero(value, expr))
+			write(&b, "\t\t*missing = append(*missing, %s)\n", path.expr(""))
+			write(&b, "\t}\n")
+		}
+		if expr != nil {
+			vg.nested(&b, value, expr, path, 1)
+		}
+	}
+	write(&b, "}\n\n")
+	return b.String()
+}
+
+// nested は値 value（型 expr）の中にある検証の必要な型を検証するコードを生成する
+// depth は入れ子のループの深さ（変数名と字下げに使う）
+func (vg *validationGen) nested(b *strings.Builder, value string, expr ast.Expr, path validationPath, depth int) {
+	if !vg.nests(expr) {
+		return
+	}
+	indent := strings.Repeat("\t", depth)
+	suffix := ""
+	if depth > 1 {
+		suffix = strconv.Itoa(depth - 1)
+	}
+
+	switch e := expr.(type) {
+	case *ast.Ident:
+		write(b, "%s%s.validate(%s, missing)\n", indent, value, path.expr("."))
+	case *ast.StarExpr:
+		write(b, "%sif %s != nil {\n", indent, value)
+		vg.nested(b, value, e.X, path, depth+1)
+		write(b, "%s}\n", indent)
+	case *ast.ArrayType:
+		i, v := "i"+suffix, "v"+suffix
+		write(b, "%sfor %s, %s := range %s {\n", indent, i, v, value)
+		vg.nested(b, v, e.Elt, path.with("[%d]", i), depth+1)
+		write(b, "%s}\n", indent)
+	case *ast.MapType:
+		k := "k" + suffix
+		vg.imports["maps"] = true
+		vg.imports["slices"] = true
+		write(b, "%sfor _, %s := range slices.Sorted(maps.Keys(%s)) {\n", indent, k, value)
+		vg.nested(b, value+"["+k+"]", e.Value, path.with("[%q]", k), depth+1)
+		write(b, "%s}\n", indent)
+	}
+}
+
+// zero は値 value（型 expr）が未設定（ゼロ値）かを判定する式を返す
+func (g *structGraph) zero(value string, expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		switch e.Name {
+		case "string":
+			return value + ` == ""`
+		case "bool":
+			return "!" + value
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64",
+			"uintptr", "byte", "rune", "float32", "float64":
+			return value + " == 0"
+		case "any", "error":
+			return value + " == nil"
+		}
+		if g.p.isEnumType(e.Name) {
+			return value + ` == ""`
+		}
+	case *ast.SelectorExpr:
+		switch types.ExprString(e) {
+		case "time.Time":
+			return value + ".IsZero()"
+		case "time.Duration":
+			return value + " == 0"
+		case "template.HTML", "template.HTMLAttr", "template.CSS", "template.JS", "template.JSStr", "template.URL", "template.Srcset", "json.Number":
+			return value + ` == ""`
+		}
+	case *ast.StarExpr, *ast.InterfaceType, *ast.FuncType, *ast.ChanType:
+		return value + " == nil"
+	case *ast.ArrayType:
+		if e.Len == nil {
+			return "len(" + value + ") == 0"
+		}
+	case *ast.MapType:
+		return "len(" + value + ") == 0"
+	}
+	// 構造体や外部パッケージの型など、ゼロ値の書き方が決まらない型
+	g.imports["reflect"] = true
+	return "reflect.ValueOf(" + value + ").IsZero()"
+}
+
+// required はフィールドが必須か返す（JSON Schema と schema サブコマンドで使う）
+func (v *validation) required(t tmpl, f *typing.TypedField) bool {
+	if t.bound != nil {
+		return false
+	}
+	return v.gen.required(f, v.gen.fieldExpr(t, f))
+}
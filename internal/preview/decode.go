@@ -0,0 +1,337 @@
+package preview
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+)
+
+// YAML のスカラーのタグ
+const (
+	tagNull      = "!!null"
+	tagBool      = "!!bool"
+	tagInt       = "!!int"
+	tagFloat     = "!!float"
+	tagStr       = "!!str"
+	tagTimestamp = "!!timestamp"
+)
+
+// decode はデータを v に変換する
+// JSON は YAML のサブセットなので、どちらも yaml.Node として読んで位置付きのエラーを報告する
+func decode(file string, data []byte, v reflect.Value, types *typeBuilder) error {
+	if strings.EqualFold(filepath.Ext(file), ".json") && !json.Valid(data) {
+		var x any
+		err := json.Unmarshal(data, &x)
+		if se, ok := err.(*json.SyntaxError); ok {
+			return scan.Errorf(offsetPos(file, data, se.Offset), "%v", err)
+		}
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil // 空のデータはゼロ値
+	}
+
+	d := &decoder{file: file, types: types}
+	d.decode("", doc.Content[0], v)
+	return errors.Join(d.errs...)
+}
+
+// decoder は yaml.Node を動的に構築した型の値に変換し、不一致をすべて記録する
+type decoder struct {
+	file  string
+	types *typeBuilder
+	errs  []error
+}
+
+// errorf は node の位置に path（例: "User.Items[0].ID"）のエラーを記録する
+func (d *decoder) errorf(n *yaml.Node, path, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if path != "" {
+		msg = path + ": " + msg
+	}
+	d.errs = append(d.errs, scan.Errorf(scan.Pos{File: d.file, Line: n.Line, Col: n.Column}, "%s", msg))
+}
+
+// mismatch は node を v の型に変換できないエラーを記録する
+func (d *decoder) mismatch(n *yaml.Node, path string, t reflect.Type) {
+	d.errorf(n, path, "cannot use %s as %s", describe(n), typeName(t))
+}
+
+func (d *decoder) decode(path string, n *yaml.Node, v reflect.Value) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind == yaml.ScalarNode && n.Tag == tagNull {
+		return // null はゼロ値（ポインタなら nil）
+	}
+
+	switch v.Type() {
+	case reflect.TypeFor[time.Time]():
+		d.decodeTime(path, n, v)
+		return
+	case reflect.TypeFor[time.Duration]():
+		d.decodeDuration(path, n, v)
+		return
+	case reflect.TypeFor[url.URL]():
+		u, err := url.Parse(n.Value)
+		if n.Kind != yaml.ScalarNode || n.Tag != tagStr || err != nil {
+			d.mismatch(n, path, v.Type())
+			return
+		}
+		v.Set(reflect.ValueOf(*u))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		p := reflect.New(v.Type().Elem())
+		d.decode(path, n, p.Elem())
+		v.Set(p)
+
+	case reflect.Interface:
+		var x any
+		if err := n.Decode(&x); err != nil {
+			d.errorf(n, path, "%v", err)
+			return
+		}
+		if x != nil {
+			v.Set(reflect.ValueOf(x))
+		}
+
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			d.mismatch(n, path, v.Type())
+			return
+		}
+		info := d.types.structs[v.Type()]
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			idx, ok := info.lookup(key.Value)
+			if !ok {
+				d.errorf(key, path, "unknown field %q (want one of %s)", key.Value, strings.Join(info.keys, ", "))
+				continue
+			}
+			d.decode(join(path, info.keys[idx]), value, v.Field(idx))
+		}
+
+	case reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			d.mismatch(n, path, v.Type())
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), len(n.Content), len(n.Content))
+		for i, elem := range n.Content {
+			d.decode(fmt.Sprintf("%s[%d]", path, i), elem, s.Index(i))
+		}
+		v.Set(s)
+
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			d.mismatch(n, path, v.Type())
+			return
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			elem := reflect.New(v.Type().Elem()).Elem()
+			d.decode(fmt.Sprintf("%s[%q]", path, key.Value), value, elem)
+			m.SetMapIndex(reflect.ValueOf(key.Value), elem)
+		}
+		v.Set(m)
+
+	case reflect.String:
+		if !isScalar(n, tagStr, tagTimestamp) {
+			d.mismatch(n, path, v.Type())
+			return
+		}
+		v.SetString(n.Value)
+
+	case reflect.Bool:
+		var b bool
+		if !isScalar(n, tagBool) || n.Decode(&b) != nil {
+			d.mismatch(n, path, v.Type())
+			return
+		}
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if !isScalar(n, tagInt) || n.Decode(&i) != nil || v.OverflowInt(i) {
+			d.mismatch(n, path, v.Type())
+			return
+		}
+		v.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		if !isScalar(n, tagInt) || n.Decode(&u) != nil || v.OverflowUint(u) {
+			d.mismatch(n, path, v.Type())
+			return
+		}
+		v.SetUint(u)
+
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if !isScalar(n, tagInt, tagFloat) || n.Decode(&f) != nil || v.OverflowFloat(f) {
+			d.mismatch(n, path, v.Type())
+			return
+		}
+		v.SetFloat(f)
+
+	default:
+		d.errorf(n, path, "type %s cannot be used in preview", typeName(v.Type()))
+	}
+}
+
+// decodeTime は RFC 3339 の日時または "2006-01-02" 形式の日付を time.Time に変換する
+func (d *decoder) decodeTime(path string, n *yaml.Node, v reflect.Value) {
+	if isScalar(n, tagStr, tagTimestamp) {
+		for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+			if t, err := time.Parse(layout, n.Value); err == nil {
+				v.Set(reflect.ValueOf(t))
+				return
+			}
+		}
+	}
+	d.errorf(n, path, "cannot use %s as time.Time (want RFC 3339 like \"2006-01-02T15:04:05Z\" or \"2006-01-02\")", describe(n))
+}
+
+// decodeDuration は "1h30m" 形式の文字列またはナノ秒の整数を time.Duration に変換する
+func (d *decoder) decodeDuration(path string, n *yaml.Node, v reflect.Value) {
+	switch {
+	case isScalar(n, tagStr):
+		if dur, err := time.ParseDuration(n.Value); err == nil {
+			v.SetInt(int64(dur))
+			return
+		}
+	case isScalar(n, tagInt):
+		var i int64
+		if n.Decode(&i) == nil {
+			v.SetInt(i)
+			return
+		}
+	}
+	d.errorf(n, path, "cannot use %s as time.Duration (want a duration like \"1h30m\")", describe(n))
+}
+
+// setDefaults は v の中の未設定（ゼロ値）のフィールドに @default の値を設定する（生成コードの WithDefaults と同じ）
+// 入れ子の構造体、ポインタの先、スライス・マップの要素のフィールドも対象
+func (b *typeBuilder) setDefaults(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			b.setDefaults(v.Elem())
+		}
+	case reflect.Struct:
+		info := b.structs[v.Type()]
+		if info == nil {
+			return // time.Time など
+		}
+		for i := range v.NumField() {
+			f := v.Field(i)
+			if def, ok := info.defaults[i]; ok && f.IsZero() {
+				f.Set(reflect.ValueOf(def).Convert(f.Type()))
+			}
+			b.setDefaults(f)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			b.setDefaults(v.Index(i))
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			b.setDefaults(elem)
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	}
+}
+
+// lookup はデータのキーに対応するフィールドの添字を返す
+// テンプレートでの名前と完全に一致するキーを優先し、なければ大文字小文字を区別せずに探す（encoding/json と同様）
+func (info *structInfo) lookup(key string) (int, bool) {
+	if i, ok := info.index[key]; ok {
+		return i, true
+	}
+	i := slices.IndexFunc(info.keys, func(k string) bool { return strings.EqualFold(k, key) })
+	return i, i >= 0
+}
+
+// offsetPos は data のバイトオフセット offset の直前の位置を返す（json.SyntaxError 用）
+func offsetPos(file string, data []byte, offset int64) scan.Pos {
+	prefix := data[:min(max(offset-1, 0), int64(len(data)))]
+	line := 1 + bytes.Count(prefix, []byte("\n"))
+	col := len(prefix) - bytes.LastIndexByte(prefix, '\n')
+	return scan.Pos{File: file, Line: line, Col: col}
+}
+
+func isScalar(n *yaml.Node, tags ...string) bool {
+	return n.Kind == yaml.ScalarNode && slices.Contains(tags, n.Tag)
+}
+
+// describe はエラーメッセージ用にデータの値を表す（例: `string "x"`, "object"）
+func describe(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	case yaml.ScalarNode:
+		switch n.Tag {
+		case tagStr:
+			return "string " + strconv.Quote(n.Value)
+		case tagBool:
+			return "bool " + n.Value
+		case tagInt:
+			return "int " + n.Value
+		case tagFloat:
+			return "float " + n.Value
+		}
+		return strings.TrimPrefix(n.Tag, "!!") + " " + n.Value
+	}
+	return "value"
+}
+
+// typeName はエラーメッセージ用に型を表す（動的に構築した構造体は "object"）
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeName(t.Elem())
+	case reflect.Struct:
+		if t.Name() != "" {
+			return t.String()
+		}
+		return "object"
+	case reflect.Map:
+		return "object"
+	case reflect.Slice:
+		return "array of " + typeName(t.Elem())
+	}
+	return t.String()
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
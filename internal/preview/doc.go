@@ -0,0 +1,9 @@
+// Package preview は Go のコードを書かずにテンプレートを描画します（tmpltype render）。
+//
+// gen.Describe が解決したテンプレートごとの型情報から、生成コードのパラメータ型と同じ形の
+// 型を reflect で動的に構築し、JSON または YAML のデータをその型に変換して描画します。
+// int や *string、[]struct などの型はそのまま守られ、データが型と合わない場合は
+// "data.yaml:3:7: User.Age: cannot use string "x" as int" のようにフィールドごとのエラーになります。
+//
+// FuncMap や @func の関数は Go で実装されているため、呼び出すと描画がエラーになります。
+package preview
@@ -0,0 +1,112 @@
+package preview
+
+import (
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"reflect"
+	texttemplate "text/template"
+
+	"github.com/bellwood4486/tmpltype/internal/gen"
+	"github.com/bellwood4486/tmpltype/internal/scan"
+	"github.com/bellwood4486/tmpltype/internal/typing/magic"
+)
+
+// Options は描画の設定
+type Options struct {
+	HTML  bool     // html/template で描画する
+	Funcs []string // テンプレートから呼び出せる関数名（FuncMap の関数。@func の関数は自動で追加）
+}
+
+// executor は text/template と html/template の共通部分
+type executor interface {
+	ExecuteTemplate(w io.Writer, name string, data any) error
+}
+
+// Renderer はテンプレートセットと、テンプレートごとのパラメータ型を保持する
+type Renderer struct {
+	templates map[string]gen.TemplateSchema
+	set       executor
+	types     *typeBuilder
+}
+
+// New は gen.Describe の結果からテンプレートセットを構築する
+// テンプレートは生成コードと同じ順序・オプション（missingkey=error）でパースする
+func New(templates []gen.TemplateSchema, opts Options) (*Renderer, error) {
+	funcs := make(map[string]any)
+	for _, name := range opts.Funcs {
+		funcs[name] = unavailableFunc(name)
+	}
+	if _, ok := funcs[gen.ContextFunc]; ok {
+		funcs[gen.ContextFunc] = context.Background // 生成コードの RenderXxx と同じ
+	}
+	byName := make(map[string]gen.TemplateSchema, len(templates))
+	for _, t := range templates {
+		directives, err := magic.ParseFuncs(t.Source)
+		if err != nil {
+			return nil, scan.InFile(err, t.File)
+		}
+		for _, d := range directives {
+			funcs[d.Name] = unavailableFunc(d.Name)
+		}
+		byName[t.Name] = t
+	}
+
+	r := &Renderer{templates: byName, types: newTypeBuilder(byName)}
+	if opts.HTML {
+		set := htmltemplate.New("").Option("missingkey=error").Funcs(funcs)
+		for _, t := range templates {
+			if _, err := set.New(t.Name).Parse(t.Source); err != nil {
+				return nil, err
+			}
+		}
+		r.set = set
+	} else {
+		set := texttemplate.New("").Option("missingkey=error").Funcs(funcs)
+		for _, t := range templates {
+			if _, err := set.New(t.Name).Parse(t.Source); err != nil {
+				return nil, err
+			}
+		}
+		r.set = set
+	}
+	return r, nil
+}
+
+// unavailableFunc は Go で実装された関数の代わりに組み込む関数を返す
+// パースは通すが、描画中に呼び出されるとエラーにする
+func unavailableFunc(name string) func(...any) (any, error) {
+	return func(...any) (any, error) {
+		return nil, fmt.Errorf("function %s is implemented in Go and cannot be called in preview", name)
+	}
+}
+
+// Decode はデータ（JSON または YAML）をテンプレート name のパラメータ型に変換する
+// file はエラー報告とフォーマットの判定（拡張子 .json）に使う。data が空ならゼロ値を返す
+// データが型と合わない場合、すべての不一致を "file:line:col: path: msg" の形式でまとめて返す
+// 未設定のフィールドには生成コードの RenderXxx と同じく @default の値を設定する
+func (r *Renderer) Decode(name, file string, data []byte) (any, error) {
+	if _, ok := r.templates[name]; !ok {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	typ, err := r.types.templateType(name)
+	if err != nil {
+		return nil, err
+	}
+
+	v := reflect.New(typ).Elem()
+	if err := decode(file, data, v, r.types); err != nil {
+		return nil, err
+	}
+	r.types.setDefaults(v)
+	return v.Interface(), nil
+}
+
+// Render はテンプレート name を data で描画する
+func (r *Renderer) Render(w io.Writer, name string, data any) error {
+	if _, ok := r.templates[name]; !ok {
+		return fmt.Errorf("template %q not found", name)
+	}
+	return r.set.ExecuteTemplate(w, name, data)
+}
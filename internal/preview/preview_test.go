@@ -0,0 +1,260 @@
+package preview
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bellwood4486/tmpltype/internal/funcmap"
+	"github.com/bellwood4486/tmpltype/internal/gen"
+	"github.com/bellwood4486/tmpltype/internal/scan"
+)
+
+func newRenderer(t *testing.T, html bool, units ...gen.Unit) *Renderer {
+	t.Helper()
+	mode := gen.ModeText
+	if html {
+		mode = gen.ModeHTML
+	}
+	templates, err := gen.Describe(units, ".", gen.Options{Mode: mode})
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+	r, err := New(templates, Options{HTML: html})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return r
+}
+
+func render(t *testing.T, r *Renderer, name, file, data string) (string, error) {
+	t.Helper()
+	v, err := r.Decode(name, file, []byte(data))
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	err = r.Render(&b, name, v)
+	return b.String(), err
+}
+
+func TestRender(t *testing.T) {
+	r := newRenderer(t, false,
+		gen.Unit{SourcePath: "footer.tmpl", SourceLiteral: "-- {{ .Company }}"},
+		gen.Unit{SourcePath: "header.tmpl", SourceLiteral: "[{{ .Title }}]"},
+		gen.Unit{SourcePath: "mail.tmpl", SourceLiteral: `{{/* @param User.Age int */}}{{/* @param User.Email *string */}}{{/* @param Items []struct{ID int64; Price float64} */}}{{/* @param SentAt time.Time */}}
+{{- template "header" . }}
+{{ .User.Name }} ({{ .User.Age }}){{ if .User.Email }} <{{ .User.Email }}>{{ end }}
+{{ range .Items }}#{{ .ID }} {{ .Price }} {{ end }}
+{{ if gt .User.Age 20 }}adult{{ end }} {{ .SentAt.Year }} {{ index .Meta "k" }}
+{{ template "footer" .Footer }}`},
+	)
+
+	tests := []struct {
+		name string
+		file string
+		data string
+		want string
+	}{
+		{
+			name: "yaml",
+			file: "data.yaml",
+			data: `
+Title: Hello
+User: {Name: Alice, Age: 30, Email: a@example.com}
+Items:
+  - {ID: 1, Price: 9.5}
+  - {ID: 2, Price: 3}
+SentAt: 2024-05-01
+Meta: {k: v}
+Footer: {Company: ACME}
+`,
+			want: "[Hello]\nAlice (30) <a@example.com>\n#1 9.5 #2 3 \nadult 2024 v\n-- ACME",
+		},
+		{
+			name: "json with case-insensitive keys and null",
+			file: "data.json",
+			data: `{"title": "Hi", "user": {"name": "Bob", "age": 7, "email": null},
+			       "items": [], "sentAt": "2023-01-02T03:04:05Z", "meta": {"k": "x"}, "footer": {"company": "B"}}`,
+			want: "[Hi]\nBob (7)\n\n 2023 x\n-- B",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := render(t, r, "mail", tt.file, tt.data)
+			if err != nil {
+				t.Fatalf("render failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender_HTML(t *testing.T) {
+	r := newRenderer(t, true, gen.Unit{SourcePath: "page.html.tmpl", SourceLiteral: `{{/* @param Bio template.HTML */}}<p>{{ .Name }}</p>{{ .Bio }}`})
+
+	got, err := render(t, r, "page", "data.yaml", "Name: <b>x</b>\nBio: <i>ok</i>\n")
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if want := "<p>&lt;b&gt;x&lt;/b&gt;</p><i>ok</i>"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	r := newRenderer(t, false, gen.Unit{SourcePath: "mail.tmpl", SourceLiteral: `{{/* @param User.Age int8 */}}{{/* @param Items []struct{ID int64} */}}{{/* @param SentAt time.Time */}}
+{{ .User.Name }} {{ .User.Age }} {{ range .Items }}{{ .ID }}{{ end }} {{ .SentAt }}`})
+
+	tests := []struct {
+		name string
+		file string
+		data string
+		want []string
+	}{
+		{
+			name: "type mismatches are all reported",
+			file: "data.yaml",
+			data: `User:
+  Name: 42
+  Age: 300
+  Nick: x
+Items:
+  - ID: "1"
+  - 3
+SentAt: yesterday
+`,
+			want: []string{
+				`data.yaml:2:9: User.Name: cannot use int 42 as string`,
+				`data.yaml:3:8: User.Age: cannot use int 300 as int8`,
+				`data.yaml:4:3: User: unknown field "Nick" (want one of Age, Name)`,
+				`data.yaml:6:9: Items[0].ID: cannot use string "1" as int64`,
+				`data.yaml:7:5: Items[1]: cannot use int 3 as object`,
+				`data.yaml:8:9: SentAt: cannot use string "yesterday" as time.Time`,
+			},
+		},
+		{
+			name: "top level must be an object",
+			file: "data.yaml",
+			data: "- a\n",
+			want: []string{`data.yaml:1:1: cannot use array as object`},
+		},
+		{
+			name: "json syntax error",
+			file: "data.json",
+			data: "{\n  \"User\": {\"Age\": 1,}\n}",
+			want: []string{`data.json:2:21: invalid character '}'`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := r.Decode("mail", tt.file, []byte(tt.data))
+			if err == nil {
+				t.Fatal("Decode() error = nil, want error")
+			}
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(tt.want) {
+				t.Errorf("got %d errors, want %d:\n%v", len(lines), len(tt.want), err)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("error does not contain %q:\n%v", w, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRender_UnavailableFunc(t *testing.T) {
+	units := []gen.Unit{{SourcePath: "a.tmpl", SourceLiteral: `{{/* @func shout func(string) string */}}{{ upper .Name }}{{ shout .Name }}`}}
+	fm := &funcmap.FuncMap{Name: "Funcs", Funcs: map[string]scan.Func{"upper": {Params: []string{"string"}}}}
+	templates, err := gen.Describe(units, ".", gen.Options{FuncMap: fm})
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+
+	// FuncMap の関数と @func の関数はパースできるが、呼び出すとエラーになる
+	r, err := New(templates, Options{Funcs: []string{"upper"}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	_, err = render(t, r, "a", "data.yaml", "Name: x")
+	if err == nil || !strings.Contains(err.Error(), "function upper is implemented in Go and cannot be called in preview") {
+		t.Errorf("render error = %v, want unavailable function error", err)
+	}
+}
+
+func TestRender_ContextFunc(t *testing.T) {
+	units := []gen.Unit{{SourcePath: "a.tmpl", SourceLiteral: `{{ printf "%v" context }} {{ .Name }}`}}
+	fm := &funcmap.FuncMap{Name: "Funcs", Funcs: map[string]scan.Func{}}
+	templates, err := gen.Describe(units, ".", gen.Options{FuncMap: fm})
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+
+	// 生成コードが加える context 関数は context.Background() を返す
+	r, err := New(templates, Options{Funcs: []string{gen.ContextFunc}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	got, err := render(t, r, "a", "data.yaml", "Name: x")
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if got != "context.Background x" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestRender_JSONTags(t *testing.T) {
+	units := []gen.Unit{{SourcePath: "mail.tmpl", SourceLiteral: `{{/* @tag User.Email json:"mail" */}}{{ .User.FullName }} <{{ .User.Email }}>`}}
+	templates, err := gen.Describe(units, ".", gen.Options{Tags: gen.TagOptions{Keys: []string{"json"}}})
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+	r, err := New(templates, Options{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	// json タグの名前でも、テンプレートでの名前でも書ける
+	got, err := render(t, r, "mail", "data.json", `{"user": {"full_name": "Alice", "Email": "a@example.com"}}`)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if want := "Alice <a@example.com>"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	_, err = render(t, r, "mail", "data.yaml", "user: {name: x}")
+	if err == nil || !strings.Contains(err.Error(), `unknown field "name" (want one of mail, full_name)`) {
+		t.Errorf("error = %v, want unknown field error with json keys", err)
+	}
+}
+
+func TestRender_Defaults(t *testing.T) {
+	r := newRenderer(t, false, gen.Unit{SourcePath: "page.tmpl", SourceLiteral: `{{/* @param A.Size int */}}{{/* @param B.Size int */}}{{/* @param Items []struct{Name string; Qty uint8} */}}
+{{/* @enum Theme light|dark */}}{{/* @default Theme "dark" */}}{{/* @default SiteName "Acme" */}}
+{{/* @default A.Size 10 */}}{{/* @default B.Size 20 */}}{{/* @default Items.Qty 1 */}}
+{{- .SiteName }} {{ .Theme }} {{ .A.Size }} {{ .B.Size }}{{ range .Items }} {{ .Name }}x{{ .Qty }}{{ end }}`})
+
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"", "Acme dark 10 20"},
+		{"siteName: Example\ntheme: light\nb: {size: 5}\nitems: [{name: a}, {name: b, qty: 3}]", "Example light 10 5 ax1 bx3"},
+	}
+	for _, tt := range tests {
+		got, err := render(t, r, "page", "data.yaml", tt.data)
+		if err != nil {
+			t.Fatalf("render(%q) failed: %v", tt.data, err)
+		}
+		if got = strings.TrimSpace(got); got != tt.want {
+			t.Errorf("render(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
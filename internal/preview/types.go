@@ -0,0 +1,223 @@
+package preview
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	htmltemplate "html/template"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/bellwood4486/tmpltype/internal/gen"
+)
+
+// basicTypes は組み込み型の名前と型の対応
+var basicTypes = map[string]reflect.Type{
+	"string":  reflect.TypeFor[string](),
+	"bool":    reflect.TypeFor[bool](),
+	"int":     reflect.TypeFor[int](),
+	"int8":    reflect.TypeFor[int8](),
+	"int16":   reflect.TypeFor[int16](),
+	"int32":   reflect.TypeFor[int32](),
+	"int64":   reflect.TypeFor[int64](),
+	"uint":    reflect.TypeFor[uint](),
+	"uint8":   reflect.TypeFor[uint8](),
+	"uint16":  reflect.TypeFor[uint16](),
+	"uint32":  reflect.TypeFor[uint32](),
+	"uint64":  reflect.TypeFor[uint64](),
+	"float32": reflect.TypeFor[float32](),
+	"float64": reflect.TypeFor[float64](),
+	"byte":    reflect.TypeFor[byte](),
+	"rune":    reflect.TypeFor[rune](),
+	"any":     reflect.TypeFor[any](),
+}
+
+// qualifiedTypes は描画できるパッケージ修飾付きの型（typing.WellKnownImports の修飾子）
+var qualifiedTypes = map[string]reflect.Type{
+	"time.Time":         reflect.TypeFor[time.Time](),
+	"time.Duration":     reflect.TypeFor[time.Duration](),
+	"url.URL":           reflect.TypeFor[url.URL](),
+	"template.HTML":     reflect.TypeFor[htmltemplate.HTML](),
+	"template.HTMLAttr": reflect.TypeFor[htmltemplate.HTMLAttr](),
+	"template.CSS":      reflect.TypeFor[htmltemplate.CSS](),
+	"template.JS":       reflect.TypeFor[htmltemplate.JS](),
+	"template.JSStr":    reflect.TypeFor[htmltemplate.JSStr](),
+	"template.URL":      reflect.TypeFor[htmltemplate.URL](),
+	"template.Srcset":   reflect.TypeFor[htmltemplate.Srcset](),
+}
+
+// structInfo は動的に構築した構造体のデータのキーとフィールドの対応
+type structInfo struct {
+	keys     []string       // データのキー（json タグの名前、なければテンプレートでの名前。フィールド順）
+	index    map[string]int // データのキー -> フィールドの添字
+	defaults map[int]any    // フィールドの添字 -> @default の値
+}
+
+// typeBuilder はテンプレートのパラメータ型を reflect で構築する
+type typeBuilder struct {
+	templates map[string]gen.TemplateSchema
+	types     map[string]reflect.Type      // テンプレート名 -> パラメータ型（構築済み）
+	structs   map[reflect.Type]*structInfo // 構築した構造体型 -> キーの対応
+}
+
+func newTypeBuilder(templates map[string]gen.TemplateSchema) *typeBuilder {
+	return &typeBuilder{
+		templates: templates,
+		types:     make(map[string]reflect.Type),
+		structs:   make(map[reflect.Type]*structInfo),
+	}
+}
+
+// templateType はテンプレート name のパラメータ型を返す
+// テンプレート間の参照の循環は gen で検出済み
+func (b *typeBuilder) templateType(name string) (reflect.Type, error) {
+	if t, ok := b.types[name]; ok {
+		return t, nil
+	}
+	t, ok := b.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+	typ, err := b.structType(t.Fields, t.Embeds)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", t.File, err)
+	}
+	b.types[name] = typ
+	return typ, nil
+}
+
+// structType はフィールドと埋め込むテンプレートから構造体型を構築する
+// 埋め込むテンプレートのフィールドは、生成コードの埋め込みと同じく直接のフィールドとして扱う
+// （encoding/json の埋め込みフィールドと同じくデータでも同じ階層に書く）
+func (b *typeBuilder) structType(fields []gen.FieldSchema, embeds []string) (reflect.Type, error) {
+	var sfs []reflect.StructField
+	info := &structInfo{index: make(map[string]int), defaults: make(map[int]any)}
+	seen := make(map[string]bool) // Go のフィールド名
+
+	var add func(fields []gen.FieldSchema, embeds []string) error
+	add = func(fields []gen.FieldSchema, embeds []string) error {
+		for _, f := range fields {
+			if seen[f.GoName] {
+				continue // 外側のフィールドが優先される
+			}
+			typ, err := b.fieldType(f)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", f.Name, err)
+			}
+			seen[f.GoName] = true
+			// json タグの名前（生成コードに json.Unmarshal するときのキー）とテンプレートでの名前のどちらでも書ける
+			key := f.Name
+			tag := reflect.StructTag(f.Tag)
+			if name, _, _ := strings.Cut(tag.Get("json"), ","); name != "" && name != "-" {
+				key = name
+				info.index[f.Name] = len(sfs)
+			}
+			info.index[key] = len(sfs)
+			info.keys = append(info.keys, key)
+			if f.Default != nil {
+				info.defaults[len(sfs)] = f.Default
+				// 既定値だけが違う構造体が reflect.StructOf で同じ型にならないよう、タグにも含める
+				tag = reflect.StructTag(strings.TrimSpace(fmt.Sprintf("%s default:%q", tag, fmt.Sprint(f.Default))))
+			}
+			sfs = append(sfs, reflect.StructField{Name: f.GoName, Type: typ, Tag: tag})
+		}
+		for _, e := range embeds {
+			t, ok := b.templates[e]
+			if !ok {
+				return fmt.Errorf("template %q not found", e)
+			}
+			if err := add(t.Fields, t.Embeds); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := add(fields, embeds); err != nil {
+		return nil, err
+	}
+
+	typ := reflect.StructOf(sfs)
+	b.structs[typ] = info
+	return typ, nil
+}
+
+// fieldType はフィールドの型を構築する
+// 型名のうち組み込み型と qualifiedTypes 以外は、子フィールドの構造体か参照先テンプレートの型とみなす
+func (b *typeBuilder) fieldType(f gen.FieldSchema) (reflect.Type, error) {
+	if len(f.Enum) > 0 {
+		return reflect.TypeFor[string](), nil // @enum の型は文字列
+	}
+	expr, err := parser.ParseExpr(f.Type)
+	if err != nil {
+		return nil, fmt.Errorf("invalid type %q: %w", f.Type, err)
+	}
+	return b.exprType(expr, func() (reflect.Type, error) {
+		if f.Template != "" {
+			return b.templateType(f.Template)
+		}
+		return b.structType(f.Fields, f.Embeds)
+	})
+}
+
+// exprType は型の式を構築する。named は名前付きの構造体型を構築する
+func (b *typeBuilder) exprType(expr ast.Expr, named func() (reflect.Type, error)) (reflect.Type, error) {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if t, ok := basicTypes[e.Name]; ok {
+			return t, nil
+		}
+		return named()
+	case *ast.SelectorExpr:
+		name := types.ExprString(e)
+		if t, ok := qualifiedTypes[name]; ok {
+			return t, nil
+		}
+		return nil, fmt.Errorf("type %s cannot be used in preview", name)
+	case *ast.StarExpr:
+		elem, err := b.exprType(e.X, named)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.PointerTo(elem), nil
+	case *ast.ArrayType:
+		if e.Len != nil {
+			return nil, fmt.Errorf("array type %s cannot be used in preview", types.ExprString(e))
+		}
+		elem, err := b.exprType(e.Elt, named)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(elem), nil
+	case *ast.MapType:
+		if key, ok := e.Key.(*ast.Ident); !ok || key.Name != "string" {
+			return nil, fmt.Errorf("map key of %s must be string", types.ExprString(e))
+		}
+		elem, err := b.exprType(e.Value, named)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.MapOf(reflect.TypeFor[string](), elem), nil
+	case *ast.InterfaceType:
+		if len(e.Methods.List) > 0 {
+			return nil, fmt.Errorf("interface type %s cannot be used in preview", types.ExprString(e))
+		}
+		return reflect.TypeFor[any](), nil
+	case *ast.StructType:
+		// @param の struct{...}（フィールド名がそのままデータのキーになる）
+		var fields []gen.FieldSchema
+		for _, f := range e.Fields.List {
+			for _, n := range f.Names {
+				if !token.IsExported(n.Name) {
+					return nil, fmt.Errorf("field %s of %s must be exported", n.Name, types.ExprString(e))
+				}
+				fields = append(fields, gen.FieldSchema{Name: n.Name, GoName: n.Name, Type: types.ExprString(f.Type)})
+			}
+		}
+		return b.structType(fields, nil)
+	}
+	return nil, fmt.Errorf("type %s cannot be used in preview", types.ExprString(expr))
+}
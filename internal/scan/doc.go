@@ -5,6 +5,17 @@
 //   - 葉のフィールド: string
 //   - range で使用されるフィールド: []struct{...}
 //   - index で使用されるフィールド: map[string]string
+//   - テンプレート関数の引数に渡されるフィールド: 関数の引数の型
+//   - 比較関数（eq, gt など）でリテラルと比較されるフィールド: リテラルの型（int, float64, string, bool）
+//   - and/or/not の引数: bool
+//   - len/slice の引数: スライス
+//   - printf の引数: 書式の動詞から推論（%d は int など）
+//
+// テンプレート変数（$x := .Foo、range $i, $v := .Items、$）は指すフィールドを追跡し、
+// 変数経由の参照（$x.Bar）も同じスキーマ木に反映します。
+//
+// 組み込み関数やリテラルからの推論は制約として集め、テンプレートの走査後にまとめて解決します。
+// eq / ne で比較した文字列リテラルは Field.Literals に記録し、@enum の値の候補と検証に使います。
 //
 // スキャン結果は internal/typing パッケージで型解決されます。
 package scan
@@ -0,0 +1,395 @@
+package scan
+
+import (
+	"slices"
+	"strings"
+
+	tplparse "text/template/parse"
+)
+
+// weight は型ヒントの確からしさです。値が大きいほど優先します。
+type weight int
+
+const (
+	weightBool    weight = iota + 1 // 条件に使う and/or/not の引数（真偽値として使われることが多いだけなので弱い）
+	weightLiteral                   // リテラルとの比較や printf の書式
+	weightFunc                      // 宣言されたテンプレート関数の引数
+)
+
+// hint はフィールド（ドット起点ではなくトップレベル起点のパス）の型の候補です。
+type hint struct {
+	path   []string
+	typ    string
+	weight weight
+}
+
+// constraints はスキャン中に集めた型の制約です。
+// 走査が終わってから apply でまとめて解決し、葉の型ヒント（Field.Type）に反映します。
+type constraints struct {
+	hints    []hint
+	same     [][2][]string // 同じ型であるべきフィールドの組（eq .A .B など）
+	slices   [][]string    // スライスとして使われたフィールド（len .Items など）
+	maps     [][]string    // キーが文字列として使われた range の対象（range $k, $v := .Meta で eq $k "x" など）
+	literals []literalRef  // フィールドと比較している文字列リテラル（eq .Status "shipped" など）
+	bools    [][][]string  // 条件に使う and / or / not の引数のフィールドの組（if and .IsAdmin .IsActive など）
+}
+
+// literalRef はフィールド（トップレベル起点のパス）と比較している文字列リテラルです。
+type literalRef struct {
+	path []string
+	lit  Literal
+}
+
+func (cs *constraints) add(path []string, typ string, w weight) {
+	if typ == "" || typ == "any" || typ == "interface{}" {
+		return
+	}
+	cs.hints = append(cs.hints, hint{path: path, typ: typ, weight: w})
+}
+
+func (cs *constraints) addSlice(path []string) {
+	cs.slices = append(cs.slices, path)
+}
+
+func (cs *constraints) addMap(path []string) {
+	cs.maps = append(cs.maps, path)
+}
+
+func (cs *constraints) addBools(paths [][]string) {
+	cs.bools = append(cs.bools, paths)
+}
+
+func (cs *constraints) addSame(a, b []string) {
+	cs.same = append(cs.same, [2][]string{a, b})
+}
+
+// apply は制約を解決して s の葉に型ヒントを設定します。
+//   - 同じフィールドに複数の候補があれば weight の大きいものを採用します
+//   - 同じ weight で int と float64 が競合したら float64 に広げ、それ以外は最初に現れた候補を採用します
+//   - 条件に使う and / or / not の引数は、組のどれにも bool 以外の候補がなければ bool にします
+//   - 型の決まらないフィールドは、同じ型であるべき相手の型を引き継ぎます
+//
+// スライスとして使われたフィールドは、走査を終えても葉（子のない string）のままなら要素が string のスライスにします。
+// .Items.Title のように子を参照していたり range で要素の構造体が確定していれば、そちらを優先します。
+// range のキー変数が文字列として使われたフィールドは、スライスではなくマップにします。
+func (cs *constraints) apply(s *Schema) {
+	for _, path := range cs.maps {
+		markMapKey(s, path)
+	}
+	for _, path := range cs.slices {
+		markSliceLeaf(s, path)
+	}
+
+	best := make(map[string]hint)
+	var order []string
+	for _, h := range cs.hints {
+		k := pathKey(h.path)
+		cur, ok := best[k]
+		switch {
+		case !ok:
+			best[k] = h
+			order = append(order, k)
+		case h.weight > cur.weight:
+			best[k] = h
+		case h.weight == cur.weight && isNumeric(cur.typ) && isNumeric(h.typ) && h.typ == "float64":
+			best[k] = h
+		}
+	}
+
+	for _, group := range cs.bools {
+		// {{ if or .Nickname .Name }} で .Name が string と分かっていれば、.Nickname も bool にしない
+		if slices.ContainsFunc(group, func(path []string) bool {
+			h, ok := best[pathKey(path)]
+			return ok && h.typ != "bool"
+		}) {
+			continue
+		}
+		for _, path := range group {
+			if k := pathKey(path); best[k].typ == "" {
+				best[k] = hint{path: path, typ: "bool", weight: weightBool}
+				order = append(order, k)
+			}
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, pair := range cs.same {
+			a, b := pathKey(pair[0]), pathKey(pair[1])
+			ha, okA := best[a]
+			hb, okB := best[b]
+			switch {
+			case okA && !okB:
+				best[b] = hint{path: pair[1], typ: ha.typ, weight: ha.weight}
+				order = append(order, b)
+				changed = true
+			case okB && !okA:
+				best[a] = hint{path: pair[0], typ: hb.typ, weight: hb.weight}
+				order = append(order, a)
+				changed = true
+			}
+		}
+	}
+
+	for _, k := range order {
+		h := best[k]
+		setLeafType(s, h.path, h.typ)
+	}
+
+	for _, l := range cs.literals {
+		if f := findField(s, l.path); f != nil {
+			f.Literals = append(f.Literals, l.lit)
+		}
+	}
+}
+
+func pathKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+func isNumeric(typ string) bool {
+	return typ == "int" || typ == "float64"
+}
+
+// inferCmd はコマンド（関数呼び出し）の引数位置から、引数に渡されたフィールドの型を推論します。
+// args は関数名を除いた引数で、パイプラインの前段の値があれば最後に含みます（不明な値は nil）。
+// cond はコマンドの結果を if の条件として使うかどうかです。
+func (sc *scanner) inferCmd(name string, args []tplparse.Node, c ctx, cond bool) {
+	switch name {
+	case "eq", "ne", "lt", "le", "gt", "ge":
+		sc.inferComparison(name, args, c)
+	case "and", "or", "not":
+		// {{ if and .IsAdmin .IsActive }} → bool
+		// {{ or .Nickname .Name }} や {{ or .Title "Untitled" }} のように値を選ぶ使い方もあるので、
+		// 結果を条件に使い、bool 以外のリテラルと一緒に使っていないときだけ推論する
+		if !cond {
+			return
+		}
+		var group [][]string
+		for _, a := range args {
+			if typ := literalType(a); typ != "" && typ != "bool" {
+				return
+			}
+			if path, ok := c.fieldPath(a); ok {
+				group = append(group, path)
+			}
+		}
+		sc.cons.addBools(group)
+	case "len":
+		// len .Items → Items はスライス
+		if len(args) == 1 {
+			if path, ok := c.fieldPath(args[0]); ok {
+				sc.cons.addSlice(path)
+			}
+		}
+	case "slice":
+		// slice .Items 1 3 → Items はスライス、添字は int
+		if len(args) == 0 {
+			return
+		}
+		if path, ok := c.fieldPath(args[0]); ok {
+			sc.cons.addSlice(path)
+		}
+		for _, a := range args[1:] {
+			sc.hintArg(a, c, "int", weightLiteral)
+		}
+	case "index":
+		// index .Meta "key" → Meta は map[string]string、index .Items 0 → Items はスライス
+		if len(args) < 2 {
+			return
+		}
+		path, ok := c.fieldPath(args[0])
+		if !ok {
+			return
+		}
+		if _, ok := args[1].(*tplparse.NumberNode); ok {
+			sc.cons.addSlice(path)
+			return
+		}
+		markMapString(sc.schema, path)
+	case "printf":
+		// printf "%d" .Age → Age は int
+		if len(args) == 0 {
+			return
+		}
+		format, ok := args[0].(*tplparse.StringNode)
+		if !ok {
+			return
+		}
+		for i, typ := range printfArgTypes(format.Text) {
+			if i+1 >= len(args) {
+				break
+			}
+			sc.hintArg(args[i+1], c, typ, weightLiteral)
+		}
+	default:
+		// 関数呼び出し formatDate .CreatedAt → 引数位置の型を葉の型ヒントにする
+		fn, ok := sc.funcs[name]
+		if !ok {
+			return
+		}
+		for i, a := range args {
+			if typ, ok := fn.paramType(i); ok {
+				sc.hintArg(a, c, typ, weightFunc)
+			}
+		}
+	}
+}
+
+// inferComparison は比較関数（eq, lt など）の引数から型を推論します。
+// リテラルと比較されたフィールドはリテラルの型に、フィールド同士は同じ型になります。
+// eq / ne で比較した文字列リテラルはフィールドの Literals に記録します（@enum の値の候補と検証に使う）。
+func (sc *scanner) inferComparison(name string, args []tplparse.Node, c ctx) {
+	var lit string
+	var fields [][]string
+	var keys []*variable
+	var strs []Literal
+	for _, a := range args {
+		if path, ok := c.fieldPath(a); ok {
+			fields = append(fields, path)
+		} else if v := c.rangeKey(a); v != nil {
+			keys = append(keys, v)
+		} else if typ := literalType(a); typ != "" {
+			if lit == "" {
+				lit = typ
+			}
+			if str, ok := a.(*tplparse.StringNode); ok {
+				strs = append(strs, Literal{Value: str.Text, Pos: sc.pos(a)})
+			}
+		}
+	}
+
+	for i, path := range fields {
+		if lit != "" {
+			sc.cons.add(path, lit, weightLiteral)
+		}
+		if i > 0 {
+			sc.cons.addSame(fields[0], path)
+		}
+		if name == "eq" || name == "ne" {
+			for _, l := range strs {
+				sc.cons.literals = append(sc.cons.literals, literalRef{path: path, lit: l})
+			}
+		}
+	}
+	if lit == "string" {
+		for _, v := range keys {
+			sc.cons.addMap(v.path)
+		}
+	}
+}
+
+// hintArg は引数 a がフィールド（または変数が指すフィールド）なら型の候補を追加します。
+// range のキー変数（$k）に string が渡された場合は、range の対象をマップとして扱います。
+func (sc *scanner) hintArg(a tplparse.Node, c ctx, typ string, w weight) {
+	if v := c.rangeKey(a); v != nil {
+		if typ == "string" {
+			sc.cons.addMap(v.path)
+		}
+		return
+	}
+	if path, ok := c.fieldPath(a); ok {
+		sc.cons.add(path, typ, w)
+	}
+}
+
+// literalType はリテラルノードの Go の型を返します。リテラルでなければ空文字を返します。
+func literalType(n tplparse.Node) string {
+	switch x := n.(type) {
+	case *tplparse.StringNode:
+		return "string"
+	case *tplparse.BoolNode:
+		return "bool"
+	case *tplparse.NumberNode:
+		text := strings.ToLower(x.Text)
+		isHex := strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "-0x") || strings.HasPrefix(text, "+0x")
+		switch {
+		case x.IsComplex && !x.IsFloat:
+			return ""
+		case strings.Contains(text, ".") || (!isHex && strings.Contains(text, "e")) || (isHex && strings.Contains(text, "p")):
+			return "float64"
+		case x.IsInt:
+			return "int"
+		case x.IsFloat:
+			return "float64"
+		}
+	}
+	return ""
+}
+
+// printfArgTypes は printf の書式から、書式に続く各引数の型を返します（推論できない引数は空文字）。
+// %[n]d のような引数番号の指定を含む書式は扱いません。
+func printfArgTypes(format string) []string {
+	var types []string
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		// フラグ、幅、精度（* は int の引数を消費する）
+		for i < len(format) && strings.IndexByte("+-# 0123456789.*", format[i]) >= 0 {
+			if format[i] == '*' {
+				types = append(types, "int")
+			}
+			i++
+		}
+		if i >= len(format) {
+			break
+		}
+
+		switch format[i] {
+		case '%':
+			continue
+		case '[':
+			return nil
+		case 'd', 'o', 'O':
+			types = append(types, "int")
+		case 'e', 'E', 'f', 'F', 'g', 'G':
+			types = append(types, "float64")
+		case 't':
+			types = append(types, "bool")
+		case 's', 'q':
+			types = append(types, "string")
+		default:
+			// %v, %x などは複数の型を受け付けるので推論しない
+			types = append(types, "")
+		}
+	}
+	return types
+}
+
+// markSliceLeaf は parts が指すフィールドを、要素が string のスライスとして確定します。
+// 葉（string）以外のフィールドは変更しません。
+func markSliceLeaf(s *Schema, parts []string) {
+	f := findField(s, parts)
+	if f == nil || f.Kind != KindString {
+		return
+	}
+	*f = Field{
+		Name: f.Name,
+		Kind: KindSlice,
+		Elem: &Field{
+			Name: f.Name + "Item",
+			Kind: KindString,
+		},
+		Refs: f.Refs,
+	}
+}
+
+// markMapKey は parts が指すフィールドを、キーが string のマップとして確定します。
+// range で推論したスライスは要素をそのまま値にします。
+func markMapKey(s *Schema, parts []string) {
+	f := findField(s, parts)
+	if f == nil {
+		return
+	}
+	switch f.Kind {
+	case KindSlice:
+		f.Kind = KindMap
+		if f.Elem != nil {
+			f.Elem.Name = f.Name + "Value"
+		}
+	case KindString:
+		markMapString(s, parts)
+	}
+}
@@ -1,7 +1,15 @@
 package scan
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	htmltemplate "html/template"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"text/template"
 	tplparse "text/template/parse"
 
@@ -16,144 +24,694 @@ const (
 	KindStruct
 	KindSlice
 	KindMap
+	KindTemplate // {{ template "name" .Foo }} で別テンプレートに渡されるフィールド
 )
 
+// String は種別の名前（"string", "struct", "slice", "map", "template"）を返します。
+func (k Kind) String() string {
+	switch k {
+	case KindString:
+		return "string"
+	case KindStruct:
+		return "struct"
+	case KindSlice:
+		return "slice"
+	case KindMap:
+		return "map"
+	case KindTemplate:
+		return "template"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
 // Fileld は推論スキーマ木のノードです。
 type Field struct {
 	Name     string
 	Kind     Kind
 	Elem     *Field            // Slice/Map の要素
 	Children map[string]*Field // Struct の子
+	Template string            // KindTemplate の参照先テンプレート名
+	Type     string            // 葉の型ヒント（関数の引数位置などから推論。空なら string）
+	Embeds   []string          // {{ template "name" . }} でドットごと渡されるテンプレート名
+	Refs     []Pos             // テンプレート内でこのフィールドを参照している位置（出現順）
+	Literals []Literal         // eq / ne で比較している文字列リテラル（出現順）
+}
+
+// Literal はフィールドと比較している文字列リテラルです。
+type Literal struct {
+	Value string // リテラルの値（引用符を除く）
+	Pos   Pos    // リテラルの位置
+}
+
+// Pos はテンプレートファイル内の位置です。
+type Pos struct {
+	File string // テンプレートファイルのパス（Source.File、未指定なら Source.Name）
+	Line int    // 1 起点の行番号
+	Col  int    // 1 起点の列番号（バイト単位）
+}
+
+// String は "file:line:col" 形式の位置を返します。
+// 列が不明（0）なら "file:line"、ファイルが不明なら "line:col" を返します。
+func (p Pos) String() string {
+	var b strings.Builder
+	b.WriteString(p.File)
+	if p.Line > 0 {
+		if p.File != "" {
+			b.WriteString(":")
+		}
+		b.WriteString(strconv.Itoa(p.Line))
+		if p.Col > 0 {
+			b.WriteString(":" + strconv.Itoa(p.Col))
+		}
+	}
+	return b.String()
+}
+
+// Error は位置付きのエラーです。
+// エディタで開けるよう "file:line:col: msg" 形式で表示します。
+type Error struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *Error) Error() string {
+	if pos := e.Pos.String(); pos != "" {
+		return pos + ": " + e.Msg
+	}
+	return e.Msg
+}
+
+// Errorf は位置 pos のエラーを返します。
+func Errorf(pos Pos, format string, args ...any) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// InFile は err がファイル不明の位置付きエラー（テンプレート内の行と列のみ）なら、ファイル file の位置に置き換えます。
+// 位置を持たないエラーは "file: msg" にします。errors.Join でまとめたエラーは個別に置き換えます。
+func InFile(err error, file string) error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs := joined.Unwrap()
+		out := make([]error, 0, len(errs))
+		for _, e := range errs {
+			out = append(out, InFile(e, file))
+		}
+		return errors.Join(out...)
+	}
+
+	var pe *Error
+	if errors.As(err, &pe) {
+		if pe.Pos.File != "" {
+			return err
+		}
+		pos := pe.Pos
+		pos.File = file
+		return &Error{Pos: pos, Msg: pe.Msg}
+	}
+	return &Error{Pos: Pos{File: file}, Msg: err.Error()}
 }
 
 // Schema はトップレベル（Params直下）のフィールド集合です。
 type Schema struct {
 	Fields map[string]*Field
+	Embeds []string // トップレベルのドットごと渡されるテンプレート名
+	File   string   // テンプレートファイルのパス（Source.File、未指定なら Source.Name）
+}
+
+// Source はテンプレートセットを構成する1ファイル分のソースです。
+type Source struct {
+	Name string // テンプレート名（例: "footer", "mail_invite/title"）
+	Src  string // テンプレ本文
+	File string // 位置情報に使うファイルパス（空なら Name）
+}
+
+// Config はスキャン時の設定です。
+type Config struct {
+	HTML  bool            // html/template としてパースする
+	Funcs map[string]Func // テンプレートから呼び出せる関数（FuncMap）
+}
+
+// Func はテンプレート関数のシグネチャです。
+// 引数位置に渡されたフィールドの型推論に使います。
+type Func struct {
+	Params   []string // 引数の Go 型（例: "time.Time"）。不明なら nil
+	Variadic bool     // 最後の引数が可変長（Params の最後は要素型）
+}
+
+// paramType は i 番目（0 起点）の引数の型を返します。
+func (f Func) paramType(i int) (string, bool) {
+	if len(f.Params) == 0 {
+		return "", false
+	}
+	if i >= len(f.Params)-1 && f.Variadic {
+		return f.Params[len(f.Params)-1], true
+	}
+	if i >= len(f.Params) {
+		return "", false
+	}
+	return f.Params[i], true
 }
 
 // ScanTemplate は Go テンプレートを AST 解析して、.(ドット）スコープを追跡して
 // フィールド参照からスキーマ木を推論します。
 // 既定では葉はすべて string として扱い、 range は []struct{}, index は map[string]string を推論します。
 func ScanTemplate(src string) (Schema, error) {
-	// Use text/template to ensure built-in funcs (e.g., index) are defined.
-	tmpl, err := template.New("tpl").Parse(src)
+	schemas, err := ScanTemplateSet([]Source{{Name: "tpl", Src: src}}, Config{})
 	if err != nil {
-		return Schema{}, fmt.Errorf("failed to parse template: %w", err)
+		return Schema{}, err
+	}
+	return schemas["tpl"], nil
+}
+
+// ScanTemplateSet は複数のテンプレートを1つの関連付けられたテンプレートセットとしてパースし、
+// テンプレートごとのスキーマを推論します。
+// 同名の {{define}} が複数のファイルにある場合や、{{define}} が別ファイルのテンプレート名と重なる場合はエラーです
+// （別ファイルの {{block}} を1つの {{define}} で上書きするのは許可します）。
+//
+// {{ template "name" pipeline }} は呼び出し先に応じて次のように扱います:
+//   - 呼び出し先がセット内の別ファイル: .Foo を渡せば Foo をそのテンプレートの型参照 (KindTemplate) に、
+//     . を渡せば現在の構造体にそのテンプレートの型を埋め込みます (Embeds)
+//   - 呼び出し先が {{define}} / {{block}} で定義されたもの: 渡されたドットでその本体を走査します
+//   - 呼び出し先がセット内に定義されていない: エラーです
+func ScanTemplateSet(sources []Source, cfg Config) (map[string]Schema, error) {
+	files := make(map[string]string, len(sources))
+	for _, src := range sources {
+		files[src.Name] = src.File
+		if src.File == "" {
+			files[src.Name] = src.Name
+		}
+	}
+
+	defs, err := parseSet(sources, cfg, files)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.Join(checkDefines(sources, files), checkCalls(defs, files)); err != nil {
+		return nil, err
+	}
+
+	schemas := make(map[string]Schema, len(sources))
+	for _, src := range sources {
+		tree := defs[src.Name]
+		if tree == nil || tree.Root == nil {
+			return nil, fmt.Errorf("template not found: %s", src.Name)
+		}
+
+		s := Schema{Fields: map[string]*Field{}, File: files[src.Name]}
+		sc := &scanner{
+			schema:   &s,
+			funcs:    cfg.Funcs,
+			defs:     defs,
+			files:    files,
+			visiting: map[string]bool{src.Name: true},
+			tree:     tree,
+		}
+		sc.walk(tree.Root, ctx{})
+		sc.cons.apply(&s)
+		schemas[src.Name] = s
+	}
+
+	return schemas, nil
+}
+
+// funcMap はパースを通すためのダミー関数を登録した FuncMap を返します。
+// パースでは関数名の存在だけが検査されるので、実装は使いません。
+func funcMap(funcs map[string]Func) map[string]any {
+	m := make(map[string]any, len(funcs))
+	for name := range funcs {
+		m[name] = func(...any) (any, error) { return nil, nil }
+	}
+	return m
+}
+
+// parseSet はテンプレートセットをパースし、セット内の全テンプレート（ファイル + {{define}}）の木を返します。
+// 生成コードと同じパッケージ（text/template または html/template）でパースし、
+// 組み込み関数（index など）やパース時の挙動を揃えます。
+func parseSet(sources []Source, cfg Config, files map[string]string) (map[string]*tplparse.Tree, error) {
+	defs := make(map[string]*tplparse.Tree)
+	var errs []error
+
+	if cfg.HTML {
+		set := htmltemplate.New("").Funcs(funcMap(cfg.Funcs))
+		for _, src := range sources {
+			if _, err := set.New(src.Name).Parse(src.Src); err != nil {
+				errs = append(errs, parseError(err, src.Name, files))
+			}
+		}
+		if len(errs) > 0 {
+			return nil, errors.Join(errs...)
+		}
+		for _, t := range set.Templates() {
+			if t.Tree != nil {
+				defs[t.Name()] = t.Tree
+			}
+		}
+		return defs, nil
+	}
+
+	set := template.New("").Funcs(funcMap(cfg.Funcs))
+	for _, src := range sources {
+		if _, err := set.New(src.Name).Parse(src.Src); err != nil {
+			errs = append(errs, parseError(err, src.Name, files))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	for _, t := range set.Templates() {
+		if t.Tree != nil {
+			defs[t.Name()] = t.Tree
+		}
+	}
+	return defs, nil
+}
+
+// definition はファイル内の {{define}} / {{block}} による定義です。
+type definition struct {
+	pos   Pos
+	block bool
+}
+
+// checkDefines は {{define}} / {{block}} の名前がセット内で衝突していないか検査します。
+// セットは1つなので、後からパースした定義が前の定義を黙って置き換えてしまうのを防ぎます。
+func checkDefines(sources []Source, files map[string]string) error {
+	var errs []error
+	seen := make(map[string][]definition)
+	for _, src := range sources {
+		trees := make(map[string]*tplparse.Tree)
+		t := tplparse.New(src.Name)
+		t.Mode = tplparse.SkipFuncCheck
+		if _, err := t.Parse(src.Src, "", "", trees); err != nil {
+			continue // 構文エラーは parseSet で報告済み
+		}
+		names := make([]string, 0, len(trees))
+		for name := range trees {
+			names = append(names, name)
+		}
+		slices.SortFunc(names, func(a, b string) int {
+			return int(trees[a].Root.Pos) - int(trees[b].Root.Pos)
+		})
+		for _, name := range names {
+			tree := trees[name]
+			if name == src.Name || tplparse.IsEmptyTree(tree.Root) {
+				continue // 空の {{define}} は既存の定義を置き換えない
+			}
+			def := defineAt(src.Src, int(tree.Root.Pos))
+			def.pos.File = files[src.Name]
+			if _, ok := files[name]; ok {
+				errs = append(errs, Errorf(def.pos, "{{%s %q}} shadows the template file %s; rename the definition", def.kind(), name, files[name]))
+				continue
+			}
+			for _, prev := range seen[name] {
+				if prev.block == def.block {
+					errs = append(errs, Errorf(def.pos, "template %q is already defined at %s; define it in only one file", name, prev.pos))
+					break
+				}
+				if def.block {
+					// 後からパースした {{block}} の既定の本体が {{define}} を置き換えてしまう
+					errs = append(errs, Errorf(def.pos, "{{block %q}} replaces the {{define %q}} at %s because it is parsed later; move the {{define}} to a file that sorts after this one", name, name, prev.pos))
+					break
+				}
+			}
+			seen[name] = append(seen[name], def)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (d definition) kind() string {
+	if d.block {
+		return "block"
 	}
-	if tmpl.Tree == nil || tmpl.Tree.Root == nil {
-		return Schema{}, fmt.Errorf("template not found: %s", "tpl")
+	return "define"
+}
+
+// defineAt は本体の開始位置 body の直前にある {{define}} / {{block}} アクションの位置と種類を返します。
+func defineAt(src string, body int) definition {
+	start := strings.LastIndex(src[:body], "{{")
+	if start < 0 {
+		start = 0
 	}
+	action := strings.TrimLeft(src[start+2:], "- \t\r\n")
+	line := 1 + strings.Count(src[:start], "\n")
+	col := start - strings.LastIndex(src[:start], "\n")
+	return definition{
+		pos:   Pos{Line: line, Col: col},
+		block: strings.HasPrefix(action, "block"),
+	}
+}
 
-	s := Schema{Fields: map[string]*Field{}}
-	walk(tmpl.Tree.Root, &s, ctx{})
+// checkCalls は {{ template "name" }} の呼び出し先がセット内に定義されているか検査します。
+// 未定義の名前は実行時に初めてエラーになり、引数のフィールドも推論できないので生成時に報告します。
+func checkCalls(defs map[string]*tplparse.Tree, files map[string]string) error {
+	var errs []*Error
+	for _, name := range slices.Sorted(maps.Keys(defs)) {
+		tree := defs[name]
+		sc := &scanner{files: files, tree: tree}
+		templateNodes(tree.Root, func(x *tplparse.TemplateNode) {
+			if _, ok := defs[x.Name]; !ok {
+				errs = append(errs, Errorf(sc.pos(x), "template %q is not defined", x.Name))
+			}
+		})
+	}
+	slices.SortFunc(errs, func(a, b *Error) int {
+		return cmp.Or(strings.Compare(a.Pos.File, b.Pos.File), a.Pos.Line-b.Pos.Line, a.Pos.Col-b.Pos.Col)
+	})
+	joined := make([]error, len(errs))
+	for i, e := range errs {
+		joined[i] = e
+	}
+	return errors.Join(joined...)
+}
 
-	return s, nil
+// templateNodes は n 以下の {{template}} ノードを出現順に visit に渡します。
+func templateNodes(n tplparse.Node, visit func(*tplparse.TemplateNode)) {
+	switch x := n.(type) {
+	case *tplparse.ListNode:
+		if x == nil {
+			return
+		}
+		for _, c := range x.Nodes {
+			templateNodes(c, visit)
+		}
+	case *tplparse.IfNode:
+		templateNodes(x.List, visit)
+		templateNodes(x.ElseList, visit)
+	case *tplparse.RangeNode:
+		templateNodes(x.List, visit)
+		templateNodes(x.ElseList, visit)
+	case *tplparse.WithNode:
+		templateNodes(x.List, visit)
+		templateNodes(x.ElseList, visit)
+	case *tplparse.TemplateNode:
+		visit(x)
+	}
+}
+
+// parseErrorRe は text/template の構文エラー "template: NAME:LINE: msg" にマッチします。
+var parseErrorRe = regexp.MustCompile(`^template: (.+?):(\d+): (.*)$`)
+
+// parseError は text/template の構文エラーを、ファイルと行を持つ *Error に変換します。
+// 形式が異なるエラーはテンプレート name のファイルの位置として扱います。
+func parseError(err error, name string, files map[string]string) error {
+	m := parseErrorRe.FindStringSubmatch(err.Error())
+	if m == nil {
+		return &Error{Pos: Pos{File: files[name]}, Msg: err.Error()}
+	}
+	line, _ := strconv.Atoi(m[2])
+	file := files[m[1]]
+	if file == "" {
+		// {{ define }} 内のエラーは定義名で報告されるので、解析中のファイルとみなす
+		file = files[name]
+	}
+	return &Error{Pos: Pos{File: file, Line: line}, Msg: m[3]}
 }
 
-// ctx は現在の .(ドット)を表すパスを保持します。
+// scanner は1テンプレート分のスキャン状態を保持します。
+type scanner struct {
+	schema   *Schema
+	funcs    map[string]Func
+	defs     map[string]*tplparse.Tree // セット内の全テンプレート（ファイル + {{define}}）
+	files    map[string]string         // ファイル単位のテンプレート名 -> 位置情報に使うファイルパス
+	visiting map[string]bool           // 走査中の {{define}}（再帰呼び出し対策）
+	tree     *tplparse.Tree            // 位置情報の計算に使う木（ノードは自身の木を覚えているのでどれでもよい）
+	cons     constraints               // 型推論の制約（走査後にまとめて解決する）
+}
+
+// ctx は現在の .(ドット)を表すパスと、スコープ内のテンプレート変数を保持します。
 // with/range でドットが移動したときはこのパスを延長します。
+// elem は直近のパス延長が range によるもの（ドットが要素を指す）かどうかを表します。
 type ctx struct {
-	dot []string
+	dot  []string
+	elem bool
+	vars map[string]*variable // 宣言済みの変数（$ を除く）。値が nil の変数は追跡できない値を指す
 }
 
 func (c ctx) with(prefix []string) ctx {
 	dup := make([]string, len(c.dot))
 	copy(dup, c.dot)
-	return ctx{dot: append(dup, prefix...)}
+	return ctx{dot: append(dup, prefix...), vars: c.vars}
 }
 
 // walk はテンプレ AST を DFS します。 with/range/inf での . の取り扱いをテンプレ仕様取りに行います。
-func walk(n tplparse.Node, s *Schema, c ctx) {
+func (sc *scanner) walk(n tplparse.Node, c ctx) {
+	s := sc.schema
 	switch x := n.(type) {
 	case *tplparse.ListNode:
 		for _, nn := range x.Nodes {
-			walk(nn, s, c)
+			sc.walk(nn, c)
+			// {{ $x := .Foo }} で宣言した変数はリストの残り（囲む制御構造の end まで）で有効
+			if a, ok := nn.(*tplparse.ActionNode); ok {
+				c = sc.declare(c, a.Pipe)
+			}
 		}
 	case *tplparse.ActionNode:
-		collectFromPipe(x.Pipe, s, c)
+		sc.collectFromPipe(x.Pipe, c)
 	case *tplparse.IfNode:
 		// if のパイプに出る単独フィールドは存在チェック用途が多いので、
 		// 基点フィールドは struct として確保しておくと後続の .Foo.Bar に親和的。
-		base := baseFieldFromPipe(x.Pipe)
-		if len(base) > 0 {
-			ensureStructPath(s, append(c.dot, base...))
+		if r, ok := c.resolve(firstArg(x.Pipe)); ok && len(r.path()) > 0 {
+			ensureStructPath(s, r.path())
 		}
-		collectFromPipe(x.Pipe, s, c)
+		sc.collectPipe(x.Pipe, c, true)
+		// {{ if $x := .Foo }} の変数は else 側でも有効
+		c = sc.declare(c, x.Pipe)
 		if x.List != nil {
-			walk(x.List, s, c)
+			sc.walk(x.List, c)
 		}
 		if x.ElseList != nil {
-			walk(x.ElseList, s, c)
+			sc.walk(x.ElseList, c)
 		}
 	case *tplparse.WithNode:
 		// with 本体では . が基点に切り替わる。 esle 側は元の . に戻る。
-		base := baseFieldFromPipe(x.Pipe)
-		if len(base) > 0 {
-			ensureStructPath(s, append(c.dot, base...))
-		}
-		nc := c
-		if len(base) > 0 {
-			nc = c.with(base)
+		// {{ with $x := .User }} の変数は本体と else 側で有効
+		vc := sc.declare(c, x.Pipe)
+		nc := vc
+		arg := firstArg(x.Pipe)
+		if r, ok := c.resolve(arg); ok && len(r.path()) > 0 {
+			ensureStructPath(s, r.path())
+			sc.addRefs(c, arg)
+			nc = r.target()
+			nc.vars = vc.vars
 		}
 		if x.List != nil {
-			walk(x.List, s, nc)
+			sc.walk(x.List, nc)
 		}
 		if x.ElseList != nil {
-			walk(x.ElseList, s, c)
+			sc.walk(x.ElseList, vc)
 		}
 	case *tplparse.RangeNode:
 		// range .Items → Items は []struct{] に
-		base := baseFieldFromPipe(x.Pipe)
-		if len(base) > 0 {
-			markSliceStruct(s, append(c.dot, base...))
-		}
+		arg := firstArg(x.Pipe)
 		nc := c
-		if len(base) > 0 {
-			nc = c.with(base)
+		var v *variable // range の対象
+		if r, ok := c.resolve(arg); ok && len(r.path()) > 0 {
+			path := r.path()
+			markSliceStruct(s, path)
+			sc.addRefs(c, arg)
+			nc = r.target()
+			nc.elem = true
+			v = &variable{path: path}
+		}
+		// range $v := .Items / range $i, $v := .Items
+		// 2変数でもスライスのままにし、キー変数が文字列として使われたときだけマップにする（hintArg）。
+		// {{ $k }} のように出力するだけでは添字とキーを区別できず、どちらでも実行できるため
+		if decl := x.Pipe.Decl; len(decl) > 0 {
+			var key, val *variable
+			if v != nil {
+				key = &variable{path: v.path, index: true}
+				val = &variable{path: v.path, elem: true}
+			}
+			if len(decl) == 1 {
+				nc = nc.declare(decl[0].Ident[0], val)
+			} else {
+				nc = nc.declare(decl[0].Ident[0], key).declare(decl[1].Ident[0], val)
+			}
 		}
 		if x.List != nil {
-			walk(x.List, s, nc)
+			sc.walk(x.List, nc)
 		}
 		if x.ElseList != nil {
-			walk(x.ElseList, s, c)
+			sc.walk(x.ElseList, c)
 		}
+	case *tplparse.TemplateNode:
+		sc.walkTemplate(x, c)
 	}
 }
 
+// walkTemplate は {{ template "name" pipeline }} を処理します。
+func (sc *scanner) walkTemplate(x *tplparse.TemplateNode, c ctx) {
+	s := sc.schema
+
+	// 呼び出し先に渡すドットを求める（.Foo, $x.Foo, ., $x のみ追跡する）
+	var arg tplparse.Node
+	if x.Pipe != nil && len(x.Pipe.Decl) == 0 && len(x.Pipe.Cmds) == 1 && len(x.Pipe.Cmds[0].Args) == 1 {
+		arg = x.Pipe.Cmds[0].Args[0]
+	}
+
+	r, ok := c.resolve(arg)
+	if !ok {
+		// 関数呼び出しなどの結果を渡す場合は型を追跡できないので、パイプ内の参照のみ収集する
+		sc.collectFromPipe(x.Pipe, c)
+		return
+	}
+	_, isFile := sc.files[x.Name]
+	switch {
+	case len(r.idents) == 0 && isFile:
+		// ドットごと渡す → 現在の構造体に埋め込む
+		addEmbed(s, r.base, x.Name)
+	case len(r.idents) == 0:
+		sc.walkDefine(x.Name, r.base)
+	case isFile:
+		markTemplateRef(s, r.path(), x.Name)
+		sc.addRefs(c, arg)
+	default:
+		sc.walkDefine(x.Name, r.target())
+	}
+}
+
+// walkDefine は {{define}} / {{block}} で定義されたテンプレート本体を、与えられたドットで走査します。
+// 本体の変数スコープは呼び出し元と独立で、$ は渡されたドットを指します。
+func (sc *scanner) walkDefine(name string, c ctx) {
+	tree := sc.defs[name]
+	if tree == nil || tree.Root == nil || sc.visiting[name] {
+		return
+	}
+	sc.visiting[name] = true
+	root := &variable{path: c.dot, elem: c.elem}
+	sc.walk(tree.Root, ctx{dot: c.dot, elem: c.elem, vars: map[string]*variable{"$": root}})
+	delete(sc.visiting, name)
+}
+
 // collectFromPipe は {{ .Foo.Bar }} や {{ index .Meta "k" }} など、パイプ内のフィールド参照を収集します。
-func collectFromPipe(p *tplparse.PipeNode, s *Schema, c ctx) {
+// 括弧で囲んだ入れ子のパイプ（{{ if and (gt .Count 0) .IsActive }}）も収集します。
+func (sc *scanner) collectFromPipe(p *tplparse.PipeNode, c ctx) {
+	sc.collectPipe(p, c, false)
+}
+
+// collectPipe は collectFromPipe の本体です。cond はパイプの結果を if の条件として使うかどうかです。
+func (sc *scanner) collectPipe(p *tplparse.PipeNode, c ctx, cond bool) {
 	if p == nil {
 		return
 	}
 
-	for _, cmd := range p.Cmds {
-		// index .Meta "key" → Meta は map[string]string
-		if len(cmd.Args) >= 2 {
-			if id, ok := cmd.Args[0].(*tplparse.IdentifierNode); ok && id.Ident == "index" {
-				if fn, ok := cmd.Args[1].(*tplparse.FieldNode); ok {
-					markMapString(s, append(c.dot, fn.Ident...))
+	s := sc.schema
+	for i, cmd := range p.Cmds {
+		// 条件として使う and / or / not は、その引数も条件として使う
+		cmdCond := cond && i == len(p.Cmds)-1 && isLogical(cmd)
+		// 通常のフィールド参照 .Foo.Bar や $x.Foo を葉 string として確保
+		for _, a := range cmd.Args {
+			switch x := a.(type) {
+			case *tplparse.FieldNode, *tplparse.VariableNode:
+				if r, ok := c.resolve(x); ok && len(r.idents) > 0 {
+					ensurePath(s, r.path(), true)
+					sc.addRefs(c, x)
 				}
+			case *tplparse.PipeNode:
+				sc.collectPipe(x, c, cmdCond)
 			}
 		}
-		// 通常のフィールド参照 .Foo.Bar を葉 string として確保
-		for _, a := range cmd.Args {
-			if f, ok := a.(*tplparse.FieldNode); ok {
-				ensurePath(s, append(c.dot, f.Ident...), true)
+
+		// 関数呼び出しの引数位置から型を推論する
+		if len(cmd.Args) == 0 {
+			continue
+		}
+		id, ok := cmd.Args[0].(*tplparse.IdentifierNode)
+		if !ok {
+			continue
+		}
+		args := slices.Clone(cmd.Args[1:])
+		if i > 0 {
+			// パイプライン .CreatedAt | formatDate → 前段の値は最後の引数になる
+			var piped tplparse.Node
+			if prev := p.Cmds[i-1]; len(prev.Args) == 1 {
+				switch prev.Args[0].(type) {
+				case *tplparse.FieldNode, *tplparse.VariableNode:
+					piped = prev.Args[0]
+				}
 			}
+			args = append(args, piped)
+		}
+		sc.inferCmd(id.Ident, args, c, cmdCond)
+	}
+}
+
+// isLogical はコマンドが and / or / not の呼び出しかを返します。
+func isLogical(cmd *tplparse.CommandNode) bool {
+	if len(cmd.Args) == 0 {
+		return false
+	}
+	id, ok := cmd.Args[0].(*tplparse.IdentifierNode)
+	return ok && (id.Ident == "and" || id.Ident == "or" || id.Ident == "not")
+}
+
+// addRefs は引数ノード n（.User.Name や $u.Name）が参照するパス上の各ノードに、参照位置を記録します。
+// .User.Name なら User と User.Name の両方に、$u.Name なら $u が指すフィールドの Name に記録します。
+func (sc *scanner) addRefs(c ctx, n tplparse.Node) {
+	r, ok := c.resolve(n)
+	if !ok || len(r.idents) == 0 {
+		return
+	}
+
+	pos := sc.pos(n)
+	switch x := n.(type) {
+	case *tplparse.FieldNode:
+		if len(x.Ident) > 1 {
+			// .User.Name はチェインとしてパースされ、位置が2つ目のセグメント（.Name）を指すので先頭に戻す
+			pos.Col -= len(x.Ident[0]) + 1
+		}
+	case *tplparse.VariableNode:
+		if len(x.Ident) > 1 {
+			// $u.Name も同様に .Name を指すので $u の先頭に戻す
+			pos.Col -= len(x.Ident[0])
+		}
+	}
+	for i := range r.idents {
+		path := append(slices.Clone(r.base.dot), r.idents[:i+1]...)
+		if fd := findField(sc.schema, path); fd != nil {
+			fd.Refs = append(fd.Refs, pos)
 		}
 	}
 }
 
-// baseFieldFromPipe はパイプ内で最初に現れるフィールドノード（.Foo.Bar など）の識別子スライスを返します。
-func baseFieldFromPipe(p *tplparse.PipeNode) []string {
+// pos はノードのテンプレートファイル内の位置を返します。
+func (sc *scanner) pos(n tplparse.Node) Pos {
+	// ErrorContext の位置は "name:line:col"（col は 0 起点）
+	loc, _ := sc.tree.ErrorContext(n)
+	var p Pos
+	if i := strings.LastIndex(loc, ":"); i >= 0 {
+		p.Col, _ = strconv.Atoi(loc[i+1:])
+		p.Col++
+		loc = loc[:i]
+	}
+	if i := strings.LastIndex(loc, ":"); i >= 0 {
+		p.Line, _ = strconv.Atoi(loc[i+1:])
+		loc = loc[:i]
+	}
+	p.File = loc
+	if file, ok := sc.files[loc]; ok {
+		p.File = file
+	}
+	return p
+}
+
+// firstArg はパイプ内で最初に現れるフィールドノード（.Foo.Bar）または変数ノード（$x.Foo）を返します。
+func firstArg(p *tplparse.PipeNode) tplparse.Node {
 	if p == nil {
 		return nil
 	}
 
 	for _, cmd := range p.Cmds {
 		for _, a := range cmd.Args {
-			if f, ok := a.(*tplparse.FieldNode); ok && len(f.Ident) > 0 {
-				return f.Ident
+			switch x := a.(type) {
+			case *tplparse.FieldNode:
+				if len(x.Ident) > 0 {
+					return x
+				}
+			case *tplparse.VariableNode:
+				return x
 			}
 		}
 	}
@@ -167,17 +725,7 @@ func ensureStructPath(s *Schema, parts []string) {
 	if len(parts) == 0 {
 		return
 	}
-	if s.Fields == nil {
-		s.Fields = map[string]*Field{}
-	}
-
-	cur := ensureStruct(s.Fields, parts[0])
-	for i := 1; i < len(parts); i++ {
-		if cur.Children == nil {
-			cur.Children = map[string]*Field{}
-		}
-		cur = ensureStruct(cur.Children, parts[i])
-	}
+	nodeAt(s, parts)
 }
 
 // ensurePath は（通常の）フィールド参照を処理します。
@@ -201,11 +749,12 @@ func ensurePath(s *Schema, parts []string, leafAsString bool) {
 				// 既にコンテナとして確定 → 触らない
 				return
 			case KindStruct:
-				if len(cur.Children) == 0 {
+				if len(cur.Children) == 0 && len(cur.Embeds) == 0 {
 					// 子なし struct → 文字列に置換
 					*cur = Field{
 						Name: util.Export(name),
 						Kind: KindString,
+						Refs: cur.Refs,
 					}
 				}
 				return
@@ -233,6 +782,9 @@ func ensurePath(s *Schema, parts []string, leafAsString bool) {
 		switch existing.Kind {
 		case KindSlice, KindMap:
 			// そのまま尊重
+		case KindTemplate:
+			// 別テンプレートの型として確定済み → 触らない
+			return
 		case KindStruct:
 			if existing.Children == nil {
 				existing.Children = map[string]*Field{}
@@ -260,6 +812,10 @@ func ensurePath(s *Schema, parts []string, leafAsString bool) {
 			}
 			cur = cur.Elem
 		}
+		// マップの .Meta.key はキーの参照なので、値の型は変えない
+		if cur.Kind == KindMap {
+			return
+		}
 		if cur.Children == nil {
 			cur.Children = map[string]*Field{}
 		}
@@ -269,13 +825,14 @@ func ensurePath(s *Schema, parts []string, leafAsString bool) {
 			// 葉は string として確保（既存確定は尊重）
 			if ch, ok := cur.Children[name]; ok && ch != nil {
 				switch ch.Kind {
-				case KindSlice, KindMap:
+				case KindSlice, KindMap, KindTemplate:
 					return
 				case KindStruct:
-					if len(ch.Children) == 0 {
+					if len(ch.Children) == 0 && len(ch.Embeds) == 0 {
 						*ch = Field{
 							Name: util.Export(name),
 							Kind: KindString,
+							Refs: ch.Refs,
 						}
 					}
 				default:
@@ -295,6 +852,9 @@ func ensurePath(s *Schema, parts []string, leafAsString bool) {
 			case KindSlice, KindMap:
 				// コンテナはそのまま潜る
 				cur = ch
+			case KindTemplate:
+				// 別テンプレートの型として確定済み → これ以上掘らない
+				return
 			case KindStruct:
 				if ch.Children == nil {
 					ch.Children = map[string]*Field{}
@@ -318,7 +878,8 @@ func ensurePath(s *Schema, parts []string, leafAsString bool) {
 // 既に存在して Kind が struct 以外でも、struct に「昇格」させ、Children を確保します。
 func ensureStruct(m map[string]*Field, name string) *Field {
 	if m[name] != nil {
-		if m[name].Kind != KindStruct {
+		// 別テンプレートの型参照は struct として扱い、種別は保持する
+		if m[name].Kind != KindStruct && m[name].Kind != KindTemplate {
 			m[name].Kind = KindStruct
 		}
 		if m[name].Children == nil {
@@ -337,20 +898,15 @@ func ensureStruct(m map[string]*Field, name string) *Field {
 }
 
 // markSliceStruct は parts の最終セグメントをスライス（要素は struct）として確定します。
+// 既にマップとして確定している場合（index .Meta "k" の後の range .Meta など）はマップのままにします。
 func markSliceStruct(s *Schema, parts []string) {
 	if len(parts) == 0 {
 		return
 	}
 
-	if s.Fields == nil {
-		s.Fields = map[string]*Field{}
-	}
-	cur := ensureStruct(s.Fields, parts[0])
-	for i := 1; i < len(parts); i++ {
-		if cur.Children == nil {
-			cur.Children = map[string]*Field{}
-		}
-		cur = ensureStruct(cur.Children, parts[i])
+	cur := nodeAt(s, parts)
+	if cur.Kind == KindMap {
+		return
 	}
 	cur.Kind = KindSlice
 	if cur.Elem == nil {
@@ -363,24 +919,130 @@ func markSliceStruct(s *Schema, parts []string) {
 }
 
 // markMapString は parts の最終セグメントを map[string]string として確定します。
+// range で要素が推論済みのスライスは、その要素を値とするマップにします。
 func markMapString(s *Schema, parts []string) {
 	if len(parts) == 0 {
 		return
 	}
 
+	cur := nodeAt(s, parts)
+	cur.Kind = KindMap
+	if cur.Elem == nil {
+		cur.Elem = &Field{
+			Kind: KindString, // string を既定
+		}
+	}
+	cur.Elem.Name = cur.Name + "Value"
+}
+
+// nodeAt は parts（ドット起点）が指すノードを返します。
+// 途中の Slice/Map は要素へ潜り、存在しないノードは struct として作成します。
+func nodeAt(s *Schema, parts []string) *Field {
 	if s.Fields == nil {
 		s.Fields = map[string]*Field{}
 	}
-	cur := ensureStruct(s.Fields, parts[0])
-	for i := 1; i < len(parts); i++ {
-		if cur.Children == nil {
-			cur.Children = map[string]*Field{}
+
+	m := s.Fields
+	var cur *Field
+	for i, name := range parts {
+		if i > 0 {
+			if (cur.Kind == KindSlice || cur.Kind == KindMap) && cur.Elem != nil {
+				if cur.Elem.Kind == KindString {
+					cur.Elem.Kind = KindStruct
+				}
+				cur = cur.Elem
+			}
+			if cur.Children == nil {
+				cur.Children = map[string]*Field{}
+			}
+			m = cur.Children
+		}
+		if ch := m[name]; ch != nil && (ch.Kind == KindSlice || ch.Kind == KindMap) {
+			cur = ch
+		} else {
+			cur = ensureStruct(m, name)
 		}
-		cur = ensureStruct(cur.Children, parts[i])
 	}
-	cur.Kind = KindMap
-	cur.Elem = &Field{
-		Name: cur.Name + "Value",
-		Kind: KindString, // string を既定
+
+	return cur
+}
+
+// markTemplateRef は parts の最終セグメントを、テンプレート name の型を持つフィールドとして確定します。
+func markTemplateRef(s *Schema, parts []string, name string) {
+	if len(parts) == 0 {
+		return
+	}
+
+	cur := nodeAt(s, parts)
+	*cur = Field{
+		Name:     cur.Name,
+		Kind:     KindTemplate,
+		Template: name,
+		Refs:     cur.Refs,
+	}
+}
+
+// addEmbed は現在のドットが指す構造体に、テンプレート name の型を埋め込みます。
+// range の中ではドットは要素を指すので、要素側の構造体に埋め込みます。
+func addEmbed(s *Schema, c ctx, name string) {
+	if len(c.dot) == 0 {
+		s.Embeds = appendUnique(s.Embeds, name)
+		return
+	}
+
+	cur := nodeAt(s, c.dot)
+	if c.elem && cur.Kind == KindSlice {
+		if cur.Elem == nil {
+			cur.Elem = &Field{
+				Name:     cur.Name + "Item",
+				Kind:     KindStruct,
+				Children: map[string]*Field{},
+			}
+		}
+		cur = cur.Elem
+	}
+	if cur.Kind != KindStruct {
+		return
+	}
+	cur.Embeds = appendUnique(cur.Embeds, name)
+}
+
+func appendUnique(list []string, v string) []string {
+	if slices.Contains(list, v) {
+		return list
+	}
+	return append(list, v)
+}
+
+// findField は parts（ドット起点）が指す既存ノードを返します。途中の Slice/Map は要素へ潜ります。
+// 見つからない場合は nil を返します。
+func findField(s *Schema, parts []string) *Field {
+	m := s.Fields
+	var cur *Field
+	for i, name := range parts {
+		if i > 0 {
+			if (cur.Kind == KindSlice || cur.Kind == KindMap) && cur.Elem != nil {
+				cur = cur.Elem
+			}
+			m = cur.Children
+		}
+		cur = m[name]
+		if cur == nil {
+			return nil
+		}
+	}
+	return cur
+}
+
+// setLeafType は parts が指す葉に型ヒントを設定します。
+// 型が any の場合や、既に型ヒントがある場合は変更しません（最初に現れたヒントを優先）。
+func setLeafType(s *Schema, parts []string, typ string) {
+	if typ == "any" || typ == "interface{}" {
+		return
+	}
+	f := findField(s, parts)
+	if f == nil || f.Kind != KindString || f.Type != "" {
+		return
 	}
+	f.Type = typ
 }
@@ -1,6 +1,9 @@
 package scan_test
 
 import (
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/bellwood4486/tmpltype/internal/scan"
@@ -139,6 +142,104 @@ func TestScanTemplate_IfPipeLine_fieldAndChild(t *testing.T) {
 	assertKind(t, name, scan.KindString)
 }
 
+func TestScanTemplate_DefineAndBlock_WalkedWithPassedDot(t *testing.T) {
+	src := `
+{{ define "row" }}{{ .Title }}{{ end }}
+{{ range .Items }}{{ template "row" . }}{{ end }}
+{{ block "user" .User }}{{ .Name }}{{ end }}
+`
+	sch, err := scan.ScanTemplate(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := getTop(t, sch, "Items")
+	assertKind(t, items, scan.KindSlice)
+	title := getChild(t, items.Elem, "Title")
+	assertKind(t, title, scan.KindString)
+
+	user := getTop(t, sch, "User")
+	assertKind(t, user, scan.KindStruct)
+	name := getChild(t, user, "Name")
+	assertKind(t, name, scan.KindString)
+}
+
+func TestScanTemplate_RecursiveDefine_Terminates(t *testing.T) {
+	src := `
+{{ define "tree" }}{{ .Name }}{{ range .Children }}{{ template "tree" . }}{{ end }}{{ end }}
+{{ template "tree" .Root }}
+`
+	sch, err := scan.ScanTemplate(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := getTop(t, sch, "Root")
+	name := getChild(t, root, "Name")
+	assertKind(t, name, scan.KindString)
+}
+
+func TestScanTemplateSet_FileTemplateRefAndEmbed(t *testing.T) {
+	sources := []scan.Source{
+		{Name: "header", Src: `<h1>{{ .Title }}</h1>`},
+		{Name: "page", Src: `
+{{ template "header" .Header }}
+{{ with .Sidebar }}{{ template "header" . }}{{ end }}
+{{ range .Rows }}{{ template "header" . }}{{ end }}
+{{ template "header" . }}
+`},
+	}
+	schemas, err := scan.ScanTemplateSet(sources, scan.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := schemas["page"]
+	header := getTop(t, page, "Header")
+	assertKind(t, header, scan.KindTemplate)
+	if header.Template != "header" {
+		t.Fatalf("Header.Template = %q; want %q", header.Template, "header")
+	}
+
+	sidebar := getTop(t, page, "Sidebar")
+	assertKind(t, sidebar, scan.KindStruct)
+	if len(sidebar.Embeds) != 1 || sidebar.Embeds[0] != "header" {
+		t.Fatalf("Sidebar.Embeds = %v; want [header]", sidebar.Embeds)
+	}
+
+	rows := getTop(t, page, "Rows")
+	assertKind(t, rows, scan.KindSlice)
+	if len(rows.Elem.Embeds) != 1 || rows.Elem.Embeds[0] != "header" {
+		t.Fatalf("Rows.Elem.Embeds = %v; want [header]", rows.Elem.Embeds)
+	}
+
+	if len(page.Embeds) != 1 || page.Embeds[0] != "header" {
+		t.Fatalf("page Embeds = %v; want [header]", page.Embeds)
+	}
+
+	// 呼び出し先のフィールドは呼び出し元のスキーマには現れない
+	if _, ok := page.Fields["Title"]; ok {
+		t.Fatalf("callee field Title leaked into caller schema")
+	}
+	getTop(t, schemas["header"], "Title")
+}
+
+func TestScanTemplateSet_DefineFromAnotherFile(t *testing.T) {
+	sources := []scan.Source{
+		{Name: "layout", Src: `{{ define "card" }}<div>{{ .Label }}</div>{{ end }}`},
+		{Name: "page", Src: `{{ template "card" .Card }}`},
+	}
+	schemas, err := scan.ScanTemplateSet(sources, scan.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	card := getTop(t, schemas["page"], "Card")
+	assertKind(t, card, scan.KindStruct)
+	label := getChild(t, card, "Label")
+	assertKind(t, label, scan.KindString)
+}
+
 func getTop(t *testing.T, s scan.Schema, name string) *scan.Field {
 	t.Helper()
 	f := s.Fields[name]
@@ -166,3 +267,487 @@ func assertKind(t *testing.T, got *scan.Field, want scan.Kind) {
 		t.Fatalf("kind mismatch: got=%v want=%v", got.Kind, want)
 	}
 }
+
+func TestScanTemplateSet_HTMLMode(t *testing.T) {
+	sources := []scan.Source{
+		{Name: "page", Src: `<a href="{{ .Link.URL }}">{{ .Link.Label }}</a><script>var x = {{ .Data }};</script>`},
+	}
+	schemas, err := scan.ScanTemplateSet(sources, scan.Config{HTML: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link := getTop(t, schemas["page"], "Link")
+	assertKind(t, link, scan.KindStruct)
+	url := getChild(t, link, "URL")
+	assertKind(t, url, scan.KindString)
+	data := getTop(t, schemas["page"], "Data")
+	assertKind(t, data, scan.KindString)
+}
+
+func TestScanTemplateSet_FuncArgumentTypes(t *testing.T) {
+	sources := []scan.Source{
+		{Name: "tpl", Src: `
+{{ formatDate .CreatedAt }}
+{{ .Price | price "JPY" }}
+{{ join ", " .First .Second }}
+{{ range .Items }}{{ formatDate .At }}{{ end }}
+{{ unknown .Other }}
+`},
+	}
+	cfg := scan.Config{Funcs: map[string]scan.Func{
+		"formatDate": {Params: []string{"time.Time"}},
+		"price":      {Params: []string{"string", "float64"}},
+		"join":       {Params: []string{"string", "string"}, Variadic: true},
+		"unknown":    {},
+	}}
+	schemas, err := scan.ScanTemplateSet(sources, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sch := schemas["tpl"]
+
+	assertType := func(f *scan.Field, want string) {
+		t.Helper()
+		assertKind(t, f, scan.KindString)
+		if f.Type != want {
+			t.Fatalf("%s.Type = %q; want %q", f.Name, f.Type, want)
+		}
+	}
+	assertType(getTop(t, sch, "CreatedAt"), "time.Time")
+	assertType(getTop(t, sch, "Price"), "float64")
+	assertType(getTop(t, sch, "First"), "string")
+	assertType(getTop(t, sch, "Second"), "string")
+	assertType(getChild(t, getTop(t, sch, "Items").Elem, "At"), "time.Time")
+	// シグネチャ不明の関数は型ヒントなし
+	assertType(getTop(t, sch, "Other"), "")
+}
+
+func TestScanTemplateSet_UndeclaredFunc(t *testing.T) {
+	sources := []scan.Source{{Name: "tpl", Src: `{{ formatDate .CreatedAt }}`}}
+	if _, err := scan.ScanTemplateSet(sources, scan.Config{}); err == nil {
+		t.Fatal("expected parse error for undeclared func, got nil")
+	}
+}
+
+func TestScanTemplateSet_Refs(t *testing.T) {
+	sources := []scan.Source{
+		{Name: "page", File: "templates/page.tmpl", Src: `{{ define "row" }}{{ .Title }}{{ end }}Hi {{ .User.Name }}
+{{ range .Items }}{{ template "row" . }}{{ end }}
+{{ with .User }}{{ .Email }}{{ end }}`},
+	}
+	schemas, err := scan.ScanTemplateSet(sources, scan.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sch := schemas["page"]
+
+	assertRefs := func(f *scan.Field, want ...string) {
+		t.Helper()
+		var got []string
+		for _, p := range f.Refs {
+			got = append(got, p.String())
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s.Refs = %v; want %v", f.Name, got, want)
+		}
+	}
+	user := getTop(t, sch, "User")
+	assertRefs(user, "templates/page.tmpl:1:46", "templates/page.tmpl:3:9")
+	assertRefs(getChild(t, user, "Name"), "templates/page.tmpl:1:46")
+	assertRefs(getChild(t, user, "Email"), "templates/page.tmpl:3:20")
+	items := getTop(t, sch, "Items")
+	assertRefs(items, "templates/page.tmpl:2:10")
+	// {{define}} 本体の参照は定義された位置を指す
+	assertRefs(getChild(t, items.Elem, "Title"), "templates/page.tmpl:1:22")
+}
+
+func TestScanTemplateSet_Literals(t *testing.T) {
+	sources := []scan.Source{
+		{Name: "order", File: "order.tmpl", Src: `{{ if eq .Status "shipped" }}s{{ else if ne .Status "cancelled" }}c{{ end }}
+{{ range .Items }}{{ if eq .State "a" "b" }}x{{ end }}{{ if lt .Name "m" }}{{ end }}{{ end }}`},
+	}
+	schemas, err := scan.ScanTemplateSet(sources, scan.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sch := schemas["order"]
+
+	assertLiterals := func(f *scan.Field, want ...string) {
+		t.Helper()
+		var got []string
+		for _, l := range f.Literals {
+			got = append(got, l.Value+"@"+l.Pos.String())
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s.Literals = %v; want %v", f.Name, got, want)
+		}
+	}
+	assertLiterals(getTop(t, sch, "Status"), "shipped@order.tmpl:1:18", "cancelled@order.tmpl:1:53")
+	items := getTop(t, sch, "Items")
+	assertLiterals(getChild(t, items.Elem, "State"), "a@order.tmpl:2:35", "b@order.tmpl:2:39")
+	// eq / ne 以外の比較は記録しない
+	assertLiterals(getChild(t, items.Elem, "Name"))
+}
+
+func TestScanTemplate_BuiltinTypeInference(t *testing.T) {
+	src := `
+{{ if gt .Count 10 }}many{{ end }}
+{{ if lt .Price 9.99 }}cheap{{ end }}
+{{ if eq .Status "active" }}active{{ end }}
+{{ if and .IsAdmin .IsActive }}admin{{ end }}
+{{ if not .Hidden }}shown{{ end }}
+{{ len .Items }}
+{{ index .Rows 0 }}
+{{ printf "%d years, %.1f%%, %s" .Age .Ratio .Label }}
+{{ .Score | printf "%5d" }}
+{{ if and (ge .Total 0) .User.Verified }}ok{{ end }}
+{{ if eq .Plan .DefaultPlan }}default{{ end }}{{ if eq .DefaultPlan "free" }}free{{ end }}
+{{ if eq .Mixed 1 }}{{ end }}{{ if eq .Mixed 1.5 }}{{ end }}
+{{ range .Orders }}{{ if gt .Qty 0 }}{{ .Name }}{{ end }}{{ end }}
+`
+	sch, err := scan.ScanTemplate(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertType := func(f *scan.Field, want string) {
+		t.Helper()
+		assertKind(t, f, scan.KindString)
+		if f.Type != want {
+			t.Fatalf("%s.Type = %q; want %q", f.Name, f.Type, want)
+		}
+	}
+	assertType(getTop(t, sch, "Count"), "int")
+	assertType(getTop(t, sch, "Price"), "float64")
+	assertType(getTop(t, sch, "Status"), "string")
+	assertType(getTop(t, sch, "IsAdmin"), "bool")
+	assertType(getTop(t, sch, "IsActive"), "bool")
+	assertType(getTop(t, sch, "Hidden"), "bool")
+	assertType(getTop(t, sch, "Age"), "int")
+	assertType(getTop(t, sch, "Ratio"), "float64")
+	assertType(getTop(t, sch, "Label"), "string")
+	assertType(getTop(t, sch, "Score"), "int")
+	assertType(getTop(t, sch, "Total"), "int")
+	assertType(getChild(t, getTop(t, sch, "User"), "Verified"), "bool")
+	// フィールド同士の比較は相手の型を引き継ぐ
+	assertType(getTop(t, sch, "Plan"), "string")
+	// int と float64 の競合は float64 に広げる
+	assertType(getTop(t, sch, "Mixed"), "float64")
+	orders := getTop(t, sch, "Orders")
+	assertType(getChild(t, orders.Elem, "Qty"), "int")
+
+	items := getTop(t, sch, "Items")
+	assertKind(t, items, scan.KindSlice)
+	assertKind(t, items.Elem, scan.KindString)
+	rows := getTop(t, sch, "Rows")
+	assertKind(t, rows, scan.KindSlice)
+}
+
+func TestScanTemplate_BuiltinTypeInference_LogicalOperands(t *testing.T) {
+	src := `
+{{ or .Nickname .Name }}
+{{ or .Title "Untitled" }}
+{{ and .A "yes" }}
+{{ not .Negated }}
+{{ if or .Subject "none" }}{{ end }}
+{{ if and .Limit .Limited }}{{ printf "%d" .Limit }}{{ end }}
+{{ if or (not .Closed) .Reopened }}{{ end }}
+{{ if .Enabled | not }}{{ end }}
+{{ with or .Heading .Caption }}{{ . }}{{ end }}
+`
+	sch, err := scan.ScanTemplate(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertType := func(f *scan.Field, want string) {
+		t.Helper()
+		if f.Type != want {
+			t.Fatalf("%s.Type = %q; want %q", f.Name, f.Type, want)
+		}
+	}
+	// 結果を値として使う and / or / not の引数は bool にしない
+	for _, name := range []string{"Nickname", "Name", "Title", "A", "Negated"} {
+		assertType(getTop(t, sch, name), "")
+	}
+	// 条件でも、bool 以外のリテラルやフィールドと一緒に使う引数は bool にしない
+	assertType(getTop(t, sch, "Subject"), "")
+	assertType(getTop(t, sch, "Limit"), "int")
+	assertType(getTop(t, sch, "Limited"), "")
+	// 条件に使う and / or / not の中の and / or / not と、パイプで渡した値は bool
+	assertType(getTop(t, sch, "Closed"), "bool")
+	assertType(getTop(t, sch, "Reopened"), "bool")
+	assertType(getTop(t, sch, "Enabled"), "bool")
+}
+
+func TestScanTemplate_BuiltinTypeInference_Precedence(t *testing.T) {
+	sources := []scan.Source{{Name: "tpl", Src: `
+{{ if and .At .Flag }}{{ end }}
+{{ formatDate .At }}
+{{ if .User }}{{ len .User }}{{ .User.Name }}{{ end }}
+{{ len .Items }}{{ range .Items }}{{ .Title }}{{ end }}
+`}}
+	cfg := scan.Config{Funcs: map[string]scan.Func{
+		"formatDate": {Params: []string{"time.Time"}},
+	}}
+	schemas, err := scan.ScanTemplateSet(sources, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sch := schemas["tpl"]
+
+	// 宣言された関数の引数の型は and の bool より優先
+	at := getTop(t, sch, "At")
+	if at.Type != "time.Time" {
+		t.Fatalf("At.Type = %q; want %q", at.Type, "time.Time")
+	}
+
+	// 子を持つ構造体は len でスライスにならない
+	user := getTop(t, sch, "User")
+	assertKind(t, user, scan.KindStruct)
+
+	// len でスライスと推論した後に range で要素のフィールドを参照すれば要素は構造体
+	items := getTop(t, sch, "Items")
+	assertKind(t, items, scan.KindSlice)
+	assertKind(t, items.Elem, scan.KindStruct)
+	getChild(t, items.Elem, "Title")
+}
+
+func TestScanTemplate_Variables(t *testing.T) {
+	src := `
+{{ $u := .User }}{{ $u.Name }}
+{{ range $i, $item := .Items }}{{ if $i }}, {{ end }}{{ $item.Title }}{{ $.Site }}{{ end }}
+{{ range $tag := .Tags }}{{ $tag }}{{ end }}
+{{ with $a := .Account }}{{ $a.ID }}{{ .Plan }}{{ end }}
+{{ range .Groups }}{{ $g := . }}{{ range .Members }}{{ $g.Label }}{{ .Email }}{{ end }}{{ end }}
+{{ $x := .First }}{{ $x = .Second }}{{ $x.Value }}
+{{ $n := len .Rows }}{{ $n.Ignored }}
+`
+	sch, err := scan.ScanTemplate(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := getTop(t, sch, "User")
+	assertKind(t, user, scan.KindStruct)
+	assertKind(t, getChild(t, user, "Name"), scan.KindString)
+
+	items := getTop(t, sch, "Items")
+	assertKind(t, items, scan.KindSlice)
+	assertKind(t, getChild(t, items.Elem, "Title"), scan.KindString)
+	// $ はトップレベルを指す
+	assertKind(t, getTop(t, sch, "Site"), scan.KindString)
+	if _, ok := items.Elem.Children["Site"]; ok {
+		t.Fatal("$.Site leaked into Items element")
+	}
+
+	tags := getTop(t, sch, "Tags")
+	assertKind(t, tags, scan.KindSlice)
+
+	account := getTop(t, sch, "Account")
+	getChild(t, account, "ID")
+	getChild(t, account, "Plan")
+
+	groups := getTop(t, sch, "Groups")
+	assertKind(t, groups, scan.KindSlice)
+	getChild(t, groups.Elem, "Label")
+	members := getChild(t, groups.Elem, "Members")
+	assertKind(t, members, scan.KindSlice)
+	getChild(t, members.Elem, "Email")
+
+	// 代入後の変数は新しい値を指す
+	assertKind(t, getTop(t, sch, "First"), scan.KindString)
+	getChild(t, getTop(t, sch, "Second"), "Value")
+
+	// 関数の結果を指す変数は追跡しない
+	if _, ok := sch.Fields["Ignored"]; ok {
+		t.Fatal("field of untracked variable leaked into schema")
+	}
+}
+
+func TestScanTemplate_Variables_RangeKeyAndIndex(t *testing.T) {
+	src := `
+{{ range $k, $v := .Meta }}{{ if eq $k "env" }}{{ $v }}{{ end }}{{ end }}
+{{ range $k, $v := .Sections }}{{ printf "%s" $k }}: {{ $v.Title }}{{ end }}
+{{ range $i, $v := .Rows }}{{ if gt $i 0 }}{{ $v.Name }}{{ end }}{{ end }}
+{{ range $k, $v := .Labels }}{{ $k }}={{ $v }}{{ end }}
+`
+	sch, err := scan.ScanTemplate(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// キーが文字列として使われるとマップ
+	meta := getTop(t, sch, "Meta")
+	assertKind(t, meta, scan.KindMap)
+	sections := getTop(t, sch, "Sections")
+	assertKind(t, sections, scan.KindMap)
+	getChild(t, sections.Elem, "Title")
+
+	// 添字として使われればスライスのまま
+	rows := getTop(t, sch, "Rows")
+	assertKind(t, rows, scan.KindSlice)
+	getChild(t, rows.Elem, "Name")
+
+	// 出力するだけでは添字とキーを区別できないのでスライスのまま
+	labels := getTop(t, sch, "Labels")
+	assertKind(t, labels, scan.KindSlice)
+	if len(labels.Elem.Children) > 0 {
+		t.Fatalf("Labels element has children: %v", labels.Elem.Children)
+	}
+}
+
+func TestScanTemplate_Variables_DefineHasOwnScope(t *testing.T) {
+	src := `
+{{ define "card" }}{{ $.Title }}{{ end }}
+{{ $u := .User }}{{ template "card" $u }}
+{{ range $item := .Items }}{{ template "card" $item }}{{ end }}
+`
+	sch, err := scan.ScanTemplate(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// {{define}} 本体の $ は渡されたドット
+	getChild(t, getTop(t, sch, "User"), "Title")
+	getChild(t, getTop(t, sch, "Items").Elem, "Title")
+	if _, ok := sch.Fields["Title"]; ok {
+		t.Fatal("$.Title in define body resolved to the caller's root")
+	}
+}
+
+func TestScanTemplateSet_Variables_Refs(t *testing.T) {
+	sources := []scan.Source{
+		{Name: "page", File: "page.tmpl", Src: `{{ $u := .User }}
+{{ $u.Name }}`},
+	}
+	schemas, err := scan.ScanTemplateSet(sources, scan.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := getChild(t, getTop(t, schemas["page"], "User"), "Name")
+	if len(name.Refs) != 1 || name.Refs[0].String() != "page.tmpl:2:4" {
+		t.Fatalf("User.Name.Refs = %v; want [page.tmpl:2:4]", name.Refs)
+	}
+}
+
+func TestScanTemplateSet_ParseErrorPositions(t *testing.T) {
+	sources := []scan.Source{
+		{Name: "a", File: "a.tmpl", Src: "Hello\n{{ if .X }}"},
+		{Name: "b", File: "b.tmpl", Src: "{{ .Y }}"},
+		{Name: "c", File: "c.tmpl", Src: "{{ define \"x\" }}\n\n{{ .Z ) }}{{ end }}"},
+	}
+	_, err := scan.ScanTemplateSet(sources, scan.Config{})
+	if err == nil {
+		t.Fatal("expected parse error, got nil")
+	}
+
+	// 構文エラーのあるファイルをすべて、ファイルと行の位置付きで報告する
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 errors, got:\n%v", err)
+	}
+	if !strings.HasPrefix(lines[0], "a.tmpl:2: ") {
+		t.Errorf("lines[0] = %q; want prefix a.tmpl:2", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "c.tmpl:3: ") {
+		t.Errorf("lines[1] = %q; want prefix c.tmpl:3", lines[1])
+	}
+}
+
+func TestScanTemplateSet_DuplicateDefines(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources []scan.Source
+		want    []string
+	}{
+		{
+			name: "same define in two files",
+			sources: []scan.Source{
+				{Name: "layout", File: "layout.tmpl", Src: `<main>{{ block "content" . }}{{ end }}</main>`},
+				{Name: "pagea", File: "pagea.tmpl", Src: "{{ define \"content\" }}{{ .Body }}{{ end }}"},
+				{Name: "pageb", File: "pageb.tmpl", Src: "\n{{ define \"content\" }}{{ .Title }}{{ end }}"},
+			},
+			want: []string{`pageb.tmpl:2:1: template "content" is already defined at pagea.tmpl:1:1; define it in only one file`},
+		},
+		{
+			name: "define shadows a template file",
+			sources: []scan.Source{
+				{Name: "header", File: "header.tmpl", Src: `<h1>{{ .Title }}</h1>`},
+				{Name: "page", File: "page.tmpl", Src: `{{ define "header" }}{{ .Foo }}{{ end }}{{ template "header" . }}`},
+			},
+			want: []string{`page.tmpl:1:1: {{define "header"}} shadows the template file header.tmpl; rename the definition`},
+		},
+		{
+			name: "block parsed after the define",
+			sources: []scan.Source{
+				{Name: "about", File: "about.tmpl", Src: `{{ define "content" }}{{ .Body }}{{ end }}`},
+				{Name: "layout", File: "layout.tmpl", Src: `<main>{{ block "content" . }}empty{{ end }}</main>`},
+			},
+			want: []string{`layout.tmpl:1:7: {{block "content"}} replaces the {{define "content"}} at about.tmpl:1:1 because it is parsed later; move the {{define}} to a file that sorts after this one`},
+		},
+		{
+			name: "one define overrides a block",
+			sources: []scan.Source{
+				{Name: "layout", File: "layout.tmpl", Src: `<main>{{ block "content" . }}{{ end }}</main>`},
+				{Name: "page", File: "page.tmpl", Src: `{{ define "content" }}{{ .Body }}{{ end }}`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := scan.ScanTemplateSet(tt.sources, scan.Config{})
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if got := strings.Split(err.Error(), "\n"); !slices.Equal(got, tt.want) {
+				t.Errorf("errors:\n got: %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanTemplateSet_UndefinedTemplate(t *testing.T) {
+	sources := []scan.Source{
+		{Name: "a", File: "a.tmpl", Src: `{{ define "row" }}{{ template "cell" . }}{{ end }}`},
+		{Name: "b", File: "b.tmpl", Src: "{{ if .X }}\n  {{ template \"header\" .Y }}{{ end }}{{ template \"row\" . }}"},
+	}
+	_, err := scan.ScanTemplateSet(sources, scan.Config{})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	// 呼ばれていない {{define}} の中の呼び出しも報告する
+	want := []string{
+		`a.tmpl:1:31: template "cell" is not defined`,
+		`b.tmpl:2:15: template "header" is not defined`,
+	}
+	if got := strings.Split(err.Error(), "\n"); !slices.Equal(got, want) {
+		t.Errorf("errors:\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestPos_String(t *testing.T) {
+	tests := []struct {
+		pos  scan.Pos
+		want string
+	}{
+		{scan.Pos{File: "a.tmpl", Line: 2, Col: 5}, "a.tmpl:2:5"},
+		{scan.Pos{File: "a.tmpl", Line: 2}, "a.tmpl:2"},
+		{scan.Pos{File: "a.tmpl"}, "a.tmpl"},
+		{scan.Pos{Line: 2, Col: 5}, "2:5"},
+	}
+	for _, tt := range tests {
+		if got := tt.pos.String(); got != tt.want {
+			t.Errorf("%#v.String() = %q; want %q", tt.pos, got, tt.want)
+		}
+	}
+}
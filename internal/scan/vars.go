@@ -0,0 +1,125 @@
+package scan
+
+import (
+	"maps"
+	"slices"
+
+	tplparse "text/template/parse"
+)
+
+// variable はテンプレート変数（$x）が指す値です。
+type variable struct {
+	path  []string // 値のパス（トップレベル起点）
+	elem  bool     // path のスライス/マップの要素を指す（range の値変数）
+	index bool     // range のキー/添字変数（path は range の対象）
+}
+
+// lookup は変数 name が指す値を返します。追跡できない値を指す変数は nil を返します。
+// $ は宣言されていなければトップレベル（テンプレートに渡されたデータ）を指します。
+func (c ctx) lookup(name string) *variable {
+	if v, ok := c.vars[name]; ok {
+		return v
+	}
+	if name == "$" {
+		return &variable{}
+	}
+	return nil
+}
+
+// declare は変数 name を宣言した（または代入した）新しいスコープを返します。
+// 外側のスコープの変数表は変更しません。
+func (c ctx) declare(name string, v *variable) ctx {
+	vars := maps.Clone(c.vars)
+	if vars == nil {
+		vars = make(map[string]*variable)
+	}
+	vars[name] = v
+	c.vars = vars
+	return c
+}
+
+// declare はパイプの変数宣言（{{ $x := .Foo }}, {{ $x = .Foo }}）を c に反映したスコープを返します。
+// 値がフィールドや変数そのものでなければ、変数は追跡できない値を指します。
+func (sc *scanner) declare(c ctx, p *tplparse.PipeNode) ctx {
+	if p == nil || len(p.Decl) != 1 {
+		return c
+	}
+
+	var v *variable
+	if len(p.Cmds) == 1 && len(p.Cmds[0].Args) == 1 {
+		arg := p.Cmds[0].Args[0]
+		if x, ok := arg.(*tplparse.VariableNode); ok && len(x.Ident) == 1 {
+			// $j := $i は同じ値を指す
+			v = c.lookup(x.Ident[0])
+		} else if r, ok := c.resolve(arg); ok {
+			v = &variable{path: r.path(), elem: len(r.idents) == 0 && r.base.elem}
+		}
+	}
+	return c.declare(p.Decl[0].Ident[0], v)
+}
+
+// ref は引数ノード（.Foo.Bar, $x.Foo, ., $x）が指す値です。
+type ref struct {
+	base   ctx      // 起点（ドット、または変数が指す値）
+	idents []string // 起点からのフィールド名
+}
+
+// path は値のトップレベル起点のパスを返します。
+func (r ref) path() []string {
+	return append(slices.Clone(r.base.dot), r.idents...)
+}
+
+// target は値をドットとするスコープを返します（{{ with }} や {{ template }} で渡す先）。
+func (r ref) target() ctx {
+	if len(r.idents) == 0 {
+		return r.base
+	}
+	return r.base.with(r.idents)
+}
+
+// resolve は引数ノードが指す値を返します。
+// 追跡できない値（関数の結果、range のキー変数など）は ok = false を返します。
+func (c ctx) resolve(n tplparse.Node) (ref, bool) {
+	switch x := n.(type) {
+	case *tplparse.FieldNode:
+		return ref{base: c, idents: x.Ident}, true
+	case *tplparse.DotNode:
+		return ref{base: c}, true
+	case *tplparse.VariableNode:
+		v := c.lookup(x.Ident[0])
+		if v == nil || v.index {
+			return ref{}, false
+		}
+		base := ctx{dot: v.path, elem: v.elem, vars: c.vars}
+		return ref{base: base, idents: x.Ident[1:]}, true
+	}
+	return ref{}, false
+}
+
+// fieldPath は引数ノードが指すフィールドのパスを返します。
+// トップレベルそのもの（., $）、range の要素、追跡できない値は ok = false を返します。
+func (c ctx) fieldPath(n tplparse.Node) ([]string, bool) {
+	switch n.(type) {
+	case *tplparse.FieldNode, *tplparse.VariableNode:
+	default:
+		return nil, false
+	}
+	r, ok := c.resolve(n)
+	if !ok || len(r.path()) == 0 || (len(r.idents) == 0 && r.base.elem) {
+		// range の値変数そのもの（$v）は要素を指すので、フィールドとしては扱わない
+		return nil, false
+	}
+	return r.path(), true
+}
+
+// rangeKey は引数ノードが range のキー/添字変数（$i）そのものなら、その変数を返します。
+func (c ctx) rangeKey(n tplparse.Node) *variable {
+	x, ok := n.(*tplparse.VariableNode)
+	if !ok || len(x.Ident) != 1 {
+		return nil
+	}
+	if v := c.lookup(x.Ident[0]); v != nil && v.index {
+		return v
+	}
+	return nil
+}
@@ -1,10 +1,10 @@
 // Package typing はスキャン結果から最終的な型を解決します。
 //
 // このパッケージは以下の処理を行います:
-//   1. デフォルト型推論 (scan パッケージの結果から)
-//   2. @param ディレクティブによる型オーバーライド (magic パッケージを使用)
-//   3. 名前付き型の抽出
-//   4. 必要なimportの収集
+//  1. デフォルト型推論 (scan パッケージの結果から)
+//  2. @param ディレクティブによる型オーバーライド (magic パッケージを使用)
+//  3. 名前付き型の抽出と @tag / @required / @optional / @enum / @default / @doc ディレクティブの設定
+//  4. 必要なimportの収集
 //
 // 最終的に TypedSchema を生成し、コード生成に必要な情報を提供します。
 package typing
@@ -0,0 +1,90 @@
+package typing
+
+import (
+	"errors"
+	"maps"
+	"regexp"
+	"slices"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+	"github.com/bellwood4486/tmpltype/internal/typing/magic"
+)
+
+// WellKnownImports は @import なしで使えるパッケージ修飾子と import パスの対応
+var WellKnownImports = map[string]string{
+	"time":     "time",
+	"url":      "net/url",
+	"template": "html/template",
+	"json":     "encoding/json",
+}
+
+// qualifierRegex は型文字列中の "pkg.Type" の pkg 部分にマッチする
+var qualifierRegex = regexp.MustCompile(`\b([A-Za-z_][A-Za-z0-9_]*)\.[A-Za-z_]`)
+
+// qualifiers は型文字列に現れるパッケージ修飾子を返す
+// 例: "map[string]time.Time" -> ["time"]
+func qualifiers(goType string) []string {
+	var qs []string
+	for _, m := range qualifierRegex.FindAllStringSubmatch(goType, -1) {
+		qs = append(qs, m[1])
+	}
+	return qs
+}
+
+// collectImports は解決済みの型に現れるパッケージ修飾子を集め、import パスに対応付ける
+// 対応付けの優先順位は @import ディレクティブ、known（FuncMap のパッケージなど）、WellKnownImports の順
+func collectImports(typed *TypedSchema, directives []magic.ImportDirective, known map[string]string) error {
+	var errs []error
+	declared := make(map[string]string)
+	declaredPos := make(map[string]scan.Pos)
+	for _, d := range directives {
+		pos := scan.Pos{Line: d.Line, Col: d.Col}
+		if prev, ok := declared[d.Alias]; ok {
+			if prev != d.Path {
+				errs = append(errs, scan.Errorf(pos, "@import alias %q is already used for %q", d.Alias, prev))
+			}
+			continue
+		}
+		declared[d.Alias] = d.Path
+		declaredPos[d.Alias] = pos
+	}
+
+	// 使われている修飾子を型ごとに集める（エラーメッセージ用にフィールドも保持）
+	used := make(map[string]*TypedField) // 修飾子 -> 最初に現れたフィールド
+	var collect func(fields map[string]*TypedField)
+	collect = func(fields map[string]*TypedField) {
+		for _, name := range slices.Sorted(maps.Keys(fields)) {
+			f := fields[name]
+			for _, q := range qualifiers(f.GoType) {
+				if _, ok := used[q]; !ok {
+					used[q] = f
+				}
+			}
+			collect(f.Children)
+		}
+	}
+	collect(typed.Fields)
+	for _, nt := range typed.NamedTypes {
+		collect(nt.Fields)
+	}
+
+	typed.Imports = make(map[string]string, len(used))
+	typed.ImportPos = make(map[string]scan.Pos, len(used))
+	for _, q := range slices.Sorted(maps.Keys(used)) {
+		typed.ImportPos[q] = used[q].Pos
+		switch {
+		case declared[q] != "":
+			typed.Imports[q] = declared[q]
+			typed.ImportPos[q] = declaredPos[q]
+		case known[q] != "":
+			typed.Imports[q] = known[q]
+		case WellKnownImports[q] != "":
+			typed.Imports[q] = WellKnownImports[q]
+		default:
+			f := used[q]
+			errs = append(errs, scan.Errorf(f.Pos, "unknown package qualifier %q in type %q (declare it with {{/* @import %s \"import/path\" */}})", q, f.GoType, q))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,51 @@
+package magic
+
+import (
+	"regexp"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+)
+
+// DefaultDirective は @default ディレクティブを表す
+type DefaultDirective struct {
+	Path  string // 例: "SiteName"
+	Value string // Go の定数式（例: `"Acme"`, "10", "true"）
+	Line  int    // テンプレート内の行番号
+	Col   int    // テンプレート内の列番号（ディレクティブの開始位置）
+	VCol  int    // 値の列番号
+}
+
+var defaultRegex = regexp.MustCompile(`\{\{/\*\s*@default\s+(\S+)(?:\s+(.*?))?\s*\*/\}\}`)
+
+// ParseDefaultDirectives はテンプレートソースから @default ディレクティブを抽出する
+// 同じパスへの @default が複数ある場合、値がない場合はエラー
+// 値の型検査はフィールドの型が決まってから行う
+//
+// 形式:
+//
+//	{{/* @default SiteName "Acme" */}}
+//	{{/* @default Page.Size 20 */}}
+func ParseDefaultDirectives(src string) ([]DefaultDirective, error) {
+	var directives []DefaultDirective
+	seen := make(map[string]int) // パス -> 最初の @default の行番号
+
+	for _, m := range findDirectives(defaultRegex, src) {
+		path := m.groups[1]
+		if line, ok := seen[path]; ok {
+			return nil, scan.Errorf(m.pos(0), "duplicate @default directive for %s (first declared at line %d)", path, line)
+		}
+		seen[path] = m.line
+		if m.groups[2] == "" {
+			return nil, scan.Errorf(m.pos(0), "@default %s has no value", path)
+		}
+		directives = append(directives, DefaultDirective{
+			Path:  path,
+			Value: m.groups[2],
+			Line:  m.line,
+			Col:   m.cols[0],
+			VCol:  m.cols[2],
+		})
+	}
+
+	return directives, nil
+}
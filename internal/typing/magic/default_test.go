@@ -0,0 +1,39 @@
+package magic
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDefaultDirectives(t *testing.T) {
+	src := `{{/* @default SiteName "Acme Corp" */}}
+{{/* @default Page.Size 20 */}}{{/* @default Debug true*/}}
+{{ .SiteName }}`
+	ds, err := ParseDefaultDirectives(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DefaultDirective{
+		{Path: "SiteName", Value: `"Acme Corp"`, Line: 1, Col: 1, VCol: 24},
+		{Path: "Page.Size", Value: "20", Line: 2, Col: 1, VCol: 25},
+		{Path: "Debug", Value: "true", Line: 2, Col: 32, VCol: 52},
+	}
+	if !reflect.DeepEqual(ds, want) {
+		t.Fatalf("got %+v, want %+v", ds, want)
+	}
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"{{/* @default A 1 */}}\n{{/* @default A 2 */}}", "2:1: duplicate @default directive for A (first declared at line 1)"},
+		{"{{/* @default A */}}", "1:1: @default A has no value"},
+	}
+	for _, tt := range tests {
+		_, err := ParseDefaultDirectives(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseDefaultDirectives(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
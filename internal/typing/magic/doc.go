@@ -4,8 +4,56 @@
 //   - テンプレート内の @param ディレクティブの抽出
 //   - 型表現のパース (基本型、スライス、マップ、ポインタ、構造体)
 //   - 型オーバーライドの管理
+//   - テンプレート関数を宣言する @func ディレクティブの抽出
+//   - 型で使うパッケージを宣言する @import ディレクティブの抽出
+//   - テンプレートを既存の Go 型に結び付ける @type ディレクティブの抽出
+//   - テンプレート名を上書きする @name ディレクティブの抽出
+//   - フィールドに構造体タグを付ける @tag ディレクティブの抽出
+//   - 必須のフィールドを指定する @required / @optional ディレクティブの抽出
+//   - フィールドの値を列挙する @enum ディレクティブの抽出
+//   - フィールドの既定値を指定する @default ディレクティブの抽出
+//   - フィールドの説明を書く @doc ディレクティブと、テンプレート先頭のコメント（テンプレートの説明）の抽出
 //
 // @param ディレクティブの形式:
-//   {{/* @param User.Age int */}}
-//   {{/* @param Items []struct{ID int; Name string} */}}
+//
+//	{{/* @param User.Age int */}}
+//	{{/* @param Items []struct{ID int; Name string} */}}
+//
+// @func ディレクティブの形式:
+//
+//	{{/* @func formatDate func(time.Time) string */}}
+//
+// @import ディレクティブの形式:
+//
+//	{{/* @import decimal "github.com/shopspring/decimal" */}}
+//
+// @type ディレクティブの形式:
+//
+//	{{/* @type github.com/acme/app/domain.OrderView */}}
+//
+// @name ディレクティブの形式:
+//
+//	{{/* @name user_list_v2 */}}
+//
+// @tag ディレクティブの形式:
+//
+//	{{/* @tag User.Email json:"email" validate:"required,email" */}}
+//
+// @required / @optional ディレクティブの形式:
+//
+//	{{/* @required User.Email */}}
+//	{{/* @optional User.Nickname */}}
+//
+// @enum ディレクティブの形式:
+//
+//	{{/* @enum Status shipped|cancelled|pending */}}
+//	{{/* @enum Status */}}
+//
+// @default ディレクティブの形式:
+//
+//	{{/* @default SiteName "Acme" */}}
+//
+// @doc ディレクティブの形式:
+//
+//	{{/* @doc Footer.Year copyright year shown in the footer */}}
 package magic
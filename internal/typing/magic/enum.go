@@ -0,0 +1,60 @@
+package magic
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+)
+
+// EnumDirective は @enum ディレクティブを表す
+type EnumDirective struct {
+	Path   string   // 例: "Status"
+	Values []string // 値（記述順）。空ならテンプレートで比較しているリテラルから推論する
+	Line   int      // テンプレート内の行番号
+	Col    int      // テンプレート内の列番号（ディレクティブの開始位置）
+}
+
+var enumRegex = regexp.MustCompile(`\{\{/\*\s*@enum\s+(\S+)(?:\s+(\S+))?\s*\*/\}\}`)
+
+// ParseEnumDirectives はテンプレートソースから @enum ディレクティブを抽出する
+// 同じパスへの @enum が複数ある場合、値が空または重複している場合はエラー
+//
+// 形式:
+//
+//	{{/* @enum Status shipped|cancelled|pending */}}
+//	{{/* @enum Status */}}（eq / ne で比較している文字列リテラルを値にする）
+func ParseEnumDirectives(src string) ([]EnumDirective, error) {
+	var directives []EnumDirective
+	seen := make(map[string]int) // パス -> 最初の @enum の行番号
+
+	for _, m := range findDirectives(enumRegex, src) {
+		path := m.groups[1]
+		if line, ok := seen[path]; ok {
+			return nil, scan.Errorf(m.pos(0), "duplicate @enum directive for %s (first declared at line %d)", path, line)
+		}
+		seen[path] = m.line
+
+		var values []string
+		if m.groups[2] != "" {
+			values = strings.Split(m.groups[2], "|")
+			for i, v := range values {
+				if v == "" {
+					return nil, scan.Errorf(m.pos(2), "empty value in @enum %s", path)
+				}
+				if slices.Contains(values[:i], v) {
+					return nil, scan.Errorf(m.pos(2), "duplicate value %q in @enum %s", v, path)
+				}
+			}
+		}
+		directives = append(directives, EnumDirective{
+			Path:   path,
+			Values: values,
+			Line:   m.line,
+			Col:    m.cols[0],
+		})
+	}
+
+	return directives, nil
+}
@@ -0,0 +1,39 @@
+package magic
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEnumDirectives(t *testing.T) {
+	src := `{{/* @enum Status shipped|cancelled|pending */}}
+{{/* @enum Order.Kind */}}
+{{ .Status }}`
+	ds, err := ParseEnumDirectives(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []EnumDirective{
+		{Path: "Status", Values: []string{"shipped", "cancelled", "pending"}, Line: 1, Col: 1},
+		{Path: "Order.Kind", Line: 2, Col: 1},
+	}
+	if !reflect.DeepEqual(ds, want) {
+		t.Fatalf("got %+v, want %+v", ds, want)
+	}
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"{{/* @enum A x|y */}}\n{{/* @enum A z */}}", "2:1: duplicate @enum directive for A (first declared at line 1)"},
+		{"{{/* @enum A x||y */}}", "1:14: empty value in @enum A"},
+		{"{{/* @enum A x|y|x */}}", `1:14: duplicate value "x" in @enum A`},
+	}
+	for _, tt := range tests {
+		_, err := ParseEnumDirectives(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseEnumDirectives(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package magic
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+)
+
+// DocDirective は @doc ディレクティブを表す
+type DocDirective struct {
+	Path string // 例: "Footer.Year"
+	Text string // 説明（生成コードのドキュメントコメントになる）
+	Line int    // テンプレート内の行番号
+	Col  int    // テンプレート内の列番号（ディレクティブの開始位置）
+}
+
+var docRegex = regexp.MustCompile(`\{\{/\*\s*@doc\s+(\S+)(?:\s+(.*?))?\s*\*/\}\}`)
+
+// ParseDocDirectives はテンプレートソースから @doc ディレクティブを抽出する
+// 同じパスへの @doc が複数ある場合、説明がない場合はエラー
+//
+// 形式:
+//
+//	{{/* @doc Footer.Year copyright year shown in the footer */}}
+func ParseDocDirectives(src string) ([]DocDirective, error) {
+	var directives []DocDirective
+	seen := make(map[string]int) // パス -> 最初の @doc の行番号
+
+	for _, m := range findDirectives(docRegex, src) {
+		path := m.groups[1]
+		if line, ok := seen[path]; ok {
+			return nil, scan.Errorf(m.pos(0), "duplicate @doc directive for %s (first declared at line %d)", path, line)
+		}
+		seen[path] = m.line
+		if m.groups[2] == "" {
+			return nil, scan.Errorf(m.pos(0), "@doc %s has no text", path)
+		}
+		directives = append(directives, DocDirective{
+			Path: path,
+			Text: m.groups[2],
+			Line: m.line,
+			Col:  m.cols[0],
+		})
+	}
+
+	return directives, nil
+}
+
+// leadingCommentRegex はテンプレート先頭のコメント（{{/* ... */}}、{{- /* ... */ -}}）にマッチする
+var leadingCommentRegex = regexp.MustCompile(`(?s)^\{\{(?:- )?/\*(.*?)\*/(?: -)?\}\}`)
+
+// ParseDescription はテンプレート先頭のコメントからテンプレートの説明を抽出する（なければ空）
+// 先頭に並ぶコメントのうちディレクティブ（@ で始まる）以外の最初のものを使う
+// 各行の前後の空白と、前後の空行は取り除く
+//
+// 形式:
+//
+//	{{/*
+//	  Welcome email sent right after sign-up.
+//	*/}}
+func ParseDescription(src string) string {
+	for {
+		src = strings.TrimLeft(src, " \t\r\n")
+		m := leadingCommentRegex.FindStringSubmatch(src)
+		if m == nil {
+			return ""
+		}
+		src = src[len(m[0]):]
+
+		text := strings.TrimSpace(m[1])
+		if text == "" || strings.HasPrefix(text, "@") {
+			continue
+		}
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimSpace(line)
+		}
+		return strings.Join(lines, "\n")
+	}
+}
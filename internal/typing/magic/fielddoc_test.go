@@ -0,0 +1,60 @@
+package magic
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDocDirectives(t *testing.T) {
+	src := `{{/* @doc Footer.Year copyright year shown in the footer */}}
+{{/* @doc Title  Subject line */}}
+{{ .Title }}`
+	ds, err := ParseDocDirectives(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DocDirective{
+		{Path: "Footer.Year", Text: "copyright year shown in the footer", Line: 1, Col: 1},
+		{Path: "Title", Text: "Subject line", Line: 2, Col: 1},
+	}
+	if !reflect.DeepEqual(ds, want) {
+		t.Fatalf("got %+v, want %+v", ds, want)
+	}
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"{{/* @doc A x */}}\n{{/* @doc A y */}}", "2:1: duplicate @doc directive for A (first declared at line 1)"},
+		{"{{/* @doc A */}}", "1:1: @doc A has no text"},
+	}
+	for _, tt := range tests {
+		_, err := ParseDocDirectives(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseDocDirectives(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestParseDescription(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"single line", "{{/* Welcome email. */}}\nHello", "Welcome email."},
+		{"multi line", "{{/*\n  Welcome email.\n\n  Sent after sign-up.\n*/}}\nHello", "Welcome email.\n\nSent after sign-up."},
+		{"after directives", "{{/* @param Age int */}}\n{{- /* @doc Age user age */ -}}\n\n{{- /* Profile card. */ -}}\n{{ .Age }}", "Profile card."},
+		{"not at the top", "Hello\n{{/* Welcome email. */}}", ""},
+		{"directives only", "{{/* @param Age int */}}{{ .Age }}", ""},
+		{"empty comment", "{{/* */}}{{/* Card. */}}", "Card."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseDescription(tt.src); got != tt.want {
+				t.Errorf("ParseDescription() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package magic
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"regexp"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+)
+
+// FuncDirective は @func ディレクティブを表す
+type FuncDirective struct {
+	Name     string   // 関数名（例: "formatDate"）
+	Params   []string // 引数の Go 型（例: ["time.Time"]）
+	Variadic bool     // 最後の引数が可変長（Params の最後は要素型）
+	Line     int      // テンプレート内の行番号
+	Col      int      // テンプレート内の列番号（ディレクティブの開始位置）
+}
+
+var funcRegex = regexp.MustCompile(`\{\{/\*\s*@func\s+(\S+)\s+(.+?)\s*\*/\}\}`)
+
+// ParseFuncs はテンプレートソースから @func ディレクティブを抽出する
+//
+// 形式:
+//
+//	{{/* @func formatDate func(time.Time) string */}}
+//	{{/* @func join func(string, ...string) string */}}
+func ParseFuncs(src string) ([]FuncDirective, error) {
+	var directives []FuncDirective
+
+	for _, m := range findDirectives(funcRegex, src) {
+		sig := m.groups[2]
+		params, variadic, err := ParseFuncSignature(sig)
+		if err != nil {
+			return nil, scan.Errorf(m.pos(2), "invalid func signature %q: %v", sig, err)
+		}
+
+		directives = append(directives, FuncDirective{
+			Name:     m.groups[1],
+			Params:   params,
+			Variadic: variadic,
+			Line:     m.line,
+			Col:      m.cols[0],
+		})
+	}
+
+	return directives, nil
+}
+
+// ParseFuncSignature は "func(time.Time) string" 形式のシグネチャをパースし、引数の型を返す
+// テンプレート関数の制約に従い、戻り値は1つ、または2つ（2つ目は error）でなければならない
+func ParseFuncSignature(sig string) ([]string, bool, error) {
+	expr, err := parser.ParseExpr(sig)
+	if err != nil {
+		return nil, false, err
+	}
+	ft, ok := expr.(*ast.FuncType)
+	if !ok {
+		return nil, false, fmt.Errorf("not a func type")
+	}
+	if err := checkFuncResults(ft); err != nil {
+		return nil, false, err
+	}
+
+	params, variadic := FuncParams(ft)
+	return params, variadic, nil
+}
+
+// FuncParams は関数型の引数の型を文字列で返す
+// 可変長引数の場合は要素型を最後に入れ、variadic を true にする
+func FuncParams(ft *ast.FuncType) (params []string, variadic bool) {
+	if ft.Params == nil {
+		return nil, false
+	}
+	for _, field := range ft.Params.List {
+		typ := field.Type
+		if ell, ok := typ.(*ast.Ellipsis); ok {
+			typ = ell.Elt
+			variadic = true
+		}
+		// func(a, b string) のように名前付きの場合は名前の数だけ引数がある
+		n := max(len(field.Names), 1)
+		for range n {
+			params = append(params, types.ExprString(typ))
+		}
+	}
+	return params, variadic
+}
+
+func checkFuncResults(ft *ast.FuncType) error {
+	var results []string
+	if ft.Results != nil {
+		for _, field := range ft.Results.List {
+			n := max(len(field.Names), 1)
+			for range n {
+				results = append(results, types.ExprString(field.Type))
+			}
+		}
+	}
+
+	switch {
+	case len(results) == 1:
+		return nil
+	case len(results) == 2 && results[1] == "error":
+		return nil
+	default:
+		return fmt.Errorf("must return one value, or one value and an error")
+	}
+}
@@ -0,0 +1,53 @@
+package magic
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseFuncs(t *testing.T) {
+	src := `
+{{/* @func formatDate func(time.Time) string */}}
+{{/* @func join func(sep string, parts ...string) (string, error) */}}
+{{ formatDate .CreatedAt }}
+`
+	directives, err := ParseFuncs(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(directives) != 2 {
+		t.Fatalf("expected 2 directives, got %d", len(directives))
+	}
+
+	if directives[0].Name != "formatDate" || !slices.Equal(directives[0].Params, []string{"time.Time"}) || directives[0].Variadic {
+		t.Errorf("unexpected directive: %+v", directives[0])
+	}
+	if directives[0].Line != 2 {
+		t.Errorf("Line = %d, want 2", directives[0].Line)
+	}
+
+	if directives[1].Name != "join" || !slices.Equal(directives[1].Params, []string{"string", "string"}) || !directives[1].Variadic {
+		t.Errorf("unexpected directive: %+v", directives[1])
+	}
+}
+
+func TestParseFuncs_InvalidSignature(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"not a func", `{{/* @func f string */}}`},
+		{"no result", `{{/* @func f func(string) */}}`},
+		{"second result not error", `{{/* @func f func(string) (string, bool) */}}`},
+		{"syntax error", `{{/* @func f func(string */}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseFuncs(tt.src); err == nil {
+				t.Errorf("expected error for %s, got nil", tt.src)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package magic
+
+import (
+	"go/token"
+	"path"
+	"regexp"
+	"strconv"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+)
+
+// ImportDirective は @import ディレクティブを表す
+type ImportDirective struct {
+	Alias string // 型で使う修飾子（例: "decimal"）
+	Path  string // import パス（例: "github.com/shopspring/decimal"）
+	Line  int    // テンプレート内の行番号
+	Col   int    // テンプレート内の列番号（ディレクティブの開始位置）
+}
+
+var importRegex = regexp.MustCompile(`\{\{/\*\s*@import\s+(?:(\S+)\s+)?("[^"]*")\s*\*/\}\}`)
+
+// ParseImports はテンプレートソースから @import ディレクティブを抽出する
+//
+// 形式:
+//
+//	{{/* @import decimal "github.com/shopspring/decimal" */}}
+//	{{/* @import "github.com/acme/app/domain" */}}
+//
+// 別名を省略した場合は import パスの最後の要素を修飾子とする
+func ParseImports(src string) ([]ImportDirective, error) {
+	var directives []ImportDirective
+
+	for _, m := range findDirectives(importRegex, src) {
+		importPath, err := strconv.Unquote(m.groups[2])
+		if err != nil || importPath == "" {
+			return nil, scan.Errorf(m.pos(2), "invalid import path %s", m.groups[2])
+		}
+
+		alias := m.groups[1]
+		if alias == "" {
+			alias = path.Base(importPath)
+		}
+		if !token.IsIdentifier(alias) || alias == "_" {
+			return nil, scan.Errorf(m.pos(0), "invalid import alias %q", alias)
+		}
+
+		directives = append(directives, ImportDirective{
+			Alias: alias,
+			Path:  importPath,
+			Line:  m.line,
+			Col:   m.cols[0],
+		})
+	}
+
+	return directives, nil
+}
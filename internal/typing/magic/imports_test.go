@@ -0,0 +1,49 @@
+package magic
+
+import (
+	"testing"
+)
+
+func TestParseImports(t *testing.T) {
+	src := `
+{{/* @import decimal "github.com/shopspring/decimal" */}}
+{{/* @import "github.com/acme/app/domain" */}}
+{{/* @param Price decimal.Decimal */}}
+`
+	directives, err := ParseImports(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(directives) != 2 {
+		t.Fatalf("expected 2 directives, got %d", len(directives))
+	}
+
+	if directives[0].Alias != "decimal" || directives[0].Path != "github.com/shopspring/decimal" || directives[0].Line != 2 {
+		t.Errorf("unexpected directive: %+v", directives[0])
+	}
+
+	// 別名省略時はパスの最後の要素
+	if directives[1].Alias != "domain" || directives[1].Path != "github.com/acme/app/domain" {
+		t.Errorf("unexpected directive: %+v", directives[1])
+	}
+}
+
+func TestParseImports_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"empty path", `{{/* @import x "" */}}`},
+		{"invalid alias", `{{/* @import 1x "example.com/x" */}}`},
+		{"blank alias", `{{/* @import _ "example.com/x" */}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseImports(tt.src); err == nil {
+				t.Errorf("expected error for %s, got nil", tt.src)
+			}
+		})
+	}
+}
@@ -1,9 +1,10 @@
 package magic
 
 import (
-	"fmt"
 	"regexp"
 	"strings"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
 )
 
 // TypeKind は型表現の種類を表す
@@ -36,6 +37,7 @@ type ParamDirective struct {
 	Path string   // 例: "User.Age"
 	Type TypeExpr // パース済みの型
 	Line int      // テンプレート内の行番号
+	Col  int      // テンプレート内の列番号（ディレクティブの開始位置）
 }
 
 var paramRegex = regexp.MustCompile(`\{\{/\*\s*@param\s+(\S+)\s+(.+?)\s*\*/\}\}`)
@@ -44,34 +46,53 @@ var paramRegex = regexp.MustCompile(`\{\{/\*\s*@param\s+(\S+)\s+(.+?)\s*\*/\}\}`
 func ParseParams(src string) ([]ParamDirective, error) {
 	var directives []ParamDirective
 
-	lines := strings.Split(src, "\n")
-	lineNum := 0
+	for _, m := range findDirectives(paramRegex, src) {
+		typeStr := m.groups[2]
+		typeExpr, err := parseType(typeStr)
+		if err != nil {
+			return nil, scan.Errorf(m.pos(2), "invalid type expression %q: %v", typeStr, err)
+		}
 
-	for _, line := range lines {
-		lineNum++
-		matches := paramRegex.FindAllStringSubmatch(line, -1)
+		directives = append(directives, ParamDirective{
+			Path: m.groups[1],
+			Type: typeExpr,
+			Line: m.line,
+			Col:  m.cols[0],
+		})
+	}
 
-		for _, match := range matches {
-			if len(match) != 3 {
-				continue
-			}
+	return directives, nil
+}
 
-			path := match[1]
-			typeStr := match[2]
+// directiveMatch はテンプレート内のディレクティブ1件のマッチを表す
+type directiveMatch struct {
+	groups []string // サブマッチ（0 はディレクティブ全体）
+	cols   []int    // 各サブマッチの開始列（1始まり、マッチしなかったサブマッチは 0）
+	line   int      // 行番号
+}
 
-			typeExpr, err := parseType(typeStr)
-			if err != nil {
-				return nil, fmt.Errorf("line %d: invalid type expression %q: %w", lineNum, typeStr, err)
-			}
+// pos はサブマッチ i の位置を返す（ファイル名は呼び出し側で補う）
+func (m directiveMatch) pos(i int) scan.Pos {
+	return scan.Pos{Line: m.line, Col: m.cols[i]}
+}
 
-			directives = append(directives, ParamDirective{
-				Path: path,
-				Type: typeExpr,
-				Line: lineNum,
-			})
+// findDirectives は src から re にマッチするディレクティブを出現順に返す
+func findDirectives(re *regexp.Regexp, src string) []directiveMatch {
+	var matches []directiveMatch
+	for i, line := range strings.Split(src, "\n") {
+		for _, loc := range re.FindAllStringSubmatchIndex(line, -1) {
+			m := directiveMatch{line: i + 1}
+			for j := 0; j < len(loc); j += 2 {
+				if loc[j] < 0 {
+					m.groups = append(m.groups, "")
+					m.cols = append(m.cols, 0)
+					continue
+				}
+				m.groups = append(m.groups, line[loc[j]:loc[j+1]])
+				m.cols = append(m.cols, loc[j]+1)
+			}
+			matches = append(matches, m)
 		}
 	}
-
-	return directives, nil
+	return matches
 }
-
@@ -1,7 +1,10 @@
 package magic
 
 import (
+	"errors"
 	"testing"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
 )
 
 func TestParseParams_Simple(t *testing.T) {
@@ -127,3 +130,53 @@ func TestTypeResolver_SliceType(t *testing.T) {
 		t.Errorf("expected Tags to be '[]string', got %s", typ)
 	}
 }
+
+func TestParseDirectives_Positions(t *testing.T) {
+	src := "Hello\n  {{/* @param User.Age int */}} {{/* @func fmt func(int) string */}}\n\t{{/* @import \"x/y\" */}}{{/* @type T */}}"
+
+	params, err := ParseParams(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params[0].Line != 2 || params[0].Col != 3 {
+		t.Errorf("@param at %d:%d, want 2:3", params[0].Line, params[0].Col)
+	}
+
+	funcs, err := ParseFuncs(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if funcs[0].Line != 2 || funcs[0].Col != 33 {
+		t.Errorf("@func at %d:%d, want 2:33", funcs[0].Line, funcs[0].Col)
+	}
+
+	imports, err := ParseImports(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imports[0].Line != 3 || imports[0].Col != 2 {
+		t.Errorf("@import at %d:%d, want 3:2", imports[0].Line, imports[0].Col)
+	}
+
+	typ, err := ParseTypeDirective(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ.Line != 3 || typ.Col != 25 {
+		t.Errorf("@type at %d:%d, want 3:25", typ.Line, typ.Col)
+	}
+}
+
+func TestParseParams_ErrorPosition(t *testing.T) {
+	_, err := ParseParams("\n {{/* @param Items struct{Field */}}")
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	var pe *scan.Error
+	if !errors.As(err, &pe) {
+		t.Fatalf("error should be *scan.Error: %T", err)
+	}
+	if pe.Pos.Line != 2 || pe.Pos.Col != 20 {
+		t.Errorf("error at %s, want 2:20", pe.Pos)
+	}
+}
@@ -0,0 +1,45 @@
+package magic
+
+import (
+	"regexp"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+)
+
+// NameDirective は @name ディレクティブを表す
+type NameDirective struct {
+	Name string // テンプレート名（グループ内のテンプレートならグループを除いた名前）
+	Line int    // テンプレート内の行番号
+	Col  int    // テンプレート内の列番号（ディレクティブの開始位置）
+}
+
+var nameRegex = regexp.MustCompile(`\{\{/\*\s*@name\s+(\S+)\s*\*/\}\}`)
+
+// validNameRegex は @name に指定できる名前にマッチする（ファイル名から作る名前と同じ文字種）
+var validNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// ParseNameDirective はテンプレートソースから @name ディレクティブを抽出する
+// ディレクティブがなければ nil を返す。1つのテンプレートに複数ある場合はエラー
+//
+// 形式:
+//
+//	{{/* @name user_list_v2 */}}
+func ParseNameDirective(src string) (*NameDirective, error) {
+	var directive *NameDirective
+
+	for _, m := range findDirectives(nameRegex, src) {
+		if directive != nil {
+			return nil, scan.Errorf(m.pos(0), "duplicate @name directive (first declared at line %d)", directive.Line)
+		}
+		if !validNameRegex.MatchString(m.groups[1]) {
+			return nil, scan.Errorf(m.pos(1), "invalid @name %q (use letters, digits and underscores, starting with a letter)", m.groups[1])
+		}
+		directive = &NameDirective{
+			Name: m.groups[1],
+			Line: m.line,
+			Col:  m.cols[0],
+		}
+	}
+
+	return directive, nil
+}
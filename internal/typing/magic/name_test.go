@@ -0,0 +1,44 @@
+package magic
+
+import (
+	"testing"
+)
+
+func TestParseNameDirective(t *testing.T) {
+	src := `
+{{/* @name user_list_v2 */}}
+{{ .ID }}
+`
+	d, err := ParseNameDirective(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d == nil || d.Name != "user_list_v2" || d.Line != 2 || d.Col != 1 {
+		t.Fatalf("unexpected directive: %+v", d)
+	}
+
+	// ディレクティブなし
+	d, err = ParseNameDirective(`{{ .ID }}`)
+	if err != nil || d != nil {
+		t.Fatalf("expected nil directive, got %+v, %v", d, err)
+	}
+}
+
+func TestParseNameDirective_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"duplicate", "{{/* @name a */}}\n{{/* @name b */}}"},
+		{"slash", "{{/* @name mail/invite */}}"},
+		{"hyphen", "{{/* @name user-list */}}"},
+		{"leading digit", "{{/* @name 1user */}}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseNameDirective(tt.src); err == nil {
+				t.Fatal("expected error, got nil")
+			}
+		})
+	}
+}
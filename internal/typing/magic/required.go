@@ -0,0 +1,53 @@
+package magic
+
+import (
+	"regexp"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+)
+
+// RequirementDirective は @required / @optional ディレクティブを表す
+type RequirementDirective struct {
+	Path     string // 例: "User.Email"
+	Required bool   // @required なら true、@optional なら false
+	Line     int    // テンプレート内の行番号
+	Col      int    // テンプレート内の列番号（ディレクティブの開始位置）
+}
+
+var requirementRegex = regexp.MustCompile(`\{\{/\*\s*@(required|optional)\s+(\S+)\s*\*/\}\}`)
+
+// ParseRequirementDirectives はテンプレートソースから @required / @optional ディレクティブを抽出する
+// 同じパスへの指定が複数ある場合はエラー
+//
+// 形式:
+//
+//	{{/* @required User.Email */}}
+//	{{/* @optional User.Nickname */}}
+func ParseRequirementDirectives(src string) ([]RequirementDirective, error) {
+	var directives []RequirementDirective
+	seen := make(map[string]RequirementDirective)
+
+	for _, m := range findDirectives(requirementRegex, src) {
+		d := RequirementDirective{
+			Path:     m.groups[2],
+			Required: m.groups[1] == "required",
+			Line:     m.line,
+			Col:      m.cols[0],
+		}
+		if prev, ok := seen[d.Path]; ok {
+			return nil, scan.Errorf(m.pos(0), "%s is already marked %s at line %d", d.Path, prev.directive(), prev.Line)
+		}
+		seen[d.Path] = d
+		directives = append(directives, d)
+	}
+
+	return directives, nil
+}
+
+// directive はディレクティブ名（"@required" または "@optional"）を返す
+func (d RequirementDirective) directive() string {
+	if d.Required {
+		return "@required"
+	}
+	return "@optional"
+}
@@ -0,0 +1,29 @@
+package magic
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRequirementDirectives(t *testing.T) {
+	src := `{{/* @required User.Email */}}
+{{/* @optional Items.Note */}}
+{{ .User.Email }}`
+	ds, err := ParseRequirementDirectives(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RequirementDirective{
+		{Path: "User.Email", Required: true, Line: 1, Col: 1},
+		{Path: "Items.Note", Required: false, Line: 2, Col: 1},
+	}
+	if !reflect.DeepEqual(ds, want) {
+		t.Fatalf("got %+v, want %+v", ds, want)
+	}
+
+	_, err = ParseRequirementDirectives("{{/* @required A */}}\n{{/* @optional A */}}")
+	if err == nil || !strings.Contains(err.Error(), "2:1: A is already marked @required at line 1") {
+		t.Fatalf("error = %v, want duplicate error", err)
+	}
+}
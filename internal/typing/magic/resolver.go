@@ -3,6 +3,7 @@ package magic
 import (
 	"strings"
 
+	"github.com/bellwood4486/tmpltype/internal/scan"
 	"github.com/bellwood4486/tmpltype/internal/util"
 )
 
@@ -10,6 +11,7 @@ import (
 type TypeResolver struct {
 	overrides    map[string]string      // パス -> Go型文字列 (例: "User.Age" -> "int")
 	structFields map[string]map[string]string  // パス -> 構造体型のフィールド定義
+	positions    map[string]scan.Pos           // パス -> @param ディレクティブの位置（ファイル名なし）
 }
 
 // NewTypeResolver はテンプレートソースからTypeResolverを作成する
@@ -22,9 +24,11 @@ func NewTypeResolver(src string) (*TypeResolver, error) {
 	resolver := &TypeResolver{
 		overrides:    make(map[string]string),
 		structFields: make(map[string]map[string]string),
+		positions:    make(map[string]scan.Pos),
 	}
 
 	for _, dir := range directives {
+		resolver.positions[dir.Path] = scan.Pos{Line: dir.Line, Col: dir.Col}
 		// []struct{...} を特別に扱い、名前付き型を作成
 		if dir.Type.Kind == TypeKindSlice && dir.Type.Elem != nil && dir.Type.Elem.Kind == TypeKindStruct {
 			// []struct{...} に対して "ItemsItem" のような名前付き型を作成
@@ -53,6 +57,12 @@ func (r *TypeResolver) GetType(path []string) (string, bool) {
 	return typ, ok
 }
 
+// GetPos は指定されたパスの @param ディレクティブの位置を返す
+func (r *TypeResolver) GetPos(path []string) (scan.Pos, bool) {
+	pos, ok := r.positions[strings.Join(path, ".")]
+	return pos, ok
+}
+
 // GetAllOverrides はすべての型オーバーライドを返す
 func (r *TypeResolver) GetAllOverrides() map[string]string {
 	return r.overrides
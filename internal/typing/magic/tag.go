@@ -0,0 +1,127 @@
+package magic
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+)
+
+// Tag は構造体タグの1つのキーと値を表す（例: json:"user_name,omitempty"）
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// TagDirective は @tag ディレクティブを表す
+type TagDirective struct {
+	Path string // 例: "User.Email"
+	Tags []Tag  // 指定したタグ（記述順）
+	Line int    // テンプレート内の行番号
+	Col  int    // テンプレート内の列番号（ディレクティブの開始位置）
+}
+
+var tagRegex = regexp.MustCompile(`\{\{/\*\s*@tag\s+(\S+)\s+(.+?)\s*\*/\}\}`)
+
+// ParseTagDirectives はテンプレートソースから @tag ディレクティブを抽出する
+// 同じパスへの @tag が複数ある場合はエラー
+//
+// 形式:
+//
+//	{{/* @tag User.Email json:"email,omitempty" validate:"required,email" */}}
+func ParseTagDirectives(src string) ([]TagDirective, error) {
+	var directives []TagDirective
+	seen := make(map[string]int) // パス -> 最初の @tag の行番号
+
+	for _, m := range findDirectives(tagRegex, src) {
+		path := m.groups[1]
+		if line, ok := seen[path]; ok {
+			return nil, scan.Errorf(m.pos(0), "duplicate @tag directive for %s (first declared at line %d)", path, line)
+		}
+		seen[path] = m.line
+
+		tags, err := ParseStructTag(m.groups[2])
+		if err != nil {
+			return nil, scan.Errorf(m.pos(2), "invalid struct tag %s: %v", m.groups[2], err)
+		}
+		directives = append(directives, TagDirective{
+			Path: path,
+			Tags: tags,
+			Line: m.line,
+			Col:  m.cols[0],
+		})
+	}
+
+	return directives, nil
+}
+
+// ParseStructTag は `key:"value" key2:"value2"` 形式の構造体タグをパースする
+// 全体をバッククォートで囲んでもよい。同じキーが複数ある場合はエラー
+func ParseStructTag(s string) ([]Tag, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '`' && s[len(s)-1] == '`' {
+		s = s[1 : len(s)-1]
+	}
+
+	var tags []Tag
+	for {
+		s = strings.TrimLeft(s, " ")
+		if s == "" {
+			break
+		}
+
+		// キー（reflect.StructTag と同じく空白、引用符、コロン、制御文字以外）
+		i := 0
+		for i < len(s) && s[i] > ' ' && s[i] != ':' && s[i] != '"' && s[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(s) || s[i] != ':' || s[i+1] != '"' {
+			return nil, fmt.Errorf("want key:\"value\" pairs separated by spaces")
+		}
+		key := s[:i]
+		s = s[i+1:]
+
+		// 引用符で囲まれた値
+		i = 1
+		for i < len(s) && s[i] != '"' {
+			if s[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(s) {
+			return nil, fmt.Errorf("unterminated value for key %q", key)
+		}
+		value, err := strconv.Unquote(s[:i+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for key %q: %v", key, err)
+		}
+		s = s[i+1:]
+		if s != "" && s[0] != ' ' {
+			return nil, fmt.Errorf("missing space after the value of key %q", key)
+		}
+
+		for _, t := range tags {
+			if t.Key == key {
+				return nil, fmt.Errorf("duplicate key %q", key)
+			}
+		}
+		tags = append(tags, Tag{Key: key, Value: value})
+	}
+
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("no tags")
+	}
+	return tags, nil
+}
+
+// FormatStructTag はタグを `key:"value"` 形式で空白区切りにつなげる（バッククォートは含まない）
+func FormatStructTag(tags []Tag) string {
+	parts := make([]string, 0, len(tags))
+	for _, t := range tags {
+		parts = append(parts, t.Key+":"+strconv.Quote(t.Value))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,49 @@
+package magic
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTagDirectives(t *testing.T) {
+	src := `{{/* @tag User.Email json:"email,omitempty" validate:"required,email" */}}
+{{/* @tag Items ` + "`json:\"items\"`" + ` */}}
+{{ .User.Email }}`
+	ds, err := ParseTagDirectives(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TagDirective{
+		{Path: "User.Email", Tags: []Tag{{"json", "email,omitempty"}, {"validate", "required,email"}}, Line: 1, Col: 1},
+		{Path: "Items", Tags: []Tag{{"json", "items"}}, Line: 2, Col: 1},
+	}
+	if !reflect.DeepEqual(ds, want) {
+		t.Fatalf("got %+v, want %+v", ds, want)
+	}
+	if got := FormatStructTag(ds[0].Tags); got != `json:"email,omitempty" validate:"required,email"` {
+		t.Errorf("FormatStructTag = %s", got)
+	}
+}
+
+func TestParseTagDirectives_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"duplicate path", "{{/* @tag A json:\"a\" */}}\n{{/* @tag A yaml:\"a\" */}}", `2:1: duplicate @tag directive for A (first declared at line 1)`},
+		{"missing quotes", `{{/* @tag A json:a */}}`, `1:13: invalid struct tag json:a: want key:"value" pairs`},
+		{"unterminated", `{{/* @tag A json:"a */}}`, `unterminated value for key "json"`},
+		{"duplicate key", `{{/* @tag A json:"a" json:"b" */}}`, `duplicate key "json"`},
+		{"no space", `{{/* @tag A json:"a"yaml:"b" */}}`, `missing space after the value of key "json"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTagDirectives(tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package magic
+
+import (
+	"regexp"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+)
+
+// TypeDirective は @type ディレクティブを表す
+type TypeDirective struct {
+	Ref  string // 型の参照（"Name" または "import/path.Name"）
+	Line int    // テンプレート内の行番号
+	Col  int    // テンプレート内の列番号（ディレクティブの開始位置）
+}
+
+var typeRegex = regexp.MustCompile(`\{\{/\*\s*@type\s+(\S+)\s*\*/\}\}`)
+
+// ParseTypeDirective はテンプレートソースから @type ディレクティブを抽出する
+// ディレクティブがなければ nil を返す。1つのテンプレートに複数ある場合はエラー
+//
+// 形式:
+//
+//	{{/* @type github.com/acme/app/domain.OrderView */}}
+//	{{/* @type OrderView */}}
+func ParseTypeDirective(src string) (*TypeDirective, error) {
+	var directive *TypeDirective
+
+	for _, m := range findDirectives(typeRegex, src) {
+		if directive != nil {
+			return nil, scan.Errorf(m.pos(0), "duplicate @type directive (first declared at line %d)", directive.Line)
+		}
+		directive = &TypeDirective{
+			Ref:  m.groups[1],
+			Line: m.line,
+			Col:  m.cols[0],
+		}
+	}
+
+	return directive, nil
+}
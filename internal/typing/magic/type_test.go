@@ -0,0 +1,35 @@
+package magic
+
+import (
+	"testing"
+)
+
+func TestParseTypeDirective(t *testing.T) {
+	src := `
+{{/* @type github.com/acme/app/domain.OrderView */}}
+{{ .ID }}
+`
+	d, err := ParseTypeDirective(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d == nil || d.Ref != "github.com/acme/app/domain.OrderView" || d.Line != 2 {
+		t.Fatalf("unexpected directive: %+v", d)
+	}
+
+	// ディレクティブなし
+	d, err = ParseTypeDirective(`{{ .ID }}`)
+	if err != nil || d != nil {
+		t.Fatalf("expected nil directive, got %+v, %v", d, err)
+	}
+}
+
+func TestParseTypeDirective_Duplicate(t *testing.T) {
+	src := `
+{{/* @type OrderView */}}
+{{/* @type UserView */}}
+`
+	if _, err := ParseTypeDirective(src); err == nil {
+		t.Fatal("expected error for duplicate @type, got nil")
+	}
+}
@@ -1,7 +1,13 @@
 package typing
 
 import (
+	"errors"
 	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"go/types"
 	"maps"
 	"slices"
 	"strings"
@@ -13,13 +19,19 @@ import (
 
 // Resolve resolves types for a schema with both default inference and @param overrides
 func Resolve(schema scan.Schema, templateSrc string) (*TypedSchema, error) {
+	return ResolveWithImports(schema, templateSrc, nil)
+}
+
+// ResolveWithImports は Resolve と同様だが、@import や WellKnownImports 以外に
+// 解決できるパッケージ修飾子 known（修飾子 -> import パス）を追加で指定できる
+func ResolveWithImports(schema scan.Schema, templateSrc string, known map[string]string) (*TypedSchema, error) {
 	// 1. デフォルト型推論
 	typed := inferDefaultTypes(schema)
 
 	// 2. @paramによるオーバーライド適用
 	resolver, err := magic.NewTypeResolver(templateSrc)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create type resolver: %w", err)
+		return nil, scan.InFile(err, schema.File)
 	}
 
 	// オーバーライドを適用
@@ -28,14 +40,112 @@ func Resolve(schema scan.Schema, templateSrc string) (*TypedSchema, error) {
 	// 3. 名前付き型を抽出
 	extractNamedTypes(typed)
 
+	// ここからはディレクティブごとのエラーを集め、まとめて報告する
+	var errs []error
+
+	// @tag で指定した構造体タグを設定（名前付き型のフィールドも対象）
+	if tags, err := magic.ParseTagDirectives(templateSrc); err != nil {
+		errs = append(errs, err)
+	} else if err := applyTags(typed, tags); err != nil {
+		errs = append(errs, err)
+	}
+
+	// @required / @optional の指定を設定
+	if requirements, err := magic.ParseRequirementDirectives(templateSrc); err != nil {
+		errs = append(errs, err)
+	} else if err := applyRequirements(typed, requirements); err != nil {
+		errs = append(errs, err)
+	}
+
+	// @enum のフィールドを文字列の名前付き型にする
+	if enums, err := magic.ParseEnumDirectives(templateSrc); err != nil {
+		errs = append(errs, err)
+	} else if err := applyEnums(typed, enums); err != nil {
+		errs = append(errs, err)
+	}
+	for _, e := range typed.Enums {
+		e.Pos.File = schema.File
+	}
+
+	// @default の値をフィールドの型で検査して設定する（@enum の型が決まってから）
+	if defaults, err := magic.ParseDefaultDirectives(templateSrc); err != nil {
+		errs = append(errs, err)
+	} else if err := applyDefaults(typed, defaults, schema.File); err != nil {
+		errs = append(errs, err)
+	}
+
+	// @doc の説明をフィールドとその名前付き型に設定する
+	if docs, err := magic.ParseDocDirectives(templateSrc); err != nil {
+		errs = append(errs, err)
+	} else if err := applyDocs(typed, docs); err != nil {
+		errs = append(errs, err)
+	}
+	typed.Doc = magic.ParseDescription(templateSrc)
+
+	// 4. 型に現れるパッケージ修飾子から必要な import を収集
+	if imports, err := magic.ParseImports(templateSrc); err != nil {
+		errs = append(errs, err)
+	} else if err := collectImports(typed, imports, known); err != nil {
+		errs = append(errs, err)
+	}
+	for q, pos := range typed.ImportPos {
+		if pos.File == "" {
+			pos.File = schema.File
+			typed.ImportPos[q] = pos
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, scan.InFile(err, schema.File)
+	}
 	return typed, nil
 }
 
+// CheckDirectives はスキーマを使わずに検査できるディレクティブのエラー（構文と @param の型のパッケージ修飾子）を返す
+// テンプレートセットのパースに失敗してスキーマを推論できないときに、構文エラーと一緒に報告するために使う
+func CheckDirectives(templateSrc, file string, known map[string]string) error {
+	var errs []error
+	typed := &TypedSchema{Fields: map[string]*TypedField{}}
+	if resolver, err := magic.NewTypeResolver(templateSrc); err != nil {
+		errs = append(errs, err)
+	} else {
+		// 推論したフィールドがないので、@param のパスごとにフィールドを置いて型を検査する
+		for path, goType := range resolver.GetAllOverrides() {
+			pos, _ := resolver.GetPos(strings.Split(path, "."))
+			typed.Fields[path] = &TypedField{Name: path, GoType: goType, Pos: pos, Param: true}
+		}
+		applyOverrides(typed, resolver)
+	}
+
+	if _, err := magic.ParseTagDirectives(templateSrc); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := magic.ParseRequirementDirectives(templateSrc); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := magic.ParseEnumDirectives(templateSrc); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := magic.ParseDefaultDirectives(templateSrc); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := magic.ParseDocDirectives(templateSrc); err != nil {
+		errs = append(errs, err)
+	}
+	if imports, err := magic.ParseImports(templateSrc); err != nil {
+		errs = append(errs, err)
+	} else if err := collectImports(typed, imports, known); err != nil {
+		errs = append(errs, err)
+	}
+	return scan.InFile(errors.Join(errs...), file)
+}
+
 // inferDefaultTypes performs default type inference
 func inferDefaultTypes(schema scan.Schema) *TypedSchema {
 	typed := &TypedSchema{
 		Fields:     make(map[string]*TypedField),
 		NamedTypes: []*NamedType{},
+		Embeds:     schema.Embeds,
 	}
 
 	for name, field := range schema.Fields {
@@ -48,18 +158,27 @@ func inferDefaultTypes(schema scan.Schema) *TypedSchema {
 // inferFieldType infers type for a single field
 func inferFieldType(path []string, field *scan.Field) *TypedField {
 	typed := &TypedField{
-		Name: field.Name,
+		Name:     field.Name,
+		Literals: field.Literals,
+	}
+	if len(field.Refs) > 0 {
+		typed.Pos = field.Refs[0]
 	}
 
 	switch field.Kind {
 	case scan.KindString:
 		typed.GoType = "string"
+		if field.Type != "" {
+			// 関数の引数位置などから推論された型
+			typed.GoType = field.Type
+		}
 
 	case scan.KindStruct:
 		// 構造体の場合、名前付き型かインライン型か判断
 		typeName := util.Export(path[len(path)-1])
 		typed.GoType = typeName
 		typed.Children = make(map[string]*TypedField)
+		typed.Embeds = field.Embeds
 
 		// 子フィールドの型推論
 		for childName, childField := range field.Children {
@@ -70,7 +189,7 @@ func inferFieldType(path []string, field *scan.Field) *TypedField {
 	case scan.KindSlice:
 		elemType := "string"
 		if field.Elem != nil {
-			if field.Elem.Kind == scan.KindStruct {
+			if isStructElem(field.Elem) {
 				// スライスの要素が構造体の場合、ItemsItemのような名前付き型
 				if len(path) > 0 {
 					elemType = util.Export(path[len(path)-1]) + "Item"
@@ -80,7 +199,8 @@ func inferFieldType(path []string, field *scan.Field) *TypedField {
 				// 要素の子フィールドも推論し、その子フィールドを保存
 				elem := inferFieldType(path, field.Elem)
 				typed.Children = elem.Children
-			} else {
+				typed.Embeds = elem.Embeds
+			} else if field.Elem.Kind != scan.KindStruct {
 				elem := inferFieldType(path, field.Elem)
 				elemType = elem.GoType
 			}
@@ -90,11 +210,23 @@ func inferFieldType(path []string, field *scan.Field) *TypedField {
 	case scan.KindMap:
 		valType := "string"
 		if field.Elem != nil {
-			elem := inferFieldType(path, field.Elem)
-			valType = elem.GoType
+			if isStructElem(field.Elem) {
+				// マップの値が構造体の場合、MetaValueのような名前付き型
+				valType = util.Export(path[len(path)-1]) + "Value"
+				elem := inferFieldType(path, field.Elem)
+				typed.Children = elem.Children
+				typed.Embeds = elem.Embeds
+			} else if field.Elem.Kind != scan.KindStruct {
+				elem := inferFieldType(path, field.Elem)
+				valType = elem.GoType
+			}
 		}
 		typed.GoType = "map[string]" + valType
 
+	case scan.KindTemplate:
+		// 型名はコード生成時に参照先テンプレートから決まる
+		typed.Template = field.Template
+
 	default:
 		typed.GoType = "string"
 	}
@@ -102,6 +234,12 @@ func inferFieldType(path []string, field *scan.Field) *TypedField {
 	return typed
 }
 
+// isStructElem はスライス/マップの要素が構造体（子または埋め込みあり）かどうかを返す
+// {{ range .Tags }}{{ . }}{{ end }} のように要素のフィールドを参照しない場合は string として扱う
+func isStructElem(elem *scan.Field) bool {
+	return elem.Kind == scan.KindStruct && (len(elem.Children) > 0 || len(elem.Embeds) > 0)
+}
+
 // applyOverrides applies @param overrides to typed schema
 func applyOverrides(typed *TypedSchema, resolver *magic.TypeResolver) {
 	// トップレベルフィールドから順に処理
@@ -109,8 +247,10 @@ func applyOverrides(typed *TypedSchema, resolver *magic.TypeResolver) {
 		applyFieldOverride([]string{name}, field, resolver)
 	}
 
-	// @paramで定義された構造体型を名前付き型として追加
-	for path, typeStr := range resolver.GetAllOverrides() {
+	// @paramで定義された構造体型を名前付き型として追加（出力を安定させるためパス順）
+	overrides := resolver.GetAllOverrides()
+	for _, path := range slices.Sorted(maps.Keys(overrides)) {
+		typeStr := overrides[path]
 		if strings.HasPrefix(typeStr, "[]") && !strings.HasPrefix(typeStr, "[]struct{") && !isBuiltinType(typeStr[2:]) {
 			// []ItemsItem のような名前付き型
 			if fields := resolver.GetStructFields(path); fields != nil {
@@ -118,10 +258,13 @@ func applyOverrides(typed *TypedSchema, resolver *magic.TypeResolver) {
 					Name:   typeStr[2:], // "ItemsItem"
 					Fields: make(map[string]*TypedField),
 				}
+				pos, _ := resolver.GetPos(strings.Split(path, "."))
 				for fieldName, fieldType := range fields {
 					namedType.Fields[fieldName] = &TypedField{
 						Name:   util.Export(fieldName),
 						GoType: fieldType,
+						Pos:    pos,
+						Param:  true,
 					}
 				}
 				typed.NamedTypes = append(typed.NamedTypes, namedType)
@@ -135,8 +278,12 @@ func applyFieldOverride(path []string, field *TypedField, resolver *magic.TypeRe
 	// このパスに対するオーバーライドを確認
 	if overrideType, ok := resolver.GetType(path); ok {
 		field.GoType = overrideType
-		// @paramで上書きされた場合、子フィールドは不要
+		field.Pos, _ = resolver.GetPos(path)
+		field.Param = true
+		// @paramで上書きされた場合、子フィールドや参照先テンプレートは不要
 		field.Children = nil
+		field.Template = ""
+		field.Embeds = nil
 		return
 	}
 
@@ -155,18 +302,18 @@ func extractNamedTypes(typed *TypedSchema) {
 
 	var extract func(path []string, field *TypedField)
 	extract = func(path []string, field *TypedField) {
-		// スライスの要素型が名前付き構造体の場合
-		if strings.HasPrefix(field.GoType, "[]") {
-			elemType := field.GoType[2:]
+		// スライス/マップの要素型が名前付き構造体の場合
+		if elemType, ok := containerElem(field.GoType); ok {
 			if !isBuiltinType(elemType) && !strings.Contains(elemType, "[") &&
 				!strings.Contains(elemType, "map") && !strings.HasPrefix(elemType, "struct{") {
 				// すでに登録済みでない場合のみ追加
 				if _, exists := namedTypes[elemType]; !exists {
 					// scan結果から構造体を探す
-					if field.Children != nil && len(field.Children) > 0 {
+					if len(field.Children) > 0 || len(field.Embeds) > 0 {
 						namedType := &NamedType{
 							Name:   elemType,
 							Fields: field.Children,
+							Embeds: field.Embeds,
 						}
 						namedTypes[elemType] = namedType
 					}
@@ -177,11 +324,12 @@ func extractNamedTypes(typed *TypedSchema) {
 		// 構造体型の場合
 		if field.GoType != "" && !isBuiltinType(field.GoType) &&
 			!strings.Contains(field.GoType, "[") && !strings.Contains(field.GoType, "map") &&
-			field.GoType != "Params" && field.Children != nil && len(field.Children) > 0 {
+			field.GoType != "Params" && (len(field.Children) > 0 || len(field.Embeds) > 0) {
 			if _, exists := namedTypes[field.GoType]; !exists {
 				namedType := &NamedType{
 					Name:   field.GoType,
 					Fields: field.Children,
+					Embeds: field.Embeds,
 				}
 				namedTypes[field.GoType] = namedType
 			}
@@ -208,6 +356,279 @@ func extractNamedTypes(typed *TypedSchema) {
 	}
 }
 
+// applyTags は @tag ディレクティブのタグをパスのフィールドに設定する
+// どのフィールドにも一致しないパスはまとめてエラーにする
+func applyTags(typed *TypedSchema, directives []magic.TagDirective) error {
+	namedTypes := namedTypeMap(typed)
+
+	var errs []error
+	for _, d := range directives {
+		field := lookupField(typed.Fields, namedTypes, strings.Split(d.Path, "."))
+		if field == nil {
+			errs = append(errs, scan.Errorf(scan.Pos{Line: d.Line, Col: d.Col}, "@tag %s does not match any field", d.Path))
+			continue
+		}
+		field.Tags = d.Tags
+	}
+	return errors.Join(errs...)
+}
+
+// applyRequirements は @required / @optional の指定をパスのフィールドに設定する
+func applyRequirements(typed *TypedSchema, directives []magic.RequirementDirective) error {
+	namedTypes := namedTypeMap(typed)
+
+	var errs []error
+	for _, d := range directives {
+		field := lookupField(typed.Fields, namedTypes, strings.Split(d.Path, "."))
+		if field == nil {
+			name := "@optional"
+			if d.Required {
+				name = "@required"
+			}
+			errs = append(errs, scan.Errorf(scan.Pos{Line: d.Line, Col: d.Col}, "%s %s does not match any field", name, d.Path))
+			continue
+		}
+		field.Required = RequirementOptional
+		if d.Required {
+			field.Required = RequirementRequired
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyEnums は @enum のフィールドの型を文字列の名前付き型にする
+// 型名はパスの各要素をつなげた名前（例: User.Status -> UserStatus）
+// 値を省略した場合は eq / ne で比較している文字列リテラルを値にし、指定した場合はそれ以外のリテラルとの比較を警告する
+func applyEnums(typed *TypedSchema, directives []magic.EnumDirective) error {
+	namedTypes := namedTypeMap(typed)
+
+	var errs []error
+	for _, d := range directives {
+		pos := scan.Pos{Line: d.Line, Col: d.Col}
+		field := lookupField(typed.Fields, namedTypes, strings.Split(d.Path, "."))
+		if field == nil {
+			errs = append(errs, scan.Errorf(pos, "@enum %s does not match any field", d.Path))
+			continue
+		}
+		if field.GoType != "string" {
+			errs = append(errs, scan.Errorf(pos, "@enum %s requires a string field (the field is %s)", d.Path, fieldKind(field)))
+			continue
+		}
+
+		values := d.Values
+		if len(values) == 0 {
+			for _, l := range field.Literals {
+				if !slices.Contains(values, l.Value) {
+					values = append(values, l.Value)
+				}
+			}
+			if len(values) == 0 {
+				errs = append(errs, scan.Errorf(pos, "@enum %s has no values; list them (@enum %s a|b) or compare the field with string literals", d.Path, d.Path))
+				continue
+			}
+		} else {
+			for _, l := range field.Literals {
+				if !slices.Contains(values, l.Value) {
+					typed.Warnings = append(typed.Warnings, Warning{
+						Pos: l.Pos,
+						Msg: fmt.Sprintf("%s is compared with %q, which is not a value of @enum %s (%s)", d.Path, l.Value, d.Path, strings.Join(values, "|")),
+					})
+				}
+			}
+		}
+
+		var name string
+		for _, part := range strings.Split(d.Path, ".") {
+			name += util.Export(part)
+		}
+		if _, ok := namedTypes[name]; ok || slices.ContainsFunc(typed.Enums, func(e *Enum) bool { return e.Name == name }) {
+			errs = append(errs, scan.Errorf(pos, "@enum %s: type name %s is already used by another type of the template", d.Path, name))
+			continue
+		}
+		field.GoType = name
+		typed.Enums = append(typed.Enums, &Enum{Name: name, Path: d.Path, Values: values, Pos: pos})
+	}
+	return errors.Join(errs...)
+}
+
+// applyDocs は @doc の説明をパスのフィールドに設定する
+// フィールドの型（スライス・マップでは要素の型）が名前付き型なら、その型の説明にもする
+func applyDocs(typed *TypedSchema, directives []magic.DocDirective) error {
+	namedTypes := namedTypeMap(typed)
+
+	var errs []error
+	for _, d := range directives {
+		field := lookupField(typed.Fields, namedTypes, strings.Split(d.Path, "."))
+		if field == nil {
+			errs = append(errs, scan.Errorf(scan.Pos{Line: d.Line, Col: d.Col}, "@doc %s does not match any field", d.Path))
+			continue
+		}
+		field.Doc = d.Text
+
+		typeName := field.GoType
+		if elem, ok := containerElem(typeName); ok {
+			typeName = elem
+		}
+		if nt, ok := namedTypes[typeName]; ok && nt.Doc == "" {
+			nt.Doc = d.Text
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyDefaults は @default の値をパスのフィールドに設定する
+// 値はフィールドの型に代入できる Go の定数式で、ゼロ値（効果がない）や @enum にない値はエラー
+func applyDefaults(typed *TypedSchema, directives []magic.DefaultDirective, file string) error {
+	namedTypes := namedTypeMap(typed)
+
+	var errs []error
+	for _, d := range directives {
+		pos := scan.Pos{Line: d.Line, Col: d.Col}
+		valuePos := scan.Pos{Line: d.Line, Col: d.VCol}
+		field := lookupField(typed.Fields, namedTypes, strings.Split(d.Path, "."))
+		if field == nil {
+			errs = append(errs, scan.Errorf(pos, "@default %s does not match any field", d.Path))
+			continue
+		}
+		if field.Required == RequirementRequired {
+			errs = append(errs, scan.Errorf(pos, "@default %s cannot be used with @required %s (the default is used when the field is not set)", d.Path, d.Path))
+			continue
+		}
+
+		var enum *Enum
+		for _, e := range typed.Enums {
+			if e.Name == field.GoType {
+				enum = e
+			}
+		}
+		value, err := defaultValue(d.Value, field, enum)
+		if err != nil {
+			errs = append(errs, scan.Errorf(valuePos, "@default %s: %v", d.Path, err))
+			continue
+		}
+		valuePos.File = file
+		field.Default = &Default{Expr: d.Value, Value: value, Pos: valuePos}
+	}
+	return errors.Join(errs...)
+}
+
+// defaultKinds は @default を指定できるパッケージ修飾付きの型と、その基になる型
+var defaultKinds = map[string]string{
+	"template.HTML":     "string",
+	"template.HTMLAttr": "string",
+	"template.CSS":      "string",
+	"template.JS":       "string",
+	"template.JSStr":    "string",
+	"template.URL":      "string",
+	"template.Srcset":   "string",
+}
+
+// defaultValue は expr をフィールドの型の定数として型検査し、その値を返す
+// 文字列、数値、bool の型と @enum の型（enum が nil でない）のフィールドに指定できる
+func defaultValue(expr string, field *TypedField, enum *Enum) (constant.Value, error) {
+	base := field.GoType
+	if enum != nil {
+		base = "string"
+	} else if kind, ok := defaultKinds[base]; ok {
+		base = kind
+	}
+	var basic *types.Basic
+	if obj, ok := types.Universe.Lookup(base).(*types.TypeName); ok && field.Template == "" && field.Children == nil {
+		basic, _ = obj.Type().Underlying().(*types.Basic) // any と error は nil
+	}
+	if basic == nil {
+		return nil, fmt.Errorf("a default cannot be set on %s; use a string, number, bool or @enum field", fieldKind(field))
+	}
+
+	// var _ T = expr を型検査して、代入できる定数か確かめる
+	if _, err := parser.ParseExpr(expr); err != nil {
+		return nil, fmt.Errorf("invalid value %s", expr)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", "package p\nvar _ "+base+" = "+expr+"\n", 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %s", expr)
+	}
+	info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	if _, err := (&types.Config{}).Check("p", fset, []*ast.File{f}, info); err != nil {
+		var te types.Error
+		if errors.As(err, &te) {
+			return nil, errors.New(strings.Replace(te.Msg, " in variable declaration", "", 1))
+		}
+		return nil, err
+	}
+	valueExpr := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values[0]
+	value := info.Types[valueExpr].Value
+	if value == nil {
+		return nil, fmt.Errorf("%s is not a constant", expr)
+	}
+	if basic.Info()&types.IsFloat != 0 {
+		value = constant.ToFloat(value)
+	}
+
+	switch {
+	case enum != nil && !slices.Contains(enum.Values, constant.StringVal(value)):
+		return nil, fmt.Errorf("%s is not a value of @enum %s (%s)", expr, enum.Path, strings.Join(enum.Values, "|"))
+	case value.Kind() == constant.String && constant.StringVal(value) == "",
+		value.Kind() == constant.Bool && !constant.BoolVal(value),
+		(value.Kind() == constant.Int || value.Kind() == constant.Float) && constant.Sign(value) == 0:
+		return nil, fmt.Errorf("%s is the zero value of the field, so the default has no effect", expr)
+	}
+	return value, nil
+}
+
+// fieldKind はエラーメッセージに使うフィールドの型の説明を返す
+func fieldKind(field *TypedField) string {
+	switch {
+	case field.Template != "":
+		return fmt.Sprintf("the parameter of template %q", field.Template)
+	case field.Children != nil:
+		return "a struct"
+	}
+	return field.GoType
+}
+
+// namedTypeMap は名前付き型を型名で引けるようにする
+func namedTypeMap(typed *TypedSchema) map[string]*NamedType {
+	namedTypes := make(map[string]*NamedType, len(typed.NamedTypes))
+	for _, nt := range typed.NamedTypes {
+		namedTypes[nt.Name] = nt
+	}
+	return namedTypes
+}
+
+// lookupField はパスのフィールドを返す（なければ nil）
+// 子フィールドは構造体の子、またはスライス/マップの要素の名前付き型からたどる
+func lookupField(fields map[string]*TypedField, namedTypes map[string]*NamedType, path []string) *TypedField {
+	var field *TypedField
+	for _, name := range path {
+		if field != nil {
+			fields = field.Children
+			if fields == nil {
+				typeName := field.GoType
+				if elem, ok := containerElem(typeName); ok {
+					typeName = elem
+				}
+				if nt, ok := namedTypes[typeName]; ok {
+					fields = nt.Fields
+				}
+			}
+		}
+		if field = fields[name]; field == nil {
+			return nil
+		}
+	}
+	return field
+}
+
+// containerElem は "[]X" または "map[string]X" の要素型 X を返す
+func containerElem(goType string) (string, bool) {
+	if elem, ok := strings.CutPrefix(goType, "[]"); ok {
+		return elem, true
+	}
+	return strings.CutPrefix(goType, "map[string]")
+}
+
 func isBuiltinType(typeName string) bool {
 	builtins := []string{
 		"string", "int", "int8", "int16", "int32", "int64",
@@ -1,9 +1,13 @@
 package typing
 
 import (
+	"go/constant"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/bellwood4486/tmpltype/internal/scan"
+	"github.com/bellwood4486/tmpltype/internal/typing/magic"
 )
 
 func TestIsBuiltinType(t *testing.T) {
@@ -345,3 +349,472 @@ func TestResolve_InvalidParamDirective(t *testing.T) {
 		t.Error("expected error for invalid param directive, got nil")
 	}
 }
+
+func TestResolve_CollectsImports(t *testing.T) {
+	schema := scan.Schema{
+		Fields: map[string]*scan.Field{
+			"CreatedAt": {Name: "CreatedAt", Kind: scan.KindString},
+			"Homepage":  {Name: "Homepage", Kind: scan.KindString},
+			"Price":     {Name: "Price", Kind: scan.KindString},
+			"Name":      {Name: "Name", Kind: scan.KindString},
+		},
+	}
+
+	templateSrc := `
+{{/* @import decimal "github.com/shopspring/decimal" */}}
+{{/* @param CreatedAt time.Time */}}
+{{/* @param Homepage *url.URL */}}
+{{/* @param Price decimal.Decimal */}}
+{{/* @param Tags []struct{At time.Time} */}}
+`
+
+	typed, err := Resolve(schema, templateSrc)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	want := map[string]string{
+		"time":    "time",
+		"url":     "net/url",
+		"decimal": "github.com/shopspring/decimal",
+	}
+	if len(typed.Imports) != len(want) {
+		t.Fatalf("Imports = %v, want %v", typed.Imports, want)
+	}
+	for q, p := range want {
+		if typed.Imports[q] != p {
+			t.Errorf("Imports[%q] = %q, want %q", q, typed.Imports[q], p)
+		}
+	}
+}
+
+func TestResolveWithImports_KnownQualifier(t *testing.T) {
+	schema := scan.Schema{
+		Fields: map[string]*scan.Field{
+			"Amount": {Name: "Amount", Kind: scan.KindString, Type: "money.Amount"},
+		},
+	}
+
+	typed, err := ResolveWithImports(schema, "{{ .Amount }}", map[string]string{"money": "example.com/money"})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if typed.Imports["money"] != "example.com/money" {
+		t.Errorf("Imports = %v", typed.Imports)
+	}
+}
+
+func TestResolve_UnknownQualifier(t *testing.T) {
+	schema := scan.Schema{
+		Fields: map[string]*scan.Field{
+			"Price": {Name: "Price", Kind: scan.KindString},
+		},
+	}
+
+	_, err := Resolve(schema, `{{/* @param Price decimal.Decimal */}}`)
+	if err == nil {
+		t.Fatal("expected error for unknown qualifier, got nil")
+	}
+	if !strings.Contains(err.Error(), "@import decimal") {
+		t.Errorf("error should suggest @import: %v", err)
+	}
+}
+
+func TestResolve_ErrorPositions(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema scan.Schema
+		src    string
+		want   string
+	}{
+		{
+			name: "invalid @param",
+			schema: scan.Schema{
+				Fields: map[string]*scan.Field{"Name": {Name: "Name", Kind: scan.KindString}},
+				File:   "a.tmpl",
+			},
+			src:  "\n  {{/* @param Name struct{Field */}}\n",
+			want: "a.tmpl:2:20: invalid type expression",
+		},
+		{
+			name: "unknown qualifier in @param",
+			schema: scan.Schema{
+				Fields: map[string]*scan.Field{"Price": {Name: "Price", Kind: scan.KindString}},
+				File:   "a.tmpl",
+			},
+			src:  "x\n{{/* @param Price decimal.Decimal */}}",
+			want: "a.tmpl:2:1: unknown package qualifier \"decimal\"",
+		},
+		{
+			name: "unknown qualifier from reference",
+			schema: scan.Schema{
+				Fields: map[string]*scan.Field{"Amount": {
+					Name: "Amount",
+					Kind: scan.KindString,
+					Type: "money.Amount",
+					Refs: []scan.Pos{{File: "b.tmpl", Line: 3, Col: 5}},
+				}},
+				File: "a.tmpl",
+			},
+			src:  "{{ .Amount }}",
+			want: "b.tmpl:3:5: unknown package qualifier \"money\"",
+		},
+		{
+			name: "conflicting @import alias",
+			schema: scan.Schema{
+				Fields: map[string]*scan.Field{},
+				File:   "a.tmpl",
+			},
+			src:  "{{/* @import d \"x/d\" */}}\n{{/* @import d \"y/d\" */}}",
+			want: "a.tmpl:2:1: @import alias \"d\" is already used",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Resolve(tt.schema, tt.src)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("error = %q, want prefix %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve_JoinsDirectiveErrors(t *testing.T) {
+	// 最初のエラーで止めず、ディレクティブごとのエラーをすべて報告する
+	schema := scan.Schema{
+		Fields: map[string]*scan.Field{"Count": {Name: "Count", Kind: scan.KindString, Type: "int"}},
+		File:   "a.tmpl",
+	}
+	src := "{{/* @required Nope */}}\n{{/* @default Count \"s\" */}}\n{{/* @import d \"x/d\" */}}{{/* @import d \"y/d\" */}}"
+	_, err := Resolve(schema, src)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	for _, want := range []string{
+		"a.tmpl:1:1: @required Nope does not match any field",
+		"a.tmpl:2:21: @default Count: cannot use \"s\"",
+		"a.tmpl:3:26: @import alias \"d\" is already used for \"x/d\"",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not contain %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestResolve_ParamSource(t *testing.T) {
+	schema := scan.Schema{
+		Fields: map[string]*scan.Field{
+			"User": {Name: "User", Kind: scan.KindStruct, Children: map[string]*scan.Field{
+				"Name": {Name: "Name", Kind: scan.KindString},
+				"Age":  {Name: "Age", Kind: scan.KindString},
+			}},
+			"Items": {Name: "Items", Kind: scan.KindSlice, Elem: &scan.Field{Kind: scan.KindStruct}},
+		},
+	}
+
+	typed, err := Resolve(schema, "{{/* @param User.Age int */}}{{/* @param Items []struct{ID int64} */}}")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	user := typed.Fields["User"]
+	if user.Param || user.Children["Name"].Param {
+		t.Errorf("inferred fields should not be marked as @param")
+	}
+	if !user.Children["Age"].Param || !typed.Fields["Items"].Param {
+		t.Errorf("overridden fields should be marked as @param")
+	}
+	for _, nt := range typed.NamedTypes {
+		if nt.Name == "ItemsItem" && !nt.Fields["ID"].Param {
+			t.Errorf("fields of @param struct should be marked as @param")
+		}
+	}
+}
+
+func TestResolve_TagDirective(t *testing.T) {
+	schema := scan.Schema{
+		File: "mail.tmpl",
+		Fields: map[string]*scan.Field{
+			"User": {Name: "User", Kind: scan.KindStruct, Children: map[string]*scan.Field{
+				"Email": {Name: "Email", Kind: scan.KindString},
+			}},
+			"Items": {Name: "Items", Kind: scan.KindSlice, Elem: &scan.Field{Kind: scan.KindStruct}},
+			"Tags": {Name: "Tags", Kind: scan.KindSlice, Elem: &scan.Field{Kind: scan.KindStruct, Children: map[string]*scan.Field{
+				"Label": {Name: "Label", Kind: scan.KindString},
+			}}},
+		},
+	}
+
+	src := `{{/* @param Items []struct{ID int64} */}}
+{{/* @tag User json:"user" */}}
+{{/* @tag User.Email json:"email" validate:"required" */}}
+{{/* @tag Items.ID json:"id" */}}
+{{/* @tag Tags.Label json:"label" */}}`
+	typed, err := Resolve(schema, src)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	var itemID *TypedField
+	for _, nt := range typed.NamedTypes {
+		if nt.Name == "ItemsItem" {
+			itemID = nt.Fields["ID"]
+		}
+	}
+	tests := []struct {
+		path  string
+		field *TypedField
+		want  []magic.Tag
+	}{
+		{"User", typed.Fields["User"], []magic.Tag{{Key: "json", Value: "user"}}},
+		{"User.Email", typed.Fields["User"].Children["Email"], []magic.Tag{{Key: "json", Value: "email"}, {Key: "validate", Value: "required"}}},
+		{"Items.ID", itemID, []magic.Tag{{Key: "json", Value: "id"}}},
+		{"Tags.Label", typed.Fields["Tags"].Children["Label"], []magic.Tag{{Key: "json", Value: "label"}}},
+	}
+	for _, tt := range tests {
+		if tt.field == nil {
+			t.Errorf("%s: field not found", tt.path)
+			continue
+		}
+		if !reflect.DeepEqual(tt.field.Tags, tt.want) {
+			t.Errorf("%s: Tags = %v, want %v", tt.path, tt.field.Tags, tt.want)
+		}
+	}
+
+	// どのフィールドにも一致しないパスはすべて位置付きで報告する
+	_, err = Resolve(schema, "{{/* @tag User.Mail json:\"m\" */}}\n{{/* @tag Foo json:\"f\" */}}")
+	if err == nil {
+		t.Fatal("expected error for unknown @tag paths")
+	}
+	for _, want := range []string{"mail.tmpl:1:1: @tag User.Mail does not match any field", "mail.tmpl:2:1: @tag Foo does not match any field"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestResolve_RequirementDirective(t *testing.T) {
+	schema := scan.Schema{
+		File: "mail.tmpl",
+		Fields: map[string]*scan.Field{
+			"User": {Name: "User", Kind: scan.KindStruct, Children: map[string]*scan.Field{
+				"Email":    {Name: "Email", Kind: scan.KindString},
+				"Nickname": {Name: "Nickname", Kind: scan.KindString},
+			}},
+			"Items": {Name: "Items", Kind: scan.KindSlice, Elem: &scan.Field{Kind: scan.KindStruct}},
+		},
+	}
+
+	src := `{{/* @param Items []struct{ID int64} */}}
+{{/* @required User.Email */}}
+{{/* @optional User.Nickname */}}
+{{/* @required Items.ID */}}`
+	typed, err := Resolve(schema, src)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	user := typed.Fields["User"]
+	if got := user.Children["Email"].Required; got != RequirementRequired {
+		t.Errorf("User.Email: Required = %v, want RequirementRequired", got)
+	}
+	if got := user.Children["Nickname"].Required; got != RequirementOptional {
+		t.Errorf("User.Nickname: Required = %v, want RequirementOptional", got)
+	}
+	if got := user.Required; got != RequirementDefault {
+		t.Errorf("User: Required = %v, want RequirementDefault", got)
+	}
+	for _, nt := range typed.NamedTypes {
+		if nt.Name == "ItemsItem" && nt.Fields["ID"].Required != RequirementRequired {
+			t.Errorf("Items.ID: Required = %v, want RequirementRequired", nt.Fields["ID"].Required)
+		}
+	}
+
+	_, err = Resolve(schema, "{{/* @optional User.Mail */}}")
+	if err == nil || !strings.Contains(err.Error(), "mail.tmpl:1:1: @optional User.Mail does not match any field") {
+		t.Fatalf("error = %v, want unknown path error", err)
+	}
+}
+
+func TestResolve_EnumDirective(t *testing.T) {
+	lit := func(v string, col int) scan.Literal {
+		return scan.Literal{Value: v, Pos: scan.Pos{File: "order.tmpl", Line: 3, Col: col}}
+	}
+	schema := scan.Schema{
+		File: "order.tmpl",
+		Fields: map[string]*scan.Field{
+			"Status": {Name: "Status", Kind: scan.KindString, Literals: []scan.Literal{lit("shipped", 10), lit("delivered", 30)}},
+			"User": {Name: "User", Kind: scan.KindStruct, Children: map[string]*scan.Field{
+				"Plan": {Name: "Plan", Kind: scan.KindString, Literals: []scan.Literal{lit("free", 40), lit("pro", 50), lit("free", 60)}},
+			}},
+			"Count": {Name: "Count", Kind: scan.KindString, Type: "int"},
+		},
+	}
+
+	src := `{{/* @enum Status shipped|cancelled|pending */}}
+{{/* @enum User.Plan */}}`
+	typed, err := Resolve(schema, src)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if got := typed.Fields["Status"].GoType; got != "Status" {
+		t.Errorf("Status.GoType = %q, want Status", got)
+	}
+	if got := typed.Fields["User"].Children["Plan"].GoType; got != "UserPlan" {
+		t.Errorf("User.Plan.GoType = %q, want UserPlan", got)
+	}
+	wantEnums := []*Enum{
+		{Name: "Status", Path: "Status", Values: []string{"shipped", "cancelled", "pending"}, Pos: scan.Pos{File: "order.tmpl", Line: 1, Col: 1}},
+		{Name: "UserPlan", Path: "User.Plan", Values: []string{"free", "pro"}, Pos: scan.Pos{File: "order.tmpl", Line: 2, Col: 1}},
+	}
+	if !reflect.DeepEqual(typed.Enums, wantEnums) {
+		t.Errorf("Enums = %+v, want %+v", typed.Enums, wantEnums)
+	}
+	wantWarnings := []string{`order.tmpl:3:30: warning: Status is compared with "delivered", which is not a value of @enum Status (shipped|cancelled|pending)`}
+	var gotWarnings []string
+	for _, w := range typed.Warnings {
+		gotWarnings = append(gotWarnings, w.String())
+	}
+	if !reflect.DeepEqual(gotWarnings, wantWarnings) {
+		t.Errorf("Warnings = %q, want %q", gotWarnings, wantWarnings)
+	}
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"{{/* @enum Missing a|b */}}", "order.tmpl:1:1: @enum Missing does not match any field"},
+		{"{{/* @enum Count a|b */}}", "order.tmpl:1:1: @enum Count requires a string field (the field is int)"},
+		{"{{/* @enum User a|b */}}", "order.tmpl:1:1: @enum User requires a string field (the field is a struct)"},
+		{"{{/* @param Title string */}}\n{{/* @enum Title */}}", "order.tmpl:2:1: @enum Title has no values"},
+	}
+	schema.Fields["Title"] = &scan.Field{Name: "Title", Kind: scan.KindString}
+	for _, tt := range tests {
+		_, err := Resolve(schema, tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Resolve(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestResolve_DefaultDirective(t *testing.T) {
+	schema := scan.Schema{
+		File: "page.tmpl",
+		Fields: map[string]*scan.Field{
+			"SiteName": {Name: "SiteName", Kind: scan.KindString},
+			"Status":   {Name: "Status", Kind: scan.KindString},
+			"Page": {Name: "Page", Kind: scan.KindStruct, Children: map[string]*scan.Field{
+				"Size":  {Name: "Size", Kind: scan.KindString},
+				"Ratio": {Name: "Ratio", Kind: scan.KindString},
+			}},
+			"Email": {Name: "Email", Kind: scan.KindString},
+		},
+	}
+
+	src := `{{/* @param Page.Size int8 */}}{{/* @param Page.Ratio float64 */}}
+{{/* @enum Status draft|published */}}
+{{/* @default SiteName "Acme Corp" */}}
+{{/* @default Page.Size 20 */}}{{/* @default Page.Ratio 2 */}}
+{{/* @default Status "published" */}}`
+	typed, err := Resolve(schema, src)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	want := map[string]string{
+		"SiteName": `"Acme Corp"`,
+		"Size":     "20",
+		"Ratio":    "2",
+		"Status":   `"published"`,
+	}
+	got := map[string]string{
+		"SiteName": typed.Fields["SiteName"].Default.Value.ExactString(),
+		"Size":     typed.Fields["Page"].Children["Size"].Default.Value.ExactString(),
+		"Ratio":    typed.Fields["Page"].Children["Ratio"].Default.Value.ExactString(),
+		"Status":   typed.Fields["Status"].Default.Value.ExactString(),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("default values = %v, want %v", got, want)
+	}
+	if got := typed.Fields["Page"].Children["Ratio"].Default.Value.Kind(); got != constant.Float {
+		t.Errorf("Page.Ratio default kind = %v, want Float", got)
+	}
+	if d := typed.Fields["SiteName"].Default; d.Expr != `"Acme Corp"` || d.Pos != (scan.Pos{File: "page.tmpl", Line: 3, Col: 24}) {
+		t.Errorf("SiteName default = %+v", d)
+	}
+	if typed.Fields["Email"].Default != nil {
+		t.Errorf("Email should have no default")
+	}
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`{{/* @default Missing "x" */}}`, "page.tmpl:1:1: @default Missing does not match any field"},
+		{`{{/* @default SiteName 1 */}}`, "page.tmpl:1:24: @default SiteName: cannot use 1 (untyped int constant) as string value"},
+		{`{{/* @param Page.Size int8 */}}{{/* @default Page.Size 300 */}}`, "page.tmpl:1:56: @default Page.Size: cannot use 300 (untyped int constant) as int8 value (overflows)"},
+		{`{{/* @default SiteName "" */}}`, `page.tmpl:1:24: @default SiteName: "" is the zero value of the field, so the default has no effect`},
+		{`{{/* @default SiteName len("x") */}}`, `@default SiteName: cannot use len("x") (constant 1 of type int) as string value`},
+		{`{{/* @default SiteName "a" + */}}`, `@default SiteName: invalid value "a" +`},
+		{`{{/* @default Page "x" */}}`, "@default Page: a default cannot be set on a struct; use a string, number, bool or @enum field"},
+		{`{{/* @param Email *string */}}{{/* @default Email "x" */}}`, "@default Email: a default cannot be set on *string"},
+		{"{{/* @enum Status a|b */}}{{/* @default Status \"c\" */}}", `@default Status: "c" is not a value of @enum Status (a|b)`},
+		{`{{/* @required Email */}}{{/* @default Email "x" */}}`, "@default Email cannot be used with @required Email"},
+	}
+	for _, tt := range tests {
+		_, err := Resolve(schema, tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Resolve(%q) error = %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestResolve_DocDirective(t *testing.T) {
+	schema := scan.Schema{
+		File: "mail.tmpl",
+		Fields: map[string]*scan.Field{
+			"Footer": {Name: "Footer", Kind: scan.KindStruct, Children: map[string]*scan.Field{
+				"Year": {Name: "Year", Kind: scan.KindString},
+			}},
+			"Items": {Name: "Items", Kind: scan.KindSlice, Elem: &scan.Field{Kind: scan.KindStruct, Children: map[string]*scan.Field{
+				"ID": {Name: "ID", Kind: scan.KindString},
+			}}},
+		},
+	}
+
+	src := `{{/* @doc Footer.Year copyright year */}}
+{{/* @doc Footer footer of every mail */}}
+{{/* @doc Items ordered items */}}
+{{/*
+  Order confirmation.
+  Sent after checkout.
+*/}}`
+	typed, err := Resolve(schema, src)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if got := typed.Fields["Footer"].Children["Year"].Doc; got != "copyright year" {
+		t.Errorf("Footer.Year.Doc = %q", got)
+	}
+	if got := typed.Fields["Items"].Doc; got != "ordered items" {
+		t.Errorf("Items.Doc = %q", got)
+	}
+	docs := make(map[string]string)
+	for _, nt := range typed.NamedTypes {
+		docs[nt.Name] = nt.Doc
+	}
+	if want := map[string]string{"Footer": "footer of every mail", "ItemsItem": "ordered items"}; !reflect.DeepEqual(docs, want) {
+		t.Errorf("named type docs = %v, want %v", docs, want)
+	}
+	if want := "Order confirmation.\nSent after checkout."; typed.Doc != want {
+		t.Errorf("Doc = %q, want %q", typed.Doc, want)
+	}
+
+	_, err = Resolve(schema, "{{/* @doc Missing x */}}")
+	if err == nil || !strings.Contains(err.Error(), "mail.tmpl:1:1: @doc Missing does not match any field") {
+		t.Errorf("error = %v", err)
+	}
+}
@@ -1,22 +1,87 @@
 package typing
 
+import (
+	"go/constant"
+
+	"github.com/bellwood4486/tmpltype/internal/scan"
+	"github.com/bellwood4486/tmpltype/internal/typing/magic"
+)
+
 // TypedSchema represents a schema with resolved types
 type TypedSchema struct {
 	// トップレベルフィールド
 	Fields map[string]*TypedField
 	// 生成すべき名前付き型のリスト（例: ItemsItem）
 	NamedTypes []*NamedType
+	// トップレベルに埋め込むテンプレート名（{{ template "name" . }}）
+	Embeds []string
+	// 型で使われるパッケージ修飾子と import パス（例: "time" -> "time"）
+	Imports map[string]string
+	// 修飾子を宣言した @import の位置（なければ最初に使ったフィールドの位置）
+	ImportPos map[string]scan.Pos
+	// @enum で生成する文字列の名前付き型（ディレクティブの順）
+	Enums []*Enum
+	// 生成を止めない問題（@enum にない値との比較など）
+	Warnings []Warning
+	// テンプレートの説明（テンプレート先頭のコメント）
+	Doc string
 }
 
 // TypedField represents a field with resolved type
 type TypedField struct {
-	Name     string                   // フィールド名（エクスポート済み）
-	GoType   string                   // 最終的なGo型文字列（例: "int", "[]ItemsItem"）
-	Children map[string]*TypedField   // 構造体の子フィールド
+	Name     string                 // フィールド名（エクスポート済み）
+	GoType   string                 // 最終的なGo型文字列（例: "int", "[]ItemsItem"）
+	Children map[string]*TypedField // 構造体の子フィールド
+	Template string                 // 別テンプレートの型を参照する場合のテンプレート名
+	Embeds   []string               // 構造体に埋め込むテンプレート名
+	Pos      scan.Pos               // エラー報告用の位置（最初の参照、または @param の位置）
+	Param    bool                   // 型を @param で指定した（false なら推論）
+	Tags     []magic.Tag            // @tag で指定した構造体タグ
+	Required Requirement            // @required / @optional の指定
+	Literals []scan.Literal         // eq / ne で比較している文字列リテラル
+	Default  *Default               // @default で指定した既定値（なければ nil）
+	Doc      string                 // @doc で指定した説明
 }
 
+// Requirement はフィールドが必須かどうかの指定を表す
+type Requirement int
+
+const (
+	RequirementDefault  Requirement = iota // 指定なし（生成オプションに従う）
+	RequirementRequired                    // @required
+	RequirementOptional                    // @optional
+)
+
 // NamedType represents a named type to be generated
 type NamedType struct {
-	Name   string                   // 型名（例: "ItemsItem"）
-	Fields map[string]*TypedField   // 構造体フィールド
-}
\ No newline at end of file
+	Name   string                 // 型名（例: "ItemsItem"）
+	Fields map[string]*TypedField // 構造体フィールド
+	Embeds []string               // 埋め込むテンプレート名
+	Doc    string                 // 型を作ったフィールドの @doc の説明
+}
+
+// Enum は @enum で生成する文字列の名前付き型
+type Enum struct {
+	Name   string   // 型名（例: "Status", "UserStatus"）
+	Path   string   // フィールドのパス（例: "User.Status"）
+	Values []string // 値（@enum の記述順、推論した場合はテンプレートでの出現順）
+	Pos    scan.Pos // @enum の位置
+}
+
+// Default は @default で指定したフィールドの既定値
+type Default struct {
+	Expr  string         // Go の定数式（@default の記述どおり。例: `"Acme"`）
+	Value constant.Value // 式の値（フィールドの型に変換済み）
+	Pos   scan.Pos       // 値の位置
+}
+
+// Warning は生成を止めない問題
+type Warning struct {
+	Pos scan.Pos
+	Msg string
+}
+
+// String は "file:line:col: warning: msg" 形式の文字列を返す
+func (w Warning) String() string {
+	return w.Pos.String() + ": warning: " + w.Msg
+}
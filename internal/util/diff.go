@@ -0,0 +1,172 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext は unified diff の各ハンクの前後に含める変更のない行数です。
+const diffContext = 3
+
+// UnifiedDiff は old から new への行単位の差分を unified diff 形式で返します。
+// 差分がなければ空文字を返します。
+//
+// oldName と newName はヘッダ（"--- " と "+++ " の行）に使うファイル名です。
+// 末尾に改行のない行は、diff(1) と同様に "\ No newline at end of file" を付けて出力します。
+func UnifiedDiff(oldName, newName string, old, new []byte) string {
+	if string(old) == string(new) {
+		return ""
+	}
+	a, b := splitLines(string(old)), splitLines(string(new))
+	ops := diffLines(a, b)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+
+	// 変更のある op の前後 diffContext 行をまとめてハンクにする
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		start := max(i-diffContext, 0)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			// 次の変更までの変更なし行が 2*diffContext 以下なら同じハンクに含める
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*diffContext {
+				end = min(end+diffContext, len(ops))
+				break
+			}
+			end = next
+		}
+		writeHunk(&sb, ops[start:end])
+		i = end
+	}
+	return sb.String()
+}
+
+// diffOp は差分の1行です。kind は ' '（共通）、'-'（削除）、'+'（追加）のいずれかです。
+type diffOp struct {
+	kind       byte
+	line       string
+	aIdx, bIdx int // 0 始まりの行番号（ハンクヘッダ用）
+}
+
+func writeHunk(sb *strings.Builder, ops []diffOp) {
+	// ハンクの開始行と行数を数える（空の範囲は直前の行番号を開始行とする）
+	aStart, bStart := -1, -1
+	var aLen, bLen int
+	for _, op := range ops {
+		if op.kind != '+' {
+			if aStart < 0 {
+				aStart = op.aIdx
+			}
+			aLen++
+		}
+		if op.kind != '-' {
+			if bStart < 0 {
+				bStart = op.bIdx
+			}
+			bLen++
+		}
+	}
+	if aStart < 0 {
+		aStart = ops[0].aIdx - 1
+	}
+	if bStart < 0 {
+		bStart = ops[0].bIdx - 1
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+
+	for _, op := range ops {
+		sb.WriteByte(op.kind)
+		if line, ok := strings.CutSuffix(op.line, "\n"); ok {
+			sb.WriteString(line)
+			sb.WriteByte('\n')
+		} else {
+			sb.WriteString(op.line)
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(start, n int) string {
+	if n == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// splitLines は s を改行を含めた行に分割します。
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines は a と b の最長共通部分列から行単位の編集列を求めます。
+// 共通の先頭と末尾を除いた範囲だけを動的計画法で比較します。
+func diffLines(a, b []string) []diffOp {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+
+	// lcs[i][j] は ma[i:] と mb[j:] の最長共通部分列の長さ
+	lcs := make([][]int, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for k := 0; k < pre; k++ {
+		ops = append(ops, diffOp{kind: ' ', line: a[k], aIdx: k, bIdx: k})
+	}
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			ops = append(ops, diffOp{kind: ' ', line: ma[i], aIdx: pre + i, bIdx: pre + j})
+			i++
+			j++
+		case j == len(mb) || (i < len(ma) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{kind: '-', line: ma[i], aIdx: pre + i, bIdx: pre + j})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', line: mb[j], aIdx: pre + i, bIdx: pre + j})
+			j++
+		}
+	}
+	for k := 0; k < suf; k++ {
+		ai, bi := len(a)-suf+k, len(b)-suf+k
+		ops = append(ops, diffOp{kind: ' ', line: a[ai], aIdx: ai, bIdx: bi})
+	}
+	return ops
+}
@@ -2,4 +2,5 @@
 //
 // 現在提供されている機能:
 //   - Export: 識別子を Go のエクスポート済み識別子に変換
+//   - UnifiedDiff: 2つのファイル内容の差分を unified diff 形式で出力
 package util
@@ -44,3 +44,71 @@ func Export(name string) string {
 
 	return string(r)
 }
+
+// SnakeCase は Go の識別子をスネークケースに変換します。
+//
+// 大文字の連続（頭字語）は1つの単語として扱います。
+// 構造体タグのキー名を Go のフィールド名から作るときに利用します。
+//
+// 例:
+//   - "UserName" -> "user_name"
+//   - "UserID" -> "user_id"
+//   - "HTMLBody" -> "html_body"
+//   - "Item2Name" -> "item2_name"
+func SnakeCase(name string) string {
+	return strings.ToLower(strings.Join(splitWords(name), "_"))
+}
+
+// CamelCase は Go の識別子を先頭が小文字のキャメルケースに変換します。
+//
+// 先頭の単語は頭字語でもすべて小文字にします。
+//
+// 例:
+//   - "UserName" -> "userName"
+//   - "UserID" -> "userID"
+//   - "HTMLBody" -> "htmlBody"
+//   - "ID" -> "id"
+func CamelCase(name string) string {
+	words := splitWords(name)
+	if len(words) == 0 {
+		return ""
+	}
+	words[0] = strings.ToLower(words[0])
+	for i := 1; i < len(words); i++ {
+		words[i] = Export(words[i])
+	}
+	return strings.Join(words, "")
+}
+
+// splitWords は識別子を大文字小文字の境界とアンダースコアで単語に分割します。
+// 数字は直前の単語に含めます。
+func splitWords(name string) []string {
+	var words []string
+	r := []rune(name)
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			words = append(words, string(r[start:end]))
+		}
+		start = end
+	}
+	for i := 0; i < len(r); i++ {
+		switch {
+		case r[i] == '_':
+			flush(i)
+			start = i + 1
+		case i > start && isUpper(r[i]) && !isUpper(r[i-1]):
+			// "userName" の "N"
+			flush(i)
+		case i > start && isUpper(r[i]) && i+1 < len(r) && isLower(r[i+1]):
+			// "HTMLBody" の "B"
+			flush(i)
+		}
+	}
+	flush(len(r))
+	return words
+}
+
+func isUpper(r rune) bool { return r >= 'A' && r <= 'Z' }
+
+func isLower(r rune) bool { return r >= 'a' && r <= 'z' }
@@ -34,3 +34,31 @@ func TestExport(t *testing.T) {
 		})
 	}
 }
+
+func TestSnakeCase_CamelCase(t *testing.T) {
+	tests := []struct {
+		in    string
+		snake string
+		camel string
+	}{
+		{in: "", snake: "", camel: ""},
+		{in: "User", snake: "user", camel: "user"},
+		{in: "UserName", snake: "user_name", camel: "userName"},
+		{in: "UserID", snake: "user_id", camel: "userID"},
+		{in: "ID", snake: "id", camel: "id"},
+		{in: "HTMLBody", snake: "html_body", camel: "htmlBody"},
+		{in: "Item2Name", snake: "item2_name", camel: "item2Name"},
+		{in: "user_name", snake: "user_name", camel: "userName"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := SnakeCase(tt.in); got != tt.snake {
+				t.Errorf("SnakeCase(%q) = %q; want %q", tt.in, got, tt.snake)
+			}
+			if got := CamelCase(tt.in); got != tt.camel {
+				t.Errorf("CamelCase(%q) = %q; want %q", tt.in, got, tt.camel)
+			}
+		})
+	}
+}